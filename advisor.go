@@ -0,0 +1,346 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Advisor recommends breaker settings from recorded traffic. Feed it by
+// setting Config.OnCall to Advisor.ObserveCall and Config.OnEvent to
+// Advisor.ObserveEvent, ideally on a breaker that does not reject much
+// (for example with a high FailureThreshold while evaluating), since calls
+// the breaker rejected have no outcome to learn from. An Advisor is safe
+// for concurrent use.
+type Advisor struct {
+	current Config
+	limit   int
+
+	mu sync.Mutex
+	// Ring of the latest calls; next is the slot to overwrite once full.
+	outcomes []outcome
+	next     int
+	// Times the circuit closed and reopened, from state-change events.
+	closes []time.Time
+	opens  []time.Time
+}
+
+// outcome is one recorded call.
+type outcome struct {
+	at      time.Time
+	failed  bool
+	latency time.Duration
+}
+
+// NewAdvisor returns an Advisor that judges the current settings in cfg
+// and keeps at most the latest limit calls. A limit below 1 keeps 100000.
+func NewAdvisor(cfg Config, limit int) *Advisor {
+	if limit < 1 {
+		limit = 100000
+	}
+	return &Advisor{current: cfg.withDefaults(), limit: limit}
+}
+
+// ObserveCall records a call; rejected calls are ignored.
+func (a *Advisor) ObserveCall(rec CallRecord) {
+	if rec.Rejected {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	o := outcome{at: rec.Time, failed: rec.Err != nil, latency: rec.Duration}
+	if len(a.outcomes) < a.limit {
+		a.outcomes = append(a.outcomes, o)
+		return
+	}
+	a.outcomes[a.next] = o
+	a.next = (a.next + 1) % a.limit
+}
+
+// ObserveEvent records the breaker's state transitions.
+func (a *Advisor) ObserveEvent(ev Event) {
+	if ev.Type != EventStateChange {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case ev.To == Closed:
+		a.closes = append(a.closes, ev.Time)
+	case ev.From == Closed && ev.To == Open:
+		a.opens = append(a.opens, ev.Time)
+	}
+}
+
+// Candidate is how one setting would have fared on the recorded calls.
+type Candidate struct {
+	FailureThreshold int
+	Timeout          time.Duration
+	// Opens is how often the circuit would have opened, counting reopens
+	// after failed probes.
+	Opens int
+	// PassedFailures is the number of failed calls it would have let
+	// through, and RejectedSuccesses the calls that would have succeeded
+	// but been rejected. Their sum is the Cost the Advisor minimises.
+	PassedFailures    int
+	RejectedSuccesses int
+	Cost              int
+}
+
+// PathologyKind names a problem the Advisor can spot.
+type PathologyKind string
+
+const (
+	// PathologyFlapping: the circuit repeatedly reopened soon after
+	// closing.
+	PathologyFlapping PathologyKind = "flapping"
+	// PathologyNeverTrips: the history has sustained failures that the
+	// current settings would never have opened the circuit for.
+	PathologyNeverTrips PathologyKind = "never trips"
+	// PathologyTripsOnBlip: the current settings open the circuit on short
+	// failure bursts that cleared up on their own.
+	PathologyTripsOnBlip PathologyKind = "trips on blip"
+)
+
+// Pathology is a problem found in the recorded history.
+type Pathology struct {
+	Kind   PathologyKind
+	Detail string
+}
+
+// AdvisorReport is the Advisor's analysis.
+type AdvisorReport struct {
+	Calls    int
+	Failures int
+	// LongestBlip is the longest run of consecutive failures judged to be
+	// noise rather than an outage.
+	LongestBlip int
+	// Incidents is the number of failure runs longer than that.
+	Incidents int
+
+	LatencyP50 time.Duration
+	LatencyP99 time.Duration
+
+	SuggestedFailureThreshold int
+	SuggestedTimeout          time.Duration
+	// SuggestedLatencyThreshold leaves headroom above the p99 latency of
+	// successful calls.
+	SuggestedLatencyThreshold time.Duration
+
+	// Current is how the current settings did; Candidates lists the best
+	// settings tried, cheapest first.
+	Current    Candidate
+	Candidates []Candidate
+
+	Pathologies []Pathology
+}
+
+var (
+	advisorThresholds = []int{1, 2, 3, 4, 5, 6, 8, 10, 15, 20, 30, 50}
+	advisorTimeouts   = []time.Duration{
+		time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second,
+		30 * time.Second, time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute,
+	}
+)
+
+// flapWindow is how soon after closing a reopen counts as flapping.
+const flapWindow = time.Minute
+
+// Report analyses everything recorded so far. Each candidate setting is
+// replayed against the recorded calls with a consecutive-failure breaker
+// that closes on the first successful probe.
+func (a *Advisor) Report() AdvisorReport {
+	a.mu.Lock()
+	outcomes := append([]outcome(nil), a.outcomes...)
+	closes := append([]time.Time(nil), a.closes...)
+	opens := append([]time.Time(nil), a.opens...)
+	a.mu.Unlock()
+	sort.SliceStable(outcomes, func(i, j int) bool { return outcomes[i].at.Before(outcomes[j].at) })
+
+	r := AdvisorReport{
+		Calls:                     len(outcomes),
+		SuggestedFailureThreshold: a.current.FailureThreshold,
+		SuggestedTimeout:          a.current.Timeout,
+	}
+	var latencies []time.Duration
+	for _, o := range outcomes {
+		if o.failed {
+			r.Failures++
+		} else {
+			latencies = append(latencies, o.latency)
+		}
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		r.LatencyP50 = latencies[(len(latencies)-1)/2]
+		r.LatencyP99 = latencies[(len(latencies)-1)*99/100]
+		r.SuggestedLatencyThreshold = r.LatencyP99 * 3 / 2
+	}
+
+	runs := failureRuns(outcomes)
+	r.LongestBlip = longestBlip(runs)
+	for _, n := range runs {
+		if n > r.LongestBlip {
+			r.Incidents++
+		}
+	}
+
+	r.Current = simulate(outcomes, a.current.FailureThreshold, a.current.Timeout)
+	var candidates []Candidate
+	for _, threshold := range advisorThresholds {
+		for _, timeout := range advisorTimeouts {
+			candidates = append(candidates, simulate(outcomes, threshold, timeout))
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Cost < candidates[j].Cost })
+	if len(outcomes) > 0 {
+		r.SuggestedFailureThreshold = candidates[0].FailureThreshold
+		r.SuggestedTimeout = candidates[0].Timeout
+	}
+	if len(candidates) > 5 {
+		candidates = candidates[:5]
+	}
+	r.Candidates = candidates
+
+	if flaps := countFlaps(closes, opens); flaps >= 3 {
+		r.Pathologies = append(r.Pathologies, Pathology{PathologyFlapping,
+			fmt.Sprintf("the circuit reopened within %s of closing %d times", flapWindow, flaps)})
+	}
+	if r.Incidents > 0 && r.Current.Opens == 0 {
+		r.Pathologies = append(r.Pathologies, Pathology{PathologyNeverTrips,
+			fmt.Sprintf("%d failure runs longer than %d calls never opened the circuit", r.Incidents, r.LongestBlip)})
+	}
+	blips := 0
+	for _, n := range runs {
+		if n <= r.LongestBlip && n >= a.current.FailureThreshold {
+			blips++
+		}
+	}
+	if blips > 0 {
+		r.Pathologies = append(r.Pathologies, Pathology{PathologyTripsOnBlip,
+			fmt.Sprintf("%d bursts of at most %d failures would open the circuit at FailureThreshold %d",
+				blips, r.LongestBlip, a.current.FailureThreshold)})
+	}
+	return r
+}
+
+// failureRuns returns the lengths of the runs of consecutive failures.
+func failureRuns(outcomes []outcome) []int {
+	var runs []int
+	n := 0
+	for _, o := range outcomes {
+		if o.failed {
+			n++
+			continue
+		}
+		if n > 0 {
+			runs = append(runs, n)
+		}
+		n = 0
+	}
+	if n > 0 {
+		runs = append(runs, n)
+	}
+	return runs
+}
+
+// longestBlip separates noise from outages at the largest relative gap
+// between run lengths, provided runs at least triple there; otherwise every
+// run counts as noise.
+func longestBlip(runs []int) int {
+	if len(runs) == 0 {
+		return 0
+	}
+	lengths := append([]int(nil), runs...)
+	sort.Ints(lengths)
+	best, bestRatio := lengths[len(lengths)-1], 3.0
+	for i := 1; i < len(lengths); i++ {
+		if ratio := float64(lengths[i]) / float64(lengths[i-1]); ratio >= bestRatio {
+			best, bestRatio = lengths[i-1], ratio
+		}
+	}
+	return best
+}
+
+// simulate replays outcomes through a consecutive-failure breaker.
+func simulate(outcomes []outcome, threshold int, timeout time.Duration) Candidate {
+	c := Candidate{FailureThreshold: threshold, Timeout: timeout}
+	open := false
+	var openedAt time.Time
+	failures := 0
+	for _, o := range outcomes {
+		if open {
+			if o.at.Sub(openedAt) < timeout {
+				if !o.failed {
+					c.RejectedSuccesses++
+				}
+				continue
+			}
+			// the probe.
+			if o.failed {
+				c.PassedFailures++
+				c.Opens++
+				openedAt = o.at
+				continue
+			}
+			open, failures = false, 0
+			continue
+		}
+		if !o.failed {
+			failures = 0
+			continue
+		}
+		c.PassedFailures++
+		failures++
+		if failures >= threshold {
+			open, openedAt = true, o.at
+			c.Opens++
+		}
+	}
+	c.Cost = c.PassedFailures + c.RejectedSuccesses
+	return c
+}
+
+// countFlaps counts reopens that came within flapWindow of a close.
+func countFlaps(closes, opens []time.Time) int {
+	flaps := 0
+	for _, o := range opens {
+		for _, c := range closes {
+			if !o.Before(c) && o.Sub(c) < flapWindow {
+				flaps++
+				break
+			}
+		}
+	}
+	return flaps
+}
+
+// String formats the report for people.
+func (r AdvisorReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "calls: %d, failures: %d, incidents: %d, longest blip: %d\n",
+		r.Calls, r.Failures, r.Incidents, r.LongestBlip)
+	fmt.Fprintf(&b, "latency: p50 %s, p99 %s\n", r.LatencyP50, r.LatencyP99)
+	fmt.Fprintf(&b, "suggested: FailureThreshold %d, Timeout %s, LatencyThreshold %s\n",
+		r.SuggestedFailureThreshold, r.SuggestedTimeout, r.SuggestedLatencyThreshold)
+	b.WriteString("\nthreshold  timeout  opens  passed failures  rejected successes\n")
+	row := func(label string, c Candidate) {
+		fmt.Fprintf(&b, "%9d  %7s  %5d  %15d  %18d%s\n",
+			c.FailureThreshold, c.Timeout, c.Opens, c.PassedFailures, c.RejectedSuccesses, label)
+	}
+	row("  (current)", r.Current)
+	for _, c := range r.Candidates {
+		row("", c)
+	}
+	if len(r.Pathologies) > 0 {
+		b.WriteString("\n")
+		for _, p := range r.Pathologies {
+			fmt.Fprintf(&b, "%s: %s\n", p.Kind, p.Detail)
+		}
+	}
+	return b.String()
+}
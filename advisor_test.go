@@ -0,0 +1,107 @@
+package circuitbreaker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// recordHistory replays six hours at one call per second through a
+// breaker that never trips, with isolated blips and five outages of two to
+// ten minutes, and returns the advisor it fed.
+func recordHistory(t *testing.T, current circuitbreaker.Config) *circuitbreaker.Advisor {
+	t.Helper()
+	advisor := circuitbreaker.NewAdvisor(current, 0)
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1 << 30,
+		Clock:            clock,
+		OnCall:           advisor.ObserveCall,
+		OnEvent:          advisor.ObserveEvent,
+	})
+	outages := []int{0, 120, 180, 300, 480, 600}
+	for i := 0; i < 6*3600; i++ {
+		outage := i%3600 < outages[i/3600]
+		blip := i%97 == 0 || i%500 < 2
+		latency := 20 * time.Millisecond
+		if i%50 == 0 {
+			latency = 200 * time.Millisecond
+		}
+		cb.Execute(func() (any, error) {
+			clock.Advance(latency)
+			if outage || blip {
+				return failFn()
+			}
+			return successFn()
+		})
+		clock.Advance(time.Second - latency)
+	}
+	return advisor
+}
+
+func TestAdvisor_RecommendsFromHistory(t *testing.T) {
+	advisor := recordHistory(t, circuitbreaker.Config{FailureThreshold: 1, Timeout: 5 * time.Minute})
+	r := advisor.Report()
+
+	if r.Calls != 6*3600 || r.Incidents != 5 {
+		t.Fatalf("expected %d calls and 5 incidents, got %d and %d", 6*3600, r.Calls, r.Incidents)
+	}
+	if r.SuggestedFailureThreshold <= r.LongestBlip || r.SuggestedFailureThreshold > 6 {
+		t.Errorf("expected a threshold just above the longest blip (%d), got %d", r.LongestBlip, r.SuggestedFailureThreshold)
+	}
+	if r.SuggestedTimeout < 10*time.Second || r.SuggestedTimeout > time.Minute {
+		t.Errorf("expected a timeout between 10s and 1m for outages of minutes, got %s", r.SuggestedTimeout)
+	}
+	if r.LatencyP50 != 20*time.Millisecond || r.LatencyP99 != 200*time.Millisecond {
+		t.Errorf("unexpected latency percentiles p50 %s p99 %s", r.LatencyP50, r.LatencyP99)
+	}
+	if best := r.Candidates[0]; best.Opens < 5 || best.Cost >= r.Current.Cost {
+		t.Errorf("expected the suggestion to open for each outage and beat the current settings, got %+v vs %+v", best, r.Current)
+	}
+	if !hasPathology(r, circuitbreaker.PathologyTripsOnBlip) {
+		t.Errorf("expected FailureThreshold 1 to be flagged as tripping on blips, got %v", r.Pathologies)
+	}
+	text := r.String()
+	for _, want := range []string{"suggested: FailureThreshold", "(current)", "trips on blip"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("report is missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestAdvisor_FlagsNeverTrips(t *testing.T) {
+	advisor := recordHistory(t, circuitbreaker.Config{FailureThreshold: 1000, Timeout: 10 * time.Second})
+	r := advisor.Report()
+	if !hasPathology(r, circuitbreaker.PathologyNeverTrips) {
+		t.Errorf("expected FailureThreshold 1000 to be flagged as never tripping, got %v", r.Pathologies)
+	}
+	if hasPathology(r, circuitbreaker.PathologyTripsOnBlip) {
+		t.Error("a threshold above every blip was flagged as tripping on blips")
+	}
+}
+
+func TestAdvisor_FlagsFlapping(t *testing.T) {
+	advisor := circuitbreaker.NewAdvisor(circuitbreaker.Config{}, 0)
+	at := cbt.Epoch
+	for i := 0; i < 4; i++ {
+		advisor.ObserveEvent(circuitbreaker.Event{Type: circuitbreaker.EventStateChange, Time: at, From: circuitbreaker.HalfOpen, To: circuitbreaker.Closed})
+		at = at.Add(20 * time.Second)
+		advisor.ObserveEvent(circuitbreaker.Event{Type: circuitbreaker.EventStateChange, Time: at, From: circuitbreaker.Closed, To: circuitbreaker.Open})
+		at = at.Add(10 * time.Second)
+	}
+	if r := advisor.Report(); !hasPathology(r, circuitbreaker.PathologyFlapping) {
+		t.Errorf("expected flapping to be flagged, got %v", r.Pathologies)
+	}
+}
+
+func hasPathology(r circuitbreaker.AdvisorReport, kind circuitbreaker.PathologyKind) bool {
+	for _, p := range r.Pathologies {
+		if p.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// errReportedFailure is recorded for a call whose done callback from
+// Allow reported failure.
+var errReportedFailure = errors.New("circuit breaker: call reported failure")
+
+// Allow is the two-step form of Execute, for callers whose request does
+// not fit in a closure, such as one that spans several calls into a
+// connection pool. Allow makes the same admission decision as Execute and
+// returns the same errors; once admitted, the caller makes the request
+// and calls done with its outcome, which records it and releases what
+// the call held. Only the first call to done counts; later ones do
+// nothing. As with Execute, an outcome reported after the state has
+// changed since Allow, including by Reset, is left out of the state
+// machine's counts. Every admitted call must be finished with done, or it
+// holds its probe slot and bulkhead share for good. A nil breaker admits
+// everything.
+func (cb *CircuitBreaker) Allow(opts ...CallOption) (done func(success bool), err error) {
+	if cb == nil {
+		return func(bool) {}, nil
+	}
+	finish, err := cb.allow(newCallOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return func(success bool) {
+		if success {
+			finish(nil)
+		} else {
+			finish(errReportedFailure)
+		}
+	}, nil
+}
+
+// allow admits a call and returns the function that records its outcome.
+func (cb *CircuitBreaker) allow(o callOptions) (func(error), error) {
+	cb.lazyInit()
+	c, err := cb.admit(o)
+	if err != nil {
+		cb.reportRejection(err)
+		return nil, err
+	}
+	return cb.finisher(c), nil
+}
+
+// finisher returns the function that records the outcome of admitted call
+// c. Only its first use counts.
+func (cb *CircuitBreaker) finisher(c call) func(error) {
+	var finished atomic.Bool
+	return func(err error) {
+		if finished.CompareAndSwap(false, true) {
+			cb.complete(c, err)
+		}
+	}
+}
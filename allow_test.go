@@ -0,0 +1,88 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestAllow_RecordsOutcomes(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, SuccessThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+
+	for range 2 {
+		done, err := cb.Allow()
+		if err != nil {
+			t.Fatalf("expected the call admitted, got %v", err)
+		}
+		done(false)
+	}
+	if _, err := cb.Allow(); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen once tripped, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	probe, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("expected the probe admitted, got %v", err)
+	}
+	if _, err := cb.Allow(); !errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+		t.Errorf("expected the probe to hold the only slot, got %v", err)
+	}
+	probe(true)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the probe to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestAllow_DoneTwiceIsANoOp(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, MaxConcurrent: 1, Strict: true})
+	done, _ := cb.Allow()
+	done(false)
+	done(false)
+	done(true)
+	if c := cb.Counts(); c.ConsecutiveFailures != 1 {
+		t.Errorf("expected only the first outcome counted, got %+v", c)
+	}
+	if s := cb.Status(); s.InFlightCost != 0 || s.Totals.Failures != 1 || s.Totals.Successes != 0 {
+		t.Errorf("expected the call released once, got in-flight cost %d and totals %+v", s.InFlightCost, s.Totals)
+	}
+}
+
+func TestAllow_OutcomeAfterResetIsNotCounted(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	cb.Execute(failFn)
+	done, _ := cb.Allow()
+	cb.Reset()
+	done(false)
+	if c := cb.Counts(); c.ConsecutiveFailures != 0 {
+		t.Errorf("expected the outcome from before Reset to be left out, got %+v", c)
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected a single failure after Reset to leave the circuit closed, got %v", cb.State())
+	}
+}
+
+func TestAllow_FailureBypassesIsFailure(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		IsFailure:        func(error) bool { return false },
+		Strict:           true,
+	})
+	done, _ := cb.Allow()
+	done(false)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected a reported failure to count whatever IsFailure says, got %v", cb.State())
+	}
+
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	done, err := nilBreaker.Allow()
+	if err != nil {
+		t.Fatalf("expected a nil breaker to admit, got %v", err)
+	}
+	done(false)
+}
@@ -0,0 +1,51 @@
+package circuitbreaker
+
+import "context"
+
+// Result is the outcome of a call made with ExecuteAsync.
+type Result struct {
+	Value any
+	Err   error
+}
+
+// ExecuteAsync is like Execute but runs request on a goroutine of its own
+// and delivers the outcome on the returned channel, which receives
+// exactly one Result and is buffered so that nobody needs to read it.
+//
+// Admission is decided before ExecuteAsync returns: a rejected call
+// starts no goroutine and its Result is ready straight away, and an
+// admitted one holds its place, such as a half-open probe, until request
+// returns and the outcome is recorded. A request that panics is recorded
+// as a failure and, as on any goroutine, the panic then crashes the
+// program unless Config.RecoverPanics is set, in which case the Result
+// carries a *PanicError.
+func (cb *CircuitBreaker) ExecuteAsync(request func() (any, error), opts ...CallOption) <-chan Result {
+	out := make(chan Result, 1)
+	if request == nil {
+		out <- Result{Err: ErrNilFunction}
+		return out
+	}
+	if cb == nil {
+		go func() {
+			value, err := request()
+			out <- Result{Value: value, Err: err}
+		}()
+		return out
+	}
+	done, err := cb.allow(newCallOptions(opts))
+	if err != nil {
+		value, err := cb.serveStale(err)
+		out <- Result{Value: value, Err: err}
+		return out
+	}
+	go func() {
+		value, err := cb.runTimed(context.Background(), done, func(context.Context) (any, error) {
+			return request()
+		})
+		if err == nil {
+			cb.remember(value)
+		}
+		out <- Result{Value: value, Err: err}
+	}()
+	return out
+}
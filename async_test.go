@@ -0,0 +1,95 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestExecuteAsync_Outcomes(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	if r := <-cb.ExecuteAsync(successFn); r.Value != "ok" || r.Err != nil {
+		t.Fatalf("expected ok, got %+v", r)
+	}
+	if r := <-cb.ExecuteAsync(failFn); !errors.Is(r.Err, errSimulated) {
+		t.Fatalf("expected the request's error, got %+v", r)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected the failure to open the circuit, got %v", cb.State())
+	}
+
+	ran := false
+	results := cb.ExecuteAsync(func() (any, error) { ran = true; return nil, nil })
+	select {
+	case r := <-results:
+		if !errors.Is(r.Err, circuitbreaker.ErrCircuitOpen) {
+			t.Errorf("expected ErrCircuitOpen, got %+v", r)
+		}
+	default:
+		t.Fatal("expected a rejection to be ready before ExecuteAsync returns")
+	}
+	if ran {
+		t.Error("expected a rejected request not to run")
+	}
+	if r := <-cb.ExecuteAsync(nil); r.Err != circuitbreaker.ErrNilFunction {
+		t.Errorf("expected ErrNilFunction for a nil request, got %+v", r)
+	}
+	want := circuitbreaker.Totals{Requests: 3, Successes: 1, Failures: 1, Rejected: 1, RejectedOpen: 1}
+	if got := cb.Totals(); got != want {
+		t.Errorf("expected totals %+v, got %+v", want, got)
+	}
+}
+
+func TestExecuteAsync_HoldsHalfOpenProbe(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	release := make(chan struct{})
+	probe := cb.ExecuteAsync(func() (any, error) {
+		<-release
+		return "probe", nil
+	})
+	// the probe is admitted before ExecuteAsync returns, so a second call
+	// finds its place taken.
+	if r := <-cb.ExecuteAsync(successFn); !errors.Is(r.Err, circuitbreaker.ErrTooManyRequests) {
+		t.Errorf("expected ErrTooManyRequests while the probe runs, got %+v", r)
+	}
+	close(release)
+	if r := <-probe; r.Value != "probe" || r.Err != nil {
+		t.Fatalf("expected the probe's result, got %+v", r)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the probe's success to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestExecuteAsync_RecoversPanics(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{RecoverPanics: true, Strict: true})
+
+	r := <-cb.ExecuteAsync(func() (any, error) { panic("boom") })
+	var pe *circuitbreaker.PanicError
+	if !errors.As(r.Err, &pe) || pe.Value != "boom" {
+		t.Fatalf("expected a *PanicError, got %+v", r)
+	}
+	if got := cb.Totals().Failures; got != 1 {
+		t.Errorf("expected the panic counted as a failure, got %d", got)
+	}
+}
@@ -0,0 +1,55 @@
+package circuitbreaker
+
+import "time"
+
+// AsAttempt marks a call as one attempt of the logical call identified by
+// callID, for retry loops that wrap the breaker: however many attempts
+// fail, the logical call counts as a single failure, and an attempt that
+// succeeds counts as usual and clears it. Failures are collapsed per
+// state, so an attempt that fails as a half-open probe still reopens the
+// circuit. IDs are forgotten Config.AttemptTTL after their last failed
+// attempt. An empty callID has no effect.
+func AsAttempt(callID string) CallOption {
+	return func(o *callOptions) {
+		o.attempt = callID
+	}
+}
+
+// attempts remembers the logical calls that already had a failure counted.
+type attempts struct {
+	ttl  time.Duration
+	seen map[string]attempt
+	// swept is when expired IDs were last dropped.
+	swept time.Time
+}
+
+type attempt struct {
+	// generation the failure was counted in.
+	generation uint64
+	expires    time.Time
+}
+
+// failed records a failed attempt of id admitted in generation and reports
+// whether an earlier attempt's failure was already counted.
+func (a *attempts) failed(id string, generation uint64, now time.Time) bool {
+	if now.Sub(a.swept) >= a.ttl {
+		for k, e := range a.seen {
+			if !now.Before(e.expires) {
+				delete(a.seen, k)
+			}
+		}
+		a.swept = now
+	}
+	prev, ok := a.seen[id]
+	repeat := ok && prev.generation == generation && now.Before(prev.expires)
+	if a.seen == nil {
+		a.seen = map[string]attempt{}
+	}
+	a.seen[id] = attempt{generation: generation, expires: now.Add(a.ttl)}
+	return repeat
+}
+
+// succeeded forgets id once one of its attempts has succeeded.
+func (a *attempts) succeeded(id string) {
+	delete(a.seen, id)
+}
@@ -0,0 +1,109 @@
+package circuitbreaker_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// retryLoop makes up to three attempts of one logical call through cb,
+// the way a retrying client wrapped around the breaker would.
+func retryLoop(cb *circuitbreaker.CircuitBreaker, id string, fn func() (any, error)) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var opts []circuitbreaker.CallOption
+		if id != "" {
+			opts = append(opts, circuitbreaker.AsAttempt(id))
+		}
+		if _, err = cb.Execute(fn, opts...); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func TestAsAttempt_CollapsesRetriesIntoOneFailure(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var calls int
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		Clock:            clock,
+		Strict:           true,
+		OnCall:           func(circuitbreaker.CallRecord) { calls++ },
+	})
+
+	retryLoop(cb, "order-1", failFn)
+	if got := cb.Status().Counts.ConsecutiveFailures; got != 1 {
+		t.Fatalf("expected three failed attempts to count once, got %d", got)
+	}
+	if calls != 3 {
+		t.Errorf("expected OnCall to still see every attempt, got %d", calls)
+	}
+	retryLoop(cb, "order-2", failFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected two logical failures to leave the circuit closed, got %v", cb.State())
+	}
+	retryLoop(cb, "order-3", failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the third logical failure to trip, got %v", cb.State())
+	}
+
+	// without AsAttempt the first logical call trips on its own.
+	plain := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Clock: clock, Strict: true})
+	retryLoop(plain, "", failFn)
+	if plain.State() != circuitbreaker.Open {
+		t.Errorf("expected per-attempt accounting to trip, got %v", plain.State())
+	}
+}
+
+func TestAsAttempt_SuccessfulRetryWins(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+
+	for i := 0; i < 5; i++ {
+		attempt := 0
+		err := retryLoop(cb, fmt.Sprint("order-", i), func() (any, error) {
+			if attempt++; attempt < 3 {
+				return failFn()
+			}
+			return successFn()
+		})
+		if err != nil {
+			t.Fatalf("logical call %d: %v", i, err)
+		}
+	}
+	if cb.State() != circuitbreaker.Closed || cb.Status().Counts.ConsecutiveFailures != 0 {
+		t.Errorf("expected calls that succeeded on retry to leave no failures, got %+v", cb.Status())
+	}
+}
+
+func TestAsAttempt_FailedProbeStillReopens(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: time.Second, Clock: clock, Strict: true})
+
+	cb.Execute(failFn, circuitbreaker.AsAttempt("order-1"))
+	clock.Advance(time.Second)
+	cb.Execute(failFn, circuitbreaker.AsAttempt("order-1"))
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the failed probe to reopen, got %v", cb.State())
+	}
+}
+
+func TestAsAttempt_IDsExpire(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, AttemptTTL: time.Minute, Clock: clock, Strict: true})
+
+	cb.Execute(failFn, circuitbreaker.AsAttempt("order-1"))
+	clock.Advance(30 * time.Second)
+	cb.Execute(failFn, circuitbreaker.AsAttempt("order-1"))
+	if got := cb.Status().Counts.ConsecutiveFailures; got != 1 {
+		t.Fatalf("expected a retry within the TTL to collapse, got %d failures", got)
+	}
+	clock.Advance(time.Minute)
+	cb.Execute(failFn, circuitbreaker.AsAttempt("order-1"))
+	if got := cb.Status().Counts.ConsecutiveFailures; got != 2 {
+		t.Errorf("expected an ID past its TTL to count again, got %d failures", got)
+	}
+}
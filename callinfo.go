@@ -0,0 +1,65 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// CallInfo describes the call ExecuteContext is running, for the request
+// to adapt to, for example by using a shorter downstream timeout while it
+// is a half-open probe.
+type CallInfo struct {
+	// Name is the breaker's Config.Name.
+	Name string
+	// State is the state the call was admitted in.
+	State State
+	// Probe reports whether the call was admitted as a half-open probe.
+	Probe bool
+	// Attempt numbers the attempts made by a Retry policy, from 1. It is
+	// zero when the call is not run under Retry.
+	Attempt int
+}
+
+// callContextKey is the context key of the *callContext of a call run by
+// ExecuteContext.
+type callContextKey struct{}
+
+// callContext is what ExecuteContext attaches to the request's context.
+type callContext struct {
+	info CallInfo
+	// neutral is set by MarkNeutral.
+	neutral atomic.Bool
+}
+
+// FromContext returns the CallInfo ExecuteContext attached to the context
+// it passes to the request. It reports false for any other context,
+// including one captured by a request run with Execute.
+func FromContext(ctx context.Context) (CallInfo, bool) {
+	cc := callContextFrom(ctx)
+	if cc == nil {
+		return CallInfo{}, false
+	}
+	return cc.info, true
+}
+
+func callContextFrom(ctx context.Context) *callContext {
+	if ctx == nil {
+		return nil
+	}
+	cc, _ := ctx.Value(callContextKey{}).(*callContext)
+	return cc
+}
+
+// withCallContext attaches what is known about the admitted call c to ctx
+// and lets MarkNeutral reach c.
+func (cb *CircuitBreaker) withCallContext(ctx context.Context, c *call) context.Context {
+	attempt, _ := ctx.Value(retryAttemptKey{}).(int)
+	cc := &callContext{info: CallInfo{
+		Name:    cb.config.Name,
+		State:   c.state,
+		Probe:   c.probe,
+		Attempt: attempt,
+	}}
+	c.neutral = &cc.neutral
+	return context.WithValue(ctx, callContextKey{}, cc)
+}
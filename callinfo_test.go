@@ -0,0 +1,68 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestFromContext_ProbeDuringHalfOpen(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "payments", FailureThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+
+	var closed circuitbreaker.CallInfo
+	cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+		closed, _ = circuitbreaker.FromContext(ctx)
+		return failFn()
+	})
+	if closed.Name != "payments" || closed.State != circuitbreaker.Closed || closed.Probe {
+		t.Errorf("unexpected info for a closed call: %+v", closed)
+	}
+
+	clock.Advance(time.Minute)
+	var probe circuitbreaker.CallInfo
+	var ok bool
+	cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+		probe, ok = circuitbreaker.FromContext(ctx)
+		return successFn()
+	})
+	if !ok || probe.State != circuitbreaker.HalfOpen || !probe.Probe || probe.Attempt != 0 {
+		t.Errorf("expected a half-open probe, got %+v (%v)", probe, ok)
+	}
+}
+
+func TestFromContext_AbsentOnExecutePath(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	ctx := context.Background()
+	cb.Execute(func() (any, error) {
+		if info, ok := circuitbreaker.FromContext(ctx); ok {
+			t.Errorf("expected no info outside ExecuteContext, got %+v", info)
+		}
+		return successFn()
+	})
+
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	nilBreaker.ExecuteContext(ctx, func(ctx context.Context) (any, error) {
+		if info, ok := circuitbreaker.FromContext(ctx); ok {
+			t.Errorf("expected no info from a nil breaker, got %+v", info)
+		}
+		return successFn()
+	})
+}
+
+func TestFromContext_AttemptUnderRetry(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 10, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	var attempts []int
+	circuitbreaker.Pipeline(circuitbreaker.Breaker(cb), circuitbreaker.Retry(circuitbreaker.RetryPolicy{MaxAttempts: 3})).
+		Execute(context.Background(), func(ctx context.Context) (any, error) {
+			info, _ := circuitbreaker.FromContext(ctx)
+			attempts = append(attempts, info.Attempt)
+			return failFn()
+		})
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Errorf("expected attempts 1 to 3, got %v", attempts)
+	}
+}
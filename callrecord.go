@@ -0,0 +1,46 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"time"
+)
+
+// CallRecord describes one call for Config.OnCall.
+type CallRecord struct {
+	// Time is when the call was admitted or rejected.
+	Time time.Time
+	// Duration is how long the call ran; zero for rejections.
+	Duration time.Duration
+	// Err is the call's error, or the breaker's rejection error.
+	Err error
+	// Rejected reports whether the breaker turned the call away, in which
+	// case Err says why and the call never ran.
+	Rejected bool
+	// State is the breaker's state when the call was admitted or
+	// rejected.
+	State State
+}
+
+// reportRejection counts a rejected call and passes it to Config.OnCall.
+func (cb *CircuitBreaker) reportRejection(err error) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		cb.diag.rejected++
+		cb.rejectedOpen()
+	case errors.Is(err, ErrTooManyRequests):
+		cb.diag.rejected++
+		cb.diag.rejectedHalfOpen++
+		cb.rejectedOpen()
+	}
+	cb.emitCall(EventRejected, err, 0)
+	cb.logRejection(err)
+	hook := cb.config.OnCall
+	if hook == nil {
+		return
+	}
+	rec := CallRecord{Time: cb.clock.Now(), Err: err, Rejected: true, State: cb.state}
+	cb.queueHook("OnCall", func() { hook(rec) })
+}
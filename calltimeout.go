@@ -0,0 +1,101 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrCallTimeout is returned by Execute and ExecuteContext, and counted as
+// a failure, when a request runs longer than Config.CallTimeout.
+var ErrCallTimeout = errors.New("circuit breaker: call timed out")
+
+// timedOutcome is what a request running under Config.CallTimeout hands
+// back to its caller.
+type timedOutcome struct {
+	result any
+	err    error
+	// Set with the recovered value when the request panicked.
+	panicked bool
+	value    any
+}
+
+// runTimed is run for requests subject to Config.CallTimeout. Without one
+// it calls request on the caller's goroutine. With one, request runs on a
+// goroutine of its own and the caller waits at most CallTimeout for it;
+// past that, ctx is cancelled with ErrCallTimeout as its cause, the call
+// counts as a failure and its admission is released. The abandoned
+// request keeps running, since Go cannot stop it, and whatever it returns
+// in the end, panics included, is discarded.
+func (cb *CircuitBreaker) runTimed(ctx context.Context, done func(error), request func(context.Context) (any, error)) (any, error) {
+	timeout := cb.config.CallTimeout
+	if timeout <= 0 {
+		return cb.run(done, func() (any, error) { return request(ctx) })
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	expired := make(chan struct{})
+	timer := cb.clock.AfterFunc(timeout, func() { close(expired) })
+	defer timer.Stop()
+
+	// buffered so that an abandoned request does not block on sending.
+	outcome := make(chan timedOutcome, 1)
+	go func() {
+		completed := false
+		defer func() {
+			// nil means runtime.Goexit; the caller waits for the timeout.
+			if r := recover(); !completed && r != nil {
+				outcome <- timedOutcome{panicked: true, value: r}
+			}
+		}()
+		result, err := request(ctx)
+		completed = true
+		outcome <- timedOutcome{result: result, err: err}
+	}()
+	return cb.run(done, func() (any, error) {
+		select {
+		case o := <-outcome:
+			if o.panicked {
+				// carry the panic on from the caller's goroutine.
+				panic(o.value)
+			}
+			return o.result, o.err
+		case <-expired:
+			cancel(ErrCallTimeout)
+			return nil, ErrCallTimeout
+		}
+	})
+}
+
+// handoff passes a value that has to be released, such as a connection or
+// a response, from a request run under Config.CallTimeout to its caller,
+// which may have stopped waiting for it. Once the caller has taken what
+// there is, a value given after that is released on the spot instead.
+type handoff[T any] struct {
+	mu      sync.Mutex
+	value   T
+	taken   bool
+	release func(T)
+}
+
+// give hands v to the caller. It reports false, having released v, when
+// the caller has already stopped waiting.
+func (h *handoff[T]) give(v T) bool {
+	h.mu.Lock()
+	if h.taken {
+		h.mu.Unlock()
+		h.release(v)
+		return false
+	}
+	h.value = v
+	h.mu.Unlock()
+	return true
+}
+
+// take returns the value given so far, if any, and ends the handoff.
+func (h *handoff[T]) take() T {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.taken = true
+	return h.value
+}
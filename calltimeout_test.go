@@ -0,0 +1,100 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestCallTimeout_CountsAsFailureAndDiscardsLateResult(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, CallTimeout: 2 * time.Second, Clock: clock, Strict: true})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		<-started
+		clock.Advance(2 * time.Second)
+	}()
+	_, err := cb.Execute(func() (any, error) {
+		defer close(returned)
+		close(started)
+		<-release
+		return nil, errSimulated
+	})
+	if err != circuitbreaker.ErrCallTimeout {
+		t.Fatalf("expected ErrCallTimeout, got %v", err)
+	}
+	if c := cb.Counts(); c.ConsecutiveFailures != 1 {
+		t.Fatalf("expected the timeout to count as a failure, got %+v", c)
+	}
+	if s := cb.Status(); s.InFlight != 0 {
+		t.Errorf("expected the timed-out call to be released, got %d in flight", s.InFlight)
+	}
+
+	// the abandoned request finally fails; that must not count again.
+	close(release)
+	<-returned
+	if tot := cb.Totals(); tot.Requests != 1 || tot.Failures != 1 {
+		t.Errorf("expected the late result to be discarded, got %+v", tot)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected one failure not to trip the circuit, got %v", cb.State())
+	}
+}
+
+func TestCallTimeout_FastCallsUnaffected(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Second, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	if v, err := cb.Execute(successFn); v != "ok" || err != nil {
+		t.Fatalf("expected ok, got %v %v", v, err)
+	}
+	if _, err := cb.Execute(failFn); err != errSimulated {
+		t.Fatalf("expected the request's own error, got %v", err)
+	}
+	if tot := cb.Totals(); tot.Successes != 1 || tot.Failures != 1 {
+		t.Errorf("expected one success and one failure, got %+v", tot)
+	}
+}
+
+func TestCallTimeout_CancelsContext(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Second, Clock: clock, Strict: true})
+	cause := make(chan error, 1)
+	_, err := cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+		clock.Advance(time.Second)
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return nil, ctx.Err()
+	})
+	if err != circuitbreaker.ErrCallTimeout {
+		t.Fatalf("expected ErrCallTimeout, got %v", err)
+	}
+	if got := <-cause; !errors.Is(got, circuitbreaker.ErrCallTimeout) {
+		t.Errorf("expected the request's context cancelled by the timeout, got %v", got)
+	}
+	if tot := cb.Totals(); tot.Failures != 1 {
+		t.Errorf("expected one failure, got %+v", tot)
+	}
+}
+
+func TestCallTimeout_PanicReachesCaller(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Hour, RecoverPanics: true, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	_, err := cb.Execute(func() (any, error) { panic("boom") })
+	var pe *circuitbreaker.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("expected the panic as a *PanicError, got %v", err)
+	}
+	if tot := cb.Totals(); tot.Failures != 1 {
+		t.Errorf("expected the panic to count as a failure, got %+v", tot)
+	}
+}
+
+func TestCallTimeout_Validate(t *testing.T) {
+	if err := (circuitbreaker.Config{CallTimeout: -time.Second}).Validate(); err == nil {
+		t.Error("expected a negative CallTimeout to be rejected")
+	}
+}
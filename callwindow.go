@@ -0,0 +1,71 @@
+package circuitbreaker
+
+// callWindow holds the outcomes of the last Config.WindowSize calls and
+// trips on their failure rate.
+type callWindow struct {
+	// outcomes is a ring of the recent outcomes, true for failures; next
+	// is where the next one goes and size how many it holds.
+	outcomes  []bool
+	next      int
+	size      int
+	failures  int
+	min       int
+	threshold float64
+}
+
+// newCallWindow returns nil when Config.WindowSize is not set.
+func newCallWindow(cfg Config) *callWindow {
+	if cfg.WindowSize <= 0 {
+		return nil
+	}
+	return &callWindow{
+		outcomes:  make([]bool, cfg.WindowSize),
+		min:       cfg.WindowMinRequests,
+		threshold: cfg.FailureRateThreshold,
+	}
+}
+
+// newSlowCallWindow returns nil when Config.SlowCallDuration is not set. Its
+// outcomes are true for slow calls, and it trips only once full.
+func newSlowCallWindow(cfg Config) *callWindow {
+	if cfg.SlowCallDuration <= 0 {
+		return nil
+	}
+	return &callWindow{
+		outcomes:  make([]bool, cfg.SlowCallWindowSize),
+		min:       cfg.SlowCallWindowSize,
+		threshold: cfg.SlowCallRateThreshold,
+	}
+}
+
+// record adds an outcome, pushing out the oldest once the window is full,
+// and reports whether the window should trip.
+func (w *callWindow) record(failed bool) bool {
+	if w.size == len(w.outcomes) {
+		if w.outcomes[w.next] {
+			w.failures--
+		}
+	} else {
+		w.size++
+	}
+	w.outcomes[w.next] = failed
+	if failed {
+		w.failures++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+	return w.size >= w.min && w.rate() >= w.threshold
+}
+
+// rate returns the failure rate of the outcomes held.
+func (w *callWindow) rate() float64 {
+	if w.size == 0 {
+		return 0
+	}
+	return float64(w.failures) / float64(w.size)
+}
+
+// reset empties the window.
+func (w *callWindow) reset() {
+	clear(w.outcomes)
+	w.next, w.size, w.failures = 0, 0, 0
+}
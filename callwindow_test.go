@@ -0,0 +1,123 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newCallWindowBreaker(minRequests int) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:     3,
+		WindowSize:           10,
+		WindowMinRequests:    minRequests,
+		FailureRateThreshold: 0.3,
+		Strict:               true,
+	})
+}
+
+// play runs outcomes through cb in order, true for a failure, and returns
+// the index of the call that opened the circuit, or -1.
+func play(cb *circuitbreaker.CircuitBreaker, outcomes ...bool) int {
+	for i, failed := range outcomes {
+		if failed {
+			cb.Execute(failFn)
+		} else {
+			cb.Execute(successFn)
+		}
+		if cb.State() == circuitbreaker.Open {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestCallWindow_TripsAtThresholdExactly(t *testing.T) {
+	cb := newCallWindowBreaker(5)
+	// the rate stays under 30% from the 5th call on; the 3rd failure
+	// makes 3 in 10, exactly 30%.
+	const f, s = true, false
+	if i := play(cb, s, s, s, s, f, s, s, f, s); i != -1 {
+		t.Fatalf("tripped at call %d under the threshold", i)
+	}
+	if rate := cb.Status().CallWindowFailureRate; rate != 2.0/9 {
+		t.Errorf("expected a window failure rate of 2/9, got %v", rate)
+	}
+	if i := play(cb, f); i != 0 {
+		t.Errorf("expected 3 failures in 10 calls to trip, state %v", cb.State())
+	}
+}
+
+func TestCallWindow_ReplacesConsecutiveFailures(t *testing.T) {
+	cb := newCallWindowBreaker(5)
+	// three failures in a row are not enough calls to judge.
+	if i := play(cb, true, true, true, true); i != -1 {
+		t.Fatalf("tripped at call %d before WindowMinRequests", i)
+	}
+	if i := play(cb, true); i != 0 {
+		t.Errorf("expected the 5th call to trip, state %v", cb.State())
+	}
+}
+
+func TestCallWindow_WrapsAround(t *testing.T) {
+	cb := newCallWindowBreaker(10)
+	const f, s = true, false
+	// two early failures age out as the window wraps, so two later ones
+	// stay under the threshold.
+	if i := play(cb, f, f, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, f, f); i != -1 {
+		t.Fatalf("tripped at call %d although no 10 calls held 3 failures", i)
+	}
+	if rate := cb.Status().CallWindowFailureRate; rate != 0.2 {
+		t.Errorf("expected 2 failures in the last 10 calls, got rate %v", rate)
+	}
+	if i := play(cb, f); i != 0 {
+		t.Errorf("expected a third failure within 10 calls to trip, state %v", cb.State())
+	}
+}
+
+func TestCallWindow_OffByDefault(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Strict: true})
+	if i := play(cb, true, true, true); i != 2 {
+		t.Errorf("expected consecutive failures to trip at the 3rd call, got %d", i)
+	}
+	if err := (circuitbreaker.Config{WindowSize: 10}).Validate(); err == nil {
+		t.Error("expected WindowSize without FailureRateThreshold to be rejected")
+	}
+}
+
+func TestReset_EmptiesCallWindows(t *testing.T) {
+	t.Run("calls", func(t *testing.T) {
+		cb := newCallWindowBreaker(5)
+		const f, s = true, false
+		// 2 failures in 10; one more would make 30%.
+		if i := play(cb, s, s, s, s, s, s, s, s, f, f); i != -1 {
+			t.Fatalf("tripped at call %d under the threshold", i)
+		}
+		cb.Reset()
+		if i := play(cb, f); i != -1 {
+			t.Errorf("expected calls made before Reset not to count, state %v", cb.State())
+		}
+	})
+	t.Run("slow calls", func(t *testing.T) {
+		clock := cbt.NewFakeClock(cbt.Epoch)
+		cb := circuitbreaker.New(circuitbreaker.Config{
+			SlowCallDuration:      2 * time.Second,
+			SlowCallRateThreshold: 0.6,
+			SlowCallWindowSize:    5,
+			Clock:                 clock,
+			Strict:                true,
+		})
+		slow, fast := slowFn(clock, 2*time.Second), slowFn(clock, time.Second)
+		// 2 slow calls in 4; a fifth would fill the window at 60%.
+		for _, fn := range []func() (any, error){slow, fast, slow, fast} {
+			cb.Execute(fn)
+		}
+		cb.Reset()
+		cb.Execute(slow)
+		if cb.State() != circuitbreaker.Closed {
+			t.Errorf("expected slow calls made before Reset not to count, state %v", cb.State())
+		}
+	})
+}
@@ -0,0 +1,243 @@
+// Package cbgrpc protects gRPC clients with circuit breakers.
+//
+// UnaryClientInterceptor and StreamClientInterceptor guard every call on a
+// connection with a single breaker. GroupUnaryClientInterceptor and
+// GroupStreamClientInterceptor keep one breaker per method in a
+// circuitbreaker.Group instead, so a failing ExportAllData does not open
+// the circuit for GetProfile on the same connection. Per-method settings
+// come from the group's WithKeyConfig, and WithMaxKeys bounds the number
+// of breakers. Per-method status is available from the group, for
+// instance through its Statuses method.
+//
+// It is a separate module so that the circuitbreaker package itself does
+// not depend on gRPC.
+package cbgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// DefaultFailureCodes are the status codes that count as failures unless
+// WithFailureCodes says otherwise. They point at an unhealthy server or
+// network rather than at a bad request. To count more codes, such as
+// ResourceExhausted, pass them to WithFailureCodes along with these.
+var DefaultFailureCodes = []codes.Code{
+	codes.Unavailable,
+	codes.DeadlineExceeded,
+	codes.Internal,
+}
+
+// Option configures the interceptors.
+type Option func(*options)
+
+type options struct {
+	key      func(ctx context.Context, method string) string
+	failures map[codes.Code]bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{key: func(_ context.Context, method string) string { return method }}
+	WithFailureCodes(DefaultFailureCodes...)(&o)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// WithKeyFunc picks the breaker for a call. The default key is the full
+// method name, such as "/profile.Profiles/GetProfile". It has no effect on
+// interceptors that use a single breaker.
+func WithKeyFunc(key func(ctx context.Context, method string) string) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
+
+// WithFailureCodes sets the status codes that count as failures,
+// replacing DefaultFailureCodes:
+//
+//	cbgrpc.WithFailureCodes(append(slices.Clone(cbgrpc.DefaultFailureCodes), codes.ResourceExhausted)...)
+//
+// Other errors are returned to the caller without counting against the
+// breaker.
+func WithFailureCodes(cs ...codes.Code) Option {
+	return func(o *options) {
+		o.failures = make(map[codes.Code]bool, len(cs))
+		for _, c := range cs {
+			o.failures[c] = true
+		}
+	}
+}
+
+// RejectedError is returned for a call that a breaker rejected without
+// sending it. Its gRPC status is Unavailable with a message naming the
+// breaker, and errors.Is matches the breaker's own error, such as
+// circuitbreaker.ErrCircuitOpen.
+type RejectedError struct {
+	// Breaker is the name of the breaker that rejected the call.
+	Breaker string
+	Method  string
+	Err     error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("circuit breaker %q rejected %s: %v", e.Breaker, e.Method, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// GRPCStatus lets status.FromError and status.Code report the rejection
+// as Unavailable.
+func (e *RejectedError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// breakers is where the interceptors find the breaker for a key: a Group,
+// or a single breaker used for every key.
+type breakers interface {
+	ExecuteContext(ctx context.Context, key string, fn func(context.Context) (any, error), opts ...circuitbreaker.CallOption) (any, error)
+	// name is the name of the breaker for key, for RejectedError.
+	name(key string) string
+}
+
+// group keeps a breaker per key.
+type group struct {
+	*circuitbreaker.Group
+}
+
+func (g group) name(key string) string { return g.Breaker(key).Status().Name }
+
+// single is a breaker used for every key.
+type single struct {
+	cb circuitbreaker.Guard
+}
+
+func (s single) ExecuteContext(ctx context.Context, _ string, fn func(context.Context) (any, error), opts ...circuitbreaker.CallOption) (any, error) {
+	if s.cb == nil {
+		return fn(ctx)
+	}
+	return s.cb.ExecuteContext(ctx, fn, opts...)
+}
+
+// name is the breaker's name, if it can tell; a test double may not.
+func (s single) name(string) string {
+	if st, ok := s.cb.(interface{ Status() circuitbreaker.Status }); ok {
+		return st.Status().Name
+	}
+	return ""
+}
+
+// UnaryClientInterceptor returns an interceptor that runs every call
+// through cb, for a connection to a single downstream.
+func UnaryClientInterceptor(cb circuitbreaker.Guard, opts ...Option) grpc.UnaryClientInterceptor {
+	return unaryClientInterceptor(single{cb}, newOptions(opts))
+}
+
+// GroupUnaryClientInterceptor returns an interceptor that runs each call
+// through the breaker g keeps for its key.
+func GroupUnaryClientInterceptor(g *circuitbreaker.Group, opts ...Option) grpc.UnaryClientInterceptor {
+	return unaryClientInterceptor(group{g}, newOptions(opts))
+}
+
+func unaryClientInterceptor(g breakers, o options) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		_, err := execute(ctx, o, g, method, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, invoker(ctx, method, req, reply, cc, callOpts...)
+		})
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that runs the opening of
+// every stream through cb. Errors later in the life of the stream are not
+// counted.
+func StreamClientInterceptor(cb circuitbreaker.Guard, opts ...Option) grpc.StreamClientInterceptor {
+	return streamClientInterceptor(single{cb}, newOptions(opts))
+}
+
+// GroupStreamClientInterceptor returns an interceptor that runs the
+// opening of each stream through the breaker g keeps for its key. Errors
+// later in the life of the stream are not counted.
+func GroupStreamClientInterceptor(g *circuitbreaker.Group, opts ...Option) grpc.StreamClientInterceptor {
+	return streamClientInterceptor(group{g}, newOptions(opts))
+}
+
+func streamClientInterceptor(g breakers, o options) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		// the stream outlives the call, whose context ends when it
+		// returns, so it gets a context of its own that the call's
+		// cancels only while the stream is being opened.
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		stream, err := execute(ctx, o, g, method, func(callCtx context.Context) (grpc.ClientStream, error) {
+			stop := context.AfterFunc(callCtx, func() { cancel(context.Cause(callCtx)) })
+			defer stop()
+			return streamer(streamCtx, desc, cc, method, callOpts...)
+		})
+		if err != nil {
+			// also ends a stream opened after Config.CallTimeout.
+			cancel(err)
+			return nil, err
+		}
+		return &cancelStream{ClientStream: stream, cancel: cancel}, nil
+	}
+}
+
+// cancelStream ends the context it was opened with when it finishes.
+type cancelStream struct {
+	grpc.ClientStream
+	cancel context.CancelCauseFunc
+}
+
+func (s *cancelStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		// io.EOF or the stream's status: it is over either way.
+		s.cancel(nil)
+	}
+	return err
+}
+
+// outcome is what a call returned when it did not count as a failure.
+type outcome[T any] struct {
+	value T
+	err   error
+}
+
+// execute runs call through the key's breaker with the RPC's ctx, counting
+// only failure codes against it, and returns what call returned.
+func execute[T any](ctx context.Context, o options, g breakers, method string, call func(context.Context) (T, error)) (T, error) {
+	var zero T
+	key := o.key(ctx, method)
+	// set on the call's goroutine, which Config.CallTimeout may abandon.
+	var ran atomic.Bool
+	result, err := g.ExecuteContext(ctx, key, func(ctx context.Context) (any, error) {
+		ran.Store(true)
+		v, err := call(ctx)
+		if err != nil && o.failures[status.Code(err)] {
+			return nil, err
+		}
+		return outcome[T]{value: v, err: err}, nil
+	})
+	switch {
+	case err == nil:
+		out := result.(outcome[T])
+		return out.value, out.err
+	case ran.Load() || errors.Is(err, circuitbreaker.ErrCallTimeout):
+		return zero, err
+	case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		// ctx ended while the call waited for admission.
+		return zero, status.FromContextError(err).Err()
+	}
+	return zero, &RejectedError{Breaker: g.name(key), Method: method, Err: err}
+}
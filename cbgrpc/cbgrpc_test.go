@@ -0,0 +1,359 @@
+package cbgrpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbgrpc"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+const (
+	getProfile    = "/profile.Profiles/GetProfile"
+	exportAllData = "/profile.Profiles/ExportAllData"
+	watchProfile  = "/profile.Profiles/WatchProfile"
+)
+
+// profiles is a hand-written service with two unary methods and a server
+// stream whose behaviour the tests script.
+type profiles struct {
+	getProfile, exportAllData, watchProfile func() error
+	exportCalls, watchCalls                 atomic.Int32
+}
+
+func (p *profiles) desc() *grpc.ServiceDesc {
+	handler := func(fn func() error) grpc.MethodHandler {
+		return func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if err := fn(); err != nil {
+				return nil, err
+			}
+			return wrapperspb.String("ok"), nil
+		}
+	}
+	return &grpc.ServiceDesc{
+		ServiceName: "profile.Profiles",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "GetProfile", Handler: handler(func() error { return p.getProfile() })},
+			{MethodName: "ExportAllData", Handler: handler(func() error {
+				p.exportCalls.Add(1)
+				return p.exportAllData()
+			})},
+		},
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "WatchProfile",
+			ServerStreams: true,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				p.watchCalls.Add(1)
+				if err := stream.SendMsg(wrapperspb.String("ok")); err != nil {
+					return err
+				}
+				return p.watchProfile()
+			},
+		}},
+	}
+}
+
+// dial serves p over bufconn and returns a client connection using opts.
+func dial(t *testing.T, p *profiles, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	srv.RegisterService(p.desc(), p)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func invoke(conn *grpc.ClientConn, method string) error {
+	return conn.Invoke(context.Background(), method, wrapperspb.String("user-1"), new(wrapperspb.StringValue))
+}
+
+func TestUnaryInterceptor_IsolatesMethods(t *testing.T) {
+	p := &profiles{
+		getProfile:    func() error { return nil },
+		exportAllData: func() error { return status.Error(codes.Unavailable, "export backend down") },
+	}
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{Name: "profiles", FailureThreshold: 3, Strict: true})
+	defer g.Close()
+	conn := dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.GroupUnaryClientInterceptor(g)))
+
+	for i := 0; i < 3; i++ {
+		if err := invoke(conn, exportAllData); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected the server's Unavailable, got %v", err)
+		}
+	}
+	err := invoke(conn, exportAllData)
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen once tripped, got %v", err)
+	}
+	if p.exportCalls.Load() != 3 {
+		t.Errorf("expected the rejected call not to reach the server, got %d calls", p.exportCalls.Load())
+	}
+	st := status.Convert(err)
+	if st.Code() != codes.Unavailable || !strings.Contains(st.Message(), `"profiles/`+exportAllData+`"`) {
+		t.Errorf("expected an Unavailable status naming the method's breaker, got %v", st)
+	}
+	var rejected *cbgrpc.RejectedError
+	if !errors.As(err, &rejected) || rejected.Method != exportAllData {
+		t.Errorf("expected a *RejectedError for %s, got %v", exportAllData, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := invoke(conn, getProfile); err != nil {
+			t.Fatalf("GetProfile should be unaffected, got %v", err)
+		}
+	}
+
+	states := map[string]circuitbreaker.State{}
+	for _, s := range g.Statuses() {
+		states[s.Name] = s.State
+	}
+	if states["profiles/"+exportAllData] != circuitbreaker.Open || states["profiles/"+getProfile] != circuitbreaker.Closed {
+		t.Errorf("unexpected per-method states %v", states)
+	}
+}
+
+func TestUnaryInterceptor_ClientErrorsDoNotCount(t *testing.T) {
+	p := &profiles{
+		getProfile:    func() error { return status.Error(codes.NotFound, "no such user") },
+		exportAllData: func() error { return nil },
+	}
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	defer g.Close()
+	conn := dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.GroupUnaryClientInterceptor(g)))
+
+	for i := 0; i < 5; i++ {
+		if err := invoke(conn, getProfile); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected NotFound passed through, got %v", err)
+		}
+	}
+	if s := g.Breaker(getProfile).State(); s != circuitbreaker.Closed {
+		t.Errorf("NotFound should not count as a failure, got %v", s)
+	}
+}
+
+func TestUnaryInterceptor_KeyFuncAndOverrides(t *testing.T) {
+	p := &profiles{
+		getProfile:    func() error { return status.Error(codes.Internal, "boom") },
+		exportAllData: func() error { return status.Error(codes.Internal, "boom") },
+	}
+	// one breaker for the whole service, with a tighter threshold than the
+	// group default.
+	service := func(_ context.Context, method string) string {
+		return method[:strings.LastIndex(method, "/")]
+	}
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{FailureThreshold: 10, Strict: true},
+		circuitbreaker.WithKeyConfig("/profile.Profiles", circuitbreaker.Config{FailureThreshold: 2, Strict: true}))
+	defer g.Close()
+	conn := dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.GroupUnaryClientInterceptor(g, cbgrpc.WithKeyFunc(service))))
+
+	invoke(conn, getProfile)
+	invoke(conn, exportAllData)
+	if err := invoke(conn, getProfile); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the shared service breaker to be open, got %v", err)
+	}
+	if keys := g.Keys(); len(keys) != 1 || keys[0] != "/profile.Profiles" {
+		t.Errorf("expected a single service key, got %v", keys)
+	}
+}
+
+func TestUnaryInterceptor_CardinalityLimit(t *testing.T) {
+	p := &profiles{getProfile: func() error { return nil }, exportAllData: func() error { return nil }}
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{Strict: true}, circuitbreaker.WithMaxKeys(1))
+	defer g.Close()
+	conn := dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.GroupUnaryClientInterceptor(g)))
+
+	invoke(conn, getProfile)
+	invoke(conn, exportAllData)
+	keys := g.Keys()
+	if len(keys) != 2 || keys[0] != getProfile || keys[1] != circuitbreaker.OverflowKey {
+		t.Errorf("expected %s plus the overflow breaker, got %v", getProfile, keys)
+	}
+}
+
+func TestSingleUnaryInterceptor_TripsOnFailureCodes(t *testing.T) {
+	var code atomic.Uint32
+	p := &profiles{
+		getProfile:    func() error { return status.Error(codes.Code(code.Load()), "scripted") },
+		exportAllData: func() error { return nil },
+	}
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "profiles", FailureThreshold: 3, Strict: true})
+	conn := dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.UnaryClientInterceptor(cb)))
+
+	// bad requests are the caller's problem, not the server's.
+	for _, c := range []codes.Code{codes.NotFound, codes.InvalidArgument, codes.NotFound, codes.InvalidArgument} {
+		code.Store(uint32(c))
+		if err := invoke(conn, getProfile); status.Code(err) != c {
+			t.Fatalf("expected %v passed through, got %v", c, err)
+		}
+	}
+	if s := cb.State(); s != circuitbreaker.Closed {
+		t.Fatalf("client errors should not trip the breaker, got %v", s)
+	}
+
+	for _, c := range []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Internal} {
+		code.Store(uint32(c))
+		if err := invoke(conn, getProfile); status.Code(err) != c {
+			t.Fatalf("expected the server's %v, got %v", c, err)
+		}
+	}
+	// one breaker guards every method.
+	err := invoke(conn, exportAllData)
+	if status.Code(err) != codes.Unavailable || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected an Unavailable rejection once tripped, got %v", err)
+	}
+	if p.exportCalls.Load() != 0 {
+		t.Errorf("expected the rejected call not to reach the server, got %d calls", p.exportCalls.Load())
+	}
+	if st := status.Convert(err); !strings.Contains(st.Message(), `"profiles"`) {
+		t.Errorf("expected the status to name the breaker, got %v", st)
+	}
+}
+
+func TestSingleUnaryInterceptor_OptInFailureCodes(t *testing.T) {
+	p := &profiles{
+		getProfile:    func() error { return status.Error(codes.ResourceExhausted, "quota") },
+		exportAllData: func() error { return nil },
+	}
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	conn := dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.UnaryClientInterceptor(cb)))
+	for i := 0; i < 3; i++ {
+		invoke(conn, getProfile)
+	}
+	if s := cb.State(); s != circuitbreaker.Closed {
+		t.Fatalf("ResourceExhausted is not a default failure code, got %v", s)
+	}
+
+	failureCodes := append(slices.Clone(cbgrpc.DefaultFailureCodes), codes.ResourceExhausted)
+	cb = circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	conn = dial(t, p, grpc.WithUnaryInterceptor(cbgrpc.UnaryClientInterceptor(cb, cbgrpc.WithFailureCodes(failureCodes...))))
+	invoke(conn, getProfile)
+	invoke(conn, getProfile)
+	if s := cb.State(); s != circuitbreaker.Open {
+		t.Errorf("expected opted-in ResourceExhausted to trip the breaker, got %v", s)
+	}
+}
+
+func TestSingleUnaryInterceptor_PassesTheCallContext(t *testing.T) {
+	p := &profiles{getProfile: func() error { return nil }}
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "profiles", Strict: true})
+	type key struct{}
+	var info circuitbreaker.CallInfo
+	var value any
+	inspect := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		info, _ = circuitbreaker.FromContext(ctx)
+		value = ctx.Value(key{})
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	conn := dial(t, p, grpc.WithChainUnaryInterceptor(cbgrpc.UnaryClientInterceptor(cb), inspect))
+
+	ctx := context.WithValue(context.Background(), key{}, "caller")
+	if err := conn.Invoke(ctx, getProfile, wrapperspb.String("user-1"), new(wrapperspb.StringValue)); err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "profiles" || value != "caller" {
+		t.Errorf("expected the RPC's context through the breaker, got %+v and %v", info, value)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := conn.Invoke(ctx, getProfile, wrapperspb.String("user-1"), new(wrapperspb.StringValue)); status.Code(err) != codes.Canceled {
+		t.Errorf("expected a cancelled RPC to fail with Canceled, got %v", err)
+	}
+	if tot := cb.Totals(); tot.Requests != 1 || tot.Rejected != 0 {
+		t.Errorf("expected the cancelled RPC neither run nor rejected, got %+v", tot)
+	}
+}
+
+func TestSingleStreamInterceptor(t *testing.T) {
+	p := &profiles{watchProfile: func() error { return status.Error(codes.Internal, "boom") }}
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Strict: true})
+	conn := dial(t, p, grpc.WithStreamInterceptor(cbgrpc.StreamClientInterceptor(cb)))
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	watch := func() (grpc.ClientStream, error) {
+		stream, err := conn.NewStream(context.Background(), desc, watchProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.SendMsg(wrapperspb.String("user-1")); err != nil {
+			return nil, err
+		}
+		return stream, stream.CloseSend()
+	}
+
+	stream, err := watch()
+	if err != nil {
+		t.Fatalf("expected the stream to open, got %v", err)
+	}
+	msg := new(wrapperspb.StringValue)
+	if err := stream.RecvMsg(msg); err != nil || msg.Value != "ok" {
+		t.Fatalf("expected a message, got %v, %v", msg, err)
+	}
+	if err := stream.RecvMsg(msg); status.Code(err) != codes.Internal {
+		t.Fatalf("expected the stream to end with Internal, got %v", err)
+	}
+	// the stream opened, so it counts as a success.
+	if s := cb.State(); s != circuitbreaker.Closed {
+		t.Fatalf("expected errors after opening not to count, got %v", s)
+	}
+
+	cbt.SetState(cb, circuitbreaker.Open)
+	if _, err := watch(); status.Code(err) != codes.Unavailable || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected an Unavailable rejection, got %v", err)
+	}
+	if n := p.watchCalls.Load(); n != 1 {
+		t.Errorf("expected the rejected stream not to reach the server, got %d calls", n)
+	}
+}
+
+func TestSingleStreamInterceptor_OutlivesTheCall(t *testing.T) {
+	p := &profiles{watchProfile: func() error { return nil }}
+	// with a CallTimeout, the call's context ends as soon as it returns.
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Minute, Strict: true})
+	conn := dial(t, p, grpc.WithStreamInterceptor(cbgrpc.StreamClientInterceptor(cb)))
+
+	stream, err := conn.NewStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, watchProfile)
+	if err != nil {
+		t.Fatalf("expected the stream to open, got %v", err)
+	}
+	if err := stream.SendMsg(wrapperspb.String("user-1")); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	msg := new(wrapperspb.StringValue)
+	if err := stream.RecvMsg(msg); err != nil || msg.Value != "ok" {
+		t.Fatalf("expected a message after the call returned, got %v, %v", msg, err)
+	}
+	if err := stream.RecvMsg(msg); err != io.EOF {
+		t.Errorf("expected the stream to end cleanly, got %v", err)
+	}
+}
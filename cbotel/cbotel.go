@@ -0,0 +1,169 @@
+// Package cbotel reports circuit breaker activity to OpenTelemetry.
+//
+// A Breaker wraps a *circuitbreaker.CircuitBreaker. Calls made through its
+// ExecuteContext add an event to the span in the caller's context when the
+// breaker turns them away, and the breaker's totals and state are
+// published as metrics through a MeterProvider. Everything is read through
+// the breaker's public API, Totals and State, so the numbers match Status,
+// the Report and cbprom.
+//
+// It is a separate module so that the circuitbreaker package itself does
+// not depend on OpenTelemetry.
+package cbotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// ScopeName is the instrumentation scope of the meter the metrics are
+// created with.
+const ScopeName = "github.com/teresamychu/circuitbreaker/cbotel"
+
+// RejectedEvent is the name of the span event added for a rejected call.
+const RejectedEvent = "circuit_breaker.rejected"
+
+// Attribute keys used on metrics and span events.
+const (
+	NameKey  = attribute.Key("circuit_breaker.name")
+	StateKey = attribute.Key("circuit_breaker.state")
+)
+
+// Option configures a Breaker.
+type Option func(*options)
+
+type options struct {
+	meterProvider metric.MeterProvider
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if o.meterProvider == nil {
+		o.meterProvider = otel.GetMeterProvider()
+	}
+	return o
+}
+
+// WithMeterProvider sets the MeterProvider the metrics are registered
+// with. The default is the global one, otel.GetMeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = mp
+	}
+}
+
+// states are the states reported by the state gauge, one series each.
+var states = []circuitbreaker.State{circuitbreaker.Closed, circuitbreaker.Open, circuitbreaker.HalfOpen}
+
+// Breaker is a circuit breaker instrumented with OpenTelemetry.
+type Breaker struct {
+	cb           *circuitbreaker.CircuitBreaker
+	name         attribute.KeyValue
+	registration metric.Registration
+}
+
+// New instruments cb and registers its metrics with the MeterProvider:
+//
+//   - circuitbreaker.requests, counter: calls that asked to be admitted;
+//   - circuitbreaker.failures, counter: calls that completed with a failure;
+//   - circuitbreaker.rejections, counter: calls turned away by the circuit;
+//   - circuitbreaker.state, gauge: 1 for the state the breaker is in and
+//     0 for the others, by circuit_breaker.state.
+//
+// Every series carries circuit_breaker.name. The counters cover all of
+// cb's calls, not just those made through the Breaker. The values are read
+// when the metrics are collected; Close unregisters them.
+func New(cb *circuitbreaker.CircuitBreaker, opts ...Option) (*Breaker, error) {
+	o := newOptions(opts)
+	meter := o.meterProvider.Meter(ScopeName)
+
+	requests, err := meter.Int64ObservableCounter("circuitbreaker.requests",
+		metric.WithDescription("Calls that asked to be admitted."), metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+	failures, err := meter.Int64ObservableCounter("circuitbreaker.failures",
+		metric.WithDescription("Calls that completed with a failure."), metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+	rejections, err := meter.Int64ObservableCounter("circuitbreaker.rejections",
+		metric.WithDescription("Calls turned away by the open or half-open circuit."), metric.WithUnit("{call}"))
+	if err != nil {
+		return nil, err
+	}
+	state, err := meter.Int64ObservableGauge("circuitbreaker.state",
+		metric.WithDescription("Current state of the breaker: 1 for the state it is in, 0 for the others."))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Breaker{cb: cb, name: NameKey.String(cb.Status().Name)}
+	attrs := metric.WithAttributes(b.name)
+	b.registration, err = meter.RegisterCallback(func(_ context.Context, obs metric.Observer) error {
+		t := cb.Totals()
+		obs.ObserveInt64(requests, int64(t.Requests), attrs)
+		obs.ObserveInt64(failures, int64(t.Failures), attrs)
+		obs.ObserveInt64(rejections, int64(t.Rejected), attrs)
+		current := cb.State()
+		for _, s := range states {
+			var v int64
+			if s == current {
+				v = 1
+			}
+			obs.ObserveInt64(state, v, metric.WithAttributes(b.name, StateKey.String(s.String())))
+		}
+		return nil
+	}, requests, failures, rejections, state)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Breaker returns the breaker b instruments.
+func (b *Breaker) Breaker() *circuitbreaker.CircuitBreaker {
+	return b.cb
+}
+
+// ExecuteContext runs request through the breaker with
+// CircuitBreaker.ExecuteContext. When the breaker turns the call away, a
+// RejectedEvent naming the breaker, its state and the error is added to
+// the span in ctx. A call abandoned because ctx was done is not a
+// rejection.
+func (b *Breaker) ExecuteContext(ctx context.Context, request func(context.Context) (any, error), opts ...circuitbreaker.CallOption) (any, error) {
+	if request == nil {
+		return b.cb.ExecuteContext(ctx, nil, opts...)
+	}
+	ran := false
+	result, err := b.cb.ExecuteContext(ctx, func(ctx context.Context) (any, error) {
+		ran = true
+		return request(ctx)
+	}, opts...)
+	if !ran && err != nil && ctx.Err() == nil {
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			span.AddEvent(RejectedEvent, trace.WithAttributes(
+				b.name,
+				StateKey.String(b.cb.State().String()),
+				attribute.String("error", err.Error()),
+			))
+		}
+	}
+	return result, err
+}
+
+// Close unregisters b's metrics. The breaker itself is left alone.
+func (b *Breaker) Close() error {
+	return b.registration.Unregister()
+}
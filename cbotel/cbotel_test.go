@@ -0,0 +1,123 @@
+package cbotel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbotel"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+var errSimulated = errors.New("simulated failure")
+
+func successFn(context.Context) (any, error) { return "ok", nil }
+func failFn(context.Context) (any, error)    { return nil, errSimulated }
+
+// collect returns the int64 data points of each metric, keyed by metric
+// name and then by the circuit_breaker.state attribute, empty for metrics
+// without one.
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			points := make(map[string]int64)
+			var dps []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				dps = data.DataPoints
+			case metricdata.Gauge[int64]:
+				dps = data.DataPoints
+			default:
+				t.Fatalf("unexpected data for %s: %T", m.Name, m.Data)
+			}
+			for _, dp := range dps {
+				if name, _ := dp.Attributes.Value(cbotel.NameKey); name.AsString() != "payments" {
+					t.Errorf("%s: expected circuit_breaker.name payments, got %q", m.Name, name.AsString())
+				}
+				state, _ := dp.Attributes.Value(cbotel.StateKey)
+				points[state.AsString()] = dp.Value
+			}
+			out[m.Name] = points
+		}
+	}
+	return out
+}
+
+func TestBreaker_MetricsAndSpanEventsAfterTrip(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "payments",
+		FailureThreshold: 2,
+		Timeout:          time.Minute,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+	})
+	b, err := cbotel.New(cb, cbotel.WithMeterProvider(mp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "checkout")
+	b.ExecuteContext(ctx, successFn)
+	b.ExecuteContext(ctx, failFn)
+	b.ExecuteContext(ctx, failFn)
+	if _, err := b.ExecuteContext(ctx, successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen after the trip, got %v", err)
+	}
+	span.End()
+
+	got := collect(t, reader)
+	for name, want := range map[string]map[string]int64{
+		"circuitbreaker.requests":   {"": 4},
+		"circuitbreaker.failures":   {"": 2},
+		"circuitbreaker.rejections": {"": 1},
+		"circuitbreaker.state":      {"Closed": 0, "Open": 1, "HalfOpen": 0},
+	} {
+		for state, v := range want {
+			if p, ok := got[name][state]; !ok || p != v {
+				t.Errorf("%s{state=%q}: expected %d, got %v", name, state, v, got[name])
+			}
+		}
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected one span, got %d", len(ended))
+	}
+	events := ended[0].Events()
+	if len(events) != 1 || events[0].Name != cbotel.RejectedEvent {
+		t.Fatalf("expected a single %s event, got %+v", cbotel.RejectedEvent, events)
+	}
+	attrs := attribute.NewSet(events[0].Attributes...)
+	if v, _ := attrs.Value(cbotel.StateKey); v.AsString() != "Open" {
+		t.Errorf("expected the event to carry state Open, got %q", v.AsString())
+	}
+	if v, _ := attrs.Value(cbotel.NameKey); v.AsString() != "payments" {
+		t.Errorf("expected the event to carry name payments, got %q", v.AsString())
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, reader); len(got) != 0 {
+		t.Errorf("expected no metrics after Close, got %v", got)
+	}
+}
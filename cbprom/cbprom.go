@@ -0,0 +1,507 @@
+// Package cbprom exposes circuit breaker metrics in the Prometheus text
+// exposition format without depending on the Prometheus client library.
+//
+// The metric names and labels are defined once, in Metrics, so that a
+// collector built on client_golang can register exactly the same families
+// and dashboards work against either.
+package cbprom
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Source supplies the statuses of a set of breakers. *circuitbreaker.Group
+// is a Source; Breakers adapts individual breakers.
+type Source interface {
+	Statuses() []circuitbreaker.Status
+}
+
+// Breakers returns a Source for the given breakers.
+func Breakers(cbs ...*circuitbreaker.CircuitBreaker) Source {
+	return breakers(cbs)
+}
+
+type breakers []*circuitbreaker.CircuitBreaker
+
+func (b breakers) Statuses() []circuitbreaker.Status {
+	statuses := make([]circuitbreaker.Status, len(b))
+	for i, cb := range b {
+		statuses[i] = cb.Status()
+	}
+	return statuses
+}
+
+// Metric describes one metric family.
+type Metric struct {
+	Name string
+	Help string
+	// Type is "gauge", "counter" or "histogram".
+	Type string
+	// Labels are the label names besides "name", which every family has
+	// first; they are kept in sorted order and all sort after "name". The
+	// bucket samples of a histogram also carry "le".
+	Labels []string
+
+	// samples returns the label values and value of each sample for s.
+	samples func(s circuitbreaker.Status) []sample
+}
+
+type sample struct {
+	// suffix follows the family name, as "_bucket" does for histograms.
+	suffix string
+	labels []string
+	// le is the upper bound of a histogram bucket sample.
+	le    string
+	value float64
+}
+
+func one(v float64) []sample {
+	return []sample{{value: v}}
+}
+
+// histogram returns the samples of h with the given outcome label.
+func histogram(outcome string, h circuitbreaker.LatencyHistogram) []sample {
+	out := make([]sample, 0, len(h.Bounds)+3)
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		out = append(out, sample{suffix: "_bucket", labels: []string{outcome}, le: formatValue(bound.Seconds()), value: float64(cumulative)})
+	}
+	return append(out,
+		sample{suffix: "_bucket", labels: []string{outcome}, le: "+Inf", value: float64(h.Count)},
+		sample{suffix: "_sum", labels: []string{outcome}, value: h.Sum.Seconds()},
+		sample{suffix: "_count", labels: []string{outcome}, value: float64(h.Count)},
+	)
+}
+
+// quantiles returns the samples of stats with the given outcome label.
+func quantiles(outcome string, stats circuitbreaker.LatencyStats) []sample {
+	out := make([]sample, 0, 5)
+	for _, q := range []struct {
+		label string
+		value time.Duration
+	}{{"0", stats.Min}, {"0.5", stats.P50}, {"0.95", stats.P95}, {"0.99", stats.P99}, {"1", stats.Max}} {
+		out = append(out, sample{labels: []string{outcome, q.label}, value: q.value.Seconds()})
+	}
+	return out
+}
+
+func bit(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// modeLabels are the values of the override label, in Mode order. The
+// label is not called "mode" since labels must sort after "name".
+var modeLabels = [...]string{
+	circuitbreaker.Automatic:    "none",
+	circuitbreaker.ForcedOpen:   "open",
+	circuitbreaker.ForcedClosed: "closed",
+}
+
+// stateLabels are the values of the state label, in State order.
+var stateLabels = [...]string{
+	circuitbreaker.Closed:   "closed",
+	circuitbreaker.Open:     "open",
+	circuitbreaker.HalfOpen: "half_open",
+}
+
+// Metrics lists every family Write renders, in the order it renders them.
+var Metrics = []Metric{
+	{
+		Name: "circuitbreaker_state", Type: "gauge", Labels: []string{"state"},
+		Help: "Current state of the breaker: 1 for the state it is in, 0 for the others.",
+		samples: func(s circuitbreaker.Status) []sample {
+			out := make([]sample, len(stateLabels))
+			for i, label := range stateLabels {
+				out[i] = sample{labels: []string{label}, value: bit(int(s.State) == i)}
+			}
+			return out
+		},
+	},
+	{
+		Name: "circuitbreaker_forced", Type: "gauge", Labels: []string{"override"},
+		Help: "Whether the state was forced by hand: 1 for the override in place, 0 for the others.",
+		samples: func(s circuitbreaker.Status) []sample {
+			out := make([]sample, len(modeLabels))
+			for i, label := range modeLabels {
+				out[i] = sample{labels: []string{label}, value: bit(int(s.Mode) == i)}
+			}
+			return out
+		},
+	},
+	{
+		Name: "circuitbreaker_consecutive_failures", Type: "gauge",
+		Help: "Failures since the last success.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.Counts.ConsecutiveFailures))
+		},
+	},
+	{
+		Name: "circuitbreaker_failure_weight", Type: "gauge",
+		Help: "Accumulated weight of the failures since the last success.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.FailureWeight)
+		},
+	},
+	{
+		Name: "circuitbreaker_ramp_percent", Type: "gauge",
+		Help: "Share of calls, out of 100, let through by a recovery ramp in progress; 0 when none is.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.RampPercent)
+		},
+	},
+	{
+		Name: "circuitbreaker_successes", Type: "gauge",
+		Help: "Successes since the last state change.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.Counts.Successes))
+		},
+	},
+	{
+		Name: "circuitbreaker_requests_total", Type: "counter",
+		Help: "Calls that asked to be admitted.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.Totals.Requests))
+		},
+	},
+	{
+		Name: "circuitbreaker_successes_total", Type: "counter",
+		Help: "Calls that completed successfully.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.Totals.Successes))
+		},
+	},
+	{
+		Name: "circuitbreaker_failures_total", Type: "counter",
+		Help: "Calls that completed with a failure.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.Totals.Failures))
+		},
+	},
+	{
+		Name: "circuitbreaker_rejected_total", Type: "counter",
+		Help: "Calls turned away by the open circuit.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.Totals.Rejected))
+		},
+	},
+	{
+		Name: "circuitbreaker_rejected_by_state_total", Type: "counter", Labels: []string{"state"},
+		Help: "Calls turned away, by the state that rejected them: ErrCircuitOpen while open, ErrTooManyRequests while half-open.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return []sample{
+				{labels: []string{stateLabels[circuitbreaker.Open]}, value: float64(s.Totals.RejectedOpen)},
+				{labels: []string{stateLabels[circuitbreaker.HalfOpen]}, value: float64(s.Totals.RejectedHalfOpen)},
+			}
+		},
+	},
+	{
+		Name: "circuitbreaker_state_seconds_total", Type: "counter", Labels: []string{"state"},
+		Help: "Time spent in each state, including the time so far in the current one.",
+		samples: func(s circuitbreaker.Status) []sample {
+			times := [...]time.Duration{
+				circuitbreaker.Closed:   s.Totals.TimeClosed,
+				circuitbreaker.Open:     s.Totals.TimeOpen,
+				circuitbreaker.HalfOpen: s.Totals.TimeHalfOpen,
+			}
+			out := make([]sample, len(stateLabels))
+			for i, label := range stateLabels {
+				out[i] = sample{labels: []string{label}, value: times[i].Seconds()}
+			}
+			return out
+		},
+	},
+	{
+		Name: "circuitbreaker_last_state_change_timestamp_seconds", Type: "gauge",
+		Help: "Unix time of the last state change, or 0 if there was none.",
+		samples: func(s circuitbreaker.Status) []sample {
+			if s.LastStateChange.IsZero() {
+				return one(0)
+			}
+			return one(float64(s.LastStateChange.UnixNano()) / 1e9)
+		},
+	},
+	{
+		Name: "circuitbreaker_failure_rate", Type: "gauge",
+		Help: "Exponentially weighted moving average of the failure rate.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.FailureRate)
+		},
+	},
+	{
+		Name: "circuitbreaker_window_failure_rate", Type: "gauge", Labels: []string{"window"},
+		Help: "Failure rate over each configured failure-rate window, by index.",
+		samples: func(s circuitbreaker.Status) []sample {
+			out := make([]sample, len(s.WindowFailureRates))
+			for i, r := range s.WindowFailureRates {
+				out[i] = sample{labels: []string{strconv.Itoa(i)}, value: r}
+			}
+			return out
+		},
+	},
+	{
+		Name: "circuitbreaker_call_window_failure_rate", Type: "gauge",
+		Help: "Failure rate over the last WindowSize calls.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.CallWindowFailureRate)
+		},
+	},
+	{
+		Name: "circuitbreaker_slow_call_rate", Type: "gauge",
+		Help: "Share of the last SlowCallWindowSize calls that were slow.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.SlowCallRate)
+		},
+	},
+	{
+		Name: "circuitbreaker_spike_ratio", Type: "gauge",
+		Help: "Ratio of the short-window failure rate to the baseline rate.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.SpikeRatio)
+		},
+	},
+	{
+		Name: "circuitbreaker_session_failures", Type: "gauge",
+		Help: "Weighted failures of the sessions that ended within the session window.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.SessionFailures)
+		},
+	},
+	{
+		Name: "circuitbreaker_latency_seconds", Type: "gauge",
+		Help: "Configured percentile of recent call latencies when latency tripping is on.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.Latency.Seconds())
+		},
+	},
+	{
+		Name: "circuitbreaker_call_duration_seconds", Type: "histogram",
+		Help:   "Latency of completed calls by outcome; rejected calls are not included.",
+		Labels: []string{"outcome"},
+		samples: func(s circuitbreaker.Status) []sample {
+			return append(histogram("success", s.SuccessLatency), histogram("failure", s.FailureLatency)...)
+		},
+	},
+	{
+		Name: "circuitbreaker_call_duration_quantile_seconds", Type: "gauge",
+		Help:   "Estimated latency quantiles of completed calls by outcome; quantile 0 is the fastest call and 1 the slowest.",
+		Labels: []string{"outcome", "quantile"},
+		samples: func(s circuitbreaker.Status) []sample {
+			return append(quantiles("success", s.SuccessLatency.Stats()), quantiles("failure", s.FailureLatency.Stats())...)
+		},
+	},
+	{
+		Name: "circuitbreaker_in_flight_cost", Type: "gauge",
+		Help: "Total cost of the calls currently running.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.InFlightCost))
+		},
+	},
+	{
+		Name: "circuitbreaker_in_flight", Type: "gauge",
+		Help: "Number of calls currently running.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.InFlight))
+		},
+	},
+	{
+		Name: "circuitbreaker_last_failure_timestamp_seconds", Type: "gauge",
+		Help: "Unix time of the last recorded failure, or 0 if there was none.",
+		samples: func(s circuitbreaker.Status) []sample {
+			if s.LastFailure.IsZero() {
+				return one(0)
+			}
+			return one(float64(s.LastFailure.UnixNano()) / 1e9)
+		},
+	},
+	{
+		Name: "circuitbreaker_open_timeout_seconds", Type: "gauge",
+		Help: "How long the circuit stays open before a probe, as grown by backoff.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.OpenTimeout.Seconds())
+		},
+	},
+	{
+		Name: "circuitbreaker_open_remaining_seconds", Type: "gauge",
+		Help: "Time until the open circuit lets a probe through, or 0 when it is not open.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.OpenRemaining.Seconds())
+		},
+	},
+	{
+		Name: "circuitbreaker_oldest_in_flight_seconds", Type: "gauge",
+		Help: "How long the longest-running call has been running.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.OldestInFlight.Seconds())
+		},
+	},
+	{
+		Name: "circuitbreaker_max_concurrent", Type: "gauge",
+		Help: "Bulkhead limit on the in-flight cost, or 0 when unlimited.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.MaxConcurrent))
+		},
+	},
+	{
+		Name: "circuitbreaker_queue_depth", Type: "gauge",
+		Help: "Callers waiting for admission.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.QueueDepth))
+		},
+	},
+	{
+		Name: "circuitbreaker_queue_admitted_total", Type: "counter",
+		Help: "Callers admitted after waiting.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.QueueAdmitted))
+		},
+	},
+	{
+		Name: "circuitbreaker_queue_rejected_total", Type: "counter",
+		Help: "Callers that timed out waiting or found the queue full.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.QueueRejected))
+		},
+	},
+	{
+		Name: "circuitbreaker_queue_wait_seconds_total", Type: "counter",
+		Help: "Total time admitted callers spent waiting.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.QueueWait.Seconds())
+		},
+	},
+	{
+		Name: "circuitbreaker_retry_budget_tokens", Type: "gauge",
+		Help: "Retry tokens available.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(s.RetryBudget)
+		},
+	},
+	{
+		Name: "circuitbreaker_retries_skipped_total", Type: "counter",
+		Help: "Retries turned away for lack of a retry token.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.RetriesSkipped))
+		},
+	},
+	{
+		Name: "circuitbreaker_shared_waiters", Type: "gauge",
+		Help: "Callers waiting for a shared execution to finish.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.SharedWaiters))
+		},
+	},
+	{
+		Name: "circuitbreaker_degraded", Type: "gauge",
+		Help: "1 while the breaker reports itself degraded.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(bit(s.Degraded))
+		},
+	},
+	{
+		Name: "circuitbreaker_ejected", Type: "gauge",
+		Help: "1 while outlier detection holds the circuit open.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(bit(s.Ejected))
+		},
+	},
+	{
+		Name: "circuitbreaker_in_maintenance", Type: "gauge",
+		Help: "1 while a maintenance window holds the circuit open.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(bit(s.InMaintenance))
+		},
+	},
+	{
+		Name: "circuitbreaker_quorum_breaches", Type: "gauge",
+		Help: "Instances that reported tripping within the quorum window, as last read from the quorum store.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(float64(s.QuorumBreaches))
+		},
+	},
+	{
+		Name: "circuitbreaker_under_pressure", Type: "gauge",
+		Help: "1 while the local process is over a pressure threshold.",
+		samples: func(s circuitbreaker.Status) []sample {
+			return one(bit(s.UnderPressure))
+		},
+	},
+}
+
+// Handler returns an http.Handler that renders the metrics of every
+// breaker in sources on each request.
+func Handler(sources ...Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var statuses []circuitbreaker.Status
+		for _, src := range sources {
+			statuses = append(statuses, src.Statuses()...)
+		}
+		w.Header().Set("Content-Type", ContentType)
+		Write(w, statuses)
+	})
+}
+
+// Write renders statuses in the text exposition format. Each family gets
+// its HELP and TYPE lines followed by its samples for each breaker, with
+// the labels sorted by name.
+func Write(w io.Writer, statuses []circuitbreaker.Status) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range Metrics {
+		bw.WriteString("# HELP " + m.Name + " " + escapeHelp(m.Help) + "\n")
+		bw.WriteString("# TYPE " + m.Name + " " + m.Type + "\n")
+		// "name" sorts before every other label in use but "le".
+		names := append([]string{"name"}, m.Labels...)
+		for _, s := range statuses {
+			for _, smp := range m.samples(s) {
+				bw.WriteString(m.Name + smp.suffix)
+				bw.WriteByte('{')
+				if smp.le != "" {
+					bw.WriteString(`le="` + smp.le + `",`)
+				}
+				values := append([]string{s.Name}, smp.labels...)
+				for i, n := range names {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(n + `="` + escapeLabel(values[i]) + `"`)
+				}
+				bw.WriteString("} " + formatValue(smp.value) + "\n")
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
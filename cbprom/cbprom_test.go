@@ -0,0 +1,353 @@
+package cbprom_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbprom"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// family is a parsed metric family.
+type family struct {
+	help, typ string
+	samples   []parsedSample
+}
+
+type parsedSample struct {
+	// suffix is what follows the family name, such as "_bucket".
+	suffix string
+	labels map[string]string
+	order  []string
+	value  float64
+}
+
+// parse is a minimal parser for the text exposition format. It accepts
+// only what a strict reader would: every sample must follow its family's
+// HELP and TYPE lines, and label values must be properly escaped.
+func parse(r io.Reader) (map[string]*family, error) {
+	families := map[string]*family{}
+	var current string
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "# HELP "):
+			name, help, ok := strings.Cut(line[len("# HELP "):], " ")
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed HELP", n)
+			}
+			if _, dup := families[name]; dup {
+				return nil, fmt.Errorf("line %d: family %s repeated", n, name)
+			}
+			families[name] = &family{help: help}
+			current = name
+		case strings.HasPrefix(line, "# TYPE "):
+			name, typ, ok := strings.Cut(line[len("# TYPE "):], " ")
+			if !ok || name != current {
+				return nil, fmt.Errorf("line %d: TYPE for %s does not follow its HELP", n, name)
+			}
+			if typ != "gauge" && typ != "counter" && typ != "histogram" {
+				return nil, fmt.Errorf("line %d: unknown type %q", n, typ)
+			}
+			families[name].typ = typ
+		case strings.HasPrefix(line, "#"):
+			return nil, fmt.Errorf("line %d: unexpected comment", n)
+		default:
+			name, s, err := parseSample(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			if f := families[current]; f != nil && f.typ == "histogram" {
+				for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+					if name == current+suffix {
+						name, s.suffix = current, suffix
+					}
+				}
+			}
+			if name != current || families[name].typ == "" {
+				return nil, fmt.Errorf("line %d: sample of %s outside its family", n, name)
+			}
+			families[name].samples = append(families[name].samples, s)
+		}
+	}
+	return families, sc.Err()
+}
+
+func parseSample(line string) (string, parsedSample, error) {
+	s := parsedSample{labels: map[string]string{}}
+	i := strings.IndexAny(line, "{ ")
+	if i < 0 {
+		return "", s, errors.New("no value")
+	}
+	name, rest := line[:i], line[i:]
+	if rest[0] == '{' {
+		rest = rest[1:]
+		for !strings.HasPrefix(rest, "}") {
+			eq := strings.Index(rest, `="`)
+			if eq < 0 {
+				return "", s, errors.New("malformed label")
+			}
+			label := rest[:eq]
+			rest = rest[eq+2:]
+			var value strings.Builder
+			for {
+				if rest == "" {
+					return "", s, errors.New("unterminated label value")
+				}
+				c := rest[0]
+				rest = rest[1:]
+				if c == '"' {
+					break
+				}
+				if c == '\n' {
+					return "", s, errors.New("raw newline in label value")
+				}
+				if c == '\\' {
+					if rest == "" {
+						return "", s, errors.New("dangling escape")
+					}
+					switch rest[0] {
+					case '\\':
+						value.WriteByte('\\')
+					case '"':
+						value.WriteByte('"')
+					case 'n':
+						value.WriteByte('\n')
+					default:
+						return "", s, fmt.Errorf("bad escape \\%c", rest[0])
+					}
+					rest = rest[1:]
+					continue
+				}
+				value.WriteByte(c)
+			}
+			if _, dup := s.labels[label]; dup {
+				return "", s, fmt.Errorf("label %s repeated", label)
+			}
+			s.labels[label] = value.String()
+			s.order = append(s.order, label)
+			rest = strings.TrimPrefix(rest, ",")
+		}
+		rest = rest[1:]
+	}
+	v, err := strconv.ParseFloat(strings.TrimPrefix(rest, " "), 64)
+	if err != nil {
+		return "", s, err
+	}
+	s.value = v
+	return name, s, nil
+}
+
+func scrape(t *testing.T, sources ...cbprom.Source) map[string]*family {
+	t.Helper()
+	srv := httptest.NewServer(cbprom.Handler(sources...))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != cbprom.ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, cbprom.ContentType)
+	}
+	families, err := parse(resp.Body)
+	if err != nil {
+		t.Fatalf("output does not parse: %v", err)
+	}
+	return families
+}
+
+// value returns the sample of family name whose labels match. A histogram
+// sample is named with its suffix, as in "x_bucket".
+func value(t *testing.T, families map[string]*family, name string, labels map[string]string) float64 {
+	t.Helper()
+	suffix := ""
+	for _, sfx := range []string{"_bucket", "_sum", "_count"} {
+		if f, ok := families[strings.TrimSuffix(name, sfx)]; ok && f.typ == "histogram" && strings.HasSuffix(name, sfx) {
+			name, suffix = strings.TrimSuffix(name, sfx), sfx
+		}
+	}
+	f, ok := families[name]
+	if !ok {
+		t.Fatalf("family %s missing", name)
+	}
+next:
+	for _, s := range f.samples {
+		if s.suffix != suffix || len(s.labels) != len(labels) {
+			continue
+		}
+		for k, v := range labels {
+			if s.labels[k] != v {
+				continue next
+			}
+		}
+		return s.value
+	}
+	t.Fatalf("no %s sample with labels %v", name, labels)
+	return 0
+}
+
+func TestHandlerRendersEveryFamily(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "payments",
+		FailureThreshold: 3,
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+
+	families := scrape(t, cbprom.Breakers(cb))
+
+	for _, m := range cbprom.Metrics {
+		f, ok := families[m.Name]
+		if !ok {
+			t.Errorf("family %s missing", m.Name)
+			continue
+		}
+		if f.typ != m.Type {
+			t.Errorf("%s has type %s, want %s", m.Name, f.typ, m.Type)
+		}
+	}
+	if len(families) != len(cbprom.Metrics) {
+		t.Errorf("got %d families, want %d", len(families), len(cbprom.Metrics))
+	}
+
+	name := map[string]string{"name": "payments"}
+	if got := value(t, families, "circuitbreaker_consecutive_failures", name); got != 2 {
+		t.Errorf("consecutive failures = %v, want 2", got)
+	}
+	for state, want := range map[string]float64{"closed": 1, "open": 0, "half_open": 0} {
+		labels := map[string]string{"name": "payments", "state": state}
+		if got := value(t, families, "circuitbreaker_state", labels); got != want {
+			t.Errorf("state %s = %v, want %v", state, got, want)
+		}
+	}
+
+	cb.Execute(failFn)
+	families = scrape(t, cbprom.Breakers(cb))
+	if got := value(t, families, "circuitbreaker_state", map[string]string{"name": "payments", "state": "open"}); got != 1 {
+		t.Errorf("open = %v after tripping, want 1", got)
+	}
+	want := float64(cbt.Epoch.UnixNano()) / 1e9
+	if got := value(t, families, "circuitbreaker_last_state_change_timestamp_seconds", name); got != want {
+		t.Errorf("last state change = %v, want %v", got, want)
+	}
+}
+
+func TestHandlerEscapesLabelValues(t *testing.T) {
+	odd := "a \"quoted\" \\ name\nwith a newline"
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: odd})
+
+	families := scrape(t, cbprom.Breakers(cb))
+	value(t, families, "circuitbreaker_degraded", map[string]string{"name": odd})
+}
+
+func TestHandlerSortsLabels(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name: "sorted",
+		FailureRateWindows: []circuitbreaker.RateWindow{
+			{Duration: 10 * time.Second, FailureRateThreshold: 0.5},
+			{Duration: time.Minute, FailureRateThreshold: 0.2},
+		},
+	})
+
+	families := scrape(t, cbprom.Breakers(cb))
+	for name, f := range families {
+		for _, s := range f.samples {
+			if !sort.StringsAreSorted(s.order) {
+				t.Errorf("%s labels out of order: %v", name, s.order)
+			}
+		}
+	}
+	if got := len(families["circuitbreaker_window_failure_rate"].samples); got != 2 {
+		t.Errorf("got %d window samples, want 2", got)
+	}
+}
+
+func TestHandlerRendersGroups(t *testing.T) {
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{Name: "hosts"})
+	defer g.Close()
+	g.Execute("b.example", successFn)
+	g.Execute("a.example", failFn)
+	solo := circuitbreaker.New(circuitbreaker.Config{Name: "solo"})
+
+	families := scrape(t, g, cbprom.Breakers(solo))
+	var names []string
+	for _, s := range families["circuitbreaker_failure_rate"].samples {
+		names = append(names, s.labels["name"])
+	}
+	want := []string{"hosts/a.example", "hosts/b.example", "solo"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("breakers = %v, want %v", names, want)
+	}
+}
+
+var errSimulated = errors.New("simulated failure")
+
+func successFn() (any, error) { return "ok", nil }
+func failFn() (any, error)    { return nil, errSimulated }
+
+func TestHandlerRendersLatencyByOutcome(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "payments",
+		FailureThreshold: 100,
+		LatencyBuckets:   []time.Duration{10 * time.Millisecond, time.Second},
+		Clock:            clock,
+		Strict:           true,
+	})
+	slow := func() (any, error) { clock.Advance(500 * time.Millisecond); return successFn() }
+	fast := func() (any, error) { clock.Advance(time.Millisecond); return failFn() }
+	cb.Execute(slow)
+	cb.Execute(slow)
+	cb.Execute(fast)
+
+	families := scrape(t, cbprom.Breakers(cb))
+	const name = "circuitbreaker_call_duration_seconds"
+	for _, c := range []struct {
+		outcome, le string
+		want        float64
+	}{
+		{"success", "0.01", 0}, {"success", "1", 2}, {"success", "+Inf", 2},
+		{"failure", "0.01", 1}, {"failure", "1", 1}, {"failure", "+Inf", 1},
+	} {
+		labels := map[string]string{"name": "payments", "outcome": c.outcome, "le": c.le}
+		if got := value(t, families, name+"_bucket", labels); got != c.want {
+			t.Errorf("%s bucket le=%s = %v, want %v", c.outcome, c.le, got, c.want)
+		}
+	}
+	success := map[string]string{"name": "payments", "outcome": "success"}
+	if got := value(t, families, name+"_sum", success); got != 1 {
+		t.Errorf("success sum = %v, want 1", got)
+	}
+	if got := value(t, families, name+"_count", success); got != 2 {
+		t.Errorf("success count = %v, want 2", got)
+	}
+	for _, c := range []struct {
+		outcome, quantile string
+		want              float64
+	}{
+		{"success", "0", 0.5}, {"success", "1", 0.5}, {"failure", "0.99", 0.001},
+	} {
+		labels := map[string]string{"name": "payments", "outcome": c.outcome, "quantile": c.quantile}
+		if got := value(t, families, "circuitbreaker_call_duration_quantile_seconds", labels); got != c.want {
+			t.Errorf("%s quantile %s = %v, want %v", c.outcome, c.quantile, got, c.want)
+		}
+	}
+}
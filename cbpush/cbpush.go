@@ -0,0 +1,309 @@
+// Package cbpush pushes circuit breaker metrics to a backend on an
+// interval, for processes such as batch jobs and functions that are gone
+// before a scraper would come by.
+//
+// A Reporter takes a Snapshot of its sources every interval and hands it
+// to a Pusher, retrying failed pushes with backoff, and pushes once more
+// when it is closed:
+//
+//	r := cbpush.NewReporter(cbpush.Pushgateway(gatewayURL, "nightly-import", nil),
+//		[]cbprom.Source{group})
+//	defer r.Close()
+//
+// Reporting only reads breaker statuses, so a slow or failing backend
+// never holds up calls.
+package cbpush
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbprom"
+)
+
+// Snapshot is the state of a set of breakers at one point in time.
+type Snapshot struct {
+	Time     time.Time
+	Statuses []circuitbreaker.Status
+}
+
+// Pusher delivers snapshots to a metrics backend.
+type Pusher interface {
+	Push(ctx context.Context, s Snapshot) error
+}
+
+// PushFunc adapts a function to a Pusher.
+type PushFunc func(ctx context.Context, s Snapshot) error
+
+// Push calls f.
+func (f PushFunc) Push(ctx context.Context, s Snapshot) error {
+	return f(ctx, s)
+}
+
+// Option configures NewReporter.
+type Option func(*Reporter)
+
+// WithInterval sets how often the Reporter pushes. The default is 10s.
+func WithInterval(d time.Duration) Option {
+	return func(r *Reporter) {
+		if d > 0 {
+			r.interval = d
+		}
+	}
+}
+
+// WithRetry sets how failed pushes are retried. The default makes three
+// attempts, one second apart at first and doubling from there.
+func WithRetry(policy circuitbreaker.RetryPolicy) Option {
+	return func(r *Reporter) {
+		r.retry = policy
+	}
+}
+
+// WithTimeout bounds each push attempt. The default is the interval.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Reporter) {
+		r.timeout = d
+	}
+}
+
+// WithClock sets the clock that schedules pushes and retries and stamps
+// snapshots.
+func WithClock(c circuitbreaker.Clock) Option {
+	return func(r *Reporter) {
+		if c != nil {
+			r.clock = c
+		}
+	}
+}
+
+// WithErrorHandler sets a function called with the error of each push
+// that failed every attempt. Without one such pushes are dropped silently.
+func WithErrorHandler(f func(error)) Option {
+	return func(r *Reporter) {
+		r.onError = f
+	}
+}
+
+// Reporter pushes snapshots of its sources in the background.
+type Reporter struct {
+	pusher   Pusher
+	sources  []cbprom.Source
+	interval time.Duration
+	timeout  time.Duration
+	retry    circuitbreaker.RetryPolicy
+	clock    circuitbreaker.Clock
+	onError  func(error)
+
+	// pushMu keeps pushes from overlapping, so the backend sees them in
+	// order.
+	pushMu sync.Mutex
+	// ctx is cancelled by Close to cut short a push in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewReporter starts a Reporter that pushes the statuses of sources to p.
+func NewReporter(p Pusher, sources []cbprom.Source, opts ...Option) *Reporter {
+	r := &Reporter{
+		pusher:   p,
+		sources:  sources,
+		interval: 10 * time.Second,
+		retry: circuitbreaker.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+		},
+		clock: systemClock{},
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	if r.timeout <= 0 {
+		r.timeout = r.interval
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	go r.run()
+	return r
+}
+
+// run pushes every interval until Close.
+func (r *Reporter) run() {
+	defer close(r.done)
+	for {
+		tick := make(chan struct{})
+		t := r.clock.AfterFunc(r.interval, func() { close(tick) })
+		select {
+		case <-tick:
+			if err := r.push(r.ctx); err != nil && r.ctx.Err() == nil && r.onError != nil {
+				r.onError(err)
+			}
+		case <-r.ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+// Flush pushes a snapshot now, retrying as configured, and returns the
+// error of the last attempt if none succeeded.
+func (r *Reporter) Flush(ctx context.Context) error {
+	return r.push(ctx)
+}
+
+// Close stops the Reporter, abandoning any push in progress, and pushes a
+// final snapshot. It returns that push's error. Later calls return the
+// same error.
+func (r *Reporter) Close() error {
+	r.closeOnce.Do(func() {
+		r.cancel()
+		<-r.done
+		r.closeErr = r.push(context.Background())
+	})
+	return r.closeErr
+}
+
+// snapshot reads the statuses of every source.
+func (r *Reporter) snapshot() Snapshot {
+	s := Snapshot{Time: r.clock.Now()}
+	for _, src := range r.sources {
+		s.Statuses = append(s.Statuses, src.Statuses()...)
+	}
+	return s
+}
+
+// push takes a snapshot and pushes it, retrying with backoff.
+func (r *Reporter) push(ctx context.Context) error {
+	r.pushMu.Lock()
+	defer r.pushMu.Unlock()
+	s := r.snapshot()
+
+	multiplier := r.retry.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	backoff := r.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		err := r.pusher.Push(attemptCtx, s)
+		cancel()
+		if err == nil || attempt >= r.retry.MaxAttempts {
+			return err
+		}
+		if werr := r.sleep(ctx, backoff); werr != nil {
+			return err
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if r.retry.MaxBackoff > 0 && backoff > r.retry.MaxBackoff {
+			backoff = r.retry.MaxBackoff
+		}
+	}
+}
+
+// sleep waits for d on the Reporter's clock, or until ctx is done.
+func (r *Reporter) sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	done := make(chan struct{})
+	t := r.clock.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
+
+// Pushgateway returns a Pusher that replaces the metrics of job on the
+// Prometheus Pushgateway at baseURL with each snapshot, in the same
+// families cbprom serves. A nil client means http.DefaultClient.
+func Pushgateway(baseURL, job string, client *http.Client) Pusher {
+	target := strings.TrimSuffix(baseURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	return PushFunc(func(ctx context.Context, s Snapshot) error {
+		var body bytes.Buffer
+		if err := cbprom.Write(&body, s.Statuses); err != nil {
+			return err
+		}
+		return send(ctx, client, http.MethodPut, target, cbprom.ContentType, &body)
+	})
+}
+
+// JSON returns a Pusher that POSTs each snapshot to target as a JSON object
+// with the snapshot's "time" and a "breakers" array of statuses. Status
+// fields keep their Go names, states are strings such as "Open", and
+// durations are in nanoseconds. A nil client means http.DefaultClient.
+func JSON(target string, client *http.Client) Pusher {
+	return PushFunc(func(ctx context.Context, s Snapshot) error {
+		doc := jsonSnapshot{Time: s.Time, Breakers: make([]jsonStatus, len(s.Statuses))}
+		for i, st := range s.Statuses {
+			doc.Breakers[i] = jsonStatus{Status: st, State: st.State.String()}
+		}
+		body, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return send(ctx, client, http.MethodPost, target, "application/json", bytes.NewReader(body))
+	})
+}
+
+type jsonSnapshot struct {
+	Time     time.Time    `json:"time"`
+	Breakers []jsonStatus `json:"breakers"`
+}
+
+// jsonStatus renders the state by name in place of the embedded number.
+type jsonStatus struct {
+	circuitbreaker.Status
+	State string
+}
+
+// send makes one request and turns a non-2xx response into an error.
+func send(ctx context.Context, client *http.Client, method, target, contentType string, body io.Reader) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cbpush: %s %s: %s", method, target, resp.Status)
+	}
+	return nil
+}
+
+// systemClock is the Clock used without WithClock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) circuitbreaker.Timer {
+	return time.AfterFunc(d, f)
+}
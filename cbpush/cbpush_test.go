@@ -0,0 +1,227 @@
+package cbpush_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbprom"
+	"github.com/teresamychu/circuitbreaker/cbpush"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+var errBackend = errors.New("backend unavailable")
+
+// target is a fake backend that records snapshots and fails the first
+// failures pushes.
+type target struct {
+	mu        sync.Mutex
+	failures  int
+	attempts  int
+	snapshots []cbpush.Snapshot
+}
+
+func (tg *target) Push(_ context.Context, s cbpush.Snapshot) error {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	tg.attempts++
+	if tg.failures > 0 {
+		tg.failures--
+		return errBackend
+	}
+	tg.snapshots = append(tg.snapshots, s)
+	return nil
+}
+
+func (tg *target) state() (attempts int, snapshots []cbpush.Snapshot) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	return tg.attempts, append([]cbpush.Snapshot(nil), tg.snapshots...)
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newBreaker(clock *cbt.FakeClock) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{Name: "billing", FailureThreshold: 1, Clock: clock, Strict: true})
+}
+
+func TestReporter_PushesEveryInterval(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newBreaker(clock)
+	tg := &target{}
+	r := cbpush.NewReporter(tg, []cbprom.Source{cbprom.Breakers(cb)},
+		cbpush.WithInterval(10*time.Second), cbpush.WithClock(clock))
+	defer r.Close()
+
+	for i := 1; i <= 3; i++ {
+		waitFor(t, "the interval timer", func() bool { return clock.PendingTimers() == 1 })
+		clock.Advance(10 * time.Second)
+		waitFor(t, "a push", func() bool { _, s := tg.state(); return len(s) == i })
+	}
+	_, snapshots := tg.state()
+	for i, s := range snapshots {
+		if want := cbt.Epoch.Add(time.Duration(i+1) * 10 * time.Second); !s.Time.Equal(want) {
+			t.Errorf("snapshot %d taken at %v, want %v", i, s.Time, want)
+		}
+		if len(s.Statuses) != 1 || s.Statuses[0].Name != "billing" {
+			t.Errorf("snapshot %d has statuses %+v", i, s.Statuses)
+		}
+	}
+}
+
+func TestReporter_FlushesOnClose(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newBreaker(clock)
+	tg := &target{}
+	r := cbpush.NewReporter(tg, []cbprom.Source{cbprom.Breakers(cb)},
+		cbpush.WithInterval(time.Hour), cbpush.WithClock(clock))
+
+	cb.Execute(func() (any, error) { return nil, errBackend })
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, snapshots := tg.state()
+	if len(snapshots) != 1 {
+		t.Fatalf("expected exactly the final push, got %d", len(snapshots))
+	}
+	if s := snapshots[0].Statuses[0].State; s != circuitbreaker.Open {
+		t.Errorf("expected the final push to see the trip, got %v", s)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("expected a second Close to return the same result, got %v", err)
+	}
+	if _, again := tg.state(); len(again) != 1 {
+		t.Errorf("expected a second Close not to push again")
+	}
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected no pending timers after Close, got %d", n)
+	}
+}
+
+func TestReporter_RetriesWithBackoff(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	tg := &target{failures: 2}
+	var handled []error
+	r := cbpush.NewReporter(tg, nil,
+		cbpush.WithInterval(time.Minute),
+		cbpush.WithClock(clock),
+		cbpush.WithRetry(circuitbreaker.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}),
+		cbpush.WithErrorHandler(func(err error) { handled = append(handled, err) }))
+	defer r.Close()
+
+	waitFor(t, "the interval timer", func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Minute)
+	// the first attempt fails and the second waits one second, the third
+	// two.
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		waitFor(t, "a backoff timer", func() bool { a, _ := tg.state(); return a == i+1 && clock.PendingTimers() == 1 })
+		clock.Advance(backoff - time.Nanosecond)
+		if a, _ := tg.state(); a != i+1 {
+			t.Fatalf("retried before the %v backoff was up", backoff)
+		}
+		clock.Advance(time.Nanosecond)
+	}
+	waitFor(t, "the retried push", func() bool { _, s := tg.state(); return len(s) == 1 })
+	waitFor(t, "the next interval", func() bool { return clock.PendingTimers() == 1 })
+	if len(handled) != 0 {
+		t.Errorf("expected a push that succeeded on retry not to be reported, got %v", handled)
+	}
+}
+
+func TestReporter_ReportsPushesThatExhaustRetries(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	tg := &target{failures: 100}
+	errs := make(chan error, 1)
+	r := cbpush.NewReporter(tg, nil,
+		cbpush.WithInterval(time.Minute),
+		cbpush.WithClock(clock),
+		cbpush.WithRetry(circuitbreaker.RetryPolicy{MaxAttempts: 2}),
+		cbpush.WithErrorHandler(func(err error) { errs <- err }))
+
+	waitFor(t, "the interval timer", func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Minute)
+	if err := <-errs; !errors.Is(err, errBackend) {
+		t.Errorf("expected the backend error, got %v", err)
+	}
+	if a, _ := tg.state(); a != 2 {
+		t.Errorf("expected 2 attempts, got %d", a)
+	}
+	if err := r.Close(); !errors.Is(err, errBackend) {
+		t.Errorf("expected Close to report the failed final push, got %v", err)
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	var method, path, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, contentType, body = r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type"), string(b)
+	}))
+	defer srv.Close()
+
+	cb := newBreaker(cbt.NewFakeClock(cbt.Epoch))
+	p := cbpush.Pushgateway(srv.URL+"/", "nightly import", nil)
+	if err := p.Push(context.Background(), cbpush.Snapshot{Statuses: []circuitbreaker.Status{cb.Status()}}); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/nightly%20import" || contentType != cbprom.ContentType {
+		t.Errorf("unexpected request %s %s (%s)", method, path, contentType)
+	}
+	if !strings.Contains(body, `circuitbreaker_state{name="billing",state="closed"} 1`) {
+		t.Errorf("expected breaker metrics in the body, got:\n%s", body)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var got struct {
+		Time     time.Time
+		Breakers []map[string]any
+	}
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s (%s)", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newBreaker(clock)
+	cb.Execute(func() (any, error) { return nil, errBackend })
+	p := cbpush.JSON(srv.URL, nil)
+	s := cbpush.Snapshot{Time: clock.Now(), Statuses: []circuitbreaker.Status{cb.Status()}}
+	if err := p.Push(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Time.Equal(cbt.Epoch) || len(got.Breakers) != 1 {
+		t.Fatalf("unexpected document %+v", got)
+	}
+	if b := got.Breakers[0]; b["Name"] != "billing" || b["State"] != "Open" {
+		t.Errorf("unexpected breaker %v", b)
+	}
+
+	status = http.StatusBadGateway
+	if err := p.Push(context.Background(), s); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("expected a non-2xx response to fail the push, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Package cbredis shares circuit breaker trips between processes through
+// Redis, so that a fleet can open together once enough of its instances
+// have tripped on their own; see circuitbreaker.Config.QuorumStore.
+//
+// Each breaker is a sorted set whose members are instance IDs scored by
+// when their breach expires. Expired members are dropped as breaches are
+// reported, and the key itself expires with its last breach.
+//
+// It is a separate module so that the circuitbreaker package itself does
+// not depend on a Redis client.
+package cbredis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// DefaultPrefix is prepended to breaker names to form keys unless
+// WithPrefix says otherwise.
+const DefaultPrefix = "circuitbreaker:quorum:"
+
+// Option configures a QuorumStore.
+type Option func(*QuorumStore)
+
+// WithPrefix sets the prefix of the store's keys.
+func WithPrefix(prefix string) Option {
+	return func(s *QuorumStore) {
+		s.prefix = prefix
+	}
+}
+
+// WithClock sets the clock breaches are timed by. It should be the same
+// on every instance, so within clock skew of the system clock in
+// production; tests can pass the breakers' fake clock.
+func WithClock(clock circuitbreaker.Clock) Option {
+	return func(s *QuorumStore) {
+		s.now = clock.Now
+	}
+}
+
+// QuorumStore is a circuitbreaker.QuorumStore backed by Redis. It is safe
+// for concurrent use.
+type QuorumStore struct {
+	client redis.UniversalClient
+	prefix string
+	now    func() time.Time
+}
+
+var _ circuitbreaker.QuorumStore = (*QuorumStore)(nil)
+
+// NewQuorumStore returns a store that keeps breaches in client.
+func NewQuorumStore(client redis.UniversalClient, opts ...Option) *QuorumStore {
+	s := &QuorumStore{client: client, prefix: DefaultPrefix, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// reportBreach keeps the later of the instance's current and new expiry,
+// drops expired breaches and lets the key expire with the last of them.
+//
+// KEYS[1] is the breaker's key; ARGV is the instance, the new expiry and
+// now, both in Unix milliseconds.
+var reportBreach = redis.NewScript(`
+local expires = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local current = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not current or tonumber(current) < expires then
+	redis.call('ZADD', KEYS[1], expires, ARGV[1])
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if last[2] then
+	redis.call('PEXPIRE', KEYS[1], math.max(1, tonumber(last[2]) - now))
+end
+return 0
+`)
+
+// ReportBreach implements circuitbreaker.QuorumStore.
+func (s *QuorumStore) ReportBreach(ctx context.Context, breaker, instance string, ttl time.Duration) error {
+	now := s.now()
+	return reportBreach.Run(ctx, s.client, []string{s.prefix + breaker},
+		instance, now.Add(ttl).UnixMilli(), now.UnixMilli()).Err()
+}
+
+// Breaches implements circuitbreaker.QuorumStore.
+func (s *QuorumStore) Breaches(ctx context.Context, breaker string) (int, error) {
+	now := strconv.FormatInt(s.now().UnixMilli(), 10)
+	n, err := s.client.ZCount(ctx, s.prefix+breaker, "("+now, "+inf").Result()
+	return int(n), err
+}
@@ -0,0 +1,99 @@
+package cbredis_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbredis"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+var errFailed = errors.New("failed")
+
+func failFn() (any, error) { return nil, errFailed }
+
+// fleet returns n breakers sharing a store in mr, each its own instance.
+func fleet(t *testing.T, mr *miniredis.Miniredis, clock *cbt.FakeClock, n int) []*circuitbreaker.CircuitBreaker {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := cbredis.NewQuorumStore(client, cbredis.WithClock(clock))
+	var breakers []*circuitbreaker.CircuitBreaker
+	for i := 0; i < n; i++ {
+		cb := circuitbreaker.New(circuitbreaker.Config{
+			Name:             "inventory",
+			FailureThreshold: 1,
+			Timeout:          10 * time.Second,
+			QuorumStore:      store,
+			Quorum:           2,
+			QuorumWindow:     time.Minute,
+			QuorumInterval:   5 * time.Second,
+			InstanceID:       string(rune('a' + i)),
+			Clock:            clock,
+			Strict:           true,
+		})
+		t.Cleanup(func() { cb.Close() })
+		breakers = append(breakers, cb)
+	}
+	return breakers
+}
+
+func TestQuorumStore_OpensAtQuorum(t *testing.T) {
+	mr := miniredis.RunT(t)
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cbs := fleet(t, mr, clock, 3)
+
+	cbs[0].Execute(failFn)
+	cbs[0].Execute(failFn)
+	clock.Advance(5 * time.Second)
+	if cbs[2].State() != circuitbreaker.Closed || cbs[2].Status().QuorumBreaches != 1 {
+		t.Fatalf("expected one breach below the quorum, got %v with %d", cbs[2].State(), cbs[2].Status().QuorumBreaches)
+	}
+
+	cbs[1].Execute(failFn)
+	clock.Advance(5 * time.Second)
+	if cbs[2].State() != circuitbreaker.Open {
+		t.Errorf("expected the quorum of two to open the third instance, got %v", cbs[2].State())
+	}
+	if ttl := mr.TTL(cbredis.DefaultPrefix + "inventory"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected the key to expire with its last breach, got ttl %v", ttl)
+	}
+}
+
+func TestQuorumStore_BreachesExpire(t *testing.T) {
+	mr := miniredis.RunT(t)
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cbs := fleet(t, mr, clock, 3)
+
+	cbs[0].Execute(failFn)
+	clock.Advance(5 * time.Second)
+	// the first breach has run out by the time the second is reported.
+	clock.Advance(time.Minute)
+	cbs[1].Execute(failFn)
+	clock.Advance(5 * time.Second)
+	if cbs[2].State() != circuitbreaker.Closed || cbs[2].Status().QuorumBreaches != 1 {
+		t.Errorf("expected the expired breach not to count, got %v with %d", cbs[2].State(), cbs[2].Status().QuorumBreaches)
+	}
+	if n, _ := mr.ZMembers(cbredis.DefaultPrefix + "inventory"); len(n) != 1 {
+		t.Errorf("expected the expired member dropped, got %v", n)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if mr.Exists(cbredis.DefaultPrefix + "inventory") {
+		t.Error("expected the key to expire with its last breach")
+	}
+}
+
+func TestQuorumStore_Conformance(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	cbt.RunQuorumStoreConformance(t, func(clock circuitbreaker.Clock) circuitbreaker.QuorumStore {
+		mr.FlushAll()
+		return cbredis.NewQuorumStore(client, cbredis.WithClock(clock))
+	})
+}
@@ -0,0 +1,157 @@
+// Package cbrpc protects net/rpc clients, including JSON-RPC clients from
+// net/rpc/jsonrpc, with circuit breakers.
+//
+// A Client keeps one breaker per service method in a circuitbreaker.Group,
+// so a failing "Inventory.Reserve" does not open the circuit for
+// "Inventory.Get" on the same connection:
+//
+//	client, err := cbrpc.DialJSON("tcp", addr, group)
+//	err = client.Call("Inventory.Get", args, &reply)
+//
+// Transport errors and rpc.ErrShutdown count as failures. Errors returned
+// by the server's method, rpc.ServerError, do not unless
+// WithServerErrors says otherwise.
+package cbrpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithKeyFunc picks the breaker for a call. The default key is the service
+// method, such as "Inventory.Get".
+func WithKeyFunc(key func(serviceMethod string) string) Option {
+	return func(c *Client) {
+		c.key = key
+	}
+}
+
+// WithServerErrors sets whether errors returned by the server's method
+// count as failures. They do not by default, since they usually answer a
+// bad request rather than point at an unhealthy server.
+func WithServerErrors(count bool) Option {
+	return func(c *Client) {
+		c.serverErrors = count
+	}
+}
+
+// RejectedError is returned for a call that a breaker rejected without
+// sending it. errors.Is matches the breaker's own error, such as
+// circuitbreaker.ErrCircuitOpen.
+type RejectedError struct {
+	// Breaker is the name of the breaker that rejected the call.
+	Breaker       string
+	ServiceMethod string
+	Err           error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("circuit breaker %q rejected %s: %v", e.Breaker, e.ServiceMethod, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error { return e.Err }
+
+// Client runs the calls of an *rpc.Client through the breakers of a
+// circuitbreaker.Group. It is safe for concurrent use.
+type Client struct {
+	client       *rpc.Client
+	group        *circuitbreaker.Group
+	key          func(serviceMethod string) string
+	serverErrors bool
+}
+
+// NewClient returns a Client that sends calls with client through the
+// breaker g keeps for each call's key.
+func NewClient(client *rpc.Client, g *circuitbreaker.Group, opts ...Option) *Client {
+	c := &Client{client: client, group: g, key: func(serviceMethod string) string { return serviceMethod }}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+// NewJSONClient returns a Client that speaks JSON-RPC over conn.
+func NewJSONClient(conn io.ReadWriteCloser, g *circuitbreaker.Group, opts ...Option) *Client {
+	return NewClient(jsonrpc.NewClient(conn), g, opts...)
+}
+
+// DialJSON connects to a JSON-RPC server at address on network. Failing
+// to connect is not counted against any breaker.
+func DialJSON(network, address string, g *circuitbreaker.Group, opts ...Option) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONClient(conn, g, opts...), nil
+}
+
+// Call invokes serviceMethod through its breaker and waits for it to
+// complete. A rejected call is not sent and returns a *RejectedError.
+func (c *Client) Call(serviceMethod string, args, reply any) error {
+	key := c.key(serviceMethod)
+	ran := false
+	var callErr error
+	_, err := c.group.Execute(key, func() (any, error) {
+		ran = true
+		callErr = c.client.Call(serviceMethod, args, reply)
+		if c.failure(callErr) {
+			return nil, callErr
+		}
+		return nil, nil
+	})
+	if !ran {
+		return &RejectedError{Breaker: c.group.Breaker(key).Status().Name, ServiceMethod: serviceMethod, Err: err}
+	}
+	return callErr
+}
+
+// Go invokes serviceMethod through its breaker asynchronously, like
+// rpc.Client.Go: the returned call is sent on done once it completes, with
+// a *RejectedError if its breaker turned it away, so select loops over
+// Done work unchanged. If done is nil a new channel is allocated; it must
+// otherwise be buffered. The call is made from its own goroutine.
+func (c *Client) Go(serviceMethod string, args, reply any, done chan *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 10)
+	} else if cap(done) == 0 {
+		panic("cbrpc: done channel is unbuffered")
+	}
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: done}
+	go func() {
+		call.Error = c.Call(serviceMethod, args, reply)
+		// like rpc.Client, never block on a full done channel.
+		select {
+		case call.Done <- call:
+		default:
+		}
+	}()
+	return call
+}
+
+// Close closes the underlying rpc.Client.
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// failure reports whether err counts against the breaker.
+func (c *Client) failure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var serverErr rpc.ServerError
+	if errors.As(err, &serverErr) {
+		return c.serverErrors
+	}
+	return true
+}
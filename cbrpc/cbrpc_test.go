@@ -0,0 +1,183 @@
+package cbrpc_test
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbrpc"
+)
+
+// Inventory is an rpc service whose methods fail while marked down.
+type Inventory struct {
+	mu   sync.Mutex
+	down map[string]bool
+	// Calls that reached the server.
+	calls atomic.Int32
+}
+
+func (s *Inventory) setDown(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down[method] = true
+}
+
+func (s *Inventory) fail(method string) error {
+	s.calls.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down[method] {
+		return errors.New("inventory: " + method + " unavailable")
+	}
+	return nil
+}
+
+func (s *Inventory) Get(sku string, reply *int) error {
+	*reply = len(sku)
+	return s.fail("Get")
+}
+
+func (s *Inventory) Reserve(sku string, reply *int) error {
+	return s.fail("Reserve")
+}
+
+// serve starts an rpc server for inv, speaking JSON-RPC if json is set,
+// and returns its address and a function that drops every connection.
+func serve(t *testing.T, inv *Inventory, json bool) (addr string, drop func()) {
+	t.Helper()
+	srv := rpc.NewServer()
+	if err := srv.Register(inv); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			if json {
+				go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+			} else {
+				go srv.ServeConn(conn)
+			}
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String(), func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+func newGroup() *circuitbreaker.Group {
+	return circuitbreaker.NewGroup(circuitbreaker.Config{Name: "inventory", FailureThreshold: 2, Strict: true})
+}
+
+func TestClient_ServerErrorsAreConfigurable(t *testing.T) {
+	inv := &Inventory{down: map[string]bool{}}
+	addr, _ := serve(t, inv, false)
+	inv.setDown("Reserve")
+
+	for _, count := range []bool{false, true} {
+		rc, err := rpc.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := newGroup()
+		client := cbrpc.NewClient(rc, group, cbrpc.WithServerErrors(count))
+		var reply int
+		for i := 0; i < 3; i++ {
+			client.Call("Inventory.Reserve", "sku-1", &reply)
+		}
+		if got := group.Breaker("Inventory.Reserve").State(); (got == circuitbreaker.Open) != count {
+			t.Errorf("WithServerErrors(%v): unexpected state %v", count, got)
+		}
+		// the other method has its own breaker.
+		if err := client.Call("Inventory.Get", "sku-1", &reply); err != nil || reply != 5 {
+			t.Errorf("WithServerErrors(%v): expected Get unaffected, got %v, %d", count, err, reply)
+		}
+		client.Close()
+	}
+}
+
+func TestClient_TransportFailuresTripAndGoDeliversRejections(t *testing.T) {
+	inv := &Inventory{down: map[string]bool{}}
+	addr, drop := serve(t, inv, false)
+	rc, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := newGroup()
+	client := cbrpc.NewClient(rc, group)
+	defer client.Close()
+
+	var reply int
+	if err := client.Call("Inventory.Get", "sku", &reply); err != nil {
+		t.Fatalf("expected the first call through, got %v", err)
+	}
+	drop()
+	for i := 0; i < 2; i++ {
+		if err := client.Call("Inventory.Get", "sku", &reply); err == nil {
+			t.Fatalf("call %d: expected a transport error", i)
+		}
+	}
+	if group.Breaker("Inventory.Get").State() != circuitbreaker.Open {
+		t.Fatalf("expected transport failures and rpc.ErrShutdown to trip the breaker")
+	}
+
+	before := inv.calls.Load()
+	done := make(chan *rpc.Call, 1)
+	call := client.Go("Inventory.Get", "sku", &reply, done)
+	got := <-done
+	var rejected *cbrpc.RejectedError
+	if got != call || !errors.As(got.Error, &rejected) || !errors.Is(got.Error, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the rejected call delivered on done, got %v", got.Error)
+	}
+	if rejected.ServiceMethod != "Inventory.Get" || rejected.Breaker != "inventory/Inventory.Get" {
+		t.Errorf("unexpected rejection %+v", rejected)
+	}
+	if inv.calls.Load() != before {
+		t.Error("expected the rejected call not to be sent")
+	}
+}
+
+func TestJSONClient(t *testing.T) {
+	inv := &Inventory{down: map[string]bool{}}
+	addr, _ := serve(t, inv, true)
+	group := newGroup()
+	client, err := cbrpc.DialJSON("tcp", addr, group, cbrpc.WithServerErrors(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var reply int
+	call := <-client.Go("Inventory.Get", "abc", &reply, nil).Done
+	if call.Error != nil || reply != 3 {
+		t.Fatalf("expected a JSON-RPC reply, got %v, %d", call.Error, reply)
+	}
+	inv.setDown("Get")
+	for i := 0; i < 2; i++ {
+		client.Call("Inventory.Get", "abc", &reply)
+	}
+	if err := client.Call("Inventory.Get", "abc", &reply); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the breaker open after server errors, got %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+// Package cbsql puts circuit breakers in front of a database/sql driver.
+//
+// NewConnector wraps a driver.Connector so that connecting, preparing,
+// executing, querying, beginning transactions and pinging run through a
+// breaker. Each call uses the breaker a circuitbreaker.Group keeps for the
+// key set on its context with circuitbreaker.WithKey, or for the default
+// key, so one *sql.DB can keep separate circuits for separate classes of
+// query:
+//
+//	db := sql.OpenDB(cbsql.NewConnector(connector, group))
+//	rows, err := db.QueryContext(circuitbreaker.WithKey(ctx, "analytics"), report)
+//
+// Committing and rolling back transactions and reading rows are never
+// rejected, so work that has started can finish. Errors that show the
+// database answering, such as constraint violations, do not count as
+// failures; see IsFailure.
+package cbsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// DefaultKey is the key used for calls whose context has none.
+const DefaultKey = "default"
+
+// Option configures NewConnector.
+type Option func(*connector)
+
+// WithIsFailure sets which errors count as failures. Other errors are
+// returned to the caller without counting against the breaker. The
+// default is IsFailure.
+func WithIsFailure(isFailure func(err error) bool) Option {
+	return func(c *connector) {
+		c.isFailure = isFailure
+	}
+}
+
+// IsFailure is the default for WithIsFailure. Every error is a failure
+// except those that show the database is up and answering: sql.ErrNoRows,
+// sql.ErrTxDone, and errors with an SQLSTATE of class 22 (data exception),
+// 23 (integrity constraint violation, such as a duplicate key) or 42
+// (syntax error or access rule violation). The SQLSTATE is read from
+// errors with an SQLState method, as those of pgx and lib/pq have.
+func IsFailure(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, sql.ErrTxDone) {
+		return false
+	}
+	var coded interface{ SQLState() string }
+	if errors.As(err, &coded) {
+		switch state := coded.SQLState(); {
+		case len(state) != 5:
+		case state[:2] == "22", state[:2] == "23", state[:2] == "42":
+			return false
+		}
+	}
+	return true
+}
+
+// WithDefaultKey sets the key used for calls whose context has none.
+func WithDefaultKey(key string) Option {
+	return func(c *connector) {
+		c.defaultKey = key
+	}
+}
+
+// NewConnector returns a connector that runs calls to base through the
+// breakers in g.
+func NewConnector(base driver.Connector, g *circuitbreaker.Group, opts ...Option) driver.Connector {
+	c := &connector{base: base, group: g, defaultKey: DefaultKey, isFailure: IsFailure}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return c
+}
+
+type connector struct {
+	base       driver.Connector
+	group      *circuitbreaker.Group
+	defaultKey string
+	isFailure  func(err error) bool
+}
+
+// execute runs fn through the breaker for ctx's key. driver.ErrSkip asks
+// database/sql to take another path and is not a failure, and neither is
+// an error isFailure turns down.
+func (c *connector) execute(ctx context.Context, fn func() error) error {
+	key, ok := circuitbreaker.KeyFromContext(ctx)
+	if !ok {
+		key = c.defaultKey
+	}
+	var answered error
+	_, err := c.group.Execute(key, func() (any, error) {
+		err := fn()
+		if errors.Is(err, driver.ErrSkip) || err != nil && c.isFailure != nil && !c.isFailure(err) {
+			answered = err
+			return nil, nil
+		}
+		return nil, err
+	})
+	if answered != nil {
+		return answered
+	}
+	return err
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+	err := c.execute(ctx, func() error {
+		var err error
+		conn, err = c.base.Connect(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, c: c}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return c.base.Driver() }
+
+// wrappedConn guards a connection's calls. Optional interfaces the base
+// connection lacks are answered with driver.ErrSkip so database/sql falls
+// back to its usual path.
+type wrappedConn struct {
+	driver.Conn
+	c *connector
+}
+
+func (w *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	err := w.c.execute(ctx, func() error {
+		var err error
+		if p, ok := w.Conn.(driver.ConnPrepareContext); ok {
+			stmt, err = p.PrepareContext(ctx, query)
+		} else {
+			stmt, err = w.Conn.Prepare(query)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedStmt{Stmt: stmt, c: w.c}, nil
+}
+
+func (w *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return w.PrepareContext(context.Background(), query)
+}
+
+func (w *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	err := w.c.execute(ctx, func() error {
+		var err error
+		if b, ok := w.Conn.(driver.ConnBeginTx); ok {
+			tx, err = b.BeginTx(ctx, opts)
+		} else {
+			tx, err = w.Conn.Begin()
+		}
+		return err
+	})
+	return tx, err
+}
+
+func (w *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := w.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var res driver.Result
+	err := w.c.execute(ctx, func() error {
+		var err error
+		res, err = e.ExecContext(ctx, query, args)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := w.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var rows driver.Rows
+	err := w.c.execute(ctx, func() error {
+		var err error
+		rows, err = q.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+func (w *wrappedConn) Ping(ctx context.Context) error {
+	p, ok := w.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	return w.c.execute(ctx, func() error { return p.Ping(ctx) })
+}
+
+func (w *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := w.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (w *wrappedConn) IsValid() bool {
+	if v, ok := w.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (w *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if c, ok := w.Conn.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// wrappedStmt guards a prepared statement's calls.
+type wrappedStmt struct {
+	driver.Stmt
+	c *connector
+}
+
+func (w *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+	err := w.c.execute(ctx, func() error {
+		var err error
+		if e, ok := w.Stmt.(driver.StmtExecContext); ok {
+			res, err = e.ExecContext(ctx, args)
+			return err
+		}
+		values, err := valuesOf(args)
+		if err != nil {
+			return err
+		}
+		res, err = w.Stmt.Exec(values)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := w.c.execute(ctx, func() error {
+		var err error
+		if q, ok := w.Stmt.(driver.StmtQueryContext); ok {
+			rows, err = q.QueryContext(ctx, args)
+			return err
+		}
+		values, err := valuesOf(args)
+		if err != nil {
+			return err
+		}
+		rows, err = w.Stmt.Query(values)
+		return err
+	})
+	return rows, err
+}
+
+// valuesOf converts arguments for drivers without context support, which
+// cannot take named parameters.
+func valuesOf(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, errors.New("cbsql: driver does not support named parameters")
+		}
+		values[i] = a.Value
+	}
+	return values, nil
+}
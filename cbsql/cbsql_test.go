@@ -0,0 +1,236 @@
+package cbsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cbsql"
+)
+
+var errDown = errors.New("fake: server unavailable")
+
+// errDuplicate is a unique violation as a Postgres driver reports it.
+var errDuplicate = sqlStateError("23505")
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "fake: SQLSTATE " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// fakeDB is an in-memory driver whose statements fail while their query
+// starts with a prefix marked down.
+type fakeDB struct {
+	mu   sync.Mutex
+	down map[string]bool
+	// Statements that reached the driver.
+	executed atomic.Int32
+}
+
+func (db *fakeDB) setDown(prefix string, down bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.down == nil {
+		db.down = map[string]bool{}
+	}
+	db.down[prefix] = down
+}
+
+func (db *fakeDB) run(query string) error {
+	db.executed.Add(1)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if strings.HasPrefix(query, "INSERT duplicate") {
+		return errDuplicate
+	}
+	for prefix, down := range db.down {
+		if down && strings.HasPrefix(query, prefix) {
+			return errDown
+		}
+	}
+	return nil
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake: not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("fake: not supported") }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.run(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.run(query); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"n"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func open(t *testing.T, fake *fakeDB) (*sql.DB, *circuitbreaker.Group) {
+	t.Helper()
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{Name: "db", FailureThreshold: 3, Strict: true})
+	db := sql.OpenDB(cbsql.NewConnector(fake, g))
+	t.Cleanup(func() {
+		db.Close()
+		g.Close()
+	})
+	return db, g
+}
+
+func query(ctx context.Context, db *sql.DB, q string) error {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func TestConnector_RoutesByContextKey(t *testing.T) {
+	fake := &fakeDB{}
+	db, g := open(t, fake)
+	ctx := context.Background()
+
+	if err := query(circuitbreaker.WithKey(ctx, "analytics"), db, "SELECT report"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(circuitbreaker.WithKey(ctx, "oltp"), "UPDATE orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := query(ctx, db, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := g.Keys()
+	want := []string{"analytics", cbsql.DefaultKey, "oltp"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	names := map[string]bool{}
+	for _, s := range g.Statuses() {
+		names[s.Name] = true
+	}
+	if !names["db/analytics"] || !names["db/oltp"] {
+		t.Errorf("expected per-key statuses, got %v", names)
+	}
+}
+
+func TestConnector_TrippingOneKeyLeavesOthersFlowing(t *testing.T) {
+	fake := &fakeDB{}
+	db, g := open(t, fake)
+	analytics := circuitbreaker.WithKey(context.Background(), "analytics")
+	oltp := circuitbreaker.WithKey(context.Background(), "oltp")
+	fake.setDown("SELECT report", true)
+
+	for i := 0; i < 3; i++ {
+		if err := query(analytics, db, "SELECT report"); !errors.Is(err, errDown) {
+			t.Fatalf("expected the driver's error, got %v", err)
+		}
+	}
+	before := fake.executed.Load()
+	if err := query(analytics, db, "SELECT report"); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen for analytics, got %v", err)
+	}
+	if fake.executed.Load() != before {
+		t.Error("the rejected statement reached the driver")
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := db.ExecContext(oltp, "INSERT INTO orders"); err != nil {
+			t.Fatalf("oltp should keep flowing, got %v", err)
+		}
+	}
+	if s := g.Breaker("analytics").State(); s != circuitbreaker.Open {
+		t.Errorf("expected analytics Open, got %v", s)
+	}
+	if s := g.Breaker("oltp").State(); s != circuitbreaker.Closed {
+		t.Errorf("expected oltp Closed, got %v", s)
+	}
+}
+
+func TestConnector_DefaultKeyOption(t *testing.T) {
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{Strict: true})
+	defer g.Close()
+	db := sql.OpenDB(cbsql.NewConnector(&fakeDB{}, g, cbsql.WithDefaultKey("primary")))
+	defer db.Close()
+
+	if err := query(context.Background(), db, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := g.Keys(); len(keys) != 1 || keys[0] != "primary" {
+		t.Errorf("expected only the primary key, got %v", keys)
+	}
+}
+
+func TestConnector_ConstraintViolationsAreNotFailures(t *testing.T) {
+	db, g := open(t, &fakeDB{})
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := db.ExecContext(ctx, "INSERT duplicate"); !errors.Is(err, errDuplicate) {
+			t.Fatalf("expected the driver's error, got %v", err)
+		}
+	}
+	if s := g.Breaker(cbsql.DefaultKey).State(); s != circuitbreaker.Closed {
+		t.Errorf("expected constraint violations to leave the circuit closed, got %v", s)
+	}
+}
+
+func TestConnector_WithIsFailure(t *testing.T) {
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{FailureThreshold: 3, Strict: true})
+	defer g.Close()
+	everything := func(error) bool { return true }
+	db := sql.OpenDB(cbsql.NewConnector(&fakeDB{}, g, cbsql.WithIsFailure(everything)))
+	defer db.Close()
+
+	for i := 0; i < 3; i++ {
+		db.ExecContext(context.Background(), "INSERT duplicate")
+	}
+	if s := g.Breaker(cbsql.DefaultKey).State(); s != circuitbreaker.Open {
+		t.Errorf("expected every error to count, got %v", s)
+	}
+}
+
+func TestIsFailure(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{errDown, true},
+		{sql.ErrNoRows, false},
+		{fmt.Errorf("scan: %w", sql.ErrNoRows), false},
+		{sql.ErrTxDone, false},
+		{sqlStateError("23505"), false},
+		{sqlStateError("42P01"), false},
+		{sqlStateError("22001"), false},
+		{sqlStateError("08006"), true},
+		{sqlStateError("57P01"), true},
+	} {
+		if got := cbsql.IsFailure(tt.err); got != tt.want {
+			t.Errorf("IsFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFailedChecks wraps the error of the Config.PreRequestChecks check
+// that turned a call away, so errors.Is matches it while errors.As still
+// reaches the check's own error.
+var ErrFailedChecks = errors.New("failed pre-request checks")
+
+// runChecks runs Config.PreRequestChecks until one fails. A check that
+// panics fails. Must be called without cb.mu held.
+func (cb *CircuitBreaker) runChecks() error {
+	for i, check := range cb.config.PreRequestChecks {
+		if check == nil {
+			continue
+		}
+		var err error
+		if !cb.protect("PreRequestChecks", func() { err = check(cb) }) {
+			return fmt.Errorf("%w: check %d panicked", ErrFailedChecks, i)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrFailedChecks, err)
+		}
+	}
+	return nil
+}
+
+// DependencyOpenError is returned by a DependsOn check when a breaker the
+// call depends on is open.
+type DependencyOpenError struct {
+	// Name is the name of the open breaker.
+	Name string
+}
+
+func (e *DependencyOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker: dependency %q is open", e.Name)
+}
+
+// DependsOn returns a check for Config.PreRequestChecks that turns calls
+// away with a *DependencyOpenError while any of deps is open, for a
+// dependency that is only reached through another, such as a service
+// behind a gateway with a breaker of its own. A dependency that is
+// half-open, or open with its timeout run out so that its next call will
+// probe, does not stop the call. Nil breakers are never open.
+func DependsOn(deps ...*CircuitBreaker) func(cb *CircuitBreaker) error {
+	// names do not change, so they are read once rather than on every call.
+	names := make([]string, len(deps))
+	for i, dep := range deps {
+		if dep != nil {
+			names[i] = dep.Status().Name
+		}
+	}
+	return func(*CircuitBreaker) error {
+		for i, dep := range deps {
+			if dep.State() != Open {
+				continue
+			}
+			if remaining, ok := dep.TimeUntilRetry(); !ok || remaining > 0 {
+				return &DependencyOpenError{Name: names[i]}
+			}
+		}
+		return nil
+	}
+}
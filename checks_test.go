@@ -0,0 +1,138 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// errMaintenance is a check's own error, reached with errors.As.
+type errMaintenance struct{ window string }
+
+func (e *errMaintenance) Error() string { return "in maintenance window " + e.window }
+
+func TestPreRequestChecks_RejectInEveryState(t *testing.T) {
+	for _, state := range []circuitbreaker.State{circuitbreaker.Closed, circuitbreaker.Open, circuitbreaker.HalfOpen} {
+		t.Run(state.String(), func(t *testing.T) {
+			failing := true
+			cb := circuitbreaker.New(circuitbreaker.Config{
+				FailureThreshold: 2,
+				PreRequestChecks: []func(*circuitbreaker.CircuitBreaker) error{
+					nil,
+					func(*circuitbreaker.CircuitBreaker) error {
+						if failing {
+							return &errMaintenance{window: "nightly"}
+						}
+						return nil
+					},
+				},
+				Clock:  cbt.NewFakeClock(cbt.Epoch),
+				Strict: true,
+			})
+			cbt.AdvanceToHalfOpen(cb)
+			cbt.SetState(cb, state)
+			before := cb.Counts()
+
+			ran := false
+			for _, execute := range []func() error{
+				func() error {
+					_, err := cb.Execute(func() (any, error) { ran = true; return nil, nil })
+					return err
+				},
+				func() error {
+					_, err := cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { ran = true; return nil, nil })
+					return err
+				},
+				func() error { return cb.ExecuteErr(func() error { ran = true; return nil }) },
+			} {
+				err := execute()
+				var me *errMaintenance
+				if !errors.Is(err, circuitbreaker.ErrFailedChecks) || !errors.As(err, &me) || me.window != "nightly" {
+					t.Fatalf("expected the check's error wrapped in ErrFailedChecks, got %v", err)
+				}
+			}
+			if ran {
+				t.Error("expected the request not to run")
+			}
+			if cb.State() != state || counters(cb.Counts()) != counters(before) || cb.Totals().Failures != 0 {
+				t.Errorf("expected nothing counted, got %v %+v %+v", cb.State(), cb.Counts(), cb.Totals())
+			}
+
+			failing = false
+			if _, err := cb.Execute(successFn); errors.Is(err, circuitbreaker.ErrFailedChecks) {
+				t.Errorf("expected a passing check to leave admission to the breaker, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPreRequestChecks_FirstFailureWins(t *testing.T) {
+	second := 0
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		PreRequestChecks: []func(*circuitbreaker.CircuitBreaker) error{
+			func(*circuitbreaker.CircuitBreaker) error { return errSimulated },
+			func(*circuitbreaker.CircuitBreaker) error { second++; return nil },
+		},
+		Strict: true,
+	})
+	if _, err := cb.Execute(successFn); !errors.Is(err, errSimulated) {
+		t.Fatalf("expected the first check's error, got %v", err)
+	}
+	if second != 0 {
+		t.Error("expected the checks after a failed one not to run")
+	}
+}
+
+func TestPreRequestChecks_PanicFails(t *testing.T) {
+	var panics []circuitbreaker.Panic
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		PreRequestChecks: []func(*circuitbreaker.CircuitBreaker) error{
+			func(*circuitbreaker.CircuitBreaker) error { panic("boom") },
+		},
+		OnPanic: func(p circuitbreaker.Panic) { panics = append(panics, p) },
+		Strict:  true,
+	})
+	ran := false
+	_, err := cb.Execute(func() (any, error) { ran = true; return nil, nil })
+	if !errors.Is(err, circuitbreaker.ErrFailedChecks) || ran {
+		t.Fatalf("expected a panicking check to turn the call away, got %v (ran %v)", err, ran)
+	}
+	if cb.Totals().Failures != 0 {
+		t.Errorf("expected nothing counted, got %+v", cb.Totals())
+	}
+	if len(panics) != 1 || panics[0].Component != "PreRequestChecks" || panics[0].Value != "boom" {
+		t.Errorf("expected the panic passed to OnPanic, got %+v", panics)
+	}
+}
+
+func TestDependsOn(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	gateway := circuitbreaker.New(circuitbreaker.Config{Name: "gateway", FailureThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		PreRequestChecks: []func(*circuitbreaker.CircuitBreaker) error{circuitbreaker.DependsOn(nil, gateway)},
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Fatalf("expected calls through while the gateway is closed, got %v", err)
+	}
+	gateway.Execute(failFn)
+	_, err := cb.Execute(successFn)
+	var de *circuitbreaker.DependencyOpenError
+	if !errors.Is(err, circuitbreaker.ErrFailedChecks) || !errors.As(err, &de) || de.Name != "gateway" {
+		t.Fatalf("expected a *DependencyOpenError naming the gateway, got %v", err)
+	}
+	if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Error("expected the dependency's state not to pass for this breaker's")
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Errorf("expected a gateway due to probe not to stop calls, got %v", err)
+	}
+}
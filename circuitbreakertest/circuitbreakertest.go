@@ -0,0 +1,48 @@
+// Package circuitbreakertest provides helpers for testing code that uses
+// circuit breakers.
+//
+// The helpers drive a real *circuitbreaker.CircuitBreaker into a given state
+// instantly instead of looping failures through Execute and sleeping past
+// timeouts. Like the breaker's own methods, they do nothing when given a
+// nil breaker. They are for tests only: they bypass the breaker's normal
+// admission rules and must not be used in production code.
+package circuitbreakertest
+
+import (
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/internal/testhook"
+)
+
+// SetState moves cb into the given state. The transition goes through the
+// same path as an organic one: per-state counters are reset, the state
+// change is timestamped, and OnStateChange is called. Setting the state the
+// breaker is already in does nothing.
+//
+// Forcing Open starts a fresh open period, so the breaker waits the full
+// Timeout before it will move to HalfOpen. Forcing HalfOpen from Closed is
+// a transition the breaker never makes on its own and is reported as a
+// violation in strict mode; use AdvanceToHalfOpen instead.
+func SetState(cb *circuitbreaker.CircuitBreaker, state circuitbreaker.State) {
+	testhook.SetState(cb, state)
+}
+
+// SetCounts overwrites cb's request counters without changing its state:
+// ConsecutiveFailures, ConsecutiveSuccesses and Successes. The state and
+// running totals in counts are ignored. Thresholds are evaluated as usual
+// on the next recorded request, so SetCounts can be used to put a breaker
+// one failure away from tripping or one success away from closing.
+func SetCounts(cb *circuitbreaker.CircuitBreaker, counts circuitbreaker.Counts) {
+	testhook.SetCounts(cb, counts)
+}
+
+// AdvanceToHalfOpen moves cb to HalfOpen as if its open timeout had just
+// expired. A closed breaker is tripped to Open first, so OnStateChange sees
+// the same sequence of transitions as a real trip and recovery. A breaker
+// that is already HalfOpen is left alone.
+func AdvanceToHalfOpen(cb *circuitbreaker.CircuitBreaker) {
+	if cb.State() == circuitbreaker.HalfOpen {
+		return
+	}
+	SetState(cb, circuitbreaker.Open)
+	SetState(cb, circuitbreaker.HalfOpen)
+}
@@ -0,0 +1,206 @@
+package circuitbreakertest_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+var errSimulated = errors.New("simulated failure")
+
+func successFn() (any, error) { return "ok", nil }
+func failFn() (any, error)    { return nil, errSimulated }
+
+// counters keeps only the counters of c that state changes reset.
+func counters(c circuitbreaker.Counts) circuitbreaker.Counts {
+	return circuitbreaker.Counts{
+		ConsecutiveFailures:  c.ConsecutiveFailures,
+		ConsecutiveSuccesses: c.ConsecutiveSuccesses,
+		Successes:            c.Successes,
+	}
+}
+
+// transitionLog collects OnStateChange calls.
+type transitionLog struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (l *transitionLog) hook(name string, from, to circuitbreaker.State) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = append(l.items, from.String()+"->"+to.String())
+}
+
+func (l *transitionLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.items)
+}
+
+func newBreaker(log *transitionLog) *circuitbreaker.CircuitBreaker {
+	cfg := circuitbreaker.Config{
+		Name:             "test",
+		FailureThreshold: 3,
+		SuccessThreshold: 3,
+		Timeout:          20 * time.Millisecond,
+		Strict:           true,
+	}
+	if log != nil {
+		cfg.OnStateChange = log.hook
+	}
+	return circuitbreaker.New(cfg)
+}
+
+func TestSetState_FiresTransitionEvents(t *testing.T) {
+	log := &transitionLog{}
+	cb := newBreaker(log)
+
+	circuitbreakertest.SetState(cb, circuitbreaker.Open)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected Open, got %v", cb.State())
+	}
+
+	// Setting the current state again is a no-op.
+	circuitbreakertest.SetState(cb, circuitbreaker.Open)
+
+	want := []string{"Closed->Open"}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("expected transitions %v, got %v", want, got)
+	}
+
+	_, err := cb.Execute(successFn)
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected forced Open to reject with ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestSetCounts(t *testing.T) {
+	cb := newBreaker(nil)
+
+	circuitbreakertest.SetCounts(cb, circuitbreaker.Counts{ConsecutiveFailures: 2})
+	if got := cb.Counts().ConsecutiveFailures; got != 2 {
+		t.Fatalf("expected 2 consecutive failures, got %d", got)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("SetCounts must not change state, got %v", cb.State())
+	}
+
+	// One more failure reaches FailureThreshold.
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected Open after the third failure, got %v", cb.State())
+	}
+}
+
+func TestAdvanceToHalfOpen(t *testing.T) {
+	log := &transitionLog{}
+	cb := newBreaker(log)
+
+	circuitbreakertest.AdvanceToHalfOpen(cb)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected HalfOpen, got %v", cb.State())
+	}
+
+	want := []string{"Closed->Open", "Open->HalfOpen"}
+	if got := log.get(); !slices.Equal(got, want) {
+		t.Errorf("expected transitions %v, got %v", want, got)
+	}
+}
+
+// halfOpenWithOneSuccessOrganically drives cb through real failures and a
+// real timeout into HalfOpen with one probe success recorded.
+func halfOpenWithOneSuccessOrganically(t *testing.T, cb *circuitbreaker.CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		cb.Execute(failFn)
+	}
+	time.Sleep(40 * time.Millisecond)
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected organic HalfOpen, got %v", cb.State())
+	}
+}
+
+func TestForcedStateMatchesOrganic(t *testing.T) {
+	sequences := map[string][]func() (any, error){
+		"successes close":      {successFn, successFn, successFn},
+		"failure reopens":      {failFn, successFn},
+		"success then failure": {successFn, failFn, successFn},
+	}
+
+	for name, seq := range sequences {
+		t.Run(name, func(t *testing.T) {
+			organic := newBreaker(nil)
+			halfOpenWithOneSuccessOrganically(t, organic)
+
+			forced := newBreaker(nil)
+			circuitbreakertest.AdvanceToHalfOpen(forced)
+			circuitbreakertest.SetCounts(forced, circuitbreaker.Counts{ConsecutiveSuccesses: 1, Successes: 1})
+
+			if counters(organic.Counts()) != counters(forced.Counts()) {
+				t.Fatalf("counts differ before traffic: organic %+v, forced %+v",
+					organic.Counts(), forced.Counts())
+			}
+
+			for i, fn := range seq {
+				_, errOrganic := organic.Execute(fn)
+				_, errForced := forced.Execute(fn)
+
+				// rejections differ only in how long is left to wait.
+				if errors.Is(errOrganic, circuitbreaker.ErrCircuitOpen) != errors.Is(errForced, circuitbreaker.ErrCircuitOpen) ||
+					!errors.Is(errOrganic, circuitbreaker.ErrCircuitOpen) && errOrganic != errForced {
+					t.Errorf("step %d: organic returned %v, forced returned %v", i, errOrganic, errForced)
+				}
+				if organic.State() != forced.State() {
+					t.Errorf("step %d: organic state %v, forced state %v", i, organic.State(), forced.State())
+				}
+				if counters(organic.Counts()) != counters(forced.Counts()) {
+					t.Errorf("step %d: organic counts %+v, forced counts %+v", i, organic.Counts(), forced.Counts())
+				}
+			}
+		})
+	}
+}
+
+func TestScriptedBreaker(t *testing.T) {
+	b := circuitbreakertest.NewScriptedBreaker(circuitbreakertest.Admit, circuitbreakertest.Reject)
+	var g circuitbreaker.Guard = b
+
+	if v, err := g.Execute(successFn); err != nil || v != "ok" {
+		t.Fatalf("expected the first call admitted, got %v, %v", v, err)
+	}
+	if _, err := g.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the second call rejected, got %v", err)
+	}
+	if _, err := g.Execute(failFn); err != errSimulated {
+		t.Fatalf("expected the script to start over and admit the failure, got %v", err)
+	}
+	b.RejectWith(circuitbreaker.ErrTooManyRequests)
+	if _, err := g.Execute(successFn); err != circuitbreaker.ErrTooManyRequests {
+		t.Fatalf("expected the configured rejection, got %v", err)
+	}
+	if admitted, rejected := b.Calls(); admitted != 2 || rejected != 2 {
+		t.Errorf("expected 2 admitted and 2 rejected, got %d and %d", admitted, rejected)
+	}
+	totals := circuitbreaker.Counts{Requests: 4, TotalSuccesses: 1, TotalFailures: 1, Rejected: 2}
+	want := totals
+	want.ConsecutiveFailures, want.Successes = 1, 1
+	if c := g.Counts(); c != want {
+		t.Errorf("expected the admitted outcomes counted, got %+v", c)
+	}
+
+	b.SetState(circuitbreaker.Open)
+	if g.State() != circuitbreaker.Open {
+		t.Errorf("expected the set state, got %v", g.State())
+	}
+	g.Reset()
+	if g.State() != circuitbreaker.Closed || g.Counts() != totals {
+		t.Errorf("expected Reset to close and clear counts but not totals, got %v %+v", g.State(), g.Counts())
+	}
+}
@@ -0,0 +1,116 @@
+package circuitbreakertest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// FakeClock is a circuitbreaker.Clock whose time only moves when the test
+// says so. Timers scheduled with AfterFunc run synchronously, in deadline
+// order, inside the Advance or Set call that reaches their deadline, with
+// Now reporting the deadline while they run. It is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f to run when the clock reaches Now()+d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) circuitbreaker.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, running every timer that falls due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	c.runUntil(target)
+}
+
+// Set moves the clock to t, which may be in the past. Timers due at or
+// before t run; moving backwards runs nothing.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	if t.Before(c.now) {
+		c.now = t
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	c.runUntil(t)
+}
+
+// PendingTimers returns the number of scheduled timers that have not run
+// or been stopped.
+func (c *FakeClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// runUntil fires due timers one at a time, earliest first, so timers
+// scheduled by a firing timer are honoured too, then settles at target.
+func (c *FakeClock) runUntil(target time.Time) {
+	for {
+		c.mu.Lock()
+		next := -1
+		for i, t := range c.timers {
+			if !t.when.After(target) && (next < 0 || t.when.Before(c.timers[next].when)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		c.mu.Unlock()
+
+		t.f()
+	}
+}
+
+// fakeTimer is a call scheduled on a FakeClock.
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	f     func()
+}
+
+// Stop removes the timer if it has not fired yet.
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
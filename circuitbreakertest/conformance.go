@@ -0,0 +1,437 @@
+package circuitbreakertest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// RunClockConformance checks that the clocks made by newClock keep the
+// contract the breaker relies on from a circuitbreaker.Clock. It is meant
+// for manual clocks like FakeClock: newClock is called once per case and
+// returns a clock together with a function that moves it forward by d and
+// returns once every timer that fell due has run. The first broken case
+// is reported through t.
+func RunClockConformance(t TB, newClock func() (clock circuitbreaker.Clock, advance func(d time.Duration))) {
+	t.Helper()
+	for _, c := range clockCases {
+		clock, advance := newClock()
+		if err := c.check(clock, advance); err != nil {
+			t.Fatalf("clock conformance (%s): %v", c.name, err)
+			return
+		}
+	}
+}
+
+// RunQuorumStoreConformance checks that the stores made by newStore keep
+// the contract of circuitbreaker.QuorumStore: breaches are counted once
+// per instance, expire exactly at their ttl, are never cut short by a
+// later report, and are consistent under concurrent use. newStore is
+// called once per case and returns a store over empty storage that times
+// breaches by clock. The first broken case is reported through t.
+func RunQuorumStoreConformance(t TB, newStore func(clock circuitbreaker.Clock) circuitbreaker.QuorumStore) {
+	t.Helper()
+	for _, c := range quorumStoreCases {
+		clock := NewFakeClock(Epoch)
+		if err := c.check(newStore(clock), clock); err != nil {
+			t.Fatalf("quorum store conformance (%s): %v", c.name, err)
+			return
+		}
+	}
+}
+
+// RunStoreConformance checks that the stores made by newStore keep the
+// contract of circuitbreaker.Store: a saved state loads back unchanged,
+// replaces the one saved before it, is kept apart from other breakers'
+// states, survives reopening the storage, and is consistent under
+// concurrent use. newStore is called once per case and returns a store
+// over empty storage, along with reopen, which returns a new store over
+// the same storage, as a restarted process would; a store kept in memory
+// may return itself. The first broken case is reported through t.
+func RunStoreConformance(t TB, newStore func() (store circuitbreaker.Store, reopen func() circuitbreaker.Store)) {
+	t.Helper()
+	for _, c := range storeCases {
+		store, reopen := newStore()
+		if err := c.check(store, reopen); err != nil {
+			t.Fatalf("store conformance (%s): %v", c.name, err)
+			return
+		}
+	}
+}
+
+var clockCases = []struct {
+	name  string
+	check func(clock circuitbreaker.Clock, advance func(time.Duration)) error
+}{
+	{"now moves with advance", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		start := clock.Now()
+		advance(time.Second)
+		if got := clock.Now().Sub(start); got < time.Second {
+			return fmt.Errorf("advancing 1s moved Now by %v", got)
+		}
+		return nil
+	}},
+	{"timer fires once at its deadline", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		var fired int
+		clock.AfterFunc(time.Second, func() { fired++ })
+		advance(time.Second - time.Millisecond)
+		if fired != 0 {
+			return fmt.Errorf("timer fired 1ms before its deadline")
+		}
+		advance(time.Millisecond)
+		advance(time.Hour)
+		if fired != 1 {
+			return fmt.Errorf("timer fired %d times, want 1", fired)
+		}
+		return nil
+	}},
+	{"non-positive delay fires on the next advance", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		var fired int
+		clock.AfterFunc(0, func() { fired++ })
+		clock.AfterFunc(-time.Second, func() { fired++ })
+		advance(0)
+		if fired != 2 {
+			return fmt.Errorf("%d of 2 due timers fired", fired)
+		}
+		return nil
+	}},
+	{"timers fire in deadline order", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		var order []int
+		for _, d := range []int{3, 1, 2} {
+			clock.AfterFunc(time.Duration(d)*time.Second, func() { order = append(order, d) })
+		}
+		advance(time.Minute)
+		if fmt.Sprint(order) != "[1 2 3]" {
+			return fmt.Errorf("timers fired in order %v", order)
+		}
+		return nil
+	}},
+	{"timer armed by a timer fires", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		var fired bool
+		clock.AfterFunc(time.Second, func() {
+			clock.AfterFunc(time.Second, func() { fired = true })
+		})
+		advance(2 * time.Second)
+		if !fired {
+			return fmt.Errorf("a timer armed from a firing timer did not fire within the same advance")
+		}
+		return nil
+	}},
+	{"stop", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		var fired bool
+		stopped := clock.AfterFunc(time.Second, func() { fired = true })
+		if !stopped.Stop() {
+			return fmt.Errorf("Stop on a pending timer reported false")
+		}
+		if stopped.Stop() {
+			return fmt.Errorf("a second Stop reported true")
+		}
+		done := clock.AfterFunc(time.Second, func() {})
+		advance(time.Minute)
+		if fired {
+			return fmt.Errorf("a stopped timer fired")
+		}
+		if done.Stop() {
+			return fmt.Errorf("Stop on a fired timer reported true")
+		}
+		return nil
+	}},
+	{"concurrent use", func(clock circuitbreaker.Clock, advance func(time.Duration)) error {
+		const n = 100
+		var fired, stopped atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				timer := clock.AfterFunc(time.Duration(i%10)*time.Millisecond, func() { fired.Add(1) })
+				if i%2 == 0 && timer.Stop() {
+					stopped.Add(1)
+				}
+				clock.Now()
+			}()
+		}
+		wg.Wait()
+		advance(time.Second)
+		if got := fired.Load() + stopped.Load(); got != n {
+			return fmt.Errorf("%d timers fired and %d were stopped, want %d in all", fired.Load(), stopped.Load(), n)
+		}
+		return nil
+	}},
+}
+
+var quorumStoreCases = []struct {
+	name  string
+	check func(store circuitbreaker.QuorumStore, clock *FakeClock) error
+}{
+	{"empty", func(store circuitbreaker.QuorumStore, _ *FakeClock) error {
+		return expectBreaches(store, "empty", 0)
+	}},
+	{"instances count once", func(store circuitbreaker.QuorumStore, _ *FakeClock) error {
+		for _, instance := range []string{"a", "b", "a", "a"} {
+			if err := report(store, "dedupe", instance, time.Minute); err != nil {
+				return err
+			}
+		}
+		return expectBreaches(store, "dedupe", 2)
+	}},
+	{"breakers are independent", func(store circuitbreaker.QuorumStore, _ *FakeClock) error {
+		if err := report(store, "one", "a", time.Minute); err != nil {
+			return err
+		}
+		return expectBreaches(store, "two", 0)
+	}},
+	{"breach expires at its ttl", func(store circuitbreaker.QuorumStore, clock *FakeClock) error {
+		if err := report(store, "expiry", "a", 10*time.Second); err != nil {
+			return err
+		}
+		clock.Advance(10*time.Second - time.Millisecond)
+		if err := expectBreaches(store, "expiry", 1); err != nil {
+			return fmt.Errorf("1ms before the ttl: %w", err)
+		}
+		clock.Advance(time.Millisecond)
+		if err := expectBreaches(store, "expiry", 0); err != nil {
+			return fmt.Errorf("at the ttl: %w", err)
+		}
+		// an expired instance counts again once it reports again.
+		if err := report(store, "expiry", "a", 10*time.Second); err != nil {
+			return err
+		}
+		return expectBreaches(store, "expiry", 1)
+	}},
+	{"later reports extend but never shorten", func(store circuitbreaker.QuorumStore, clock *FakeClock) error {
+		if err := report(store, "extend", "long", time.Minute); err != nil {
+			return err
+		}
+		if err := report(store, "extend", "long", time.Second); err != nil {
+			return err
+		}
+		if err := report(store, "extend", "short", time.Second); err != nil {
+			return err
+		}
+		if err := report(store, "extend", "short", time.Minute); err != nil {
+			return err
+		}
+		clock.Advance(2 * time.Second)
+		return expectBreaches(store, "extend", 2)
+	}},
+	{"concurrent reports", func(store circuitbreaker.QuorumStore, _ *FakeClock) error {
+		const n = 50
+		var wg sync.WaitGroup
+		errs := make(chan error, 2*n)
+		for i := 0; i < n; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				errs <- report(store, "concurrent", fmt.Sprint("instance-", i), time.Minute)
+			}()
+			// a reader never sees the count go down while breaches only
+			// accumulate.
+			go func() {
+				defer wg.Done()
+				last := 0
+				for j := 0; j < 5; j++ {
+					got, err := store.Breaches(context.Background(), "concurrent")
+					if err == nil && got < last {
+						err = fmt.Errorf("count went from %d to %d", last, got)
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+					last = got
+				}
+				errs <- nil
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return expectBreaches(store, "concurrent", n)
+	}},
+}
+
+func report(store circuitbreaker.QuorumStore, breaker, instance string, ttl time.Duration) error {
+	if err := store.ReportBreach(context.Background(), breaker, instance, ttl); err != nil {
+		return fmt.Errorf("ReportBreach(%q, %q): %w", breaker, instance, err)
+	}
+	return nil
+}
+
+func expectBreaches(store circuitbreaker.QuorumStore, breaker string, want int) error {
+	got, err := store.Breaches(context.Background(), breaker)
+	if err != nil {
+		return fmt.Errorf("Breaches(%q): %w", breaker, err)
+	}
+	if got != want {
+		return fmt.Errorf("Breaches(%q) = %d, want %d", breaker, got, want)
+	}
+	return nil
+}
+
+var storeCases = []struct {
+	name  string
+	check func(store circuitbreaker.Store, reopen func() circuitbreaker.Store) error
+}{
+	{"empty", func(store circuitbreaker.Store, _ func() circuitbreaker.Store) error {
+		return expectMissing(store, "empty")
+	}},
+	{"round trip", func(store circuitbreaker.Store, _ func() circuitbreaker.Store) error {
+		want := storedState(1)
+		if err := save(store, "payments", want); err != nil {
+			return err
+		}
+		return expectState(store, "payments", want)
+	}},
+	{"save replaces", func(store circuitbreaker.Store, _ func() circuitbreaker.Store) error {
+		if err := save(store, "payments", storedState(1)); err != nil {
+			return err
+		}
+		if err := save(store, "payments", storedState(2)); err != nil {
+			return err
+		}
+		return expectState(store, "payments", storedState(2))
+	}},
+	{"breakers are independent", func(store circuitbreaker.Store, _ func() circuitbreaker.Store) error {
+		// names that are awkward as keys or file names.
+		names := []string{"payments", "payments/eu", "payments eu?", "../payments", "支払い"}
+		for i, name := range names {
+			if err := save(store, name, storedState(i+1)); err != nil {
+				return err
+			}
+		}
+		for i, name := range names {
+			if err := expectState(store, name, storedState(i+1)); err != nil {
+				return err
+			}
+		}
+		return expectMissing(store, "search")
+	}},
+	{"reload", func(store circuitbreaker.Store, reopen func() circuitbreaker.Store) error {
+		if err := save(store, "payments", storedState(1)); err != nil {
+			return err
+		}
+		if err := save(store, "search", storedState(2)); err != nil {
+			return err
+		}
+		reopened := reopen()
+		if err := expectState(reopened, "payments", storedState(1)); err != nil {
+			return fmt.Errorf("after reopening: %w", err)
+		}
+		if err := expectState(reopened, "search", storedState(2)); err != nil {
+			return fmt.Errorf("after reopening: %w", err)
+		}
+		return expectMissing(reopened, "checkout")
+	}},
+	{"concurrent saves and loads", func(store circuitbreaker.Store, _ func() circuitbreaker.Store) error {
+		const n = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, 3*n)
+		for i := 0; i < n; i++ {
+			wg.Add(3)
+			// every breaker's own state, and one state all of them fight
+			// over.
+			go func() {
+				defer wg.Done()
+				errs <- save(store, fmt.Sprint("breaker-", i), storedState(i+1))
+			}()
+			go func() {
+				defer wg.Done()
+				errs <- save(store, "shared", storedState(i+1))
+			}()
+			// a reader sees nothing, or one of the states saved whole.
+			go func() {
+				defer wg.Done()
+				s, ok, err := store.Load("shared")
+				if err == nil && ok && !reflect.DeepEqual(s, storedState(s.ConsecutiveFailures)) {
+					err = fmt.Errorf("Load(%q) returned a state never saved: %+v", "shared", s)
+				}
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		for i := 0; i < n; i++ {
+			if err := expectState(store, fmt.Sprint("breaker-", i), storedState(i+1)); err != nil {
+				return err
+			}
+		}
+		s, ok, err := store.Load("shared")
+		if err != nil || !ok || s.ConsecutiveFailures < 1 || s.ConsecutiveFailures > n {
+			return fmt.Errorf("Load(%q) = %+v, %v, %v, want one of the saved states", "shared", s, ok, err)
+		}
+		return expectState(store, "shared", storedState(s.ConsecutiveFailures))
+	}},
+}
+
+// storedState is a state with every field set, told apart from others by
+// n, which is also its ConsecutiveFailures.
+func storedState(n int) circuitbreaker.PersistedState {
+	at := Epoch.Add(time.Duration(n) * time.Minute)
+	return circuitbreaker.PersistedState{
+		Version:              circuitbreaker.SnapshotVersion,
+		Taken:                at,
+		State:                circuitbreaker.HalfOpen,
+		ConsecutiveFailures:  n,
+		FailureWeight:        float64(n) + 0.5,
+		Successes:            n + 1,
+		ConsecutiveSuccesses: n + 2,
+		LastFailure:          at.Add(-time.Second),
+		LastStateChange:      at.Add(-time.Minute),
+		Reopens:              n,
+		RampPercent:          25,
+		SuccessLatency:       circuitbreaker.LatencyStats{Count: uint64(n), Min: time.Millisecond, Max: time.Second, Mean: 10 * time.Millisecond, P50: 5 * time.Millisecond, P95: 500 * time.Millisecond, P99: time.Second},
+		FailureLatency:       circuitbreaker.LatencyStats{Count: 1, Min: time.Second, Max: time.Second, Mean: time.Second, P50: time.Second, P95: time.Second, P99: time.Second},
+		Windows: []circuitbreaker.WindowSnapshot{{
+			Setting: "FailureRateWindows[0]",
+			Buckets: []circuitbreaker.BucketSnapshot{{Start: at.Add(-10 * time.Second), Total: n + 3, Failures: n, Weight: 1.5}},
+		}},
+		CallWindows: []circuitbreaker.CallWindowSnapshot{{Setting: "WindowSize", Outcomes: []bool{true, false, n%2 == 0}}},
+	}
+}
+
+func save(store circuitbreaker.Store, name string, s circuitbreaker.PersistedState) error {
+	if err := store.Save(name, s); err != nil {
+		return fmt.Errorf("Save(%q): %w", name, err)
+	}
+	return nil
+}
+
+func expectState(store circuitbreaker.Store, name string, want circuitbreaker.PersistedState) error {
+	got, ok, err := store.Load(name)
+	if err != nil {
+		return fmt.Errorf("Load(%q): %w", name, err)
+	}
+	if !ok {
+		return fmt.Errorf("Load(%q) found nothing, want the saved state", name)
+	}
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("Load(%q) = %+v, want %+v", name, got, want)
+	}
+	return nil
+}
+
+func expectMissing(store circuitbreaker.Store, name string) error {
+	got, ok, err := store.Load(name)
+	if err != nil {
+		return fmt.Errorf("Load(%q): %w", name, err)
+	}
+	if ok {
+		return fmt.Errorf("Load(%q) = %+v, want nothing saved", name, got)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package circuitbreakertest_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newFakeClock() (circuitbreaker.Clock, func(time.Duration)) {
+	clock := circuitbreakertest.NewFakeClock(circuitbreakertest.Epoch)
+	return clock, clock.Advance
+}
+
+func TestFakeClock_Conformance(t *testing.T) {
+	circuitbreakertest.RunClockConformance(t, newFakeClock)
+}
+
+// leakyClock is a FakeClock whose timers cannot be stopped.
+type leakyClock struct{ *circuitbreakertest.FakeClock }
+
+type leakyTimer struct{}
+
+func (leakyTimer) Stop() bool { return true }
+
+func (c leakyClock) AfterFunc(d time.Duration, f func()) circuitbreaker.Timer {
+	c.FakeClock.AfterFunc(d, f)
+	return leakyTimer{}
+}
+
+func TestClockConformance_CatchesBrokenClock(t *testing.T) {
+	var rec recordingTB
+	circuitbreakertest.RunClockConformance(&rec, func() (circuitbreaker.Clock, func(time.Duration)) {
+		clock := circuitbreakertest.NewFakeClock(circuitbreakertest.Epoch)
+		return leakyClock{clock}, clock.Advance
+	})
+	if !rec.failed || !strings.Contains(rec.message, "stop") {
+		t.Errorf("expected the suite to reject timers that cannot be stopped, got %q", rec.message)
+	}
+}
+
+// countingStore counts reports rather than instances, and never expires
+// them.
+type countingStore struct {
+	mu      sync.Mutex
+	reports map[string]int
+}
+
+func (s *countingStore) ReportBreach(_ context.Context, breaker, _ string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[breaker]++
+	return nil
+}
+
+func (s *countingStore) Breaches(_ context.Context, breaker string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reports[breaker], nil
+}
+
+func TestQuorumStoreConformance_CatchesBrokenStore(t *testing.T) {
+	var rec recordingTB
+	circuitbreakertest.RunQuorumStoreConformance(&rec, func(circuitbreaker.Clock) circuitbreaker.QuorumStore {
+		return &countingStore{reports: map[string]int{}}
+	})
+	if !rec.failed || !strings.Contains(rec.message, "instances count once") {
+		t.Errorf("expected the suite to reject a store counting duplicate reports, got %q", rec.message)
+	}
+}
+
+// firstSaveStore keeps the first state saved for each breaker and ignores
+// the rest.
+type firstSaveStore struct {
+	mu     sync.Mutex
+	states map[string]circuitbreaker.PersistedState
+}
+
+func (s *firstSaveStore) Save(name string, st circuitbreaker.PersistedState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.states[name]; !ok {
+		s.states[name] = st
+	}
+	return nil
+}
+
+func (s *firstSaveStore) Load(name string) (circuitbreaker.PersistedState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[name]
+	return st, ok, nil
+}
+
+func TestStoreConformance_CatchesBrokenStore(t *testing.T) {
+	var rec recordingTB
+	circuitbreakertest.RunStoreConformance(&rec, func() (circuitbreaker.Store, func() circuitbreaker.Store) {
+		store := &firstSaveStore{states: map[string]circuitbreaker.PersistedState{}}
+		return store, func() circuitbreaker.Store { return store }
+	})
+	if !rec.failed || !strings.Contains(rec.message, "save replaces") {
+		t.Errorf("expected the suite to reject a store that keeps the first save, got %q", rec.message)
+	}
+}
@@ -0,0 +1,31 @@
+package circuitbreakertest
+
+import (
+	"runtime"
+	"time"
+)
+
+// CheckGoroutines records the number of running goroutines and returns a
+// function that fails t if, after a short grace period, more goroutines are
+// running than when CheckGoroutines was called. Use it to prove a breaker's
+// background work stops on Close:
+//
+//	defer circuitbreakertest.CheckGoroutines(t)()
+func CheckGoroutines(t TB) func() {
+	before := runtime.NumGoroutine()
+	return func() {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			after := runtime.NumGoroutine()
+			if after <= before {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("goroutine leak: %d goroutines before, %d after", before, after)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
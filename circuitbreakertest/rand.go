@@ -0,0 +1,39 @@
+package circuitbreakertest
+
+import "sync"
+
+// ScriptedRand is a circuitbreaker.Rand that returns a fixed sequence of
+// values, starting over once it runs out, so a test decides every random
+// draw the breaker makes. It is safe for concurrent use.
+type ScriptedRand struct {
+	mu     sync.Mutex
+	values []float64
+	next   int
+	draws  int
+}
+
+// NewScriptedRand returns a ScriptedRand that returns values in order.
+// With no values it always returns 0.
+func NewScriptedRand(values ...float64) *ScriptedRand {
+	return &ScriptedRand{values: values}
+}
+
+// Float64 returns the next scripted value.
+func (r *ScriptedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draws++
+	if len(r.values) == 0 {
+		return 0
+	}
+	v := r.values[r.next]
+	r.next = (r.next + 1) % len(r.values)
+	return v
+}
+
+// Draws returns the number of values drawn so far.
+func (r *ScriptedRand) Draws() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.draws
+}
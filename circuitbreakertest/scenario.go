@@ -0,0 +1,170 @@
+package circuitbreakertest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// ErrScenarioFailure is the error returned by calls made with Fail.
+var ErrScenarioFailure = errors.New("circuitbreakertest: scenario failure")
+
+// Epoch is the time a scenario's fake clock starts at.
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// TB is the subset of testing.TB used to report a diverging scenario.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
+
+// Scenario runs a scripted sequence of steps against a breaker whose
+// clock is fake, so state-machine behaviour can be tested without sleeps
+// or races.
+type Scenario struct {
+	// Breaker is the breaker the steps run against.
+	Breaker *circuitbreaker.CircuitBreaker
+	// Clock is the fake clock driving Breaker.
+	Clock *FakeClock
+
+	// lastErr is the error returned by the most recent call step.
+	lastErr error
+	called  bool
+}
+
+// NewScenario creates a breaker from cfg with its Clock replaced by a
+// FakeClock starting at Epoch.
+func NewScenario(cfg circuitbreaker.Config) *Scenario {
+	clock := NewFakeClock(Epoch)
+	cfg.Clock = clock
+	return &Scenario{
+		Breaker: circuitbreaker.New(cfg),
+		Clock:   clock,
+	}
+}
+
+// Run executes steps in order. The first step that fails stops the
+// scenario and is reported through t with its position and description.
+func (s *Scenario) Run(t TB, steps ...Step) {
+	t.Helper()
+	for i, step := range steps {
+		if err := step.do(s); err != nil {
+			t.Fatalf("scenario step %d (%s): %v", i+1, step, err)
+			return
+		}
+	}
+}
+
+// LastError returns the error from the most recent call step.
+func (s *Scenario) LastError() error {
+	return s.lastErr
+}
+
+// Step is one action or assertion in a Scenario.
+type Step struct {
+	name string
+	do   func(s *Scenario) error
+}
+
+// String returns the step's description.
+func (st Step) String() string {
+	return st.name
+}
+
+// StepFunc builds a custom step. Returning an error fails the scenario.
+func StepFunc(name string, fn func(s *Scenario) error) Step {
+	return Step{name: name, do: fn}
+}
+
+// Call executes fn through the breaker and remembers the returned error.
+func Call(name string, fn func() (any, error)) Step {
+	return StepFunc(name, func(s *Scenario) error {
+		_, s.lastErr = s.Breaker.Execute(fn)
+		s.called = true
+		return nil
+	})
+}
+
+// Succeed executes a call that succeeds.
+func Succeed() Step {
+	return Call("call success", func() (any, error) { return nil, nil })
+}
+
+// Fail executes a call that fails with ErrScenarioFailure.
+func Fail() Step {
+	return Call("call failure", func() (any, error) { return nil, ErrScenarioFailure })
+}
+
+// Advance moves the fake clock forward by d.
+func Advance(d time.Duration) Step {
+	return StepFunc(fmt.Sprintf("advance clock %s", d), func(s *Scenario) error {
+		s.Clock.Advance(d)
+		return nil
+	})
+}
+
+// ExpectState asserts the breaker's current state.
+func ExpectState(want circuitbreaker.State) Step {
+	return StepFunc(fmt.Sprintf("expect state %s", want), func(s *Scenario) error {
+		if got := s.Breaker.State(); got != want {
+			return fmt.Errorf("expected state %s, got %s", want, got)
+		}
+		return nil
+	})
+}
+
+// ExpectCounts asserts the breaker's current counters: the
+// ConsecutiveFailures, ConsecutiveSuccesses and Successes that state
+// changes reset. The state and the running totals in want are ignored;
+// use ExpectState for the state.
+func ExpectCounts(want circuitbreaker.Counts) Step {
+	want = counters(want)
+	return StepFunc(fmt.Sprintf("expect counts %+v", want), func(s *Scenario) error {
+		if got := counters(s.Breaker.Counts()); got != want {
+			return fmt.Errorf("expected counts %+v, got %+v", want, got)
+		}
+		return nil
+	})
+}
+
+// counters keeps only the counters of c that state changes reset.
+func counters(c circuitbreaker.Counts) circuitbreaker.Counts {
+	return circuitbreaker.Counts{
+		ConsecutiveFailures:  c.ConsecutiveFailures,
+		ConsecutiveSuccesses: c.ConsecutiveSuccesses,
+		Successes:            c.Successes,
+	}
+}
+
+// ExpectError asserts that the most recent call returned an error
+// matching want according to errors.Is. A nil want asserts success.
+func ExpectError(want error) Step {
+	return StepFunc(fmt.Sprintf("expect error %v", want), func(s *Scenario) error {
+		if !s.called {
+			return errors.New("no call has been made yet")
+		}
+		if want == nil && s.lastErr != nil {
+			return fmt.Errorf("expected no error, got %v", s.lastErr)
+		}
+		if !errors.Is(s.lastErr, want) {
+			return fmt.Errorf("expected error %v, got %v", want, s.lastErr)
+		}
+		return nil
+	})
+}
+
+// ExpectRejected asserts that the most recent call was rejected with
+// ErrCircuitOpen, or with ErrTooManyRequests while half-open.
+func ExpectRejected() Step {
+	return StepFunc("expect rejected", func(s *Scenario) error {
+		if !s.called {
+			return errors.New("no call has been made yet")
+		}
+		if !errors.Is(s.lastErr, circuitbreaker.ErrCircuitOpen) && !errors.Is(s.lastErr, circuitbreaker.ErrTooManyRequests) {
+			return fmt.Errorf("expected the call to be rejected, got %v", s.lastErr)
+		}
+		return nil
+	})
+}
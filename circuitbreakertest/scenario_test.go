@@ -0,0 +1,174 @@
+package circuitbreakertest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// recordingTB captures Fatalf instead of failing the real test.
+type recordingTB struct {
+	failed  bool
+	message string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.failed = true
+	r.message = fmt.Sprintf(format, args...)
+}
+
+func scenarioConfig() circuitbreaker.Config {
+	return circuitbreaker.Config{
+		Name:             "scenario",
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+		Timeout:          5 * time.Second,
+		Strict:           true,
+	}
+}
+
+func TestScenario_Passes(t *testing.T) {
+	s := circuitbreakertest.NewScenario(scenarioConfig())
+	s.Run(t,
+		circuitbreakertest.Fail(),
+		circuitbreakertest.ExpectCounts(circuitbreaker.Counts{ConsecutiveFailures: 1}),
+		circuitbreakertest.Fail(),
+		circuitbreakertest.ExpectState(circuitbreaker.Open),
+		circuitbreakertest.Succeed(),
+		circuitbreakertest.ExpectRejected(),
+	)
+}
+
+func TestScenario_ReportsDivergingStep(t *testing.T) {
+	rec := &recordingTB{}
+	s := circuitbreakertest.NewScenario(scenarioConfig())
+
+	var ranAfterFailure bool
+	s.Run(rec,
+		circuitbreakertest.Fail(),
+		circuitbreakertest.ExpectState(circuitbreaker.Open),
+		circuitbreakertest.StepFunc("unreachable", func(*circuitbreakertest.Scenario) error {
+			ranAfterFailure = true
+			return nil
+		}),
+	)
+
+	if !rec.failed {
+		t.Fatal("expected scenario to fail")
+	}
+	if ranAfterFailure {
+		t.Error("steps after the diverging one must not run")
+	}
+	want := "scenario step 2 (expect state Open): expected state Open, got Closed"
+	if rec.message != want {
+		t.Errorf("expected message %q, got %q", want, rec.message)
+	}
+}
+
+func TestScenario_ExpectErrorFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []circuitbreakertest.Step
+		want  string
+	}{
+		{
+			name:  "no call yet",
+			steps: []circuitbreakertest.Step{circuitbreakertest.ExpectRejected()},
+			want:  "no call has been made yet",
+		},
+		{
+			name:  "unexpected success",
+			steps: []circuitbreakertest.Step{circuitbreakertest.Succeed(), circuitbreakertest.ExpectRejected()},
+			want:  "got <nil>",
+		},
+		{
+			name:  "unexpected error",
+			steps: []circuitbreakertest.Step{circuitbreakertest.Fail(), circuitbreakertest.ExpectError(nil)},
+			want:  "expected no error",
+		},
+		{
+			name:  "counts",
+			steps: []circuitbreakertest.Step{circuitbreakertest.ExpectCounts(circuitbreaker.Counts{Successes: 1})},
+			want:  "expected counts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingTB{}
+			circuitbreakertest.NewScenario(scenarioConfig()).Run(rec, tt.steps...)
+			if !rec.failed || !strings.Contains(rec.message, tt.want) {
+				t.Errorf("expected failure containing %q, got failed=%v %q", tt.want, rec.failed, rec.message)
+			}
+		})
+	}
+}
+
+func TestScenario_AdvanceMovesClock(t *testing.T) {
+	s := circuitbreakertest.NewScenario(scenarioConfig())
+	s.Run(t,
+		circuitbreakertest.Fail(),
+		circuitbreakertest.Fail(),
+		circuitbreakertest.ExpectState(circuitbreaker.Open),
+		circuitbreakertest.Advance(4*time.Second),
+		circuitbreakertest.Succeed(),
+		circuitbreakertest.ExpectRejected(),
+		circuitbreakertest.Advance(time.Second),
+		circuitbreakertest.Succeed(),
+		circuitbreakertest.ExpectError(nil),
+		circuitbreakertest.ExpectState(circuitbreaker.HalfOpen),
+	)
+
+	if got := s.Clock.Now().Sub(circuitbreakertest.Epoch); got != 5*time.Second {
+		t.Errorf("expected clock 5s past epoch, got %s", got)
+	}
+}
+
+func TestFakeClock_Set(t *testing.T) {
+	clock := circuitbreakertest.NewFakeClock(circuitbreakertest.Epoch)
+	clock.Set(circuitbreakertest.Epoch.Add(-time.Minute))
+	if got := clock.Now(); !got.Equal(circuitbreakertest.Epoch.Add(-time.Minute)) {
+		t.Errorf("expected clock to move backwards, got %v", got)
+	}
+}
+
+func TestFakeClock_Timers(t *testing.T) {
+	clock := circuitbreakertest.NewFakeClock(circuitbreakertest.Epoch)
+	var fired []time.Duration
+	record := func() { fired = append(fired, clock.Now().Sub(circuitbreakertest.Epoch)) }
+
+	clock.AfterFunc(3*time.Second, record)
+	clock.AfterFunc(time.Second, func() {
+		record()
+		// Timers scheduled while firing still run if they fall due.
+		clock.AfterFunc(time.Second, record)
+	})
+	stopped := clock.AfterFunc(2*time.Second, record)
+	if !stopped.Stop() {
+		t.Fatal("expected Stop to report a pending timer")
+	}
+
+	clock.Advance(5 * time.Second)
+
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if len(fired) != len(want) {
+		t.Fatalf("expected timers at %v, got %v", want, fired)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Errorf("timer %d: expected %v, got %v", i, want[i], fired[i])
+		}
+	}
+	if stopped.Stop() {
+		t.Error("expected Stop on a stopped timer to report false")
+	}
+	if clock.PendingTimers() != 0 {
+		t.Errorf("expected no pending timers, got %d", clock.PendingTimers())
+	}
+}
@@ -0,0 +1,154 @@
+package circuitbreakertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// Decision is one step of a ScriptedBreaker's script.
+type Decision int
+
+const (
+	// Admit lets the call run.
+	Admit Decision = iota
+	// Reject turns the call away without running it.
+	Reject
+)
+
+// ScriptedBreaker is a circuitbreaker.Guard whose admission decisions
+// follow a fixed script, starting over once it runs out, for testing code
+// that takes a Guard without driving a real breaker. Admitted calls run
+// and are counted in Counts, a non-nil error as a failure; rejected calls
+// fail with the rejection error and count as Rejected. State reports
+// whatever SetState last set, Closed to begin with, whatever the
+// outcomes. It is safe for concurrent use.
+type ScriptedBreaker struct {
+	mu       sync.Mutex
+	script   []Decision
+	next     int
+	state    circuitbreaker.State
+	counts   circuitbreaker.Counts
+	err      error
+	admitted int
+	rejected int
+}
+
+var _ circuitbreaker.Guard = (*ScriptedBreaker)(nil)
+
+// NewScriptedBreaker returns a ScriptedBreaker that makes decisions in
+// order. With no decisions it admits every call. Rejected calls fail with
+// circuitbreaker.ErrCircuitOpen unless RejectWith says otherwise.
+func NewScriptedBreaker(decisions ...Decision) *ScriptedBreaker {
+	return &ScriptedBreaker{script: decisions, err: circuitbreaker.ErrCircuitOpen}
+}
+
+// RejectWith sets the error rejected calls fail with, such as
+// circuitbreaker.ErrTooManyRequests or an *circuitbreaker.OpenError.
+func (b *ScriptedBreaker) RejectWith(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// SetState sets the state State reports. It does not change the script.
+func (b *ScriptedBreaker) SetState(state circuitbreaker.State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = state
+}
+
+// Execute runs request if the script admits the call. The options are
+// ignored.
+func (b *ScriptedBreaker) Execute(request func() (any, error), opts ...circuitbreaker.CallOption) (any, error) {
+	if request == nil {
+		return nil, circuitbreaker.ErrNilFunction
+	}
+	if err := b.decide(); err != nil {
+		return nil, err
+	}
+	result, err := request()
+	b.record(err)
+	return result, err
+}
+
+// ExecuteContext runs request with ctx if the script admits the call. The
+// options are ignored, and a ctx that is already done is not checked.
+func (b *ScriptedBreaker) ExecuteContext(ctx context.Context, request func(context.Context) (any, error), opts ...circuitbreaker.CallOption) (any, error) {
+	if request == nil {
+		return nil, circuitbreaker.ErrNilFunction
+	}
+	return b.Execute(func() (any, error) { return request(ctx) })
+}
+
+// State returns the state last set with SetState.
+func (b *ScriptedBreaker) State() circuitbreaker.State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Counts returns the counts of the admitted calls since the last Reset,
+// with the totals since the breaker was made and the state last set.
+// LastStateChange is always zero.
+func (b *ScriptedBreaker) Counts() circuitbreaker.Counts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	counts := b.counts
+	counts.State = b.state
+	return counts
+}
+
+// Reset sets the state back to Closed and clears the counters of Counts
+// but not its totals. The script goes on from where it was, and Calls
+// keeps counting.
+func (b *ScriptedBreaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = circuitbreaker.Closed
+	b.counts.ConsecutiveFailures = 0
+	b.counts.ConsecutiveSuccesses = 0
+	b.counts.Successes = 0
+}
+
+// Calls returns the number of calls admitted and rejected so far.
+func (b *ScriptedBreaker) Calls() (admitted, rejected int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.admitted, b.rejected
+}
+
+// decide takes the next decision, returning the rejection error for Reject.
+func (b *ScriptedBreaker) decide() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	d := Admit
+	if len(b.script) > 0 {
+		d = b.script[b.next]
+		b.next = (b.next + 1) % len(b.script)
+	}
+	b.counts.Requests++
+	if d == Reject {
+		b.rejected++
+		b.counts.Rejected++
+		return b.err
+	}
+	b.admitted++
+	return nil
+}
+
+func (b *ScriptedBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.counts.ConsecutiveFailures++
+		b.counts.ConsecutiveSuccesses = 0
+		b.counts.TotalFailures++
+		return
+	}
+	b.counts.ConsecutiveFailures = 0
+	b.counts.ConsecutiveSuccesses++
+	b.counts.Successes++
+	b.counts.TotalSuccesses++
+}
@@ -0,0 +1,53 @@
+package circuitbreaker
+
+import "errors"
+
+// weigh returns the weight of a call's outcome towards FailureThreshold:
+// 0 for a success and by Config.FailureWeight for a failure. It must not
+// be called with cb.mu held.
+func (cb *CircuitBreaker) weigh(err error) float64 {
+	if err == nil || errors.Is(err, ErrSkipRecording) {
+		return 0
+	}
+	weightOf := cb.config.FailureWeight
+	if weightOf == nil {
+		return 1
+	}
+	weight := 1.0
+	cb.protect("FailureWeight", func() { weight = weightOf(err) })
+	if !(weight > 0) {
+		// negative or NaN.
+		return 0
+	}
+	return weight
+}
+
+// classify returns the outcome the breaker records for a call that
+// returned err: err itself, nil when Config.IsFailure says it is not a
+// failure, or ErrSkipRecording when it is one of Config.IgnoredErrors.
+// Panicked requests, failures reported through Allow and calls that skip
+// recording are left alone, and a panicking IsFailure counts the call as a
+// failure. It must not be called with cb.mu held. A nil breaker counts
+// every error.
+func (cb *CircuitBreaker) classify(err error) error {
+	if cb == nil {
+		return err
+	}
+	if err != nil && err != errPanicked && err != errReportedFailure {
+		for _, ignored := range cb.config.IgnoredErrors {
+			if errors.Is(err, ignored) {
+				return ErrSkipRecording
+			}
+		}
+	}
+	isFailure := cb.config.IsFailure
+	if err == nil || isFailure == nil || err == errPanicked || err == errReportedFailure || errors.Is(err, ErrSkipRecording) {
+		return err
+	}
+	failed := true
+	cb.protect("IsFailure", func() { failed = isFailure(err) })
+	if !failed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+var errNotFound = errors.New("not found")
+
+func ignoreNotFound(err error) bool { return !errors.Is(err, errNotFound) }
+
+func TestIsFailure_IgnoredErrorsNeverTrip(t *testing.T) {
+	var records []circuitbreaker.CallRecord
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		IsFailure:        ignoreNotFound,
+		Strict:           true,
+		OnCall:           func(r circuitbreaker.CallRecord) { records = append(records, r) },
+	})
+
+	notFound := fmt.Errorf("get user 42: %w", errNotFound)
+	for i := 0; i < 100; i++ {
+		if _, err := cb.Execute(func() (any, error) { return nil, notFound }); err != notFound {
+			t.Fatalf("expected the request's own error back, got %v", err)
+		}
+	}
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.Totals.Failures != 0 || s.Totals.Successes != 100 {
+		t.Fatalf("expected ignored errors to count as successes, got %v with totals %+v", s.State, s.Totals)
+	}
+	if records[0].Err != nil {
+		t.Errorf("expected OnCall to see a success, got %v", records[0].Err)
+	}
+
+	// other errors still count, and an ignored one in between resets the run.
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	cb.Execute(func() (any, error) { return nil, notFound })
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected the ignored error to break the run of failures, got %v", cb.State())
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected three failures in a row to trip, got %v", cb.State())
+	}
+}
+
+func TestIsFailure_SkipRecordingAndPanics(t *testing.T) {
+	var panics []circuitbreaker.Panic
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		IsFailure:        func(error) bool { panic("classifier bug") },
+		OnPanic:          func(p circuitbreaker.Panic) { panics = append(panics, p) },
+		Strict:           true,
+	})
+
+	cb.Execute(func() (any, error) { return nil, errCacheHit })
+	if cb.State() != circuitbreaker.Closed || len(panics) != 0 {
+		t.Fatalf("expected ErrSkipRecording to bypass IsFailure, got %v and %d panics", cb.State(), len(panics))
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected a panicking IsFailure to count the call as a failure, got %v", cb.State())
+	}
+	if len(panics) != 1 || panics[0].Component != "IsFailure" {
+		t.Errorf("expected the panic reported for IsFailure, got %+v", panics)
+	}
+}
+
+func TestIgnoredErrors_NeitherSuccessNorFailure(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		IgnoredErrors:    []error{context.Canceled},
+		// IgnoredErrors take precedence over an IsFailure that counts
+		// everything.
+		IsFailure: func(error) bool { return true },
+		Strict:    true,
+	})
+	canceled := func() (any, error) { return nil, fmt.Errorf("client went away: %w", context.Canceled) }
+
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if _, err := cb.Execute(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the caller to get the ignored error, got %v", err)
+	}
+	if got := cb.Counts(); got.ConsecutiveFailures != 2 {
+		t.Errorf("expected an ignored error not to reset the failures, got %+v", got)
+	}
+	if got := cb.Totals(); got.Successes != 0 || got.Failures != 2 {
+		t.Errorf("expected an ignored error in neither total, got %+v", got)
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the third real failure to trip, got %v", cb.State())
+	}
+	called := false
+	cb.Reset()
+	cb.ExecuteWithFallback(canceled, func(error) (any, error) { called = true; return nil, nil })
+	if called {
+		t.Error("expected an ignored error to be returned without calling the fallback")
+	}
+}
+
+func TestIgnoredErrors_HalfOpenProbeChangesNothing(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		IgnoredErrors:    []error{context.DeadlineExceeded},
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	cb.Execute(func() (any, error) { return nil, context.DeadlineExceeded })
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected an ignored probe to neither close nor reopen, got %v", cb.State())
+	}
+	// the ignored probe gave its slot back.
+	if _, err := cb.Execute(successFn); err != nil || cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the next probe admitted and closing, got %v in %v", err, cb.State())
+	}
+}
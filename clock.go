@@ -0,0 +1,57 @@
+package circuitbreaker
+
+import "time"
+
+// Clock tells the circuit breaker what time it is and schedules its timed
+// work. Replace it in tests to make time-dependent behaviour deterministic;
+// circuitbreakertest provides a fake implementation.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc calls f once d has elapsed, like time.AfterFunc. f must
+	// not be called if the returned Timer is stopped first.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call scheduled with Clock.AfterFunc.
+type Timer interface {
+	// Stop prevents the call from running. It reports whether the call
+	// was still pending.
+	Stop() bool
+}
+
+// systemClock is the Clock used when Config.Clock is nil.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// elapsed returns the time from since to now, or zero if now is earlier,
+// as it can be when the wall clock steps backwards between readings that
+// carry no monotonic component, such as restored snapshots or custom
+// clocks.
+func elapsed(since, now time.Time) time.Duration {
+	if d := now.Sub(since); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// sinceStateChange returns how long the circuit has been in its state as
+// of now. If now is earlier than the last state change, the clock has
+// stepped backwards: the change is restamped to now and an EventClockJump
+// emitted, so an open timeout then runs in full from the jump rather than
+// for as long as the clock was set back. Must be called with cb.mu held.
+func (cb *CircuitBreaker) sinceStateChange(now time.Time) time.Duration {
+	if now.Before(cb.lastStateChange) {
+		jump := cb.lastStateChange.Sub(now)
+		cb.lastStateChange = now
+		cb.emit(Event{Type: EventClockJump, Time: now, From: cb.state, To: cb.state, Jump: jump})
+		return 0
+	}
+	return now.Sub(cb.lastStateChange)
+}
@@ -0,0 +1,87 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestClock_BackwardStepRestartsOpenTimeout(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var jumps []circuitbreaker.Event
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          10 * time.Second,
+		Clock:            clock,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.Type == circuitbreaker.EventClockJump {
+				jumps = append(jumps, ev)
+			}
+		},
+	})
+	cb.Execute(failFn)
+	clock.Advance(5 * time.Second)
+
+	// the wall clock is stepped back an hour.
+	clock.Set(clock.Now().Add(-time.Hour))
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the circuit still open, got %v", err)
+	}
+	if len(jumps) != 1 || jumps[0].Jump != time.Hour-5*time.Second {
+		t.Fatalf("expected one clock jump of 59m55s, got %v", jumps)
+	}
+
+	// the timeout runs in full from the jump, not for an extra hour.
+	clock.Advance(10*time.Second - time.Millisecond)
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the circuit open until the timeout has passed, got %v", err)
+	}
+	clock.Advance(time.Millisecond)
+	if _, err := cb.Execute(successFn); err != nil || cb.State() == circuitbreaker.Open {
+		t.Errorf("expected a probe once the timeout passed, got %v in %v", err, cb.State())
+	}
+	if len(jumps) != 1 {
+		t.Errorf("expected the jump reported once, got %d", len(jumps))
+	}
+}
+
+func TestClock_ResetThenTripHonoursTimeout(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: 10 * time.Second, Clock: clock, Strict: true})
+	cb.Reset()
+	if got := cb.Status().LastStateChange; !got.Equal(cbt.Epoch) {
+		t.Errorf("expected Reset to stamp the state change, got %v", got)
+	}
+
+	clock.Advance(time.Hour)
+	cb.Execute(failFn)
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the fresh trip to hold for its timeout, got %v", err)
+	}
+	clock.Advance(10 * time.Second)
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Errorf("expected a probe after the timeout, got %v", err)
+	}
+}
+
+func TestClock_BackwardStepDuringCallRecordsNoNegativeLatency(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var durations []time.Duration
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Clock:  clock,
+		Strict: true,
+		OnCall: func(rec circuitbreaker.CallRecord) { durations = append(durations, rec.Duration) },
+	})
+	cb.Execute(func() (any, error) {
+		clock.Set(clock.Now().Add(-time.Minute))
+		return nil, nil
+	})
+	success, _ := cb.Latencies()
+	if len(durations) != 1 || durations[0] != 0 || success.Sum != 0 {
+		t.Errorf("expected a zero latency, got %v (histogram sum %v)", durations, success.Sum)
+	}
+}
@@ -0,0 +1,71 @@
+package circuitbreaker
+
+// Clone returns a new circuit breaker with the same configuration as cb but
+// pristine state: Closed, zero counters, not closed.
+//
+// The configuration is copied by value. Fields holding functions or
+// interfaces are shared by reference: Clock, Rand, Store, QuorumStore,
+// Pressure, HealthCheck, PreRequestChecks, the strategies such as
+// IsFailure and FailureWeight, and the On* hooks. The clone reads the
+// same clock, draws from the same source and reports to the same hooks as
+// the original. That is deliberate: hooks typically feed logging or
+// metrics that should see both breakers, under the same Name.
+//
+// The clone keeps the Name, and with it the original's key in Store and
+// its InstanceID in QuorumStore. It does not load the saved state, but
+// from its first state change on it saves under the same key as the
+// original, so while both are in use the last to change state wins.
+// Clone to replace a breaker, and Close the original once the swap is
+// done.
+//
+// Cloning a nil breaker returns nil.
+func (cb *CircuitBreaker) Clone() *CircuitBreaker {
+	if cb == nil {
+		return nil
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return newClone(cb.config)
+}
+
+// CloneWithState is like Clone but the new breaker also starts from a copy
+// of cb's current state, counters and timestamps, as if it had seen the
+// same traffic. Copying is not a transition, so OnStateChange is not called.
+func (cb *CircuitBreaker) CloneWithState() *CircuitBreaker {
+	if cb == nil {
+		return nil
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	c := newClone(cb.config)
+	c.state = cb.state
+	c.published.Store(int32(cb.state))
+	c.failures = cb.failures
+	c.failureWeight = cb.failureWeight
+	c.successes = cb.successes
+	c.consecutiveSuccesses = cb.consecutiveSuccesses
+	c.probeFailures = cb.probeFailures
+	c.externalFailures = cb.externalFailures
+	c.ramp = cb.ramp
+	c.generation = cb.generation
+	c.mode = cb.mode
+	c.reopens = cb.reopens
+	c.openJitter = cb.openJitter
+	c.lastFailureTime = cb.lastFailureTime
+	c.lastStateChange = cb.lastStateChange
+	return c
+}
+
+// newClone is New for a clone, which starts from its own state rather
+// than the one in Config.Store but still saves its changes there.
+func newClone(cfg Config) *CircuitBreaker {
+	store := cfg.Store
+	cfg.Store = nil
+	c := New(cfg)
+	c.config.Store = store
+	return c
+}
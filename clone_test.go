@@ -0,0 +1,126 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestClone_TripsIndependently(t *testing.T) {
+	var transitions []string
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "original",
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Second,
+		Clock:            clock,
+		Strict:           true,
+		OnStateChange: func(name string, from, to circuitbreaker.State) {
+			transitions = append(transitions, name+":"+to.String())
+		},
+	})
+	cb.Execute(failFn)
+
+	clone := cb.Clone()
+	if clone.State() != circuitbreaker.Closed || clone.Counts() != (circuitbreaker.Counts{}) {
+		t.Fatalf("expected pristine clone, got %v %+v", clone.State(), clone.Counts())
+	}
+
+	clone.Execute(failFn)
+	clone.Execute(failFn)
+
+	if clone.State() != circuitbreaker.Open {
+		t.Errorf("expected clone Open, got %v", clone.State())
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected original still Closed, got %v", cb.State())
+	}
+	if got := cb.Counts().ConsecutiveFailures; got != 1 {
+		t.Errorf("expected original to keep 1 failure, got %d", got)
+	}
+
+	// The hook is shared by reference and the clone keeps the name.
+	if len(transitions) != 1 || transitions[0] != "original:Open" {
+		t.Errorf("expected the clone's trip on the shared hook, got %v", transitions)
+	}
+
+	// The clone reads the same clock.
+	clock.Advance(time.Second)
+	if _, err := clone.Execute(successFn); err != nil {
+		t.Errorf("expected clone to probe after the shared clock advanced, got %v", err)
+	}
+}
+
+func TestCloneWithState(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "original",
+		FailureThreshold: 3,
+		SuccessThreshold: 3,
+		Timeout:          time.Minute,
+		Strict:           true,
+	})
+	cbt.AdvanceToHalfOpen(cb)
+	cb.Execute(successFn)
+
+	clone := cb.CloneWithState()
+	if clone.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected clone in HalfOpen, got %v", clone.State())
+	}
+	if counters(clone.Counts()) != counters(cb.Counts()) {
+		t.Fatalf("expected counts %+v, got %+v", cb.Counts(), clone.Counts())
+	}
+
+	// Two more successes close the clone; the original still needs them.
+	clone.Execute(successFn)
+	clone.Execute(successFn)
+	if clone.State() != circuitbreaker.Closed {
+		t.Errorf("expected clone Closed, got %v", clone.State())
+	}
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Errorf("expected original still HalfOpen, got %v", cb.State())
+	}
+}
+
+func TestCloneWithState_TripsLikeTheOriginal(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:    3,
+		SuccessThreshold:    3,
+		HalfOpenMaxFailures: 2,
+		Timeout:             time.Minute,
+		Clock:               clock,
+		Strict:              true,
+	})
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+
+	// one failure short of the threshold: the next trips both.
+	clone := cb.CloneWithState()
+	cb.Execute(failFn)
+	clone.Execute(failFn)
+	if cb.State() != circuitbreaker.Open || clone.State() != circuitbreaker.Open {
+		t.Fatalf("expected the next failure to trip both, got %v and clone %v", cb.State(), clone.State())
+	}
+
+	// one failed probe short of HalfOpenMaxFailures: the next reopens both.
+	clock.Advance(time.Minute)
+	cb.Execute(failFn)
+	clone = cb.CloneWithState()
+	cb.Execute(failFn)
+	clone.Execute(failFn)
+	if cb.State() != circuitbreaker.Open || clone.State() != circuitbreaker.Open {
+		t.Errorf("expected the next failed probe to reopen both, got %v and clone %v", cb.State(), clone.State())
+	}
+}
+
+func TestClone_OfClosedBreakerIsUsable(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.DefaultConfig())
+	cb.Close()
+
+	if _, err := cb.Clone().Execute(successFn); err != nil {
+		t.Errorf("expected clone of a closed breaker to work, got %v", err)
+	}
+}
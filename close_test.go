@@ -0,0 +1,116 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestClose_RejectsLaterCalls(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.DefaultConfig())
+
+	if err := cb.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	ran := false
+	_, err := cb.Execute(func() (any, error) {
+		ran = true
+		return nil, nil
+	})
+	if !errors.Is(err, circuitbreaker.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if ran {
+		t.Error("request must not run on a closed breaker")
+	}
+}
+
+func TestClose_Idempotent(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.DefaultConfig())
+	for i := 0; i < 3; i++ {
+		if err := cb.Close(); err != nil {
+			t.Fatalf("Close call %d returned %v", i+1, err)
+		}
+	}
+}
+
+// backgroundConfig turns on every feature that does work in the
+// background, with a HealthCheck that runs until it is cancelled and
+// counts its runs in checks.
+func backgroundConfig(clock *cbt.FakeClock, checks *atomic.Int32) circuitbreaker.Config {
+	return circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Hour,
+		HealthCheck: func(ctx context.Context) error {
+			checks.Add(1)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		HealthCheckInterval: 10 * time.Millisecond,
+		DiagnoseInterval:    10 * time.Millisecond,
+		Pressure:            &fakePressure{},
+		PressureInterval:    10 * time.Millisecond,
+		QuorumStore:         circuitbreaker.NewMemoryQuorumStore(clock),
+		Quorum:              2,
+		QuorumInterval:      10 * time.Millisecond,
+		InstanceID:          "a",
+		InFlightDeadline:    40 * time.Millisecond,
+		MaintenanceWindows: []circuitbreaker.Window{
+			{Start: clock.Now().Add(time.Hour), Duration: time.Minute},
+		},
+		OpenAlertAfter: 20 * time.Millisecond,
+		Clock:          clock,
+		Strict:         true,
+	}
+}
+
+func TestClose_StopsBackgroundTimers(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var checks atomic.Int32
+	cfg := backgroundConfig(clock, &checks)
+	cfg.HealthCheck = func(context.Context) error {
+		checks.Add(1)
+		return errSimulated
+	}
+	cb := circuitbreaker.New(cfg)
+	cb.Execute(failFn)
+	clock.Advance(10 * time.Millisecond)
+	if checks.Load() == 0 {
+		t.Fatal("expected the health check to run while open")
+	}
+	if n := clock.PendingTimers(); n < 7 {
+		t.Fatalf("expected a timer for each background feature, got %d", n)
+	}
+
+	cb.Close()
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop every timer, %d left", n)
+	}
+	ran := checks.Load()
+	clock.Advance(2 * time.Hour)
+	if checks.Load() != ran {
+		t.Error("expected no health checks after Close")
+	}
+}
+
+func TestClose_NoGoroutineLeak(t *testing.T) {
+	defer cbt.CheckGoroutines(t)()
+
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var checks atomic.Int32
+	cb := circuitbreaker.New(backgroundConfig(clock, &checks))
+	cb.Execute(failFn)
+	// the check runs on the advancing goroutine and blocks there until its
+	// context is cancelled, which only Close can do now.
+	go clock.Advance(10 * time.Millisecond)
+	for checks.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cb.Close()
+}
@@ -0,0 +1,52 @@
+// Package example declares interfaces covering what cbgen has to handle.
+// The decorators generated for them are cbgen's golden files: the tests
+// check that regenerating them changes nothing, and exercise them here.
+package example
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+//go:generate go run github.com/teresamychu/circuitbreaker/cmd/cbgen -type PaymentsClient
+//go:generate go run github.com/teresamychu/circuitbreaker/cmd/cbgen -type Store
+
+// ChargeRequest asks for a payment.
+type ChargeRequest struct {
+	Account string
+	Amount  int64
+}
+
+// Receipt confirms a payment.
+type Receipt struct {
+	ID string
+}
+
+// PaymentsClient is a typical context-first client.
+type PaymentsClient interface {
+	Charge(ctx context.Context, req ChargeRequest) (*Receipt, error)
+	Refund(ctx context.Context, receiptID string, reasons ...string) error
+	Balance(context.Context) (int64, time.Time, error)
+	Name() string
+}
+
+// PutOption configures Store.Put.
+type PutOption func(*PutOptions)
+
+// PutOptions holds the settings of a Put.
+type PutOptions struct {
+	TTL time.Duration
+}
+
+// Store has no contexts, embeds an interface from another package and
+// uses parameter names that collide with the generated code's.
+type Store interface {
+	fmt.Stringer
+	Get(key string) ([]byte, bool, error)
+	Put(key string, value []byte, opts ...PutOption) error
+	Dump(w io.Writer, err error, r0 int) (int64, error)
+	Expire(time time.Duration) error
+	Close()
+}
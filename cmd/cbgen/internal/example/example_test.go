@@ -0,0 +1,106 @@
+package example_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	"github.com/teresamychu/circuitbreaker/cmd/cbgen/internal/example"
+)
+
+var errDeclined = errors.New("card declined")
+
+type ctxKey struct{}
+
+// payments is a fake PaymentsClient whose calls fail while down.
+type payments struct {
+	down  bool
+	calls int
+	// Value found under ctxKey by the latest call.
+	seen any
+}
+
+func (p *payments) Charge(ctx context.Context, req example.ChargeRequest) (*example.Receipt, error) {
+	p.calls++
+	p.seen = ctx.Value(ctxKey{})
+	if p.down {
+		return &example.Receipt{ID: "partial"}, errDeclined
+	}
+	return &example.Receipt{ID: req.Account}, nil
+}
+
+func (p *payments) Refund(_ context.Context, _ string, reasons ...string) error {
+	p.calls++
+	if p.down || len(reasons) == 0 {
+		return errDeclined
+	}
+	return nil
+}
+
+func (p *payments) Balance(context.Context) (int64, time.Time, error) {
+	p.calls++
+	return 42, time.Unix(1, 0), nil
+}
+
+func (p *payments) Name() string { return "payments" }
+
+func TestWithBreaker_PassesThroughAndTrips(t *testing.T) {
+	fake := &payments{}
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	client := example.NewPaymentsClientWithBreaker(fake, cb)
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "traced")
+	r, err := client.Charge(ctx, example.ChargeRequest{Account: "acct-1"})
+	if err != nil || r.ID != "acct-1" || fake.seen != "traced" {
+		t.Fatalf("expected the call and its context to pass through, got %v, %v, %v", r, err, fake.seen)
+	}
+	if n, at, err := client.Balance(ctx); n != 42 || !at.Equal(time.Unix(1, 0)) || err != nil {
+		t.Errorf("expected every result back, got %v, %v, %v", n, at, err)
+	}
+	if err := client.Refund(ctx, "r-1", "duplicate", "fraud"); err != nil {
+		t.Errorf("expected variadic arguments to pass through, got %v", err)
+	}
+
+	fake.down = true
+	r, err = client.Charge(ctx, example.ChargeRequest{})
+	if err != errDeclined || r == nil || r.ID != "partial" {
+		t.Errorf("expected the call's results and error unchanged, got %v, %v", r, err)
+	}
+	client.Refund(ctx, "r-2")
+	calls := fake.calls
+	r, err = client.Charge(ctx, example.ChargeRequest{})
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) || r != nil {
+		t.Errorf("expected a rejection with zero values, got %v, %v", r, err)
+	}
+	if fake.calls != calls {
+		t.Error("a rejected call reached the client")
+	}
+	if client.Name() != "payments" {
+		t.Error("expected methods without an error result to pass through while open")
+	}
+}
+
+func TestWithBreakerPerMethod_KeysByMethod(t *testing.T) {
+	fake := &payments{}
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	defer g.Close()
+	client := example.NewPaymentsClientWithBreakerPerMethod(fake, g)
+	ctx := context.Background()
+
+	// refunds without a reason fail and trip only Refund's breaker.
+	for i := 0; i < 3; i++ {
+		client.Refund(ctx, "r")
+	}
+	if err := client.Refund(ctx, "r", "ok"); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected Refund's circuit open, got %v", err)
+	}
+	if _, err := client.Charge(ctx, example.ChargeRequest{Account: "a"}); err != nil {
+		t.Errorf("expected Charge unaffected, got %v", err)
+	}
+	keys := g.Keys()
+	if len(keys) != 2 || keys[0] != "PaymentsClient.Charge" || keys[1] != "PaymentsClient.Refund" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
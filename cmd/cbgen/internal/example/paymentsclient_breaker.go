@@ -0,0 +1,75 @@
+// Code generated by cbgen. DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// PaymentsClientWithBreaker is a PaymentsClient that runs every call
+// through a circuit breaker. Errors from the wrapped PaymentsClient are returned
+// unchanged; calls the breaker rejects return zero values and the
+// breaker's error.
+type PaymentsClientWithBreaker struct {
+	next    PaymentsClient
+	execute func(ctx context.Context, method string, fn func(context.Context) (any, error)) (any, error)
+}
+
+var _ PaymentsClient = (*PaymentsClientWithBreaker)(nil)
+
+// NewPaymentsClientWithBreaker returns a PaymentsClient whose methods all
+// run through cb.
+func NewPaymentsClientWithBreaker(next PaymentsClient, cb *circuitbreaker.CircuitBreaker) *PaymentsClientWithBreaker {
+	return &PaymentsClientWithBreaker{
+		next: next,
+		execute: func(ctx context.Context, _ string, fn func(context.Context) (any, error)) (any, error) {
+			return cb.ExecuteContext(ctx, fn)
+		},
+	}
+}
+
+// NewPaymentsClientWithBreakerPerMethod returns a PaymentsClient whose
+// methods each run through the breaker g keeps for "PaymentsClient.<Method>".
+func NewPaymentsClientWithBreakerPerMethod(next PaymentsClient, g *circuitbreaker.Group) *PaymentsClientWithBreaker {
+	return &PaymentsClientWithBreaker{
+		next: next,
+		execute: func(ctx context.Context, method string, fn func(context.Context) (any, error)) (any, error) {
+			return g.Execute(method, func() (any, error) { return fn(ctx) })
+		},
+	}
+}
+
+func (w *PaymentsClientWithBreaker) Balance(p0 context.Context) (int64, time.Time, error) {
+	var r0 int64
+	var r1 time.Time
+	_, err := w.execute(p0, "PaymentsClient.Balance", func(p0 context.Context) (any, error) {
+		var err error
+		r0, r1, err = w.next.Balance(p0)
+		return nil, err
+	})
+	return r0, r1, err
+}
+
+func (w *PaymentsClientWithBreaker) Charge(ctx context.Context, req ChargeRequest) (*Receipt, error) {
+	var r0 *Receipt
+	_, err := w.execute(ctx, "PaymentsClient.Charge", func(ctx context.Context) (any, error) {
+		var err error
+		r0, err = w.next.Charge(ctx, req)
+		return nil, err
+	})
+	return r0, err
+}
+
+func (w *PaymentsClientWithBreaker) Name() string {
+	return w.next.Name()
+}
+
+func (w *PaymentsClientWithBreaker) Refund(ctx context.Context, receiptID string, reasons ...string) error {
+	_, err := w.execute(ctx, "PaymentsClient.Refund", func(ctx context.Context) (any, error) {
+		return nil, w.next.Refund(ctx, receiptID, reasons...)
+	})
+	return err
+}
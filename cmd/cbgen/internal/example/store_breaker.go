@@ -0,0 +1,87 @@
+// Code generated by cbgen. DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// StoreWithBreaker is a Store that runs every call
+// through a circuit breaker. Errors from the wrapped Store are returned
+// unchanged; calls the breaker rejects return zero values and the
+// breaker's error.
+type StoreWithBreaker struct {
+	next    Store
+	execute func(ctx context.Context, method string, fn func(context.Context) (any, error)) (any, error)
+}
+
+var _ Store = (*StoreWithBreaker)(nil)
+
+// NewStoreWithBreaker returns a Store whose methods all
+// run through cb.
+func NewStoreWithBreaker(next Store, cb *circuitbreaker.CircuitBreaker) *StoreWithBreaker {
+	return &StoreWithBreaker{
+		next: next,
+		execute: func(ctx context.Context, _ string, fn func(context.Context) (any, error)) (any, error) {
+			return cb.ExecuteContext(ctx, fn)
+		},
+	}
+}
+
+// NewStoreWithBreakerPerMethod returns a Store whose
+// methods each run through the breaker g keeps for "Store.<Method>".
+func NewStoreWithBreakerPerMethod(next Store, g *circuitbreaker.Group) *StoreWithBreaker {
+	return &StoreWithBreaker{
+		next: next,
+		execute: func(ctx context.Context, method string, fn func(context.Context) (any, error)) (any, error) {
+			return g.Execute(method, func() (any, error) { return fn(ctx) })
+		},
+	}
+}
+
+func (w *StoreWithBreaker) Close() {
+	w.next.Close()
+}
+
+func (w *StoreWithBreaker) Dump(p0 io.Writer, p1 error, p2 int) (int64, error) {
+	var r0 int64
+	_, err := w.execute(context.Background(), "Store.Dump", func(_ context.Context) (any, error) {
+		var err error
+		r0, err = w.next.Dump(p0, p1, p2)
+		return nil, err
+	})
+	return r0, err
+}
+
+func (w *StoreWithBreaker) Expire(p0 time.Duration) error {
+	_, err := w.execute(context.Background(), "Store.Expire", func(_ context.Context) (any, error) {
+		return nil, w.next.Expire(p0)
+	})
+	return err
+}
+
+func (w *StoreWithBreaker) Get(key string) ([]byte, bool, error) {
+	var r0 []byte
+	var r1 bool
+	_, err := w.execute(context.Background(), "Store.Get", func(_ context.Context) (any, error) {
+		var err error
+		r0, r1, err = w.next.Get(key)
+		return nil, err
+	})
+	return r0, r1, err
+}
+
+func (w *StoreWithBreaker) Put(key string, value []byte, opts ...PutOption) error {
+	_, err := w.execute(context.Background(), "Store.Put", func(_ context.Context) (any, error) {
+		return nil, w.next.Put(key, value, opts...)
+	})
+	return err
+}
+
+func (w *StoreWithBreaker) String() string {
+	return w.next.String()
+}
@@ -0,0 +1,366 @@
+// Cbgen generates circuit breaker decorators for interfaces.
+//
+// Given an interface in the package in the current directory, it writes a
+// struct implementing the same interface whose methods call through to
+// another implementation via a circuit breaker:
+//
+//	//go:generate go run github.com/teresamychu/circuitbreaker/cmd/cbgen -type PaymentsClient
+//
+// writes paymentsclient_breaker.go with a PaymentsClientWithBreaker type
+// and two constructors: NewPaymentsClientWithBreaker runs every method
+// through one breaker, and NewPaymentsClientWithBreakerPerMethod through
+// the breaker a circuitbreaker.Group keeps for "PaymentsClient.<Method>".
+//
+// Methods whose first parameter is a context.Context run with
+// ExecuteContext and pass the context on. Methods whose last result is an
+// error return the wrapped call's results and error unchanged, or zero
+// values and the breaker's error when it rejects the call. Methods without
+// an error result have no way to report a rejection and are called
+// directly.
+//
+// The generated file depends only on the circuitbreaker package, not on
+// cbgen.
+//
+// Usage:
+//
+//	cbgen -type Name [-dir .] [-output file] [-name DecoratorName]
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// header starts every generated file.
+const header = "// Code generated by cbgen. DO NOT EDIT.\n"
+
+const breakerPath = "github.com/teresamychu/circuitbreaker"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cbgen: ")
+	typeName := flag.String("type", "", "name of the interface to wrap (required)")
+	dir := flag.String("dir", ".", "directory of the package declaring the interface")
+	output := flag.String("output", "", "output file name; default <type>_breaker.go in -dir")
+	name := flag.String("name", "", "name of the generated type; default <type>WithBreaker")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src, err := generate(*dir, *typeName, *name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := *output
+	if out == "" {
+		out = filepath.Join(*dir, strings.ToLower(*typeName)+"_breaker.go")
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the source of a decorator for the interface typeName
+// declared in the package in dir.
+func generate(dir, typeName, name string) ([]byte, error) {
+	pkg, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s: no type %s", pkg.Path(), typeName)
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is an alias, not a named interface", pkg.Name(), typeName)
+	}
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not an interface", pkg.Name(), typeName)
+	}
+	if named.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("%s.%s is generic, which is not supported", pkg.Name(), typeName)
+	}
+	if !iface.IsMethodSet() {
+		return nil, fmt.Errorf("%s.%s is a constraint, not a method set", pkg.Name(), typeName)
+	}
+	if name == "" {
+		name = typeName + "WithBreaker"
+	}
+
+	g := &generator{pkg: pkg, imports: map[string]string{}, names: map[string]string{}, pkgNames: map[string]string{}}
+	g.importName("context", "context")
+	g.importName(breakerPath, "circuitbreaker")
+	var methods bytes.Buffer
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if !m.Exported() && m.Pkg() != pkg {
+			return nil, fmt.Errorf("%s.%s has unexported method %s from another package", pkg.Name(), typeName, m.Name())
+		}
+		g.method(&methods, name, typeName, m)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(header + "\n")
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", pkg.Name())
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	// standard library first, as goimports groups them.
+	sort.Slice(paths, func(i, j int) bool {
+		if si, sj := isStd(paths[i]), isStd(paths[j]); si != sj {
+			return si
+		}
+		return paths[i] < paths[j]
+	})
+	for i, path := range paths {
+		if i > 0 && isStd(path) != isStd(paths[i-1]) {
+			b.WriteString("\n")
+		}
+		alias := g.imports[path]
+		if alias == g.pkgNames[path] {
+			fmt.Fprintf(&b, "\t%q\n", path)
+		} else {
+			fmt.Fprintf(&b, "\t%s %q\n", alias, path)
+		}
+	}
+	b.WriteString(")\n\n")
+	fmt.Fprintf(&b, `// %[1]s is a %[2]s that runs every call
+// through a circuit breaker. Errors from the wrapped %[2]s are returned
+// unchanged; calls the breaker rejects return zero values and the
+// breaker's error.
+type %[1]s struct {
+	next    %[2]s
+	execute func(ctx %[3]s.Context, method string, fn func(%[3]s.Context) (any, error)) (any, error)
+}
+
+var _ %[2]s = (*%[1]s)(nil)
+
+// New%[1]s returns a %[2]s whose methods all
+// run through cb.
+func New%[1]s(next %[2]s, cb *%[4]s.CircuitBreaker) *%[1]s {
+	return &%[1]s{
+		next: next,
+		execute: func(ctx %[3]s.Context, _ string, fn func(%[3]s.Context) (any, error)) (any, error) {
+			return cb.ExecuteContext(ctx, fn)
+		},
+	}
+}
+
+// New%[1]sPerMethod returns a %[2]s whose
+// methods each run through the breaker g keeps for "%[2]s.<Method>".
+func New%[1]sPerMethod(next %[2]s, g *%[4]s.Group) *%[1]s {
+	return &%[1]s{
+		next: next,
+		execute: func(ctx %[3]s.Context, method string, fn func(%[3]s.Context) (any, error)) (any, error) {
+			return g.Execute(method, func() (any, error) { return fn(ctx) })
+		},
+	}
+}
+`, name, typeName, g.imports["context"], g.imports[breakerPath])
+	b.Write(methods.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, b.Bytes())
+	}
+	return src, nil
+}
+
+// load type-checks the package in dir. Files generated by cbgen are left
+// out so that a stale one cannot stop its own regeneration, and errors are
+// tolerated as long as the declarations cbgen needs come through.
+func load(dir string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if len(f.Comments) > 0 && strings.HasPrefix(f.Comments[0].Text(), strings.TrimPrefix(header, "// ")) {
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, errors.New(dir + ": no Go files")
+	}
+	path := bp.ImportPath
+	if path == "" || path == "." {
+		path = bp.Name
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(path, fset, files, nil)
+	return pkg, nil
+}
+
+// generator writes methods and tracks the imports they need.
+type generator struct {
+	pkg *types.Package
+	// imports maps import paths to the names they are used under, and
+	// names the other way round.
+	imports map[string]string
+	names   map[string]string
+	// pkgNames maps import paths to package names.
+	pkgNames map[string]string
+}
+
+// importName returns the name the package name at path is imported under,
+// adding the import if needed and renaming it if another import has its
+// name.
+func (g *generator) importName(path, base string) string {
+	if name, ok := g.imports[path]; ok {
+		return name
+	}
+	name := base
+	for n := 2; g.names[name] != ""; n++ {
+		name = base + strconv.Itoa(n)
+	}
+	g.imports[path] = name
+	g.names[name] = path
+	g.pkgNames[path] = base
+	return name
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		return g.importName(p.Path(), p.Name())
+	})
+}
+
+// method writes the decorator's implementation of m.
+func (g *generator) method(b *bytes.Buffer, recvType, ifaceName string, m *types.Func) {
+	sig := m.Type().(*types.Signature)
+	params, results := sig.Params(), sig.Results()
+
+	// names the generated body uses, which parameters must not shadow.
+	reserved := map[string]bool{"w": true, "err": true, "_": true, "": true}
+	for i := 0; i < results.Len(); i++ {
+		reserved["r"+strconv.Itoa(i)] = true
+	}
+	paramTypes := make([]string, params.Len())
+	for i := range paramTypes {
+		t := params.At(i).Type()
+		if sig.Variadic() && i == params.Len()-1 {
+			paramTypes[i] = "..." + g.typeString(t.(*types.Slice).Elem())
+		} else {
+			paramTypes[i] = g.typeString(t)
+		}
+	}
+	var resultTypes []string
+	for i := 0; i < results.Len(); i++ {
+		resultTypes = append(resultTypes, g.typeString(results.At(i).Type()))
+	}
+	for name := range g.names {
+		reserved[name] = true
+	}
+	names := make([]string, params.Len())
+	used := map[string]bool{}
+	for i := range names {
+		n := params.At(i).Name()
+		if reserved[n] || used[n] {
+			n = "p" + strconv.Itoa(i)
+		}
+		names[i], used[n] = n, true
+	}
+
+	var decl, args []string
+	for i := range names {
+		decl = append(decl, names[i]+" "+paramTypes[i])
+		arg := names[i]
+		if sig.Variadic() && i == len(names)-1 {
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	resultDecl := strings.Join(resultTypes, ", ")
+	if len(resultTypes) > 1 {
+		resultDecl = "(" + resultDecl + ")"
+	}
+	call := fmt.Sprintf("w.next.%s(%s)", m.Name(), strings.Join(args, ", "))
+	fmt.Fprintf(b, "\nfunc (w *%s) %s(%s) %s {\n", recvType, m.Name(), strings.Join(decl, ", "), resultDecl)
+
+	last := results.Len() - 1
+	if last < 0 || !types.Identical(results.At(last).Type(), types.Universe.Lookup("error").Type()) {
+		// nowhere to report a rejection.
+		if last < 0 {
+			fmt.Fprintf(b, "\t%s\n}\n", call)
+		} else {
+			fmt.Fprintf(b, "\treturn %s\n}\n", call)
+		}
+		return
+	}
+
+	ctx := g.imports["context"] + ".Background()"
+	ctxName := "_"
+	if t := g.contextType(); t != nil && params.Len() > 0 && types.Identical(params.At(0).Type(), t) {
+		ctx, ctxName = names[0], names[0]
+	}
+	for i := 0; i < last; i++ {
+		fmt.Fprintf(b, "\tvar r%d %s\n", i, resultTypes[i])
+	}
+	fmt.Fprintf(b, "\t_, err := w.execute(%s, %q, func(%s %s.Context) (any, error) {\n",
+		ctx, ifaceName+"."+m.Name(), ctxName, g.imports["context"])
+	if last == 0 {
+		fmt.Fprintf(b, "\t\treturn nil, %s\n", call)
+	} else {
+		var outs []string
+		for i := 0; i < last; i++ {
+			outs = append(outs, "r"+strconv.Itoa(i))
+		}
+		fmt.Fprintf(b, "\t\tvar err error\n\t\t%s, err = %s\n\t\treturn nil, err\n", strings.Join(outs, ", "), call)
+	}
+	b.WriteString("\t})\n\treturn ")
+	for i := 0; i < last; i++ {
+		fmt.Fprintf(b, "r%d, ", i)
+	}
+	b.WriteString("err\n}\n")
+}
+
+// isStd reports whether path is in the standard library.
+func isStd(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// contextType is context.Context.
+func (g *generator) contextType() types.Type {
+	for _, imp := range g.pkg.Imports() {
+		if imp.Path() == "context" {
+			if obj := imp.Scope().Lookup("Context"); obj != nil {
+				return obj.Type()
+			}
+		}
+	}
+	return nil
+}
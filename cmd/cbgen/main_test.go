@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// The generated decorators in internal/example are the golden files.
+func TestGenerate_Golden(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	for _, typeName := range []string{"PaymentsClient", "Store"} {
+		t.Run(typeName, func(t *testing.T) {
+			got, err := generate(dir, typeName, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join(dir, strings.ToLower(typeName)+"_breaker.go")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("generated code differs from %s; rerun with -update if the change is intended\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestGenerate_Name(t *testing.T) {
+	src, err := generate(filepath.Join("internal", "example"), "Store", "GuardedStore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"type GuardedStore struct", "func NewGuardedStore(", "func NewGuardedStorePerMethod("} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("expected %q in the generated code", want)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	dir := filepath.Join("testdata", "bad")
+	for typeName, want := range map[string]string{
+		"Missing":  "no type Missing",
+		"Concrete": "not an interface",
+		"Generic":  "generic",
+		"Number":   "constraint",
+	} {
+		if _, err := generate(dir, typeName, ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", typeName, want, err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package bad
+
+// Concrete is not an interface.
+type Concrete struct{}
+
+// Generic has a type parameter.
+type Generic[T any] interface {
+	Get() (T, error)
+}
+
+// Number is a constraint.
+type Number interface {
+	~int | ~float64
+}
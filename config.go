@@ -1,6 +1,17 @@
 package circuitbreaker
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// breakerSeq numbers generated breaker names.
+var breakerSeq atomic.Uint64
 
 // Config holds the circuit breaker configuration.
 type Config struct {
@@ -15,6 +26,444 @@ type Config struct {
 
 	// Timeout is how long to stay open before transitioning to half-open
 	Timeout time.Duration
+
+	// OpenTimeoutBackoff, when its MaxTimeout is set, replaces the fixed
+	// Timeout with one that starts at Initial and is multiplied by
+	// Multiplier each time a half-open probe fails, up to MaxTimeout. The
+	// circuit closing starts it over from Initial.
+	OpenTimeoutBackoff OpenBackoff
+
+	// TimeoutJitter spreads each open period uniformly over [t*(1-j),
+	// t*(1+j)] around the open timeout t, drawing afresh from Rand every
+	// time the circuit opens, so that replicas which tripped together do
+	// not all probe together. 0.2 spreads a 10s timeout over [8s, 12s].
+	// Must be in [0, 1).
+	TimeoutJitter float64
+
+	// MaxHalfOpenRequests is the number of probes a half-open circuit lets
+	// run at once, so a burst of callers does not hit a recovering
+	// dependency all together. Fewer run when fewer successes are still
+	// needed to close. Callers beyond it get ErrTooManyRequests.
+	MaxHalfOpenRequests int
+
+	// HalfOpenPolicy says how the successes of half-open probes add up to
+	// SuccessThreshold: in total, the default, or in a row, so a
+	// dependency that keeps flapping does not close the circuit.
+	HalfOpenPolicy HalfOpenPolicy
+
+	// HalfOpenMaxFailures is the number of failed probes that reopen a
+	// half-open circuit. The default of 1 reopens it on the first one; a
+	// higher value tolerates the odd failure, which under
+	// ConsecutiveSuccesses still starts the count of successes over. An
+	// unhealthy ObserveExternal report or health check reopens the circuit
+	// regardless.
+	HalfOpenMaxFailures int
+
+	// RampUp, when set, replaces the half-open probes with a gradual return
+	// of traffic, so a dependency that has barely recovered is not hit
+	// with all of it at once. Once the open timeout has passed, each call
+	// is let through with the probability of the current step's Percent,
+	// drawn from Rand, and the rest are rejected with ErrCircuitOpen. Each
+	// step lasts its Duration, measured from when the circuit went
+	// half-open, and the circuit closes once the last one is over. Once
+	// at least RampMinRequests calls admitted during the ramp have
+	// completed, a failure rate among them at or above RampFailureRate
+	// reopens the circuit. SuccessThreshold and MaxHalfOpenRequests do not
+	// apply, and healthy ObserveExternal reports and health checks do not
+	// cut the ramp short. The state is HalfOpen throughout;
+	// Status.RampPercent reports the current step. RampFailureRate
+	// defaults to 0.5 and RampMinRequests to 5.
+	RampUp          []RampStep
+	RampFailureRate float64
+	RampMinRequests int
+
+	// MaxOpenDuration, when non-zero, is a ceiling on how long the circuit
+	// stays open before a half-open probe is let through, whatever the
+	// open timeout has grown to. A transition it forces carries the
+	// reason "max open duration", so hitting the ceiling is visible. It
+	// must be below Timeout, or below OpenTimeoutBackoff.MaxTimeout with
+	// backoff on, plus any TimeoutJitter, or it could never apply;
+	// Validate rejects it otherwise.
+	MaxOpenDuration time.Duration
+
+	// MaxConcurrent, when non-zero, bounds the total cost of the calls
+	// running at once. Every call costs 1 unless it declares otherwise with
+	// WithCost; calls that do not fit are rejected with a
+	// *BulkheadFullError.
+	MaxConcurrent int
+
+	// MaxQueueWait and MaxQueueDepth enable a waiting room for
+	// ExecuteContext. When the circuit is half-open with every probe slot
+	// taken, or open but due to half-open within MaxQueueWait, up to
+	// MaxQueueDepth callers wait in FIFO order for up to MaxQueueWait
+	// instead of being rejected straight away. Callers that would have to
+	// wait longer, or find the queue full, are still rejected immediately.
+	// Both must be set for callers to wait.
+	MaxQueueWait  time.Duration
+	MaxQueueDepth int
+
+	// FairProbes shares half-open probe slots round-robin between the
+	// tenants named with ForTenant: every tenant that has asked for calls
+	// since the circuit opened gets a probe before any gets a second, so
+	// the busiest tenant cannot take them all. A tenant that has not
+	// asked for Timeout no longer holds the others back. Up to
+	// FairProbeTenants tenants are tracked, the least recently seen being
+	// forgotten first; it defaults to 100.
+	FairProbes       bool
+	FairProbeTenants int
+
+	// PreRequestChecks are run in order before every call is admitted,
+	// whatever the circuit's state, for gating the breaker itself knows
+	// nothing about: a custom rate limit, a maintenance window kept
+	// elsewhere, or another breaker that must not be open (see
+	// DependsOn). The first to return an error turns the call away
+	// without running it or counting anything but the rejection; the
+	// caller gets that error wrapped in ErrFailedChecks. Checks are given
+	// the breaker and run on the caller's goroutine without its lock
+	// held, so they may call its methods but should be quick. A check that
+	// panics fails, and the panic goes to OnPanic.
+	PreRequestChecks []func(cb *CircuitBreaker) error
+
+	// RetryBudgetRatio, when non-zero, limits retries (calls made with
+	// AsRetry, including ExecuteWithRetry's) to a fraction of the traffic:
+	// every successful first attempt adds RetryBudgetRatio tokens to a
+	// bucket, and every retry needs a whole token. The bucket starts with
+	// RetryBudgetMinTokens and holds at most RetryBudgetMaxTokens, which
+	// bounds the burst of retries saved up while healthy.
+	RetryBudgetRatio     float64
+	RetryBudgetMinTokens float64
+	RetryBudgetMaxTokens float64
+
+	// IsFailure, if set, decides which errors returned by requests count
+	// as failures. Errors it reports false for, such as a not-found or a
+	// validation error that says nothing about the dependency's health,
+	// count as successes, in OnCall records too, while the caller still
+	// gets the error unchanged. When nil, every non-nil error is a
+	// failure. A panic in IsFailure counts the call as a failure.
+	IsFailure func(err error) bool
+
+	// IgnoredErrors are errors that say nothing about the dependency's
+	// health, such as context.Canceled when clients disconnect. A call
+	// whose error matches one of them under errors.Is is recorded as
+	// neither a success nor a failure, as with ErrSkipRecording: it leaves
+	// the counters alone and, as a half-open probe, neither closes nor
+	// reopens the circuit. The caller still gets the error. IgnoredErrors
+	// are checked first, so IsFailure is not consulted for them.
+	IgnoredErrors []error
+
+	// FailureWeight, if set, weighs each failure, so that a refused
+	// connection, which says the dependency is down, can count for more
+	// than a timeout that may be noise. The weights of consecutive
+	// failures add up, and the circuit opens when they reach
+	// FailureThreshold; a success resets them as it does the count. When
+	// nil, or for a call that ran past InFlightDeadline, every failure
+	// weighs 1. Negative weights count as 0, and a panic as 1.
+	FailureWeight func(err error) float64
+
+	// ExternalFailureThreshold is the number of consecutive unhealthy
+	// ObserveExternal signals that open the circuit. Defaults to
+	// FailureThreshold.
+	ExternalFailureThreshold int
+
+	// HealthCheck, if set, is run every HealthCheckInterval while the
+	// circuit is not closed, so that a breaker with little traffic can
+	// recover without a real request paying for the probe. Its result
+	// drives the state machine as ObserveExternal does with source
+	// "health check": once the open timeout has expired a healthy check
+	// moves the circuit to HalfOpen, SuccessThreshold of them close it and
+	// an unhealthy one reopens it. Checks run one at a time on a
+	// goroutine of their own, and ctx is cancelled if a check is still
+	// running after HealthCheckInterval, when the circuit closes or when
+	// the breaker is closed. HealthCheckInterval defaults to Timeout.
+	HealthCheck         func(ctx context.Context) error
+	HealthCheckInterval time.Duration
+
+	// LatencyThreshold, when non-zero, also opens the circuit when calls get
+	// too slow: once the latencies of the last LatencyWindowSize successful
+	// calls are known, the LatencyPercentile of them is evaluated after
+	// every success (failures are often fast and would hide a slowdown),
+	// and LatencySustain consecutive evaluations above LatencyThreshold trip
+	// the circuit. A half-open probe slower than LatencyThreshold counts as
+	// a failed probe.
+	LatencyThreshold time.Duration
+
+	// LatencyPercentile is the percentile, between 0 and 1, compared
+	// against LatencyThreshold.
+	LatencyPercentile float64
+
+	// LatencySustain is the number of consecutive evaluations over
+	// LatencyThreshold needed to trip.
+	LatencySustain int
+
+	// LatencyWindowSize is the number of recent calls whose latencies are
+	// considered.
+	LatencyWindowSize int
+
+	// CountCallerCancellations counts a call made with ExecuteContext that
+	// fails with context.Canceled or context.DeadlineExceeded after the
+	// caller's context ended as an ordinary failure. By default such a call
+	// is left out of every counter, as with MarkNeutral, because the caller
+	// giving up says nothing about the dependency. Errors from contexts
+	// the request derives itself, while the caller's is still live, are
+	// always counted.
+	CountCallerCancellations bool
+
+	// RecoverPanics makes a request that panics return a *PanicError from
+	// Execute, ExecuteContext and the calls built on them, instead of the
+	// panic carrying on up the caller's stack. Either way the panic counts
+	// as a failure.
+	RecoverPanics bool
+
+	// InFlightDeadline, when non-zero, counts a call that is still running
+	// this long after it started as a failure right away, so that calls
+	// hanging on a dead backend can open the circuit before they return.
+	// Running calls are checked every quarter of InFlightDeadline. When an
+	// overdue call does return, its outcome is not counted again.
+	InFlightDeadline time.Duration
+
+	// CallTimeout, when non-zero, bounds how long Execute and
+	// ExecuteContext wait for a request. One that has not returned by then
+	// counts as a failure and the caller gets ErrCallTimeout; the context
+	// passed to ExecuteContext's request is cancelled with ErrCallTimeout
+	// as its cause. The request itself cannot be stopped, so it carries on
+	// in the background and its eventual result is discarded, not counted
+	// again. With CallTimeout set, requests run on a goroutine of their
+	// own.
+	CallTimeout time.Duration
+
+	// StaleCacheTTL, when non-zero, has the breaker keep the result of the
+	// last successful call and, while the circuit rejects calls with
+	// ErrCircuitOpen or ErrTooManyRequests, return it along with an
+	// ErrServedStale wrapping the rejection, as long as it is younger than
+	// StaleCacheTTL. Serving stale counts only the rejection. Reset
+	// empties the cache.
+	StaleCacheTTL time.Duration
+
+	// FailureRateWindows, when set, also open the circuit based on the
+	// failure rate over sliding time windows, combined according to
+	// WindowAgreement. Two windows with AllWindows, say the last 10
+	// seconds and the last 2 minutes, trip only on sustained failures
+	// while still reacting quickly once they are sustained. Unlike
+	// WindowSize, the windows do not replace FailureThreshold: enough
+	// failures in a row still open the circuit whatever the rate, so set
+	// FailureThreshold high to trip on the rate alone.
+	FailureRateWindows []RateWindow
+
+	// WindowAgreement says whether all FailureRateWindows or any one of
+	// them must be over its threshold to trip.
+	WindowAgreement WindowAgreement
+
+	// WindowSize, when non-zero, trips on the failure rate of the last
+	// WindowSize calls instead of on FailureThreshold failures in a row:
+	// once the window holds at least WindowMinRequests calls, a failure
+	// rate at or above FailureRateThreshold opens the circuit. A steady
+	// 30% error rate then trips even though successes keep interrupting
+	// the failures, while a few unlucky calls in a row do not. The window
+	// starts empty after every state change. WindowMinRequests defaults
+	// to WindowSize.
+	WindowSize           int
+	WindowMinRequests    int
+	FailureRateThreshold float64
+
+	// SlowCallDuration, when non-zero, also opens the circuit when too
+	// many calls are slow, even if they succeed: once the last
+	// SlowCallWindowSize calls are in, a share of calls that took at least
+	// SlowCallDuration reaching SlowCallRateThreshold trips the circuit.
+	// A slow success still returns its result, but unlike a fast one it
+	// does not clear the consecutive failures. The window starts empty
+	// after every state change. SlowCallWindowSize defaults to 100.
+	SlowCallDuration      time.Duration
+	SlowCallRateThreshold float64
+	SlowCallWindowSize    int
+
+	// SpikeMultiplier, when non-zero, also opens the circuit on a sudden
+	// jump in failures: when the failure rate over the last
+	// SpikeShortWindow reaches SpikeMultiplier times the baseline rate of
+	// the SpikeLongWindow before it. The short window must hold at least
+	// SpikeMinRequests calls and its rate must be at least SpikeMinRate, so
+	// a single failure at tiny volumes is not a spike.
+	SpikeMultiplier float64
+
+	// SpikeShortWindow is the window the current failure rate is measured over.
+	SpikeShortWindow time.Duration
+
+	// SpikeLongWindow is the window the baseline failure rate is measured over.
+	SpikeLongWindow time.Duration
+
+	// SpikeMinRate is the minimum short-window failure rate that can trip.
+	SpikeMinRate float64
+
+	// SpikeMinRequests is the minimum number of short-window calls needed
+	// to trip.
+	SpikeMinRequests int
+
+	// SessionFailureThreshold, when non-zero, opens the circuit on churn
+	// in long-lived connections reported with ObserveSession: once the
+	// weighted failures of the sessions that ended within the last
+	// SessionWindow reach SessionFailureThreshold. A session that ended
+	// with an error counts as a whole failure if it died straight away,
+	// and for less the longer it lived; one that lasted HealthyAfter or
+	// longer counts as a success however it ended. With HealthyAfter
+	// unset every session that ends with an error counts in full.
+	SessionFailureThreshold float64
+	SessionWindow           time.Duration
+	HealthyAfter            time.Duration
+
+	// DegradedFailureRate, when non-zero, turns on the Degraded signal:
+	// the breaker reports itself degraded while half-open, and while closed
+	// once the moving-average failure rate (see FailureRateHalfLife)
+	// reaches DegradedFailureRate, until the rate falls back to
+	// DegradedRecoveryRate. The gap between the two keeps the signal from
+	// chattering. DegradedRecoveryRate defaults to half of
+	// DegradedFailureRate. The rate is only trusted once it reflects about
+	// DegradedMinRequests recent calls.
+	DegradedFailureRate  float64
+	DegradedRecoveryRate float64
+	DegradedMinRequests  int
+
+	// MaintenanceWindows are periods of planned downtime. While one is in
+	// effect the circuit is held open with reason "maintenance" and
+	// EventMaintenanceStart/End are emitted at its boundaries, so alerts
+	// on the trip can be suppressed. Afterwards the circuit closes.
+	MaintenanceWindows []Window
+
+	// AttemptTTL is how long the breaker remembers a logical call marked
+	// with AsAttempt after its last failed attempt.
+	AttemptTTL time.Duration
+
+	// PendingTimeout is how long a call made with ExecuteDeferred may stay
+	// unresolved. When it runs out the call is recorded as a failure with
+	// ErrPendingTimeout, or as a success if PendingTimeoutSucceeds is set.
+	PendingTimeout         time.Duration
+	PendingTimeoutSucceeds bool
+
+	// Pressure, when set, is sampled every PressureInterval for signs that
+	// this process is overloaded. While the latest reading is over any of
+	// MaxGCPause, MaxHeapFraction or MaxGoroutines (zero ones are ignored),
+	// calls are turned away with ErrUnderPressure without touching the
+	// circuit's state or counters, or, when PressureMaxConcurrent is set,
+	// the bulkhead is lowered to that instead. NewRuntimePressure reads
+	// the Go runtime's own metrics.
+	Pressure              PressureSource
+	PressureInterval      time.Duration
+	MaxGCPause            time.Duration
+	MaxHeapFraction       float64
+	MaxGoroutines         int
+	PressureMaxConcurrent int
+
+	// QuorumStore and Quorum, when both set, let instances of this breaker
+	// (same Name) in other processes open it together while ignoring
+	// trouble local to one of them. Every QuorumInterval the breaker
+	// reports to the store, under InstanceID, a trip caused by its own
+	// calls within the last QuorumWindow, and reads how many distinct
+	// instances have done so; once at least Quorum have, a closed circuit
+	// opens with reason "quorum". Each instance still trips on its own
+	// counters regardless. Reports expire after QuorumWindow, so instances
+	// that go away stop counting. Store errors are passed to OnQuorumError
+	// and leave the circuit alone.
+	QuorumStore    QuorumStore
+	Quorum         int
+	QuorumWindow   time.Duration
+	QuorumInterval time.Duration
+	InstanceID     string
+	OnQuorumError  func(error)
+
+	// Store, if set, persists the breaker across restarts: every state
+	// change saves its Snapshot under Name, and New restores the one saved
+	// last, so a circuit that was open stays open for the rest of its
+	// timeout, counted from when it opened. Saves run after the breaker's
+	// lock is released. Breakers sharing a Store need distinct Names.
+	// Errors from the store, and a saved state that cannot be restored,
+	// are passed to OnStoreError; a breaker whose state cannot be loaded
+	// starts Closed.
+	Store        Store
+	OnStoreError func(error)
+
+	// LatencyBuckets are the upper bounds, in increasing order, of the
+	// buckets of the latency histograms returned by Latencies.
+	LatencyBuckets []time.Duration
+
+	// OpenAlertAfter, when non-zero, reports a circuit that has not closed
+	// again this long after tripping: OnStuckOpen is called and
+	// EventStuckOpen emitted, once per episode. Failed half-open probes do
+	// not end an episode, and neither does a RampUp in progress; closing
+	// does, and re-arms the alert.
+	OpenAlertAfter time.Duration
+
+	// FailureRateHalfLife controls how quickly the moving-average failure
+	// rate in Status forgets old calls: their weight halves every
+	// FailureRateHalfLife.
+	FailureRateHalfLife time.Duration
+
+	// Clock is the time source for timeouts and timestamps. Defaults to the
+	// system clock.
+	Clock Clock
+
+	// Rand is the source of randomness for the breaker's probabilistic
+	// decisions, such as RetryPolicy.Jitter and TimeoutJitter. Set it to
+	// NewRand(seed) or a circuitbreakertest.ScriptedRand for reproducible
+	// tests. Defaults to a securely seeded source, independent for every
+	// process.
+	Rand Rand
+
+	// Strict turns on state-machine invariant checks after every change to
+	// the breaker. Meant for tests and debugging; a violation panics unless
+	// OnInvariantViolation is set.
+	Strict bool
+
+	// OnInvariantViolation is called instead of panicking when Strict is set
+	// and an invariant is broken. It runs after the breaker's lock is released.
+	OnInvariantViolation func(err error)
+
+	// OnEvent is called for every Event the breaker emits, such as state
+	// changes with their reason. It runs after the breaker's lock is
+	// released, so it may call back into the breaker.
+	OnEvent func(Event)
+
+	// OnCall, if set, is called with a CallRecord for every call that
+	// completes or is rejected, after the breaker's lock is released. It
+	// feeds tools such as the Advisor.
+	OnCall func(CallRecord)
+
+	// OnDegradedChange, if set, is called when the breaker starts or stops
+	// reporting itself as Degraded, after the lock is released.
+	OnDegradedChange func(name string, degraded bool)
+
+	// DiagnoseInterval, when non-zero, runs Diagnose this often, so that
+	// Status carries current findings and OnFinding hears of new ones
+	// without anyone asking.
+	DiagnoseInterval time.Duration
+
+	// OnFinding, if set, is called the first time Diagnose reports each
+	// kind of finding about a setting, after the lock is released. It is
+	// meant for logging misconfigurations once.
+	OnFinding func(Finding)
+
+	// OnStuckOpen, if set, is called when an open episode outlasts
+	// OpenAlertAfter, after the lock is released.
+	OnStuckOpen func(StuckOpen)
+
+	// Logger, if set, receives structured records of what the breaker
+	// does: state changes at Info, or Warn when the circuit opens, with
+	// the name, from and to states and reason; rejected requests at Debug;
+	// and the other events, such as stuck-open alerts and exhausted retry
+	// budgets. Calls that complete normally are not logged. Records are
+	// written after the breaker's lock is released. Nil logs nothing and
+	// costs nothing.
+	Logger *slog.Logger
+
+	// OnStateChange is called after every state transition, if set. It runs
+	// after the breaker's lock is released, so it may call back into the breaker.
+	OnStateChange func(name string, from, to State)
+
+	// OnPanic is called with every panic recovered from a user-supplied
+	// function: the hooks above, Pressure, QuorumStore, Store, HealthCheck,
+	// PreRequestChecks and a Transport's ProbeSafe. The breaker carries on
+	// with a safe default: a panicking hook's call is dropped, a panicking
+	// PressureSource, QuorumStore or Store is treated as giving no answer,
+	// a panicking HealthCheck as unhealthy, a panicking check as failed and
+	// a panicking ProbeSafe as false.
+	OnPanic func(Panic)
 }
 
 // DefaultConfig returns sensible defaults.
@@ -24,11 +473,226 @@ func DefaultConfig() Config {
 		FailureThreshold: 3,
 		SuccessThreshold: 5,
 		Timeout:          10 * time.Second,
+
+		MaxHalfOpenRequests: 1,
+
+		LatencyPercentile: 0.99,
+		LatencySustain:    1,
+		LatencyWindowSize: 100,
+
+		SpikeShortWindow: 10 * time.Second,
+		SpikeLongWindow:  5 * time.Minute,
+		SpikeMinRate:     0.01,
+		SpikeMinRequests: 20,
+
+		FailureRateHalfLife: 30 * time.Second,
+
+		DegradedMinRequests: 10,
+
+		SessionWindow: 10 * time.Minute,
+
+		AttemptTTL: time.Minute,
+
+		FairProbeTenants: 100,
+
+		PendingTimeout: time.Minute,
+
+		PressureInterval: time.Second,
+
+		QuorumWindow:   time.Minute,
+		QuorumInterval: 5 * time.Second,
+
+		RetryBudgetMinTokens: 10,
+		RetryBudgetMaxTokens: 100,
 	}
 }
 
+// withDefaults returns c with zero-valued thresholds and timeout taken from
+// DefaultConfig and an empty Name replaced by a generated unique one.
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Name == "" {
+		c.Name = fmt.Sprintf("breaker-%d", breakerSeq.Add(1))
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = d.FailureThreshold
+	}
+	if c.SuccessThreshold == 0 {
+		c.SuccessThreshold = d.SuccessThreshold
+	}
+	if len(c.RampUp) > 0 && c.RampFailureRate == 0 {
+		c.RampFailureRate = 0.5
+	}
+	if len(c.RampUp) > 0 && c.RampMinRequests == 0 {
+		c.RampMinRequests = 5
+	}
+	if c.HalfOpenMaxFailures == 0 {
+		c.HalfOpenMaxFailures = 1
+	}
+	if c.WindowMinRequests == 0 {
+		c.WindowMinRequests = c.WindowSize
+	}
+	if c.SlowCallDuration > 0 && c.SlowCallWindowSize == 0 {
+		c.SlowCallWindowSize = 100
+	}
+	if c.MaxHalfOpenRequests == 0 {
+		c.MaxHalfOpenRequests = d.MaxHalfOpenRequests
+	}
+	if c.Timeout == 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.OpenTimeoutBackoff.MaxTimeout > 0 {
+		if c.OpenTimeoutBackoff.Initial == 0 {
+			c.OpenTimeoutBackoff.Initial = c.Timeout
+		}
+		if c.OpenTimeoutBackoff.Multiplier == 0 {
+			c.OpenTimeoutBackoff.Multiplier = 2
+		}
+	}
+	if c.ExternalFailureThreshold == 0 {
+		c.ExternalFailureThreshold = c.FailureThreshold
+	}
+	if c.HealthCheckInterval == 0 {
+		c.HealthCheckInterval = c.Timeout
+	}
+	if c.LatencyPercentile == 0 {
+		c.LatencyPercentile = d.LatencyPercentile
+	}
+	if c.LatencySustain == 0 {
+		c.LatencySustain = d.LatencySustain
+	}
+	if c.LatencyWindowSize == 0 {
+		c.LatencyWindowSize = d.LatencyWindowSize
+	}
+	if c.SpikeShortWindow == 0 {
+		c.SpikeShortWindow = d.SpikeShortWindow
+	}
+	if c.SpikeLongWindow == 0 {
+		c.SpikeLongWindow = d.SpikeLongWindow
+	}
+	if c.SpikeMinRate == 0 {
+		c.SpikeMinRate = d.SpikeMinRate
+	}
+	if c.SpikeMinRequests == 0 {
+		c.SpikeMinRequests = d.SpikeMinRequests
+	}
+	if c.FailureRateHalfLife == 0 {
+		c.FailureRateHalfLife = d.FailureRateHalfLife
+	}
+	if c.SessionWindow == 0 {
+		c.SessionWindow = d.SessionWindow
+	}
+	if c.AttemptTTL == 0 {
+		c.AttemptTTL = d.AttemptTTL
+	}
+	if c.PendingTimeout == 0 {
+		c.PendingTimeout = d.PendingTimeout
+	}
+	if c.QuorumWindow == 0 {
+		c.QuorumWindow = d.QuorumWindow
+	}
+	if c.QuorumInterval == 0 {
+		c.QuorumInterval = d.QuorumInterval
+	}
+	if c.InstanceID == "" && c.QuorumStore != nil {
+		c.InstanceID = defaultInstanceID()
+	}
+	if c.LatencyBuckets == nil {
+		c.LatencyBuckets = DefaultLatencyBuckets
+	}
+	if c.FairProbeTenants == 0 {
+		c.FairProbeTenants = d.FairProbeTenants
+	}
+	if c.PressureInterval == 0 {
+		c.PressureInterval = d.PressureInterval
+	}
+	if c.DegradedRecoveryRate == 0 {
+		c.DegradedRecoveryRate = c.DegradedFailureRate / 2
+	}
+	if c.DegradedMinRequests == 0 {
+		c.DegradedMinRequests = d.DegradedMinRequests
+	}
+	if c.RetryBudgetMinTokens == 0 {
+		c.RetryBudgetMinTokens = d.RetryBudgetMinTokens
+	}
+	if c.RetryBudgetMaxTokens == 0 {
+		c.RetryBudgetMaxTokens = d.RetryBudgetMaxTokens
+	}
+	return c
+}
+
+// longestOpenTimeout is the longest the open timeout can grow to: Timeout,
+// or OpenTimeoutBackoff.MaxTimeout with backoff on, stretched by the most
+// TimeoutJitter can add. A MaxOpenDuration has to be below it to apply.
+func (c Config) longestOpenTimeout() time.Duration {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultConfig().Timeout
+	}
+	if b := c.OpenTimeoutBackoff; b.MaxTimeout > 0 {
+		timeout = b.MaxTimeout
+	}
+	return timeout + time.Duration(float64(timeout)*c.TimeoutJitter)
+}
+
 // Validate checks that the config is valid.
 func (c Config) Validate() error {
-	// TODO: implement
+	switch {
+	case c.Timeout < 0:
+		return errors.New("circuit breaker: negative Timeout")
+	case c.MaxOpenDuration < 0:
+		return errors.New("circuit breaker: negative MaxOpenDuration")
+	case c.TimeoutJitter < 0 || c.TimeoutJitter >= 1:
+		return errors.New("circuit breaker: TimeoutJitter must be in [0, 1)")
+	case c.OpenTimeoutBackoff.Initial < 0 || c.OpenTimeoutBackoff.MaxTimeout < 0:
+		return errors.New("circuit breaker: negative OpenTimeoutBackoff Initial or MaxTimeout")
+	case c.OpenTimeoutBackoff.Multiplier != 0 && c.OpenTimeoutBackoff.Multiplier < 1:
+		return errors.New("circuit breaker: OpenTimeoutBackoff Multiplier below 1")
+	case c.OpenTimeoutBackoff.MaxTimeout > 0 && c.OpenTimeoutBackoff.Initial > c.OpenTimeoutBackoff.MaxTimeout:
+		return errors.New("circuit breaker: OpenTimeoutBackoff Initial above MaxTimeout")
+	case c.MaxOpenDuration > 0 && c.MaxOpenDuration >= c.longestOpenTimeout():
+		return errors.New("circuit breaker: MaxOpenDuration at or above the longest open timeout never applies")
+	case c.PendingTimeout < 0:
+		return errors.New("circuit breaker: negative PendingTimeout")
+	case c.PressureInterval < 0:
+		return errors.New("circuit breaker: negative PressureInterval")
+	case c.WindowSize < 0 || c.WindowMinRequests < 0:
+		return errors.New("circuit breaker: negative WindowSize or WindowMinRequests")
+	case c.WindowSize > 0 && (c.FailureRateThreshold <= 0 || c.FailureRateThreshold > 1):
+		return errors.New("circuit breaker: FailureRateThreshold must be in (0, 1] with WindowSize")
+	case c.SlowCallDuration < 0 || c.SlowCallWindowSize < 0:
+		return errors.New("circuit breaker: negative SlowCallDuration or SlowCallWindowSize")
+	case c.SlowCallDuration > 0 && (c.SlowCallRateThreshold <= 0 || c.SlowCallRateThreshold > 1):
+		return errors.New("circuit breaker: SlowCallRateThreshold must be in (0, 1] with SlowCallDuration")
+	case c.MaxHalfOpenRequests < 0:
+		return errors.New("circuit breaker: negative MaxHalfOpenRequests")
+	case c.InFlightDeadline < 0:
+		return errors.New("circuit breaker: negative InFlightDeadline")
+	case c.CallTimeout < 0:
+		return errors.New("circuit breaker: negative CallTimeout")
+	case c.StaleCacheTTL < 0:
+		return errors.New("circuit breaker: negative StaleCacheTTL")
+	case c.HalfOpenPolicy != CumulativeSuccesses && c.HalfOpenPolicy != ConsecutiveSuccesses:
+		return errors.New("circuit breaker: unknown HalfOpenPolicy")
+	case c.HalfOpenMaxFailures < 0:
+		return errors.New("circuit breaker: negative HalfOpenMaxFailures")
+	case c.RampFailureRate < 0 || c.RampFailureRate > 1:
+		return errors.New("circuit breaker: RampFailureRate must be in [0, 1]")
+	case c.RampMinRequests < 0:
+		return errors.New("circuit breaker: negative RampMinRequests")
+	case c.HealthCheckInterval < 0:
+		return errors.New("circuit breaker: negative HealthCheckInterval")
+	case c.FairProbeTenants < 0:
+		return errors.New("circuit breaker: negative FairProbeTenants")
+	case c.QuorumWindow < 0 || c.QuorumInterval < 0:
+		return errors.New("circuit breaker: negative QuorumWindow or QuorumInterval")
+	case !slices.IsSorted(c.LatencyBuckets):
+		return errors.New("circuit breaker: LatencyBuckets out of order")
+	}
+	for _, step := range c.RampUp {
+		if step.Percent <= 0 || step.Percent > 100 || step.Duration <= 0 {
+			return errors.New("circuit breaker: RampUp steps need a Percent in (0, 100] and a positive Duration")
+		}
+	}
 	return nil
 }
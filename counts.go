@@ -0,0 +1,62 @@
+package circuitbreaker
+
+import "time"
+
+// Counts holds the request counters the circuit breaker uses to decide
+// when to change state, with the state they apply to and, for
+// convenience, the running totals also found in Totals.
+type Counts struct {
+	// State is the state of the circuit, and LastStateChange when it was
+	// entered, or the zero time if the breaker has never changed state.
+	State           State
+	LastStateChange time.Time
+
+	// ConsecutiveFailures is the number of failures since the last success.
+	ConsecutiveFailures int
+
+	// ConsecutiveSuccesses is the number of successes since the last
+	// failure. A slow success, which does not end a run of failures, does
+	// not add to it.
+	ConsecutiveSuccesses int
+
+	// Successes is the number of successes since the last state change.
+	Successes int
+
+	// Requests, TotalSuccesses, TotalFailures and Rejected are
+	// Totals.Requests, Totals.Successes, Totals.Failures and
+	// Totals.Rejected. Unlike the counters above they are never reset.
+	Requests       uint64
+	TotalSuccesses uint64
+	TotalFailures  uint64
+	Rejected       uint64
+}
+
+// Totals holds running totals of the breaker's traffic for dashboards.
+// Unlike Counts they are never reset, by state changes or by Reset.
+type Totals struct {
+	// Requests is the number of calls that asked to be admitted, whether
+	// or not they were.
+	Requests uint64
+
+	// Successes and Failures are the number of calls that completed either
+	// way. Calls left out of the counts, such as those marked neutral or
+	// further failed attempts of a logical call (see AsAttempt), are in
+	// neither.
+	Successes uint64
+	Failures  uint64
+
+	// Rejected is the number of calls turned away with ErrCircuitOpen or
+	// ErrTooManyRequests. RejectedOpen and RejectedHalfOpen split it by
+	// error: the first counts ErrCircuitOpen, the second
+	// ErrTooManyRequests, which a half-open circuit returns when its
+	// probes are taken.
+	Rejected         uint64
+	RejectedOpen     uint64
+	RejectedHalfOpen uint64
+
+	// TimeClosed, TimeOpen and TimeHalfOpen are the time spent in each
+	// state, including the time so far in the current one.
+	TimeClosed   time.Duration
+	TimeOpen     time.Duration
+	TimeHalfOpen time.Duration
+}
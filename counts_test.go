@@ -0,0 +1,159 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestCounts_ConsecutiveRunsAndTotals(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	cb.Execute(successFn)
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	cb.Execute(successFn)
+	want := circuitbreaker.Counts{
+		State:                circuitbreaker.Closed,
+		ConsecutiveSuccesses: 1,
+		Successes:            3,
+		Requests:             4,
+		TotalSuccesses:       3,
+		TotalFailures:        1,
+	}
+	if got := cb.Counts(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	clock.Advance(time.Second)
+	cb.Execute(failFn)
+	cb.Execute(failFn) // opens
+	cb.Execute(successFn)
+	clock.Advance(time.Minute)
+	cb.Execute(successFn) // probes
+	want = circuitbreaker.Counts{
+		State:                circuitbreaker.HalfOpen,
+		LastStateChange:      clock.Now(),
+		ConsecutiveSuccesses: 1,
+		Successes:            1,
+		Requests:             8,
+		TotalSuccesses:       4,
+		TotalFailures:        3,
+		Rejected:             1,
+	}
+	if got := cb.Counts(); got != want {
+		t.Errorf("expected the runs to start over and the totals to carry on, got %+v", got)
+	}
+	if got := cb.Status().Counts; got != want {
+		t.Errorf("expected Status to carry the same counts, got %+v", got)
+	}
+
+	restored := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, SuccessThreshold: 2, Timeout: time.Minute, Clock: clock, Strict: true})
+	if err := restored.Restore(cb.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if got := counters(restored.Counts()); got != counters(want) {
+		t.Errorf("expected the counters restored from a snapshot, got %+v", got)
+	}
+}
+
+func TestTotals_AccumulateAcrossStateChanges(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	cb.Execute(failFn)
+	cb.Execute(failFn) // opens
+	cb.Execute(successFn)
+	cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { return nil, nil })
+	clock.Advance(time.Minute)
+	cb.Execute(successFn) // closes
+	cb.Execute(successFn)
+	cb.Execute(func() (any, error) { return nil, errCacheHit })
+	cb.Reset()
+	cb.Execute(failFn)
+
+	want := circuitbreaker.Totals{Requests: 8, Successes: 2, Failures: 3, Rejected: 2, RejectedOpen: 2, TimeOpen: time.Minute}
+	if got := cb.Totals(); got != want {
+		t.Errorf("expected totals %+v, got %+v", want, got)
+	}
+	if s := cb.Status(); s.Totals != want || counters(s.Counts) != (circuitbreaker.Counts{ConsecutiveFailures: 1}) {
+		t.Errorf("expected Status to carry the totals and fresh counts, got %+v and %+v", s.Totals, s.Counts)
+	}
+
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if nilBreaker.Totals() != (circuitbreaker.Totals{}) {
+		t.Error("expected a nil breaker to have zero totals")
+	}
+}
+
+func TestTotals_RejectionsAndTimeInState(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	clock.Advance(10 * time.Second)
+	cb.Execute(failFn) // opens
+	cb.Execute(successFn)
+	clock.Advance(30 * time.Second)
+
+	// read mid-state, the open time so far is included.
+	got := cb.Totals()
+	if got.TimeClosed != 10*time.Second || got.TimeOpen != 30*time.Second || got.TimeHalfOpen != 0 {
+		t.Errorf("expected 10s closed and 30s open so far, got %+v", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	probe, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("expected the probe admitted, got %v", err)
+	}
+	cb.Execute(successFn)
+	cb.Execute(successFn)
+	clock.Advance(5 * time.Second)
+	probe(true) // closes
+	clock.Advance(20 * time.Second)
+
+	want := circuitbreaker.Totals{
+		Requests:         5,
+		Successes:        1,
+		Failures:         1,
+		Rejected:         3,
+		RejectedOpen:     1,
+		RejectedHalfOpen: 2,
+		TimeClosed:       30 * time.Second,
+		TimeOpen:         time.Minute,
+		TimeHalfOpen:     5 * time.Second,
+	}
+	if got := cb.Totals(); got != want {
+		t.Errorf("expected totals %+v, got %+v", want, got)
+	}
+
+	// the totals are lifetime figures, which Reset keeps.
+	cb.Reset()
+	clock.Advance(time.Second)
+	want.TimeClosed += time.Second
+	if got := cb.Totals(); got != want {
+		t.Errorf("expected Reset to keep the totals %+v, got %+v", want, got)
+	}
+}
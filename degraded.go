@@ -0,0 +1,64 @@
+package circuitbreaker
+
+// Degraded reports whether the protected dependency is struggling but the
+// circuit is not open: the failure rate has crossed
+// Config.DegradedFailureRate and not yet fallen back to
+// Config.DegradedRecoveryRate, or the circuit is half-open and recovering.
+// It is always false unless DegradedFailureRate is set. Degraded is only a
+// signal for callers that want to simplify what they
+// do; it never changes which requests are admitted. A nil breaker is never
+// degraded.
+func (cb *CircuitBreaker) Degraded() bool {
+	if cb == nil {
+		return false
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.degraded
+}
+
+// updateDegraded re-evaluates the degraded signal and reports changes.
+// Must be called with cb.mu held.
+func (cb *CircuitBreaker) updateDegraded() {
+	threshold := cb.config.DegradedFailureRate
+	if threshold <= 0 {
+		return
+	}
+	degraded, reason := cb.degraded, ""
+	switch cb.state {
+	case Open:
+		degraded = false
+	case HalfOpen:
+		degraded, reason = true, ReasonRecovering
+	case Closed:
+		degraded = false
+		if cb.failureRate.total >= float64(cb.config.DegradedMinRequests) {
+			rate := cb.failureRate.rate()
+			switch {
+			case rate >= threshold:
+				degraded, reason = true, ReasonFailureRate
+			case rate > cb.config.DegradedRecoveryRate:
+				// between the two thresholds nothing changes.
+				degraded = cb.degraded
+			default:
+				reason = ReasonRecovered
+			}
+		}
+	}
+	if degraded == cb.degraded {
+		return
+	}
+	cb.degraded = degraded
+
+	ev := Event{Type: EventDegradedEnd, Time: cb.clock.Now(), From: cb.state, To: cb.state, Reason: reason}
+	if degraded {
+		ev.Type = EventDegradedStart
+	}
+	cb.emit(ev)
+	if hook := cb.config.OnDegradedChange; hook != nil {
+		name := cb.config.Name
+		cb.queueHook("OnDegradedChange", func() { hook(name, degraded) })
+	}
+}
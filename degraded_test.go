@@ -0,0 +1,136 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// All calls in these tests happen at the same fake instant, so the moving
+// average is a plain failures/total ratio.
+func degradedConfig(changes *[]bool) circuitbreaker.Config {
+	return circuitbreaker.Config{
+		FailureThreshold:     1000,
+		DegradedFailureRate:  0.2,
+		DegradedRecoveryRate: 0.1,
+		Clock:                cbt.NewFakeClock(cbt.Epoch),
+		Strict:               true,
+		OnDegradedChange: func(_ string, degraded bool) {
+			*changes = append(*changes, degraded)
+		},
+	}
+}
+
+func runCalls(cb *circuitbreaker.CircuitBreaker, successes, failures int) {
+	for i := 0; i < successes; i++ {
+		cb.Execute(successFn)
+	}
+	for i := 0; i < failures; i++ {
+		cb.Execute(failFn)
+	}
+}
+
+func TestDegraded_EnterAndExitWithHysteresis(t *testing.T) {
+	var changes []bool
+	cb := circuitbreaker.New(degradedConfig(&changes))
+
+	runCalls(cb, 8, 1)
+	if cb.Degraded() {
+		t.Fatal("degraded below the soft threshold")
+	}
+	runCalls(cb, 0, 1) // 2 of 10 failed
+	if !cb.Degraded() || !cb.Status().Degraded {
+		t.Fatal("expected degraded at the soft threshold")
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("degraded must not change the state, got %s", cb.State())
+	}
+
+	runCalls(cb, 5, 0) // 2 of 15
+	if !cb.Degraded() {
+		t.Fatal("left degraded between the thresholds")
+	}
+	runCalls(cb, 5, 0) // 2 of 20
+	if cb.Degraded() {
+		t.Fatal("expected to leave degraded at the recovery rate")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("expected one enter and one exit, got %v", changes)
+	}
+}
+
+func TestDegraded_NoChatterBetweenThresholds(t *testing.T) {
+	var changes []bool
+	cb := circuitbreaker.New(degradedConfig(&changes))
+	runCalls(cb, 8, 2)
+
+	// hover around 15%, between the recovery rate and the soft threshold.
+	for i := 0; i < 20; i++ {
+		runCalls(cb, 6, 1)
+	}
+	if len(changes) != 1 {
+		t.Errorf("expected a single change while hovering, got %v", changes)
+	}
+}
+
+func TestDegraded_NeedsMinimumTraffic(t *testing.T) {
+	var changes []bool
+	cb := circuitbreaker.New(degradedConfig(&changes))
+	runCalls(cb, 0, 1)
+	if cb.Degraded() {
+		t.Error("a single failure should not count as degraded")
+	}
+}
+
+func TestDegraded_WhileRecovering(t *testing.T) {
+	var changes []bool
+	var events []circuitbreaker.Event
+	cfg := degradedConfig(&changes)
+	cfg.SuccessThreshold = 1
+	cfg.OnEvent = func(ev circuitbreaker.Event) { events = append(events, ev) }
+	cb := circuitbreaker.New(cfg)
+
+	cbt.SetState(cb, circuitbreaker.Open)
+	if cb.Degraded() {
+		t.Fatal("an open circuit is not degraded")
+	}
+	cbt.AdvanceToHalfOpen(cb)
+	if !cb.Degraded() {
+		t.Fatal("expected degraded while half-open")
+	}
+	last := events[len(events)-1]
+	if last.Type != circuitbreaker.EventDegradedStart || last.Reason != circuitbreaker.ReasonRecovering {
+		t.Errorf("expected a recovering degraded-start event, got %+v", last)
+	}
+
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.Closed || cb.Degraded() {
+		t.Errorf("expected closed and healthy after recovery, got %s degraded=%v", cb.State(), cb.Degraded())
+	}
+}
+
+func TestDegraded_DoesNotChangeAdmission(t *testing.T) {
+	var changes []bool
+	with := circuitbreaker.New(degradedConfig(&changes))
+	without := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1000,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+	})
+
+	for i := 0; i < 100; i++ {
+		fn := successFn
+		if i%3 == 0 {
+			fn = failFn
+		}
+		_, errWith := with.Execute(fn)
+		_, errWithout := without.Execute(fn)
+		if errWith != errWithout {
+			t.Fatalf("call %d: %v with the degraded signal, %v without", i, errWith, errWithout)
+		}
+	}
+	if !with.Degraded() {
+		t.Error("expected the breaker to be degraded at a 34% failure rate")
+	}
+}
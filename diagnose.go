@@ -0,0 +1,227 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Severity says how much a Finding matters.
+type Severity int
+
+const (
+	// SeverityInfo: worth knowing, probably harmless.
+	SeverityInfo Severity = iota
+	// SeverityWarning: the breaker is unlikely to behave as intended.
+	SeverityWarning
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// FindingKind names a problem Diagnose can spot.
+type FindingKind string
+
+const (
+	// FindingSlowRecovery: at the observed request rate, collecting
+	// SuccessThreshold half-open successes takes longer than the circuit
+	// stays open in the first place.
+	FindingSlowRecovery FindingKind = "slow recovery"
+	// FindingWindowTooShort: a failure-rate window is shorter than a
+	// typical call, so it rarely holds a completed one.
+	FindingWindowTooShort FindingKind = "window too short"
+	// FindingLatencyThresholdTooLow: typical calls are slower than
+	// LatencyThreshold, so the circuit trips under normal load.
+	FindingLatencyThresholdTooLow FindingKind = "latency threshold too low"
+	// FindingNeverTrips: the breaker has seen a sizeable share of failures
+	// for a long time without ever opening.
+	FindingNeverTrips FindingKind = "never trips"
+)
+
+// Finding is a mismatch between a breaker's configuration and the traffic
+// it has seen.
+type Finding struct {
+	Kind     FindingKind
+	Severity Severity
+	// Setting names the configuration field at fault, such as
+	// "SuccessThreshold" or "FailureRateWindows[1]".
+	Setting string
+	// Message explains the finding with the numbers behind it.
+	Message string
+}
+
+// String returns the finding as a log line.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Setting, f.Message)
+}
+
+const (
+	// diagnoseMinRequests is how much traffic Diagnose needs before it
+	// judges the settings against it.
+	diagnoseMinRequests = 20
+	// neverTripsAfter, neverTripsMinFailures and neverTripsMinRate are how
+	// long, how many failures and what share of calls it takes for a
+	// breaker that never opened to be reported.
+	neverTripsAfter       = 30 * 24 * time.Hour
+	neverTripsMinFailures = 100
+	neverTripsMinRate     = 0.05
+	// latencyAverageWeight is the weight of each new call in the moving
+	// average of latency.
+	latencyAverageWeight = 0.05
+)
+
+// diagnostics is the traffic history Diagnose judges the settings by. It
+// survives Reset.
+type diagnostics struct {
+	// since is when the breaker started observing.
+	since time.Time
+	// requests counts calls that asked to be admitted, whether or not they
+	// were, and rejected those turned away by the circuit; calls,
+	// failures and latency describe those that completed.
+	requests uint64
+	rejected uint64
+	calls    uint64
+	// rejectedHalfOpen is the part of rejected that was
+	// ErrTooManyRequests.
+	rejectedHalfOpen uint64
+	failures         uint64
+	latency          time.Duration
+	// trips counts transitions from Closed to Open.
+	trips uint64
+	// stateTime is the time spent in each state up to stateSince, from
+	// which the current state's time runs.
+	stateTime  [3]time.Duration
+	stateSince time.Time
+	// findings are those of the latest diagnosis, and reported the
+	// kind/setting pairs already passed to OnFinding.
+	findings []Finding
+	reported map[string]bool
+	// timer runs the next diagnosis when Config.DiagnoseInterval is set.
+	timer Timer
+}
+
+// observe records a completed call.
+func (d *diagnostics) observe(latency time.Duration, failed bool) {
+	if d.calls == 0 {
+		d.latency = latency
+	} else {
+		d.latency += time.Duration(latencyAverageWeight * float64(latency-d.latency))
+	}
+	d.calls++
+	if failed {
+		d.failures++
+	}
+}
+
+// Diagnose compares the breaker's settings with the traffic it has seen
+// and returns what looks wrong, such as a SuccessThreshold that takes far
+// longer to reach than Timeout at the observed request rate. The findings
+// are kept for Status, and each kind of finding about a setting is passed
+// to Config.OnFinding the first time it appears. A nil breaker has no
+// findings.
+func (cb *CircuitBreaker) Diagnose() []Finding {
+	if cb == nil {
+		return nil
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	return append([]Finding(nil), cb.runDiagnosis()...)
+}
+
+// startDiagnosis arms the first periodic diagnosis. Called once from
+// lazyInit.
+func (cb *CircuitBreaker) startDiagnosis() {
+	cb.diag.since = cb.clock.Now()
+	cb.diag.stateSince = cb.diag.since
+	if cb.config.DiagnoseInterval <= 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.diag.timer = cb.clock.AfterFunc(cb.config.DiagnoseInterval, cb.onDiagnoseTimer)
+}
+
+// onDiagnoseTimer runs a periodic diagnosis and arms the next one.
+func (cb *CircuitBreaker) onDiagnoseTimer() {
+	cb.mu.Lock()
+	defer cb.unlock()
+	if cb.closed {
+		return
+	}
+	cb.runDiagnosis()
+	cb.diag.timer = cb.clock.AfterFunc(cb.config.DiagnoseInterval, cb.onDiagnoseTimer)
+}
+
+// runDiagnosis diagnoses the breaker, stores the findings and queues
+// OnFinding for new ones. Must be called with cb.mu held.
+func (cb *CircuitBreaker) runDiagnosis() []Finding {
+	findings := cb.diagnose(cb.clock.Now())
+	cb.diag.findings = findings
+	if hook := cb.config.OnFinding; hook != nil {
+		for _, f := range findings {
+			key := string(f.Kind) + "/" + f.Setting
+			if cb.diag.reported[key] {
+				continue
+			}
+			if cb.diag.reported == nil {
+				cb.diag.reported = map[string]bool{}
+			}
+			cb.diag.reported[key] = true
+			cb.queueHook("OnFinding", func() { hook(f) })
+		}
+	}
+	return findings
+}
+
+// diagnose applies every check. Must be called with cb.mu held.
+func (cb *CircuitBreaker) diagnose(now time.Time) []Finding {
+	d := &cb.diag
+	c := &cb.config
+	elapsed := now.Sub(d.since)
+	var findings []Finding
+
+	if d.requests >= diagnoseMinRequests && elapsed > 0 {
+		perSecond := float64(d.requests) / elapsed.Seconds()
+		recovery := time.Duration(float64(c.SuccessThreshold) / perSecond * float64(time.Second))
+		if timeout, _ := cb.openTimeout(); recovery > timeout {
+			findings = append(findings, Finding{FindingSlowRecovery, SeverityWarning, "SuccessThreshold",
+				fmt.Sprintf("%d half-open successes take about %s to collect at the observed %.1f requests/minute, longer than the %s the circuit stays open",
+					c.SuccessThreshold, recovery.Round(time.Second), perSecond*60, timeout)})
+		}
+	}
+
+	if d.calls >= diagnoseMinRequests {
+		for i, w := range c.FailureRateWindows {
+			if w.Duration < d.latency {
+				findings = append(findings, Finding{FindingWindowTooShort, SeverityWarning, fmt.Sprintf("FailureRateWindows[%d]", i),
+					fmt.Sprintf("the %s window is shorter than the typical call latency of %s", w.Duration, d.latency.Round(time.Millisecond))})
+			}
+		}
+		if c.SpikeMultiplier > 0 && c.SpikeShortWindow < d.latency {
+			findings = append(findings, Finding{FindingWindowTooShort, SeverityWarning, "SpikeShortWindow",
+				fmt.Sprintf("the %s window is shorter than the typical call latency of %s", c.SpikeShortWindow, d.latency.Round(time.Millisecond))})
+		}
+		if c.LatencyThreshold > 0 && d.latency > c.LatencyThreshold {
+			findings = append(findings, Finding{FindingLatencyThresholdTooLow, SeverityWarning, "LatencyThreshold",
+				fmt.Sprintf("the typical call latency of %s is above the %s threshold, so the circuit trips under normal load",
+					d.latency.Round(time.Millisecond), c.LatencyThreshold)})
+		}
+	}
+
+	if elapsed >= neverTripsAfter && d.trips == 0 && d.failures >= neverTripsMinFailures {
+		if rate := float64(d.failures) / float64(d.calls); rate >= neverTripsMinRate {
+			findings = append(findings, Finding{FindingNeverTrips, SeverityInfo, "FailureThreshold",
+				fmt.Sprintf("the circuit has not opened in %d days despite %d failures (%.0f%% of calls); FailureThreshold %d may be too high",
+					int(elapsed/(24*time.Hour)), d.failures, rate*100, c.FailureThreshold)})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,154 @@
+package circuitbreaker_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// findingFor returns the finding of kind about setting, if any.
+func findingFor(findings []circuitbreaker.Finding, kind circuitbreaker.FindingKind, setting string) (circuitbreaker.Finding, bool) {
+	for _, f := range findings {
+		if f.Kind == kind && f.Setting == setting {
+			return f, true
+		}
+	}
+	return circuitbreaker.Finding{}, false
+}
+
+// traffic makes n calls every interval, each taking latency.
+func traffic(cb *circuitbreaker.CircuitBreaker, clock *cbt.FakeClock, n int, interval, latency time.Duration, fn func() (any, error)) {
+	for i := 0; i < n; i++ {
+		clock.Advance(interval)
+		cb.Execute(func() (any, error) {
+			clock.Advance(latency)
+			return fn()
+		})
+	}
+}
+
+func TestDiagnose_SlowRecovery(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{SuccessThreshold: 5, Timeout: 10 * time.Second, Clock: clock, Strict: true})
+
+	// two requests a minute for twenty minutes.
+	traffic(cb, clock, 40, 30*time.Second, 0, successFn)
+	f, ok := findingFor(cb.Diagnose(), circuitbreaker.FindingSlowRecovery, "SuccessThreshold")
+	if !ok {
+		t.Fatalf("expected a slow recovery finding, got %v", cb.Diagnose())
+	}
+	if f.Severity != circuitbreaker.SeverityWarning || !strings.Contains(f.Message, "2m30s") {
+		t.Errorf("unexpected finding %v", f)
+	}
+
+	busy := circuitbreaker.New(circuitbreaker.Config{SuccessThreshold: 5, Timeout: 10 * time.Second, Clock: clock, Strict: true})
+	traffic(busy, clock, 100, 100*time.Millisecond, 0, successFn)
+	if findings := busy.Diagnose(); len(findings) != 0 {
+		t.Errorf("expected no findings for a busy endpoint, got %v", findings)
+	}
+}
+
+func TestDiagnose_WindowShorterThanLatency(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureRateWindows: []circuitbreaker.RateWindow{
+			{Duration: time.Minute, FailureRateThreshold: 0.5, MinRequests: 10},
+			{Duration: 200 * time.Millisecond, FailureRateThreshold: 0.5, MinRequests: 10},
+		},
+		LatencyThreshold: 300 * time.Millisecond,
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	traffic(cb, clock, 30, time.Second, 250*time.Millisecond, successFn)
+	findings := cb.Diagnose()
+	if _, ok := findingFor(findings, circuitbreaker.FindingWindowTooShort, "FailureRateWindows[1]"); !ok {
+		t.Errorf("expected the 200ms window to be flagged, got %v", findings)
+	}
+	if _, ok := findingFor(findings, circuitbreaker.FindingWindowTooShort, "FailureRateWindows[0]"); ok {
+		t.Errorf("the one-minute window is fine, got %v", findings)
+	}
+	if _, ok := findingFor(findings, circuitbreaker.FindingLatencyThresholdTooLow, "LatencyThreshold"); ok {
+		t.Errorf("250ms calls are within the 300ms threshold, got %v", findings)
+	}
+
+	traffic(cb, clock, 30, time.Second, 400*time.Millisecond, successFn)
+	if _, ok := findingFor(cb.Diagnose(), circuitbreaker.FindingLatencyThresholdTooLow, "LatencyThreshold"); !ok {
+		t.Errorf("expected 400ms calls to flag the 300ms threshold, got %v", cb.Diagnose())
+	}
+}
+
+func TestDiagnose_NeverTripsDespiteFailures(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 10, Clock: clock, Strict: true})
+
+	// every other call fails, an hour apart, so failures never run to 10.
+	alternate := false
+	flaky := func() (any, error) {
+		alternate = !alternate
+		if alternate {
+			return failFn()
+		}
+		return successFn()
+	}
+	traffic(cb, clock, 29*24, time.Hour, 0, flaky)
+	if _, ok := findingFor(cb.Diagnose(), circuitbreaker.FindingNeverTrips, "FailureThreshold"); ok {
+		t.Fatal("reported before 30 days had passed")
+	}
+	traffic(cb, clock, 2*24, time.Hour, 0, flaky)
+	f, ok := findingFor(cb.Diagnose(), circuitbreaker.FindingNeverTrips, "FailureThreshold")
+	if !ok {
+		t.Fatalf("expected a never-trips finding, got %v", cb.Diagnose())
+	}
+	if !strings.Contains(f.Message, "50%") {
+		t.Errorf("expected the failure share in %q", f.Message)
+	}
+
+	// once the circuit has opened the finding no longer applies.
+	for i := 0; i < 10; i++ {
+		cb.Execute(failFn)
+	}
+	if _, ok := findingFor(cb.Diagnose(), circuitbreaker.FindingNeverTrips, "FailureThreshold"); ok {
+		t.Error("expected no never-trips finding after a trip")
+	}
+}
+
+func TestDiagnose_PeriodicRunsLogEachFindingOnce(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var logged []circuitbreaker.Finding
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		SuccessThreshold: 5,
+		Timeout:          10 * time.Second,
+		DiagnoseInterval: time.Minute,
+		OnFinding:        func(f circuitbreaker.Finding) { logged = append(logged, f) },
+		Clock:            clock,
+		Strict:           true,
+	})
+
+	traffic(cb, clock, 120, 30*time.Second, 0, successFn)
+	if len(logged) != 1 || logged[0].Kind != circuitbreaker.FindingSlowRecovery {
+		t.Fatalf("expected the slow recovery logged once over an hour of diagnoses, got %v", logged)
+	}
+	if findings := cb.Status().Findings; len(findings) != 1 {
+		t.Errorf("expected Status to carry the latest findings, got %v", findings)
+	}
+
+	cb.Close()
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop diagnosing, got %d pending timers", n)
+	}
+}
+
+func TestDiagnose_QuietWithoutTraffic(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch)})
+	if findings := cb.Diagnose(); len(findings) != 0 {
+		t.Errorf("expected no findings before any traffic, got %v", findings)
+	}
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if findings := nilBreaker.Diagnose(); findings != nil {
+		t.Errorf("expected no findings for a nil breaker, got %v", findings)
+	}
+}
@@ -0,0 +1,77 @@
+package circuitbreaker
+
+import (
+	"context"
+	"net"
+)
+
+// Dialer opens network connections through a CircuitBreaker, for clients
+// such as database drivers and Redis clients that take a dial function
+// rather than an http.RoundTripper. A dial that fails counts as a failure,
+// and one the breaker rejects fails with the breaker's error without being
+// attempted.
+//
+// Only opening the connection is guarded. A connection that breaks after
+// it was dialed successfully does not count against the breaker, whose
+// say ends when the dial returns; guard the calls made over it as well to
+// count those failures.
+type Dialer struct {
+	// Breaker guards the dials. A nil Breaker lets every dial through.
+	Breaker Guard
+
+	// Addrs, if set, keeps one breaker per address instead, so one
+	// unreachable server does not open the circuit for the others: each
+	// dial goes through the group's breaker for its addr, and Breaker is
+	// ignored.
+	Addrs *Group
+
+	// Dial opens the connections, such as a net.Dialer's or tls.Dialer's
+	// DialContext. Defaults to the DialContext of a zero net.Dialer. Its
+	// own timeout, such as net.Dialer.Timeout, counts as a failure when it
+	// runs out; a dial abandoned because the caller's ctx is done counts
+	// only with Config.CountCallerCancellations, as for ExecuteContext.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialContext connects to addr on the named network through the
+// breaker. It has the signature of net.Dialer.DialContext, so it can be
+// handed to clients that accept one.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := d.Dial
+	if dial == nil {
+		var nd net.Dialer
+		dial = nd.DialContext
+	}
+	// the connection goes through a handoff, as with Config.CallTimeout
+	// the dial may be given up on while it is still under way.
+	h := &handoff[net.Conn]{release: func(conn net.Conn) { conn.Close() }}
+	request := func(ctx context.Context) (any, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if !h.give(conn) {
+			// the caller has stopped waiting, so nobody would use it.
+			return nil, context.Cause(ctx)
+		}
+		return nil, nil
+	}
+	var err error
+	if d.Addrs != nil {
+		_, err = d.Addrs.ExecuteContext(ctx, addr, request)
+	} else if d.Breaker == nil {
+		// lets every dial through, as a nil *CircuitBreaker does.
+		_, err = (*CircuitBreaker)(nil).ExecuteContext(ctx, request)
+	} else {
+		_, err = d.Breaker.ExecuteContext(ctx, request)
+	}
+	conn := h.take()
+	if err != nil {
+		if conn != nil {
+			// the call timed out after the connection was made.
+			conn.Close()
+		}
+		return nil, err
+	}
+	return conn, nil
+}
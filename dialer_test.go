@@ -0,0 +1,139 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// listen accepts connections on addr, closing each at once, until the
+// returned listener is closed.
+func listen(t *testing.T, addr string) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln
+}
+
+func TestDialer_TripRejectAndRecover(t *testing.T) {
+	ln := listen(t, "127.0.0.1:0")
+	addr := ln.Addr().String()
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	addrs := circuitbreaker.NewGroup(circuitbreaker.Config{
+		Name:             "dial",
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+	defer addrs.Close()
+	var dials atomic.Int32
+	var nd net.Dialer
+	d := &circuitbreaker.Dialer{Addrs: addrs, Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return nd.DialContext(ctx, network, addr)
+	}}
+	ctx := context.Background()
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a connection that breaks after the dial does not count.
+	conn.Close()
+
+	ln.Close()
+	for range 2 {
+		if _, err := d.DialContext(ctx, "tcp", addr); err == nil || errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+			t.Fatalf("expected the dial to fail, got %v", err)
+		}
+	}
+	before := dials.Load()
+	if _, err := d.DialContext(ctx, "tcp", addr); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen once the address's circuit opened, got %v", err)
+	}
+	if dials.Load() != before {
+		t.Error("expected a rejected dial not to be attempted")
+	}
+	if got := addrs.Breaker(addr).State(); got != circuitbreaker.Open {
+		t.Fatalf("expected the address's breaker open, got %v", got)
+	}
+
+	ln = listen(t, addr)
+	defer ln.Close()
+	clock.Advance(time.Minute)
+	conn, err = d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatalf("expected the probe dial to succeed once the server is back, got %v", err)
+	}
+	conn.Close()
+	if got := addrs.Breaker(addr).State(); got != circuitbreaker.Closed {
+		t.Errorf("expected the circuit to close again, got %v", got)
+	}
+}
+
+func TestDialer_AcceptsGuard(t *testing.T) {
+	ln := listen(t, "127.0.0.1:0")
+	defer ln.Close()
+	b := cbt.NewScriptedBreaker(cbt.Reject, cbt.Admit)
+	d := &circuitbreaker.Dialer{Breaker: b}
+
+	if _, err := d.DialContext(context.Background(), "tcp", ln.Addr().String()); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected a scripted rejection, got %v", err)
+	}
+	conn, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("expected the admitted dial to connect, got %v", err)
+	}
+	conn.Close()
+
+	// a nil Breaker lets every dial through.
+	conn, err = (&circuitbreaker.Dialer{}).DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("expected a dial without a breaker to connect, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialer_CallTimeoutClosesLateConnection(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Second, Clock: clock, Strict: true})
+	client, server := net.Pipe()
+	defer server.Close()
+	d := &circuitbreaker.Dialer{Breaker: cb, Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		clock.Advance(time.Second)
+		<-ctx.Done()
+		// the connection is made anyway, after the caller has been told.
+		return client, nil
+	}}
+
+	if _, err := d.DialContext(context.Background(), "tcp", "backend:6379"); !errors.Is(err, circuitbreaker.ErrCallTimeout) {
+		t.Fatalf("expected ErrCallTimeout, got %v", err)
+	}
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the late connection to be closed, got %v", err)
+	}
+	if tot := cb.Totals(); tot.Failures != 1 {
+		t.Errorf("expected the timeout to count as a failure, got %+v", tot)
+	}
+}
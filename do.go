@@ -0,0 +1,85 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+)
+
+// Do is Execute for a request with a typed result, sparing callers the
+// type assertion on Execute's any. It is counted exactly like Execute.
+// When the call is rejected or fails, Do returns the zero value of T
+// along with the error, whatever request returned, except for a stale
+// result served with ErrServedStale; see Config.StaleCacheTTL.
+func Do[T any](cb *CircuitBreaker, request func() (T, error), opts ...CallOption) (T, error) {
+	var zero T
+	if request == nil {
+		return zero, ErrNilFunction
+	}
+	var result T
+	value, err := cb.Execute(func() (any, error) {
+		v, err := request()
+		result = v
+		if cb.staleCaching() {
+			// kept as the result to serve stale.
+			return v, err
+		}
+		return nil, err
+	}, opts...)
+	if err != nil {
+		if stale, ok := value.(T); ok && errors.Is(err, ErrServedStale) {
+			return stale, err
+		}
+		return zero, err
+	}
+	return result, nil
+}
+
+// DoContext is ExecuteContext for a request with a typed result; see Do.
+func DoContext[T any](ctx context.Context, cb *CircuitBreaker, request func(context.Context) (T, error), opts ...CallOption) (T, error) {
+	var zero T
+	if request == nil {
+		return zero, ErrNilFunction
+	}
+	var result T
+	value, err := cb.ExecuteContext(ctx, func(ctx context.Context) (any, error) {
+		v, err := request(ctx)
+		result = v
+		if cb.staleCaching() {
+			// kept as the result to serve stale.
+			return v, err
+		}
+		return nil, err
+	}, opts...)
+	if err != nil {
+		if stale, ok := value.(T); ok && errors.Is(err, ErrServedStale) {
+			return stale, err
+		}
+		return zero, err
+	}
+	return result, nil
+}
+
+// TypedBreaker binds a breaker to one result type, for code that makes
+// many calls returning T and would rather call methods than Do. Its
+// Execute and ExecuteContext are Do and DoContext; the breaker's other
+// methods are reached through the embedded CircuitBreaker.
+type TypedBreaker[T any] struct {
+	*CircuitBreaker
+}
+
+// Typed returns cb bound to results of type T. Any number of
+// TypedBreakers, of any types, may share cb, and the calls made through
+// them count as calls to cb.
+func Typed[T any](cb *CircuitBreaker) TypedBreaker[T] {
+	return TypedBreaker[T]{CircuitBreaker: cb}
+}
+
+// Execute runs request through the breaker; see Do.
+func (b TypedBreaker[T]) Execute(request func() (T, error), opts ...CallOption) (T, error) {
+	return Do(b.CircuitBreaker, request, opts...)
+}
+
+// ExecuteContext runs request with ctx through the breaker; see DoContext.
+func (b TypedBreaker[T]) ExecuteContext(ctx context.Context, request func(context.Context) (T, error), opts ...CallOption) (T, error) {
+	return DoContext(ctx, b.CircuitBreaker, request, opts...)
+}
@@ -0,0 +1,92 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func TestDo_PreservesResultTypes(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+
+	u, err := circuitbreaker.Do(cb, func() (*user, error) { return &user{1, "ada"}, nil })
+	if err != nil || u.Name != "ada" {
+		t.Errorf("pointer: got %v, %v", u, err)
+	}
+	ids, err := circuitbreaker.Do(cb, func() ([]int, error) { return []int{1, 2}, nil })
+	if err != nil || !slices.Equal(ids, []int{1, 2}) {
+		t.Errorf("slice: got %v, %v", ids, err)
+	}
+	v, err := circuitbreaker.DoContext(context.Background(), cb, func(context.Context) (user, error) { return user{2, "bob"}, nil })
+	if err != nil || v != (user{2, "bob"}) {
+		t.Errorf("struct: got %v, %v", v, err)
+	}
+}
+
+func TestDo_ZeroValueOnFailureAndRejection(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Strict: true})
+
+	// a failing request's partial result is not passed on.
+	u, err := circuitbreaker.Do(cb, func() (*user, error) { return &user{1, "ada"}, errSimulated })
+	if u != nil || !errors.Is(err, errSimulated) {
+		t.Errorf("failed pointer call: got %v, %v", u, err)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected the failure to open the circuit, got %v", cb.State())
+	}
+
+	ran := false
+	ids, err := circuitbreaker.Do(cb, func() ([]int, error) { ran = true; return []int{1}, nil })
+	if ids != nil || ran || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("rejected slice call: got %v, %v (ran: %v)", ids, err, ran)
+	}
+	v, err := circuitbreaker.Do(cb, func() (user, error) { return user{2, "bob"}, nil })
+	if v != (user{}) || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("rejected struct call: got %v, %v", v, err)
+	}
+}
+
+func TestDo_CountsLikeExecute(t *testing.T) {
+	outcomes := []error{nil, errSimulated, nil, errSimulated, errSimulated, nil}
+	viaExecute := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	viaDo := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	for i, outcome := range outcomes {
+		viaExecute.Execute(func() (any, error) { return i, outcome })
+		circuitbreaker.Do(viaDo, func() (int, error) { return i, outcome })
+		if a, b := viaExecute.Status(), viaDo.Status(); a.State != b.State || counters(a.Counts) != counters(b.Counts) {
+			t.Fatalf("after call %d: Execute left %v %+v, Do left %v %+v", i, a.State, a.Counts, b.State, b.Counts)
+		}
+	}
+	if viaDo.State() != circuitbreaker.Open {
+		t.Errorf("expected both breakers open, got %v", viaDo.State())
+	}
+}
+
+func TestTypedBreaker(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	users := circuitbreaker.Typed[*user](cb)
+	ids := circuitbreaker.Typed[[]int](cb)
+
+	u, err := users.Execute(func() (*user, error) { return &user{1, "ada"}, nil })
+	if err != nil || u.Name != "ada" {
+		t.Errorf("got %v, %v", u, err)
+	}
+	if _, err := ids.ExecuteContext(context.Background(), func(context.Context) ([]int, error) { return nil, errSimulated }); !errors.Is(err, errSimulated) {
+		t.Errorf("expected the request's error, got %v", err)
+	}
+	users.Execute(func() (*user, error) { return nil, errSimulated })
+	if users.State() != circuitbreaker.Open || cb.Totals().Failures != 2 {
+		t.Fatalf("expected both typed breakers to count against cb, got %v %+v", cb.State(), cb.Totals())
+	}
+	if got, err := ids.Execute(func() ([]int, error) { return []int{1}, nil }); got != nil || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected a zero result with the rejection, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,219 @@
+package circuitbreaker
+
+import "time"
+
+// EventType identifies what an Event describes.
+type EventType int
+
+const (
+	// EventStateChange is emitted after every state transition.
+	EventStateChange EventType = iota
+	// EventMaintenanceStart is emitted when a maintenance window begins,
+	// just before the circuit is forced open.
+	EventMaintenanceStart
+	// EventMaintenanceEnd is emitted when the last overlapping maintenance
+	// window ends, just before the circuit closes again.
+	EventMaintenanceEnd
+	// EventRetryBudgetExhausted is emitted each time a retry is turned
+	// away because the retry budget is empty. From and To both hold the
+	// current state.
+	EventRetryBudgetExhausted
+	// EventDegradedStart and EventDegradedEnd are emitted when the breaker
+	// starts and stops reporting itself as Degraded. From and To both
+	// hold the current state.
+	EventDegradedStart
+	EventDegradedEnd
+	// EventEjected is emitted when a Group's outlier detection ejects the
+	// breaker's key, just before the circuit is forced open. FailureRate
+	// and BaselineRate hold the numbers that were compared.
+	EventEjected
+	// EventReinstated is emitted when an ejection ends, just before the
+	// circuit closes again.
+	EventReinstated
+	// EventKeyOverflow is emitted by a Group's overflow breaker the first
+	// time a key beyond the WithMaxKeys limit is used. Key holds that key.
+	EventKeyOverflow
+	// EventStuckOpen is emitted once per open episode when the circuit has
+	// not closed within Config.OpenAlertAfter of tripping. StuckOpen holds
+	// the details.
+	EventStuckOpen
+	// EventPressureStart and EventPressureEnd are emitted when a reading
+	// of Config.Pressure goes over a threshold and when one comes back
+	// under them all. From and To both hold the current state.
+	EventPressureStart
+	EventPressureEnd
+	// EventClockJump is emitted when the breaker's clock reads earlier
+	// than the last state change, as after the wall clock stepped
+	// backwards. Jump holds how far back it went; the open timeout is
+	// timed afresh from the jump. From and To both hold the current state.
+	EventClockJump
+	// EventRejected, EventCallSucceeded and EventCallFailed are delivered
+	// only to Subscribe channels, for a request turned away, one that
+	// succeeded and one that failed. Err holds the rejection or failure
+	// and Duration how long the call ran. From and To both hold the
+	// current state.
+	EventRejected
+	EventCallSucceeded
+	EventCallFailed
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventStateChange:
+		return "StateChange"
+	case EventMaintenanceStart:
+		return "MaintenanceStart"
+	case EventMaintenanceEnd:
+		return "MaintenanceEnd"
+	case EventRetryBudgetExhausted:
+		return "RetryBudgetExhausted"
+	case EventDegradedStart:
+		return "DegradedStart"
+	case EventDegradedEnd:
+		return "DegradedEnd"
+	case EventEjected:
+		return "Ejected"
+	case EventReinstated:
+		return "Reinstated"
+	case EventKeyOverflow:
+		return "KeyOverflow"
+	case EventStuckOpen:
+		return "StuckOpen"
+	case EventPressureStart:
+		return "PressureStart"
+	case EventPressureEnd:
+		return "PressureEnd"
+	case EventClockJump:
+		return "ClockJump"
+	case EventRejected:
+		return "Rejected"
+	case EventCallSucceeded:
+		return "CallSucceeded"
+	case EventCallFailed:
+		return "CallFailed"
+	default:
+		return "Unknown"
+	}
+}
+
+// Reasons carried by state-change events.
+const (
+	// ReasonFailures: consecutive failures reached FailureThreshold.
+	ReasonFailures = "failures"
+	// ReasonLatency: call latency stayed above LatencyThreshold, or a
+	// half-open probe was slower than it.
+	ReasonLatency = "latency"
+	// ReasonFailureRate: the FailureRateWindows agreed the failure rate
+	// is too high, or the rate over the last WindowSize calls reached
+	// FailureRateThreshold.
+	ReasonFailureRate = "failure rate"
+	// ReasonSlowCalls: the share of the last SlowCallWindowSize calls that
+	// took at least SlowCallDuration reached SlowCallRateThreshold.
+	ReasonSlowCalls = "slow calls"
+	// ReasonSessionChurn: sessions reported with ObserveSession ended too
+	// often too soon.
+	ReasonSessionChurn = "session churn"
+	// ReasonRecovering: the circuit is half-open, so the breaker reports
+	// itself as degraded.
+	ReasonRecovering = "recovering"
+	// ReasonOutlier: a Group's outlier detection ejected or reinstated the
+	// breaker's key.
+	ReasonOutlier = "outlier"
+	// ReasonSpike: the short-window failure rate jumped to SpikeMultiplier
+	// times the baseline.
+	ReasonSpike = "spike"
+	// ReasonProbeFailed: a half-open probe failed.
+	ReasonProbeFailed = "probe failed"
+	// ReasonTimeout: the open timeout expired.
+	ReasonTimeout = "timeout"
+	// ReasonMaxOpenDuration: the circuit had been open for MaxOpenDuration,
+	// which cut the open timeout short.
+	ReasonMaxOpenDuration = "max open duration"
+	// ReasonRecovered: enough half-open probes succeeded.
+	ReasonRecovered = "recovered"
+	// ReasonReset: Reset was called.
+	ReasonReset = "reset"
+	// ReasonForced: the state was set directly, e.g. by circuitbreakertest.
+	ReasonForced = "forced"
+	// ReasonExternal: an ObserveExternal signal drove the change.
+	ReasonExternal = "external"
+	// ReasonMaintenance: a maintenance window started or ended.
+	ReasonMaintenance = "maintenance"
+	// ReasonQuorum: at least Config.Quorum instances of the breaker
+	// reported tripping within Config.QuorumWindow.
+	ReasonQuorum = "quorum"
+)
+
+// Event describes something that happened to a circuit breaker. Events are
+// delivered to Config.OnEvent after the breaker's lock is released.
+type Event struct {
+	// Type says what happened.
+	Type EventType
+	// Name is the breaker's configured name.
+	Name string
+	// Time is when it happened, according to the breaker's clock.
+	Time time.Time
+	// From and To are the old and new state of a state change.
+	From State
+	To   State
+	// Reason explains a state change; see the Reason constants.
+	Reason string
+	// Source names the external signal behind the event, if any; see
+	// ObserveExternal.
+	Source string
+	// FailureRate and BaselineRate are the failure rate that was judged
+	// and the rate it was compared against, for events that carry them.
+	FailureRate  float64
+	BaselineRate float64
+	// Key is the Group key the event is about, for events that carry one.
+	Key string
+	// Jump is how far the clock went back, for EventClockJump.
+	Jump time.Duration
+	// StuckOpen describes the episode for EventStuckOpen.
+	StuckOpen *StuckOpen
+	// Err is the rejection or failure, for EventRejected and
+	// EventCallFailed.
+	Err error
+	// Duration is how long the call ran, for EventCallSucceeded and
+	// EventCallFailed.
+	Duration time.Duration
+}
+
+// emit publishes ev to subscribers and queues it for delivery to OnEvent
+// and Logger once cb.mu is released. Must be called with cb.mu held.
+func (cb *CircuitBreaker) emit(ev Event) {
+	hook := cb.config.OnEvent
+	if hook == nil && len(cb.subscribers) == 0 && cb.config.Logger == nil {
+		return
+	}
+	ev.Name = cb.config.Name
+	if ev.Source == "" {
+		ev.Source = cb.eventSource
+	}
+	cb.publish(ev)
+	if cb.config.Logger != nil {
+		cb.logEvent(ev)
+	}
+	if hook != nil {
+		cb.queueHook("OnEvent", func() { hook(ev) })
+	}
+}
+
+// emitCall publishes an event about a single call to subscribers only;
+// OnCall already reports calls to hooks. Must be called with cb.mu held.
+func (cb *CircuitBreaker) emitCall(typ EventType, err error, latency time.Duration) {
+	if len(cb.subscribers) == 0 {
+		return
+	}
+	cb.publish(Event{Type: typ, Name: cb.config.Name, Time: cb.clock.Now(), From: cb.state, To: cb.state, Err: err, Duration: latency, Source: cb.eventSource})
+}
+
+// emitKeyOverflow reports the first key routed to a Group's overflow
+// breaker.
+func (cb *CircuitBreaker) emitKeyOverflow(key string) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.emit(Event{Type: EventKeyOverflow, Time: cb.clock.Now(), From: cb.state, To: cb.state, Key: key})
+}
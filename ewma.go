@@ -0,0 +1,53 @@
+package circuitbreaker
+
+import (
+	"math"
+	"time"
+)
+
+// ewma is an exponentially weighted moving average of the failure rate.
+// Instead of a fixed per-call smoothing factor it decays by wall time: the
+// weight of every past outcome halves each halfLife. That keeps the signal
+// meaningful for bursty traffic and lets many calls at the same instant
+// count equally.
+type ewma struct {
+	halfLife time.Duration
+	// Decayed number of outcomes and of failures among them.
+	total    float64
+	failures float64
+	last     time.Time
+}
+
+// observe records one outcome at now.
+func (e *ewma) observe(now time.Time, failed bool) {
+	if e.total > 0 {
+		elapsed := now.Sub(e.last)
+		if elapsed > 0 {
+			decay := math.Exp2(-float64(elapsed) / float64(e.halfLife))
+			e.total *= decay
+			e.failures *= decay
+		}
+	}
+	e.last = now
+	e.total++
+	if failed {
+		e.failures++
+	}
+}
+
+// rate returns the average failure rate between 0 and 1, or 0 before any
+// outcome has been observed. Decay scales failures and total alike, so the
+// rate does not change between observations.
+func (e *ewma) rate() float64 {
+	if e.total == 0 {
+		return 0
+	}
+	return e.failures / e.total
+}
+
+// reset forgets every observed outcome.
+func (e *ewma) reset() {
+	e.total = 0
+	e.failures = 0
+	e.last = time.Time{}
+}
@@ -0,0 +1,111 @@
+package circuitbreaker_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestFailureRate_ConvergesAtHalfLife(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:    1 << 30, // never trip; only the average matters here
+		SuccessThreshold:    1,
+		Timeout:             time.Minute,
+		FailureRateHalfLife: 10 * time.Second,
+		Clock:               clock,
+		Strict:              true,
+	})
+
+	// A minute of healthy traffic at 10 calls per second.
+	for i := 0; i < 600; i++ {
+		cb.Execute(successFn)
+		clock.Advance(100 * time.Millisecond)
+	}
+	if got := cb.Status().FailureRate; got != 0 {
+		t.Fatalf("expected failure rate 0 while healthy, got %f", got)
+	}
+
+	// Step change to 100% failures. The weight of the healthy history
+	// halves every half-life, so the average should cross 0.5 after one
+	// half-life and 0.75 after two.
+	failFor := func(d time.Duration) {
+		for end := clock.Now().Add(d); clock.Now().Before(end); clock.Advance(100 * time.Millisecond) {
+			cb.Execute(failFn)
+		}
+	}
+
+	failFor(10 * time.Second)
+	if got := cb.Status().FailureRate; math.Abs(got-0.5) > 0.02 {
+		t.Errorf("expected failure rate ~0.5 after one half-life, got %f", got)
+	}
+
+	failFor(10 * time.Second)
+	if got := cb.Status().FailureRate; math.Abs(got-0.75) > 0.02 {
+		t.Errorf("expected failure rate ~0.75 after two half-lives, got %f", got)
+	}
+
+	// Reading the status later without traffic does not change the rate.
+	before := cb.Status().FailureRate
+	clock.Advance(time.Hour)
+	if got := cb.Status().FailureRate; got != before {
+		t.Errorf("expected idle time not to change the rate, got %f then %f", before, got)
+	}
+}
+
+func TestFailureRate_SameInstantCallsCountEqually(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 100,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+	})
+
+	cb.Execute(failFn)
+	cb.Execute(successFn)
+	cb.Execute(successFn)
+	cb.Execute(successFn)
+
+	if got := cb.Status().FailureRate; got != 0.25 {
+		t.Errorf("expected failure rate 0.25, got %f", got)
+	}
+}
+
+func TestFailureRate_ClearedByReset(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 100})
+	cb.Execute(failFn)
+	if cb.Status().FailureRate == 0 {
+		t.Fatal("expected a non-zero failure rate after a failure")
+	}
+
+	cb.Reset()
+	if got := cb.Status().FailureRate; got != 0 {
+		t.Errorf("expected failure rate 0 after Reset, got %f", got)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "status",
+		FailureThreshold: 2,
+		Clock:            clock,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Second)
+	cb.Execute(failFn)
+
+	s := cb.Status()
+	if s.Name != "status" || s.State != circuitbreaker.Open {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if !s.LastStateChange.Equal(cbt.Epoch.Add(time.Second)) {
+		t.Errorf("expected last state change at the trip, got %v", s.LastStateChange)
+	}
+
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if got := nilBreaker.Status(); got.State != circuitbreaker.Closed {
+		t.Errorf("expected nil breaker status Closed, got %+v", got)
+	}
+}
@@ -38,7 +38,7 @@ func main() {
 		result, err := cb.Execute(flakyService)
 
 		state := cb.State()
-		if err == circuitbreaker.ErrCircuitOpen {
+		if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
 			fmt.Printf("Request %2d: [%s] REJECTED - circuit is open\n", i, state)
 		} else if err != nil {
 			fmt.Printf("Request %2d: [%s] FAILED - %v\n", i, state, err)
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -29,7 +30,8 @@ func main() {
 	}
 
 	fmt.Println("Circuit Breaker Demo - HTTP Calls")
-	fmt.Println("==================================\n")
+	fmt.Println("==================================")
+	fmt.Println()
 
 	client := &http.Client{Timeout: 5 * time.Second}
 
@@ -48,7 +50,7 @@ func main() {
 		})
 
 		state := cb.State()
-		if err == circuitbreaker.ErrCircuitOpen {
+		if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
 			fmt.Printf("Request %d: [%s] REJECTED - %s\n", i+1, state, url)
 		} else if err != nil {
 			fmt.Printf("Request %d: [%s] FAILED - %v\n", i+1, state, err)
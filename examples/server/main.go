@@ -51,13 +51,6 @@ func apiHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func statusHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"circuit_state": cb.State().String(),
-	})
-}
-
 func main() {
 	cb = circuitbreaker.New(circuitbreaker.Config{
 		Name:             "downstream-api",
@@ -67,7 +60,7 @@ func main() {
 	})
 
 	http.HandleFunc("/api/data", apiHandler)
-	http.HandleFunc("/status", statusHandler)
+	http.Handle("/status", circuitbreaker.StatusHandler(cb))
 
 	fmt.Println("Server running on http://localhost:8080")
 	fmt.Println("Endpoints:")
@@ -0,0 +1,77 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestExecuteErr_CountsLikeExecute(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+	fail := func() error { return errSimulated }
+	calls := 0
+	succeed := func() error { calls++; return nil }
+
+	if err := cb.ExecuteErr(succeed); err != nil || calls != 1 {
+		t.Fatalf("expected the call to run, got %v after %d calls", err, calls)
+	}
+	for range 2 {
+		if err := cb.ExecuteErr(fail); err != errSimulated {
+			t.Fatalf("expected the request's error, got %v", err)
+		}
+	}
+	var oe *circuitbreaker.OpenError
+	if err := cb.ExecuteErr(succeed); !errors.As(err, &oe) || calls != 1 {
+		t.Fatalf("expected an *OpenError without running the call, got %v after %d calls", err, calls)
+	}
+	clock.Advance(time.Minute)
+	if err := cb.ExecuteErr(succeed); err != nil || cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected the probe to close the circuit, got %v in %v", err, cb.State())
+	}
+	if err := cb.ExecuteErr(nil); err != circuitbreaker.ErrNilFunction {
+		t.Errorf("expected ErrNilFunction, got %v", err)
+	}
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if err := nilBreaker.ExecuteErr(fail); err != errSimulated {
+		t.Errorf("expected a nil breaker to pass the call through, got %v", err)
+	}
+}
+
+func TestExecuteErr_RecoverPanics(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, RecoverPanics: true, Strict: true})
+	err := cb.ExecuteErr(func() error { panic("boom") })
+	var pe *circuitbreaker.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("expected a *PanicError, got %v", err)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the panic to count as a failure, got %v", cb.State())
+	}
+}
+
+func TestExecuteErr_CallTimeout(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Millisecond, Strict: true})
+	release := make(chan struct{})
+	defer close(release)
+	if err := cb.ExecuteErr(func() error { <-release; return nil }); err != circuitbreaker.ErrCallTimeout {
+		t.Errorf("expected ErrCallTimeout, got %v", err)
+	}
+}
+
+func TestExecuteErr_ClosedSuccessDoesNotAllocate(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{})
+	request := func() error { return nil }
+	if allocs := testing.AllocsPerRun(100, func() { cb.ExecuteErr(request) }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v per call", allocs)
+	}
+}
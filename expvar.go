@@ -0,0 +1,45 @@
+package circuitbreaker
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvarMu makes checking for a published name and publishing it one
+// step, since expvar.Publish panics on a name that is already taken.
+var expvarMu sync.Mutex
+
+// PublishExpvar publishes cb's Report under name with the expvar package,
+// so it appears as a JSON object at /debug/vars. The report is taken
+// afresh every time the variables are read. A name that is already
+// published, by an earlier call or by anything else, is left alone and
+// an error returned, where expvar.Publish would panic.
+func PublishExpvar(name string, cb *CircuitBreaker) error {
+	return publishExpvar(name, func() any { return cb.Report() })
+}
+
+// PublishExpvar publishes the Reports of the registered breakers with the
+// expvar package, as a JSON object under name keyed by breaker name; see
+// the package-level PublishExpvar. Breakers registered or removed later
+// are reflected the next time the variables are read.
+func (r *Registry) PublishExpvar(name string) error {
+	return publishExpvar(name, func() any {
+		reports := make(map[string]Report)
+		for key, cb := range r.All() {
+			reports[key] = cb.Report()
+		}
+		return reports
+	})
+}
+
+func publishExpvar(name string, f expvar.Func) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("circuit breaker: expvar %q is already published", name)
+	}
+	expvar.Publish(name, f)
+	return nil
+}
@@ -0,0 +1,96 @@
+package circuitbreaker_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// expvarRuns keeps the names the tests publish unique, since expvar
+// variables cannot be unpublished and tests may run more than once.
+var expvarRuns atomic.Int32
+
+// expvarName returns a name not yet published, based on base.
+func expvarName(base string) string {
+	return fmt.Sprintf("%s_%d", base, expvarRuns.Add(1))
+}
+
+// debugVars fetches /debug/vars and decodes the variable under name into v.
+func debugVars(t *testing.T, name string, v any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := vars[name]
+	if !ok {
+		t.Fatalf("expected %q in /debug/vars", name)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type expvarReport struct {
+	Name                string `json:"name"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+func TestPublishExpvar(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "payments",
+		FailureThreshold: 2,
+		Timeout:          time.Minute,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+	})
+	name := expvarName("breaker_payments")
+	if err := circuitbreaker.PublishExpvar(name, cb); err != nil {
+		t.Fatal(err)
+	}
+	if err := circuitbreaker.PublishExpvar(name, cb); err == nil {
+		t.Error("expected publishing the same name twice to fail")
+	}
+
+	var r expvarReport
+	debugVars(t, name, &r)
+	if r.Name != "payments" || r.State != "Closed" || r.ConsecutiveFailures != 0 {
+		t.Fatalf("expected a closed payments breaker, got %+v", r)
+	}
+	cb.Execute(failFn)
+	debugVars(t, name, &r)
+	if r.ConsecutiveFailures != 1 {
+		t.Errorf("expected the failure to show, got %+v", r)
+	}
+	cb.Execute(failFn)
+	debugVars(t, name, &r)
+	if r.State != "Open" {
+		t.Errorf("expected the trip to show, got %+v", r)
+	}
+}
+
+func TestRegistry_PublishExpvar(t *testing.T) {
+	reg := circuitbreaker.NewRegistry()
+	name := expvarName("breakers_registry")
+	if err := reg.PublishExpvar(name); err != nil {
+		t.Fatal(err)
+	}
+	reg.GetOrCreate("inventory", circuitbreaker.Config{FailureThreshold: 1, Strict: true}).Execute(failFn)
+
+	var reports map[string]expvarReport
+	debugVars(t, name, &reports)
+	if r := reports["inventory"]; r.State != "Open" {
+		t.Errorf("expected the registered breaker's report, got %+v", reports)
+	}
+}
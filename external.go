@@ -0,0 +1,66 @@
+package circuitbreaker
+
+// ObserveExternal feeds a health verdict from outside the breaker, such as
+// service-mesh outlier detection or a health-check system, into the state
+// machine. source names where the signal came from and is reported on any
+// state change event it causes.
+//
+// ExternalFailureThreshold consecutive unhealthy observations open a Closed
+// circuit, and an unhealthy observation reopens a HalfOpen one. Healthy
+// observations count toward recovery like successful probes: once the open
+// timeout has expired, a healthy observation moves the breaker to HalfOpen
+// and each one counts toward SuccessThreshold. Observations never run or
+// reject requests, so the breaker can be driven entirely by external
+// signals. Calling ObserveExternal on a nil or closed breaker, during a
+// maintenance window or while the breaker is ejected as an outlier, does
+// nothing.
+func (cb *CircuitBreaker) ObserveExternal(healthy bool, source string) {
+	if cb == nil {
+		return
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.observe(healthy, source)
+}
+
+// observe is ObserveExternal with cb.mu held.
+func (cb *CircuitBreaker) observe(healthy bool, source string) {
+	if cb.closed || cb.maintenance || cb.ejected {
+		return
+	}
+	cb.eventSource = source
+	defer func() { cb.eventSource = "" }()
+
+	if !healthy {
+		switch cb.state {
+		case Closed:
+			cb.externalFailures++
+			if cb.externalFailures >= cb.config.ExternalFailureThreshold {
+				cb.setState(Open, ReasonExternal)
+			}
+		case HalfOpen:
+			cb.setState(Open, ReasonExternal)
+		}
+		return
+	}
+
+	cb.externalFailures = 0
+	if cb.state == Open {
+		timeout, reason := cb.openTimeout()
+		if cb.sinceStateChange(cb.clock.Now()) < timeout {
+			return
+		}
+		cb.setState(HalfOpen, reason)
+	}
+	// a ramp in progress is not cut short.
+	if cb.state == HalfOpen && !cb.ramping() {
+		cb.successes++
+		if cb.successes >= cb.config.SuccessThreshold {
+			cb.setState(Closed, ReasonExternal)
+			return
+		}
+		cb.checkInvariants(cb.state)
+	}
+}
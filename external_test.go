@@ -0,0 +1,99 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestObserveExternal_DrivesFullCycle(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:                     "upstream",
+		FailureThreshold:         5,
+		ExternalFailureThreshold: 2,
+		SuccessThreshold:         2,
+		Timeout:                  10 * time.Second,
+		Clock:                    clock,
+		Strict:                   true,
+		OnEvent:                  func(ev circuitbreaker.Event) { events = append(events, ev) },
+	})
+
+	// A healthy signal between unhealthy ones resets the streak.
+	cb.ObserveExternal(false, "envoy")
+	cb.ObserveExternal(true, "envoy")
+	cb.ObserveExternal(false, "envoy")
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected Closed after a broken streak, got %v", cb.State())
+	}
+	cb.ObserveExternal(false, "envoy")
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected Open after 2 consecutive unhealthy signals, got %v", cb.State())
+	}
+
+	// Healthy signals before the timeout do not start recovery.
+	cb.ObserveExternal(true, "consul")
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected still Open before the timeout, got %v", cb.State())
+	}
+
+	clock.Advance(10 * time.Second)
+	cb.ObserveExternal(true, "consul")
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected HalfOpen after the timeout, got %v", cb.State())
+	}
+	cb.ObserveExternal(true, "consul")
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected Closed after SuccessThreshold healthy signals, got %v", cb.State())
+	}
+
+	want := []struct {
+		to     circuitbreaker.State
+		reason string
+		source string
+	}{
+		{circuitbreaker.Open, circuitbreaker.ReasonExternal, "envoy"},
+		{circuitbreaker.HalfOpen, circuitbreaker.ReasonTimeout, "consul"},
+		{circuitbreaker.Closed, circuitbreaker.ReasonExternal, "consul"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		ev := events[i]
+		if ev.To != w.to || ev.Reason != w.reason || ev.Source != w.source {
+			t.Errorf("event %d: expected %v/%s/%s, got %+v", i, w.to, w.reason, w.source, ev)
+		}
+	}
+}
+
+func TestObserveExternal_UnhealthyReopensHalfOpen(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{SuccessThreshold: 3, Strict: true})
+	cbt.AdvanceToHalfOpen(cb)
+
+	cb.ObserveExternal(true, "mesh")
+	cb.ObserveExternal(false, "mesh")
+
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected Open, got %v", cb.State())
+	}
+}
+
+func TestObserveExternal_DefaultsToFailureThreshold(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3})
+	cb.ObserveExternal(false, "mesh")
+	cb.ObserveExternal(false, "mesh")
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected Closed after 2 signals, got %v", cb.State())
+	}
+	cb.ObserveExternal(false, "mesh")
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected Open after FailureThreshold signals, got %v", cb.State())
+	}
+
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	nilBreaker.ObserveExternal(false, "mesh")
+}
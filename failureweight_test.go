@@ -0,0 +1,84 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+var (
+	errRefused  = errors.New("connection refused")
+	errSlowRead = errors.New("slow read")
+)
+
+func weighByCause(err error) float64 {
+	switch {
+	case errors.Is(err, errRefused):
+		return 2
+	case errors.Is(err, errSlowRead):
+		return 0.5
+	}
+	return 1
+}
+
+func TestFailureWeight_HeavyFailuresTripSooner(t *testing.T) {
+	newBreaker := func() *circuitbreaker.CircuitBreaker {
+		return circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold: 3,
+			FailureWeight:    weighByCause,
+			Strict:           true,
+		})
+	}
+	refused := func() (any, error) { return nil, errRefused }
+	slowRead := func() (any, error) { return nil, errSlowRead }
+
+	cb := newBreaker()
+	cb.Execute(refused)
+	cb.Execute(refused)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected two weight-2 failures to trip a threshold of 3, got %v", cb.State())
+	}
+
+	cb = newBreaker()
+	for i := 0; i < 4; i++ {
+		cb.Execute(slowRead)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected four weight-0.5 failures not to trip, got %v", cb.State())
+	}
+	if s := cb.Status(); s.FailureWeight != 2 || s.Counts.ConsecutiveFailures != 4 {
+		t.Errorf("expected weight 2 over 4 failures, got %v over %d", s.FailureWeight, s.Counts.ConsecutiveFailures)
+	}
+
+	// the weight survives a snapshot, and a success resets it.
+	restored := newBreaker()
+	if err := restored.Restore(cb.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Status().FailureWeight; got != 2 {
+		t.Errorf("expected the restored weight 2, got %v", got)
+	}
+	cb.Execute(successFn)
+	cb.Execute(refused)
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.FailureWeight != 2 {
+		t.Errorf("expected a success to reset the weight, got %v in %v", s.FailureWeight, s.State)
+	}
+}
+
+func TestFailureWeight_DefaultsToOne(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		FailureWeight:    func(error) float64 { panic("broken weight") },
+		OnPanic:          func(circuitbreaker.Panic) {},
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected one failure to leave the circuit closed, got %v", cb.State())
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected a panicking FailureWeight to weigh 1, got %v", cb.State())
+	}
+}
@@ -0,0 +1,84 @@
+package circuitbreaker
+
+import "time"
+
+// ForTenant names the tenant a call is made for. With Config.FairProbes
+// set, half-open probe slots are shared out round-robin between tenants,
+// so the busiest one cannot take them all. Calls without it share the
+// empty tenant. It has no effect otherwise.
+func ForTenant(tenant string) CallOption {
+	return func(o *callOptions) {
+		o.tenant = tenant
+	}
+}
+
+// fairProbes shares half-open probes between the tenants that have asked
+// for calls since the circuit opened. A tenant may take a probe only
+// while it has had no more probes this half-open period than any other
+// active tenant; tenants stop being active once they have not asked for
+// Config.Timeout, so one that went away does not hold the others back.
+type fairProbes struct {
+	max     int
+	idle    time.Duration
+	tenants map[string]*tenantProbes
+}
+
+type tenantProbes struct {
+	seen   time.Time
+	probes int
+}
+
+func newFairProbes(c Config) *fairProbes {
+	if !c.FairProbes {
+		return nil
+	}
+	return &fairProbes{max: c.FairProbeTenants, idle: c.Timeout, tenants: map[string]*tenantProbes{}}
+}
+
+// seen records that tenant asked for a call at now, making room by
+// forgetting the tenant that asked least recently if the limit is reached.
+func (f *fairProbes) seen(tenant string, now time.Time) {
+	if t, ok := f.tenants[tenant]; ok {
+		t.seen = now
+		return
+	}
+	if len(f.tenants) >= f.max {
+		var oldest string
+		first := true
+		for k, t := range f.tenants {
+			if first || t.seen.Before(f.tenants[oldest].seen) {
+				oldest, first = k, false
+			}
+		}
+		delete(f.tenants, oldest)
+	}
+	f.tenants[tenant] = &tenantProbes{seen: now}
+}
+
+// allow reports whether tenant, already seen, may take a probe at now.
+func (f *fairProbes) allow(tenant string, now time.Time) bool {
+	mine := f.tenants[tenant].probes
+	for k, t := range f.tenants {
+		if k != tenant && t.probes < mine && now.Sub(t.seen) < f.idle {
+			return false
+		}
+	}
+	return true
+}
+
+// took records that tenant was given a probe.
+func (f *fairProbes) took(tenant string) {
+	f.tenants[tenant].probes++
+}
+
+// newRound starts counting probes afresh for a new half-open period.
+func (f *fairProbes) newRound() {
+	for _, t := range f.tenants {
+		t.probes = 0
+	}
+}
+
+// reset forgets every tenant once the circuit has closed.
+func (f *fairProbes) reset() {
+	clear(f.tenants)
+}
@@ -0,0 +1,85 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// tenantTraffic sends rounds of 100 calls for "big" and 1 for "small"
+// until the circuit closes, and returns the probes each tenant got.
+func tenantTraffic(t *testing.T, cb *circuitbreaker.CircuitBreaker) map[string]int {
+	t.Helper()
+	probes := map[string]int{}
+	call := func(tenant string) {
+		cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+			if info, _ := circuitbreaker.FromContext(ctx); info.Probe {
+				probes[tenant]++
+			}
+			return nil, nil
+		}, circuitbreaker.ForTenant(tenant))
+	}
+	for round := 0; round < 10 && cb.State() != circuitbreaker.Closed; round++ {
+		for i := 0; i < 100; i++ {
+			call("big")
+		}
+		call("small")
+	}
+	return probes
+}
+
+func newTenantBreaker(clock *cbt.FakeClock, fair bool) *circuitbreaker.CircuitBreaker {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 4,
+		Timeout:          10 * time.Second,
+		FairProbes:       fair,
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+	return cb
+}
+
+func TestFairProbes_SharedBetweenTenants(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+
+	unfair := newTenantBreaker(clock, false)
+	clock.Advance(5 * time.Second)
+	tenantTraffic(t, unfair) // both tenants are seen while open.
+	clock.Advance(5 * time.Second)
+	if probes := tenantTraffic(t, unfair); probes["big"] != 4 || probes["small"] != 0 {
+		t.Errorf("expected the busy tenant to take every probe without fairness, got %v", probes)
+	}
+
+	fair := newTenantBreaker(clock, true)
+	clock.Advance(5 * time.Second)
+	tenantTraffic(t, fair)
+	clock.Advance(5 * time.Second)
+	if probes := tenantTraffic(t, fair); probes["big"] != 2 || probes["small"] != 2 {
+		t.Errorf("expected the probes shared two each, got %v", probes)
+	}
+	if fair.State() != circuitbreaker.Closed {
+		t.Errorf("expected the shared probes to close the circuit, got %v", fair.State())
+	}
+}
+
+func TestFairProbes_IdleTenantDoesNotHoldOthersBack(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newTenantBreaker(clock, true)
+	cb.Execute(successFn, circuitbreaker.ForTenant("gone"))
+
+	// "gone" has not asked for a whole timeout by the time probing starts.
+	clock.Advance(10 * time.Second)
+	for i := 0; i < 4; i++ {
+		if _, err := cb.Execute(successFn, circuitbreaker.ForTenant("busy")); err != nil {
+			t.Fatalf("probe %d: expected it admitted, got %v", i+1, err)
+		}
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the busy tenant alone to close the circuit, got %v", cb.State())
+	}
+}
@@ -0,0 +1,29 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+)
+
+// ExecuteWithFallback is Execute with a fallback, such as a cached or
+// degraded response: when the breaker rejects the call, or request fails,
+// it returns what fallback returns for the error instead. The fallback
+// runs after the outcome has been recorded, so its result never counts
+// for or against the breaker. Errors that Config.IsFailure does not count
+// as failures, Config.IgnoredErrors and errors wrapping ErrSkipRecording
+// are answers rather than failures and are returned without calling
+// fallback. If fallback
+// panics, the error is returned with the panic noted in its message, as
+// with the Fallback policy.
+func (cb *CircuitBreaker) ExecuteWithFallback(request func() (any, error), fallbackFn func(err error) (any, error), opts ...CallOption) (any, error) {
+	result, err := cb.Execute(request, opts...)
+	if err == nil || fallbackFn == nil || err == ErrNilFunction {
+		return result, err
+	}
+	if !isRejection(err) {
+		if outcome := cb.classify(err); outcome == nil || errors.Is(outcome, ErrSkipRecording) {
+			return result, err
+		}
+	}
+	return fallback(context.Background(), func(_ context.Context, err error) (any, error) { return fallbackFn(err) }, err)
+}
@@ -0,0 +1,97 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func cached(err error) (any, error) { return "cached", nil }
+
+func TestExecuteWithFallback_Rejected(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: time.Minute, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	cb.Execute(failFn)
+
+	var got error
+	result, err := cb.ExecuteWithFallback(successFn, func(err error) (any, error) {
+		got = err
+		return "cached", nil
+	})
+	if result != "cached" || err != nil {
+		t.Fatalf("expected the fallback's result, got %v, %v", result, err)
+	}
+	if !errors.Is(got, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the fallback to be passed ErrCircuitOpen, got %v", got)
+	}
+}
+
+func TestExecuteWithFallback_FailureStillCounts(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+
+	for i := 0; i < 2; i++ {
+		var got error
+		result, err := cb.ExecuteWithFallback(failFn, func(err error) (any, error) {
+			got = err
+			return "cached", nil
+		})
+		if result != "cached" || err != nil || got != errSimulated {
+			t.Fatalf("expected the fallback to handle %v, got %v, %v (passed %v)", errSimulated, result, err, got)
+		}
+	}
+	if c, tot := cb.Counts(), cb.Totals(); c.ConsecutiveFailures != 2 || tot.Failures != 2 || tot.Successes != 0 {
+		t.Errorf("expected the failures counted despite the fallback succeeding, got %+v %+v", c, tot)
+	}
+	cb.ExecuteWithFallback(failFn, cached)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the third failure to trip the circuit, got %v", cb.State())
+	}
+}
+
+func TestExecuteWithFallback_Success(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	result, err := cb.ExecuteWithFallback(successFn, func(err error) (any, error) {
+		t.Fatal("expected no fallback for a successful call")
+		return nil, nil
+	})
+	if result != "ok" || err != nil {
+		t.Errorf("expected the request's result, got %v, %v", result, err)
+	}
+}
+
+func TestExecuteWithFallback_NotAFailure(t *testing.T) {
+	errNotFound := errors.New("not found")
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Clock:     cbt.NewFakeClock(cbt.Epoch),
+		Strict:    true,
+		IsFailure: func(err error) bool { return err != errNotFound },
+	})
+	fallbacks := 0
+	count := func(err error) (any, error) {
+		fallbacks++
+		return "cached", nil
+	}
+
+	// an answer the breaker does not count as a failure reaches the
+	// caller as it is, and so does one that skips recording.
+	if _, err := cb.ExecuteWithFallback(func() (any, error) { return nil, errNotFound }, count); err != errNotFound {
+		t.Errorf("expected the request's own error back, got %v", err)
+	}
+	if _, err := cb.ExecuteWithFallback(func() (any, error) { return nil, errCacheHit }, count); err != errCacheHit {
+		t.Errorf("expected the request's own error back, got %v", err)
+	}
+	if fallbacks != 0 {
+		t.Errorf("expected no fallback for non-failures, got %d", fallbacks)
+	}
+}
+
+func TestExecuteWithFallback_FallbackPanics(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	result, err := cb.ExecuteWithFallback(failFn, func(err error) (any, error) { panic("cache down") })
+	if result != nil || !errors.Is(err, errSimulated) || !strings.Contains(err.Error(), "fallback panicked: cache down") {
+		t.Errorf("expected the request's error with the panic noted, got %v, %v", result, err)
+	}
+}
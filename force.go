@@ -0,0 +1,89 @@
+package circuitbreaker
+
+// Mode says whether a breaker runs its own state machine or has been
+// forced into a state by hand.
+type Mode int
+
+const (
+	// Automatic: the breaker opens and closes by itself.
+	Automatic Mode = iota
+	// ForcedOpen: set by ForceOpen. The circuit is open and rejects every
+	// call with ErrCircuitOpen, and never moves to HalfOpen by itself.
+	ForcedOpen
+	// ForcedClosed: set by ForceClosed. The circuit is closed and the
+	// breaker is bypassed: every call runs, and none is limited or
+	// counted.
+	ForcedClosed
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ForcedOpen:
+		return "ForcedOpen"
+	case ForcedClosed:
+		return "ForcedClosed"
+	default:
+		return "Automatic"
+	}
+}
+
+// ForceOpen opens the circuit and holds it open, for planned downtime of
+// the dependency, until Clear or Reset is called. Calls are rejected with
+// ErrCircuitOpen, and neither the open timeout nor any other signal moves
+// the circuit on.
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.force(ForcedOpen, Open)
+}
+
+// ForceClosed closes the circuit and bypasses the breaker, to let traffic
+// through in an emergency, until Clear or Reset is called. Every call
+// runs, ignoring the bulkhead, the retry budget and local pressure, and
+// its outcome is not counted.
+func (cb *CircuitBreaker) ForceClosed() {
+	cb.force(ForcedClosed, Closed)
+}
+
+// force puts the breaker in mode, in state to.
+func (cb *CircuitBreaker) force(mode Mode, to State) {
+	if cb == nil {
+		return
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.mode = mode
+	if cb.state == to {
+		// not a transition, but calls in flight must not count
+		// against the forced state either.
+		cb.generation++
+	}
+	cb.setState(to, ReasonForced)
+}
+
+// Clear returns a breaker forced with ForceOpen or ForceClosed to
+// automatic operation, carrying on from the forced state: a circuit
+// forced open lets a probe through once Timeout has passed since it was
+// forced, and one forced closed starts counting afresh. A maintenance
+// window or ejection that started meanwhile opens the circuit. Clear does
+// nothing to a breaker that is not forced.
+func (cb *CircuitBreaker) Clear() {
+	if cb == nil {
+		return
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.mode == Automatic {
+		return
+	}
+	cb.mode = Automatic
+	switch {
+	case cb.maintenance:
+		cb.setState(Open, ReasonMaintenance)
+	case cb.ejected:
+		cb.setState(Open, ReasonOutlier)
+	}
+}
@@ -0,0 +1,134 @@
+package circuitbreaker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestForceOpen_SurvivesTrafficAndTimeout(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var changes []circuitbreaker.Event
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.Type == circuitbreaker.EventStateChange {
+				changes = append(changes, ev)
+			}
+		},
+	})
+	cb.ForceOpen()
+	if len(changes) != 1 || changes[0].To != circuitbreaker.Open || changes[0].Reason != circuitbreaker.ReasonForced {
+		t.Fatalf("expected one forced transition to Open, got %+v", changes)
+	}
+
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Hour)
+		if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+			t.Fatalf("expected ErrCircuitOpen while forced open, got %v", err)
+		}
+	}
+	s := cb.Status()
+	if s.State != circuitbreaker.Open || s.Mode != circuitbreaker.ForcedOpen || s.OpenRemaining != 0 {
+		t.Errorf("expected a forced-open status with no timeout running, got %v %v %v", s.State, s.Mode, s.OpenRemaining)
+	}
+
+	// clearing hands the circuit back to its timeout, which has long
+	// passed, so the next call probes.
+	cb.Clear()
+	if cb.Status().Mode != circuitbreaker.Automatic {
+		t.Fatalf("expected Clear to return to automatic operation")
+	}
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Fatalf("expected a probe after Clear, got %v", err)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the probe to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestForceClosed_BypassesAndCountsNothing(t *testing.T) {
+	calls := 0
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		MaxConcurrent:    1,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+		OnCall:           func(circuitbreaker.CallRecord) { calls++ },
+	})
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected the circuit open, got %v", cb.State())
+	}
+
+	cb.ForceClosed()
+	for i := 0; i < 10; i++ {
+		if _, err := cb.Execute(failFn); err != errSimulated {
+			t.Fatalf("expected every call to run while forced closed, got %v", err)
+		}
+	}
+	// the bulkhead is bypassed too.
+	cb.Execute(func() (any, error) { return cb.Execute(successFn) })
+	s := cb.Status()
+	if s.State != circuitbreaker.Closed || s.Mode != circuitbreaker.ForcedClosed || s.Counts.ConsecutiveFailures != 0 || s.InFlight != 0 {
+		t.Errorf("expected a forced-closed breaker with nothing counted, got %+v", s)
+	}
+	if s.Totals.Failures != 2 || calls != 2 {
+		t.Errorf("expected only the calls before forcing counted, got %+v and %d records", s.Totals, calls)
+	}
+
+	cb.Reset()
+	if cb.Status().Mode != circuitbreaker.Automatic {
+		t.Fatal("expected Reset to end the forced mode")
+	}
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected failures to count again after Reset, got %v", cb.State())
+	}
+}
+
+func TestForce_MaintenanceDoesNotOverride(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Clock:  clock,
+		Strict: true,
+		MaintenanceWindows: []circuitbreaker.Window{
+			{Start: cbt.Epoch.Add(time.Hour), Duration: time.Hour},
+		},
+	})
+	cb.ForceOpen()
+	clock.Advance(3 * time.Hour)
+	if s := cb.Status(); s.State != circuitbreaker.Open || s.Mode != circuitbreaker.ForcedOpen {
+		t.Errorf("expected the end of maintenance to leave the forced state alone, got %v %v", s.State, s.Mode)
+	}
+
+	cb.ForceClosed()
+	cb.Clear()
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected Clear to carry on from Closed, got %v", cb.State())
+	}
+}
+
+func TestForce_Report(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	body, _ := json.Marshal(cb.Report())
+	if strings.Contains(string(body), `"mode"`) {
+		t.Errorf("expected no mode while automatic, got %s", body)
+	}
+	cb.ForceClosed()
+	body, _ = json.Marshal(cb.Report())
+	if !strings.Contains(string(body), `"mode":"ForcedClosed"`) {
+		t.Errorf("expected the forced mode in the report, got %s", body)
+	}
+}
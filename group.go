@@ -0,0 +1,351 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Group keeps one breaker per key, such as per destination host, so one
+// bad key does not open the circuit for the others. Breakers are created
+// on first use and share the group's Config, with the key appended to the
+// Name. A Group is safe for concurrent use.
+type Group struct {
+	config  Config
+	clock   Clock
+	outlier *OutlierDetection
+	// Most keys that get their own breaker; 0 means no limit.
+	maxKeys int
+	// How long an unused key keeps its breaker; 0 means forever.
+	idleTTL time.Duration
+	// Configs for keys that do not use config.
+	overrides map[string]Config
+
+	mu      sync.Mutex
+	members map[string]*member
+	// Lookups of keys beyond maxKeys, served by the OverflowKey breaker.
+	overflowed uint64
+	// Keys dropped for being idle, and when idle keys were last looked
+	// for.
+	evicted uint64
+	swept   time.Time
+	closed  bool
+	// Runs the outlier detector; nil unless it is enabled.
+	timer Timer
+}
+
+// member is a key's breaker plus what the group tracks about it.
+type member struct {
+	breaker *CircuitBreaker
+	// Recent outcomes, for outlier detection.
+	window *bucketWindow
+	// How often the key has been ejected, which lengthens each ejection.
+	ejections    int
+	ejected      bool
+	ejectedUntil time.Time
+	// When the key was last looked up, for WithIdleTTL.
+	used time.Time
+}
+
+// GroupOption configures optional Group behaviour.
+type GroupOption func(*Group)
+
+// NewGroup returns an empty Group whose breakers use cfg.
+func NewGroup(cfg Config, opts ...GroupOption) *Group {
+	g := &Group{
+		config:  cfg,
+		clock:   cfg.Clock,
+		members: make(map[string]*member),
+	}
+	if g.clock == nil {
+		g.clock = systemClock{}
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.outlier != nil {
+		g.timer = g.clock.AfterFunc(g.outlier.Interval, g.onOutlierTimer)
+	}
+	return g
+}
+
+// Execute runs fn through the breaker for key, creating it if needed.
+func (g *Group) Execute(key string, fn func() (any, error), opts ...CallOption) (any, error) {
+	if fn == nil {
+		return nil, ErrNilFunction
+	}
+	m := g.member(key)
+	result, err := m.breaker.Execute(fn, opts...)
+	g.observe(m, err)
+	return result, err
+}
+
+// ExecuteContext is Execute for CircuitBreaker.ExecuteContext.
+func (g *Group) ExecuteContext(ctx context.Context, key string, request func(context.Context) (any, error), opts ...CallOption) (any, error) {
+	if request == nil {
+		return nil, ErrNilFunction
+	}
+	m := g.member(key)
+	result, err := m.breaker.ExecuteContext(ctx, request, opts...)
+	g.observe(m, err)
+	return result, err
+}
+
+// observe adds the outcome of a call that ran to m's outlier window. Calls
+// the breaker did not count, rejected or not recorded, are left out.
+func (g *Group) observe(m *member, err error) {
+	if g.outlier == nil || err != nil && isRejection(err) {
+		return
+	}
+	outcome := m.breaker.classify(err)
+	if errors.Is(outcome, ErrSkipRecording) {
+		return
+	}
+	g.mu.Lock()
+	m.window.add(g.clock.Now(), outcome != nil)
+	g.mu.Unlock()
+}
+
+// Breaker returns the breaker for key, creating it if needed.
+func (g *Group) Breaker(key string) *CircuitBreaker {
+	return g.member(key).breaker
+}
+
+// Keys returns the keys that currently have a breaker, sorted.
+func (g *Group) Keys() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	keys := make([]string, 0, len(g.members))
+	for k := range g.members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Statuses returns the status of every breaker in the group, sorted by key.
+func (g *Group) Statuses() []Status {
+	g.mu.Lock()
+	keys := make([]string, 0, len(g.members))
+	for k := range g.members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	breakers := make([]*CircuitBreaker, len(keys))
+	for i, k := range keys {
+		breakers[i] = g.members[k].breaker
+	}
+	g.mu.Unlock()
+
+	statuses := make([]Status, len(breakers))
+	for i, cb := range breakers {
+		statuses[i] = cb.Status()
+	}
+	return statuses
+}
+
+// Close stops the group's background work and closes every breaker in it.
+// It is idempotent and always returns nil.
+func (g *Group) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	members := g.members
+	g.mu.Unlock()
+
+	for _, m := range members {
+		m.breaker.Close()
+	}
+	return nil
+}
+
+// OverflowKey is the key of the breaker that serves every key beyond a
+// Group's WithMaxKeys limit.
+const OverflowKey = "__overflow__"
+
+// WithMaxKeys caps how many keys get a breaker of their own. Once n keys
+// exist, calls for any new key share a single breaker under OverflowKey,
+// which keeps memory bounded when a buggy key function produces a new key
+// per request. The first overflow emits an EventKeyOverflow from that
+// breaker; GroupStats counts them all.
+func WithMaxKeys(n int) GroupOption {
+	return func(g *Group) {
+		g.maxKeys = n
+	}
+}
+
+// WithIdleTTL drops the breaker of a key that has not been looked up for
+// ttl, so a group keyed by something unbounded, such as client addresses,
+// does not keep every key it has ever seen. Only breakers at rest are
+// dropped: closed, not forced and with no calls in flight, so no open
+// circuit or running call is lost. What the breaker has seen goes with it:
+// its ConsecutiveFailures, the contents of its windows, its Totals and its
+// latency stats start over on the key's new breaker, as does the key's
+// outlier history. A dropped breaker is closed, and the key gets a new one
+// on its next use. Idle keys are looked for at most once per ttl, on
+// lookups.
+func WithIdleTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.idleTTL = ttl
+	}
+}
+
+// GroupStats describes a Group's keys.
+type GroupStats struct {
+	// Keys is the number of breakers in the group, including the overflow
+	// breaker if it exists.
+	Keys int
+	// Overflowed counts the lookups of keys beyond the WithMaxKeys limit
+	// that were served by the OverflowKey breaker.
+	Overflowed uint64
+	// Evicted counts the keys dropped for being idle; see WithIdleTTL.
+	Evicted uint64
+}
+
+// Stats returns the group's key counters.
+func (g *Group) Stats() GroupStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return GroupStats{Keys: len(g.members), Overflowed: g.overflowed, Evicted: g.evicted}
+}
+
+// WithKeyConfig gives the breaker for key its own Config instead of the
+// group's. The Name is still derived from the group's Name and the key,
+// and a nil Clock is taken from the group's Config. Overriding OverflowKey
+// configures the overflow breaker.
+func WithKeyConfig(key string, cfg Config) GroupOption {
+	return func(g *Group) {
+		if g.overrides == nil {
+			g.overrides = make(map[string]Config)
+		}
+		g.overrides[key] = cfg
+	}
+}
+
+// member returns key's entry, creating it under the lock so concurrent
+// first uses of a key share one breaker.
+func (g *Group) member(key string) *member {
+	g.mu.Lock()
+	var evicted []*CircuitBreaker
+	if g.idleTTL > 0 {
+		evicted = g.evictIdle()
+		// closed outside g.mu, so their hooks may use the group.
+		defer func() {
+			for _, cb := range evicted {
+				cb.Close()
+			}
+		}()
+	}
+	if m, ok := g.members[key]; ok {
+		m.used = g.clock.Now()
+		g.mu.Unlock()
+		return m
+	}
+	if g.maxKeys == 0 || g.keyCount() < g.maxKeys {
+		m := g.newMember(key)
+		g.mu.Unlock()
+		return m
+	}
+	g.overflowed++
+	m, ok := g.members[OverflowKey]
+	if !ok {
+		m = g.newMember(OverflowKey)
+	}
+	m.used = g.clock.Now()
+	g.mu.Unlock()
+
+	if !ok {
+		// outside g.mu, so the event hook may use the group.
+		m.breaker.emitKeyOverflow(key)
+	}
+	return m
+}
+
+// evictIdle drops the members unused for g.idleTTL whose breakers are at
+// rest, at most once per idleTTL, and returns their breakers for the
+// caller to close once g.mu is released. Must be called with g.mu held.
+func (g *Group) evictIdle() []*CircuitBreaker {
+	now := g.clock.Now()
+	if now.Sub(g.swept) < g.idleTTL {
+		return nil
+	}
+	g.swept = now
+	var evicted []*CircuitBreaker
+	for k, m := range g.members {
+		if now.Sub(m.used) >= g.idleTTL && m.breaker.atRest() {
+			delete(g.members, k)
+			evicted = append(evicted, m.breaker)
+		}
+	}
+	g.evicted += uint64(len(evicted))
+	return evicted
+}
+
+// keyCount is the number of keys with their own breaker. Must be called
+// with g.mu held.
+func (g *Group) keyCount() int {
+	if _, ok := g.members[OverflowKey]; ok {
+		return len(g.members) - 1
+	}
+	return len(g.members)
+}
+
+// newMember creates and registers key's entry. Must be called with g.mu
+// held.
+func (g *Group) newMember(key string) *member {
+	cfg := g.config
+	if o, ok := g.overrides[key]; ok {
+		cfg = o
+		if cfg.Clock == nil {
+			cfg.Clock = g.config.Clock
+		}
+	}
+	cfg.Name = key
+	if g.config.Name != "" {
+		cfg.Name = g.config.Name + "/" + key
+	}
+	m := &member{breaker: New(cfg), used: g.clock.Now()}
+	if g.outlier != nil {
+		m.window = newBucketWindow(g.outlier.Interval, windowBuckets)
+	}
+	if g.closed {
+		m.breaker.Close()
+	}
+	g.members[key] = m
+	return m
+}
+
+type keyContextKey struct{}
+
+// WithKey returns a context that asks integrations backed by a Group, such
+// as the cbsql driver wrapper, to use the breaker for key for calls made
+// with it. This lets one connection pool keep separate circuits for, say,
+// analytics and OLTP queries.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// KeyFromContext returns the key set with WithKey, if any.
+func KeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(string)
+	return key, ok
+}
+
+// atRest reports whether the breaker is closed, not forced and has no
+// calls in flight, so that a new one would be no different.
+func (cb *CircuitBreaker) atRest() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.state == Closed && cb.mode == Automatic && !cb.maintenance && len(cb.running.calls) == 0
+}
@@ -0,0 +1,172 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestMaxHalfOpenRequests_LimitsConcurrentProbes(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:    1,
+		SuccessThreshold:    5,
+		MaxHalfOpenRequests: 3,
+		Timeout:             time.Minute,
+		Clock:               clock,
+		Strict:              true,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	var ran, tooMany atomic.Int32
+	release := make(chan struct{})
+	var rejected, admitted sync.WaitGroup
+	start := make(chan struct{})
+	for range 100 {
+		rejected.Add(1)
+		admitted.Add(1)
+		go func() {
+			<-start
+			_, err := cb.Execute(func() (any, error) {
+				ran.Add(1)
+				rejected.Done()
+				<-release
+				return nil, nil
+			})
+			if errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+				tooMany.Add(1)
+				rejected.Done()
+			}
+			admitted.Done()
+		}()
+	}
+	close(start)
+	rejected.Wait()
+	if ran.Load() != 3 || tooMany.Load() != 97 {
+		t.Fatalf("expected 3 probes to run and 97 callers turned away, got %d and %d", ran.Load(), tooMany.Load())
+	}
+	close(release)
+	admitted.Wait()
+
+	// the finished probes free their slots; two more successes close.
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected to stay half-open after 3 of 5 successes, got %v", cb.State())
+	}
+	cb.Execute(successFn)
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected later probes to be admitted and close the circuit, got %v", cb.State())
+	}
+}
+
+func TestMaxHalfOpenRequests_DefaultsToOne(t *testing.T) {
+	if got := circuitbreaker.DefaultConfig().MaxHalfOpenRequests; got != 1 {
+		t.Errorf("expected a default of 1, got %d", got)
+	}
+	if err := (circuitbreaker.Config{MaxHalfOpenRequests: -1}).Validate(); err == nil {
+		t.Error("expected a negative MaxHalfOpenRequests to be rejected")
+	}
+}
+
+// Half-open rejections are ErrTooManyRequests and never ErrCircuitOpen,
+// cycle after cycle, whether the probes succeed or fail.
+func TestHalfOpen_RejectionsAreNotErrCircuitOpen(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+	halfOpenRejection := func(cycle int, opts ...circuitbreaker.CallOption) {
+		t.Helper()
+		_, err := cb.Execute(successFn, opts...)
+		if !errors.Is(err, circuitbreaker.ErrTooManyRequests) || errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+			t.Fatalf("cycle %d: expected ErrTooManyRequests while half-open, got %v", cycle, err)
+		}
+	}
+	for cycle := 0; cycle < 4; cycle++ {
+		cb.Execute(successFn)
+		cb.Execute(failFn)
+		cb.Execute(successFn)
+		cb.Execute(failFn)
+		cb.Execute(failFn)
+		if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+			t.Fatalf("cycle %d: expected ErrCircuitOpen while open, got %v", cycle, err)
+		}
+		clock.Advance(time.Minute)
+
+		probe, err := cb.Allow()
+		if err != nil {
+			t.Fatalf("cycle %d: expected the first probe admitted, got %v", cycle, err)
+		}
+		halfOpenRejection(cycle)
+		halfOpenRejection(cycle, circuitbreaker.NoProbe())
+		probe(true)
+		if cycle%2 == 1 {
+			// a failed second probe reopens; the next cycle starts open.
+			cb.Execute(failFn)
+			if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+				t.Fatalf("cycle %d: expected a failed probe to reopen, got %v", cycle, err)
+			}
+			clock.Advance(time.Minute)
+			cb.Execute(successFn)
+		}
+		cb.Execute(successFn)
+		if cb.State() != circuitbreaker.Closed {
+			t.Fatalf("cycle %d: expected SuccessThreshold probes to close, got %v", cycle, cb.State())
+		}
+	}
+	if tot := cb.Totals(); tot.Rejected != 14 {
+		t.Errorf("expected every rejection counted, got %d", tot.Rejected)
+	}
+}
+
+func TestHalfOpenPolicy_FlappingSequence(t *testing.T) {
+	flapping := []bool{true, false, true, false, true}
+	tests := []struct {
+		name        string
+		policy      circuitbreaker.HalfOpenPolicy
+		maxFailures int
+		want        circuitbreaker.State
+	}{
+		{"cumulative reopens on the first failure by default", circuitbreaker.CumulativeSuccesses, 0, circuitbreaker.Open},
+		{"cumulative tolerating failures closes", circuitbreaker.CumulativeSuccesses, 3, circuitbreaker.Closed},
+		{"consecutive tolerating failures stays half-open", circuitbreaker.ConsecutiveSuccesses, 3, circuitbreaker.HalfOpen},
+		{"consecutive reopens once failures run out", circuitbreaker.ConsecutiveSuccesses, 2, circuitbreaker.Open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := cbt.NewFakeClock(cbt.Epoch)
+			cb := circuitbreaker.New(circuitbreaker.Config{
+				FailureThreshold:    1,
+				SuccessThreshold:    3,
+				HalfOpenPolicy:      tt.policy,
+				HalfOpenMaxFailures: tt.maxFailures,
+				Timeout:             time.Minute,
+				Clock:               clock,
+				Strict:              true,
+			})
+			cb.Execute(failFn)
+			clock.Advance(time.Minute)
+
+			for _, ok := range flapping {
+				if ok {
+					cb.Execute(successFn)
+				} else {
+					cb.Execute(failFn)
+				}
+			}
+			if got := cb.State(); got != tt.want {
+				t.Errorf("expected %v after success, failure, success, failure, success, got %v", tt.want, got)
+			}
+		})
+	}
+}
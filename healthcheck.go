@@ -0,0 +1,91 @@
+package circuitbreaker
+
+import "context"
+
+// healthCheckSource is the source of the state changes health checks
+// cause; see Event.Source.
+const healthCheckSource = "health check"
+
+// healthCheck runs Config.HealthCheck while the circuit is not closed.
+type healthCheck struct {
+	// timer runs the next check; nil while one is in progress or none is
+	// due.
+	timer Timer
+	// cancel ends the check in progress early.
+	cancel context.CancelFunc
+	// seq identifies the run of checks, so a timer or check that could not
+	// be stopped in time does not act on a later one.
+	seq uint64
+}
+
+// trackHealthCheck starts health checks when the circuit leaves Closed and
+// stops them when it closes again. Must be called with cb.mu held.
+func (cb *CircuitBreaker) trackHealthCheck(from, to State) {
+	if cb.config.HealthCheck == nil {
+		return
+	}
+	switch {
+	case to == Closed:
+		cb.stopHealthCheck()
+	case from == Closed:
+		cb.health.seq++
+		cb.armHealthCheck()
+	}
+}
+
+// armHealthCheck schedules the next check. Must be called with cb.mu held.
+func (cb *CircuitBreaker) armHealthCheck() {
+	seq := cb.health.seq
+	cb.health.timer = cb.clock.AfterFunc(cb.config.HealthCheckInterval, func() { cb.runHealthCheck(seq) })
+}
+
+// stopHealthCheck stops the timer and cancels a check in progress. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) stopHealthCheck() {
+	hc := &cb.health
+	hc.seq++
+	if hc.timer != nil {
+		hc.timer.Stop()
+		hc.timer = nil
+	}
+	if hc.cancel != nil {
+		hc.cancel()
+		hc.cancel = nil
+	}
+}
+
+// runHealthCheck runs one check, outside the lock, and feeds its result to
+// the state machine as ObserveExternal does. The next check is only armed
+// once this one has returned, so checks never overlap. A check that is
+// still running after HealthCheckInterval has its context cancelled, and
+// one that panics counts as unhealthy.
+func (cb *CircuitBreaker) runHealthCheck(seq uint64) {
+	cb.mu.Lock()
+	if cb.closed || cb.health.seq != seq {
+		cb.unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	deadline := cb.clock.AfterFunc(cb.config.HealthCheckInterval, cancel)
+	cb.health.timer = nil
+	cb.health.cancel = cancel
+	check := cb.config.HealthCheck
+	cb.unlock()
+
+	healthy := false
+	cb.protect("HealthCheck", func() { healthy = check(ctx) == nil })
+	deadline.Stop()
+	cancel()
+
+	cb.mu.Lock()
+	defer cb.unlock()
+	if cb.closed || cb.health.seq != seq {
+		return
+	}
+	cb.health.cancel = nil
+	cb.observe(healthy, healthCheckSource)
+	// a check that closed the circuit has ended the run.
+	if cb.health.seq == seq {
+		cb.armHealthCheck()
+	}
+}
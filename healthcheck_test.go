@@ -0,0 +1,149 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestHealthCheck_RecoversWithoutTraffic(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var healthy atomic.Bool
+	var checks atomic.Int32
+	var events []circuitbreaker.Event
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:    1,
+		SuccessThreshold:    2,
+		Timeout:             30 * time.Second,
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheck: func(context.Context) error {
+			checks.Add(1)
+			if healthy.Load() {
+				return nil
+			}
+			return errSimulated
+		},
+		OnEvent: func(ev circuitbreaker.Event) { events = append(events, ev) },
+		Clock:   clock,
+		Strict:  true,
+	})
+	if clock.PendingTimers() != 0 {
+		t.Fatal("expected no health checks while closed")
+	}
+
+	cb.Execute(failFn)
+	clock.Advance(30 * time.Second)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected unhealthy checks to keep the circuit open, got %v", cb.State())
+	}
+	clock.Advance(10 * time.Second)
+	healthy.Store(true)
+	clock.Advance(10 * time.Second)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected a healthy check past the timeout to half-open the circuit, got %v", cb.State())
+	}
+	clock.Advance(10 * time.Second)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected SuccessThreshold healthy checks to close the circuit, got %v", cb.State())
+	}
+	if got := checks.Load(); got != 6 {
+		t.Errorf("expected 6 checks, got %d", got)
+	}
+	if got := events[len(events)-1].Source; got != "health check" {
+		t.Errorf("expected the close attributed to the health check, got %q", got)
+	}
+	clock.Advance(time.Hour)
+	if got := checks.Load(); got != 6 || clock.PendingTimers() != 0 {
+		t.Errorf("expected checks to stop once closed, got %d checks and %d timers", got, clock.PendingTimers())
+	}
+	if got := cb.Totals().Requests; got != 1 {
+		t.Errorf("expected checks not to count as requests, got %d", got)
+	}
+}
+
+func TestHealthCheck_UnhealthyReopens(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var healthy atomic.Bool
+	healthy.Store(true)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:    1,
+		SuccessThreshold:    3,
+		Timeout:             time.Minute,
+		HealthCheckInterval: time.Minute,
+		HealthCheck: func(context.Context) error {
+			if healthy.Load() {
+				return nil
+			}
+			return errSimulated
+		},
+		Clock:  clock,
+		Strict: true,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected HalfOpen, got %v", cb.State())
+	}
+	healthy.Store(false)
+	clock.Advance(time.Minute)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected an unhealthy check to reopen the circuit, got %v", cb.State())
+	}
+	healthy.Store(true)
+	clock.Advance(4 * time.Minute)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected checks to carry on and close the circuit, got %v", cb.State())
+	}
+}
+
+func TestHealthCheck_CloseCancelsAndStops(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	started := make(chan struct{})
+	var checks atomic.Int32
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Minute,
+		HealthCheck: func(ctx context.Context) error {
+			if checks.Add(1) == 1 {
+				close(started)
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Clock:  clock,
+		Strict: true,
+	})
+	cb.Execute(failFn)
+
+	// the fake clock runs the check inside Advance, which blocks until
+	// the check returns.
+	advanced := make(chan struct{})
+	go func() {
+		clock.Advance(time.Minute)
+		close(advanced)
+	}()
+	<-started
+	cb.Close()
+	select {
+	case <-advanced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Close to cancel the check in progress")
+	}
+	clock.Advance(time.Hour)
+	if got := checks.Load(); got != 1 || clock.PendingTimers() != 0 {
+		t.Errorf("expected no checks after Close, got %d checks and %d timers", got, clock.PendingTimers())
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected a cancelled check not to change the state, got %v", cb.State())
+	}
+}
+
+func TestConfig_ValidateHealthCheckInterval(t *testing.T) {
+	if err := (circuitbreaker.Config{HealthCheckInterval: -time.Second}).Validate(); err == nil {
+		t.Error("expected a negative HealthCheckInterval to be rejected")
+	}
+}
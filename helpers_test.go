@@ -0,0 +1,25 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// Shared helpers for the external (circuitbreaker_test) test files.
+
+var errSimulated = errors.New("simulated failure")
+
+func successFn() (any, error) { return "ok", nil }
+
+func failFn() (any, error) { return nil, errSimulated }
+
+// counters keeps only the counters of c that state changes reset, for
+// comparing against an expected Counts.
+func counters(c circuitbreaker.Counts) circuitbreaker.Counts {
+	return circuitbreaker.Counts{
+		ConsecutiveFailures:  c.ConsecutiveFailures,
+		ConsecutiveSuccesses: c.ConsecutiveSuccesses,
+		Successes:            c.Successes,
+	}
+}
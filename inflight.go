@@ -0,0 +1,103 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrInFlightDeadline is the provisional outcome recorded for a call that
+// is still running Config.InFlightDeadline after it started. It is never
+// returned to callers.
+var ErrInFlightDeadline = errors.New("circuit breaker: call overdue")
+
+// running tracks the calls admitted and not yet completed, so that hung
+// calls can count against the circuit before they return.
+type running struct {
+	next  uint64
+	calls map[uint64]runningCall
+	timer Timer
+}
+
+type runningCall struct {
+	c call
+	// Set once the call has been counted as a failure for running past
+	// Config.InFlightDeadline.
+	overdue bool
+}
+
+// add starts tracking c and returns its ID.
+func (r *running) add(c call) uint64 {
+	r.next++
+	if r.calls == nil {
+		r.calls = map[uint64]runningCall{}
+	}
+	r.calls[r.next] = runningCall{c: c}
+	return r.next
+}
+
+// remove stops tracking the call with id and reports whether it had
+// already been counted as overdue.
+func (r *running) remove(id uint64) (overdue bool) {
+	if rc, ok := r.calls[id]; ok {
+		overdue = rc.overdue
+		delete(r.calls, id)
+	}
+	return overdue
+}
+
+// oldest returns how long the longest-running call has been running.
+func (r *running) oldest(now time.Time) time.Duration {
+	var age time.Duration
+	for _, rc := range r.calls {
+		age = max(age, elapsed(rc.c.start, now))
+	}
+	return age
+}
+
+// inFlightInterval is how often running calls are checked against
+// Config.InFlightDeadline.
+func (c Config) inFlightInterval() time.Duration {
+	return max(c.InFlightDeadline/4, time.Millisecond)
+}
+
+// startInFlight arms the first check for overdue calls. Called once from
+// lazyInit.
+func (cb *CircuitBreaker) startInFlight() {
+	if cb.config.InFlightDeadline <= 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.running.timer = cb.clock.AfterFunc(cb.config.inFlightInterval(), cb.onInFlightTimer)
+}
+
+// onInFlightTimer counts every call that has just run past
+// Config.InFlightDeadline as a failure, oldest first, and arms the next
+// check. The call's own outcome is not counted again when it returns.
+func (cb *CircuitBreaker) onInFlightTimer() {
+	cb.mu.Lock()
+	defer cb.unlock()
+	if cb.closed {
+		return
+	}
+	now := cb.clock.Now()
+	ids := make([]uint64, 0, len(cb.running.calls))
+	for id := range cb.running.calls {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		rc := cb.running.calls[id]
+		age := elapsed(rc.c.start, now)
+		if rc.overdue || age < cb.config.InFlightDeadline || (rc.c.neutral != nil && rc.c.neutral.Load()) {
+			continue
+		}
+		rc.overdue = true
+		cb.running.calls[id] = rc
+		// weighed as 1: FailureWeight cannot be called under the lock.
+		cb.record(rc.c, ErrInFlightDeadline, 1, age, now)
+	}
+	cb.updateDegraded()
+	cb.running.timer = cb.clock.AfterFunc(cb.config.inFlightInterval(), cb.onInFlightTimer)
+}
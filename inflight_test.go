@@ -0,0 +1,160 @@
+package circuitbreaker_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// hang starts n calls through cb that block until release is closed, and
+// waits for all of them to be admitted.
+func hang(t *testing.T, cb *circuitbreaker.CircuitBreaker, n int, release <-chan struct{}, outcome error) *sync.WaitGroup {
+	t.Helper()
+	want := cb.Status().InFlight + n
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cb.Execute(func() (any, error) {
+				<-release
+				return nil, outcome
+			})
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for cb.Status().InFlight < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d calls running", cb.Status().InFlight, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return &wg
+}
+
+func TestInFlightDeadline_OpensBeforeHungCallsReturn(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var calls int
+	var mu sync.Mutex
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		Timeout:          time.Minute,
+		InFlightDeadline: time.Second,
+		Clock:            clock,
+		Strict:           true,
+		OnCall: func(circuitbreaker.CallRecord) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+		},
+	})
+	defer cb.Close()
+
+	release := make(chan struct{})
+	wg := hang(t, cb, 3, release, nil)
+
+	clock.Advance(750 * time.Millisecond)
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.OldestInFlight != 750*time.Millisecond {
+		t.Fatalf("before the deadline: got %v with oldest call %s, want closed and 750ms", s.State, s.OldestInFlight)
+	}
+	clock.Advance(250 * time.Millisecond)
+	s := cb.Status()
+	if s.State != circuitbreaker.Open {
+		t.Fatalf("expected the hung calls to open the circuit, got %v", s.State)
+	}
+	if s.InFlight != 3 || s.FailureRate != 1 {
+		t.Errorf("expected 3 calls still running, all counted as failures; got %d running, failure rate %v", s.InFlight, s.FailureRate)
+	}
+
+	// the calls succeed once they return, but were already counted.
+	close(release)
+	wg.Wait()
+	s = cb.Status()
+	if s.State != circuitbreaker.Open || s.InFlight != 0 || s.OldestInFlight != 0 {
+		t.Errorf("after the calls returned: got %v with %d running for %s, want open and none", s.State, s.InFlight, s.OldestInFlight)
+	}
+	if s.FailureRate != 1 || s.Counts.ConsecutiveFailures != 0 {
+		t.Errorf("late outcomes were counted again: failure rate %v, %d consecutive failures", s.FailureRate, s.Counts.ConsecutiveFailures)
+	}
+	if calls != 3 {
+		t.Errorf("expected OnCall for each of the 3 calls, got %d", calls)
+	}
+}
+
+func TestInFlightDeadline_CallsReturningInTimeCountNormally(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		InFlightDeadline: time.Second,
+		Clock:            clock,
+		Strict:           true,
+	})
+	defer cb.Close()
+
+	release := make(chan struct{})
+	wg := hang(t, cb, 1, release, errSimulated)
+	clock.Advance(900 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	clock.Advance(time.Hour)
+
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.Counts.ConsecutiveFailures != 1 {
+		t.Errorf("expected the failure counted once when it returned, got %v with %d consecutive failures", s.State, s.Counts.ConsecutiveFailures)
+	}
+}
+
+func TestExecute_OutcomeAfterStateChangeIsNotCounted(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+	defer cb.Close()
+
+	// admitted while closed; the circuit opens and goes half-open while
+	// they run.
+	releaseFailure, releaseSuccess := make(chan struct{}), make(chan struct{})
+	failing := hang(t, cb, 1, releaseFailure, errSimulated)
+	succeeding := hang(t, cb, 1, releaseSuccess, nil)
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected Open, got %v", cb.State())
+	}
+	clock.Advance(time.Minute)
+	releaseProbe := make(chan struct{})
+	probe := hang(t, cb, 1, releaseProbe, nil)
+
+	close(releaseFailure)
+	failing.Wait()
+	close(releaseSuccess)
+	succeeding.Wait()
+	if s := cb.Status(); s.State != circuitbreaker.HalfOpen || counters(s.Counts) != (circuitbreaker.Counts{}) {
+		t.Errorf("expected the late outcomes to leave the half-open circuit alone, got %v %+v", s.State, s.Counts)
+	}
+
+	// the probe, admitted half-open, still decides it.
+	close(releaseProbe)
+	probe.Wait()
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the probe to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestExecute_RequestsRunConcurrently(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	release := make(chan struct{})
+	// hang only returns once all the calls are running at the same time.
+	wg := hang(t, cb, 10, release, nil)
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Errorf("expected a call to complete while others run, got %v", err)
+	}
+	close(release)
+	wg.Wait()
+}
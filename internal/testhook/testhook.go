@@ -0,0 +1,15 @@
+// Package testhook lets circuitbreakertest reach into circuit breaker
+// internals without widening the public API of package circuitbreaker.
+//
+// The functions are installed by package circuitbreaker when it is
+// initialised. The breaker argument is always a *circuitbreaker.CircuitBreaker
+// and the other arguments are the matching circuitbreaker types.
+package testhook
+
+var (
+	// SetState forces the breaker into a state through the normal transition path.
+	SetState func(cb any, state any)
+
+	// SetCounts overwrites the breaker's request counters.
+	SetCounts func(cb any, counts any)
+)
@@ -0,0 +1,55 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvariantViolation is wrapped by the errors reported in strict mode
+// when the breaker's internal state is inconsistent.
+var ErrInvariantViolation = errors.New("circuit breaker invariant violated")
+
+// checkInvariants validates the state machine after a change when
+// Config.Strict is set. from is the state before the change (equal to the
+// current state when only counters changed). Must be called with cb.mu held.
+func (cb *CircuitBreaker) checkInvariants(from State) {
+	if !cb.config.Strict {
+		return
+	}
+
+	var problems []string
+	if cb.failures < 0 {
+		problems = append(problems, fmt.Sprintf("failure count is negative (%d)", cb.failures))
+	}
+	if cb.successes < 0 {
+		problems = append(problems, fmt.Sprintf("success count is negative (%d)", cb.successes))
+	}
+	if cb.inFlightCost < 0 {
+		problems = append(problems, fmt.Sprintf("in-flight cost is negative (%d)", cb.inFlightCost))
+	}
+	if cb.probes < 0 {
+		problems = append(problems, fmt.Sprintf("probe count is negative (%d)", cb.probes))
+	}
+	if cb.generation > 0 && cb.lastStateChange.IsZero() {
+		problems = append(problems, "last state change is not stamped after a transition")
+	}
+	if cb.state == HalfOpen && from != HalfOpen && from != Open {
+		problems = append(problems, fmt.Sprintf("HalfOpen entered from %s instead of Open", from))
+	}
+	if cb.state == HalfOpen && cb.successes > cb.config.SuccessThreshold {
+		problems = append(problems, fmt.Sprintf("%d successes in HalfOpen exceed SuccessThreshold %d",
+			cb.successes, cb.config.SuccessThreshold))
+	}
+	if len(problems) == 0 {
+		return
+	}
+
+	err := fmt.Errorf("%w: breaker %q in state %s: %s",
+		ErrInvariantViolation, cb.config.Name, cb.state, strings.Join(problems, "; "))
+	if hook := cb.config.OnInvariantViolation; hook != nil {
+		cb.queueHook("OnInvariantViolation", func() { hook(err) })
+		return
+	}
+	panic(err)
+}
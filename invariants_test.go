@@ -0,0 +1,104 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newStrictBreaker(onViolation func(error)) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:                 "strict",
+		FailureThreshold:     3,
+		SuccessThreshold:     2,
+		Timeout:              time.Second,
+		Strict:               true,
+		OnInvariantViolation: onViolation,
+	})
+}
+
+func TestStrict_DetectsCorruptedState(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(cb *circuitbreaker.CircuitBreaker)
+		want    string
+	}{
+		{
+			name: "negative failures",
+			corrupt: func(cb *circuitbreaker.CircuitBreaker) {
+				cbt.SetCounts(cb, circuitbreaker.Counts{ConsecutiveFailures: -1})
+			},
+			want: "failure count is negative",
+		},
+		{
+			name: "negative successes",
+			corrupt: func(cb *circuitbreaker.CircuitBreaker) {
+				cbt.SetCounts(cb, circuitbreaker.Counts{Successes: -4})
+			},
+			want: "success count is negative",
+		},
+		{
+			name: "half-open not from open",
+			corrupt: func(cb *circuitbreaker.CircuitBreaker) {
+				cbt.SetState(cb, circuitbreaker.HalfOpen)
+			},
+			want: "HalfOpen entered from Closed",
+		},
+		{
+			name: "too many half-open successes",
+			corrupt: func(cb *circuitbreaker.CircuitBreaker) {
+				cbt.AdvanceToHalfOpen(cb)
+				cbt.SetCounts(cb, circuitbreaker.Counts{Successes: 3})
+			},
+			want: "exceed SuccessThreshold",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []error
+			cb := newStrictBreaker(func(err error) { got = append(got, err) })
+
+			tt.corrupt(cb)
+
+			if len(got) != 1 {
+				t.Fatalf("expected 1 violation, got %d: %v", len(got), got)
+			}
+			if !errors.Is(got[0], circuitbreaker.ErrInvariantViolation) {
+				t.Errorf("expected ErrInvariantViolation, got %v", got[0])
+			}
+			if !strings.Contains(got[0].Error(), tt.want) {
+				t.Errorf("expected violation mentioning %q, got %v", tt.want, got[0])
+			}
+		})
+	}
+}
+
+func TestStrict_PanicsWithoutHook(t *testing.T) {
+	cb := newStrictBreaker(nil)
+
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || !errors.Is(err, circuitbreaker.ErrInvariantViolation) {
+			t.Fatalf("expected panic with ErrInvariantViolation, got %v", r)
+		}
+		// The breaker must still be usable after the panic.
+		cb.Reset()
+		if _, err := cb.Execute(func() (any, error) { return nil, nil }); err != nil {
+			t.Errorf("expected breaker to work after panic, got %v", err)
+		}
+	}()
+
+	cbt.SetCounts(cb, circuitbreaker.Counts{ConsecutiveFailures: -1})
+}
+
+func TestStrict_OffIgnoresViolations(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, SuccessThreshold: 2})
+	cbt.SetCounts(cb, circuitbreaker.Counts{ConsecutiveFailures: -1})
+	cbt.SetState(cb, circuitbreaker.HalfOpen)
+}
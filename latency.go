@@ -0,0 +1,229 @@
+package circuitbreaker
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// latencyTrip opens the circuit when a percentile of recent successful
+// call latencies stays above Config.LatencyThreshold. It keeps the latencies of the last
+// LatencyWindowSize calls in a ring buffer and evaluates the percentile
+// after every call once the window is full; LatencySustain consecutive
+// evaluations over the threshold trip the circuit.
+type latencyTrip struct {
+	threshold  time.Duration
+	percentile float64
+	sustain    int
+
+	window []time.Duration
+	next   int
+	full   bool
+	// Consecutive evaluations that exceeded the threshold.
+	breaches int
+}
+
+// newLatencyTrip returns nil when latency tripping is not configured.
+func newLatencyTrip(cfg Config) *latencyTrip {
+	if cfg.LatencyThreshold <= 0 {
+		return nil
+	}
+	return &latencyTrip{
+		threshold:  cfg.LatencyThreshold,
+		percentile: cfg.LatencyPercentile,
+		sustain:    cfg.LatencySustain,
+		window:     make([]time.Duration, cfg.LatencyWindowSize),
+	}
+}
+
+// record adds a call latency and reports whether the circuit should trip.
+func (l *latencyTrip) record(d time.Duration) bool {
+	l.window[l.next] = d
+	l.next = (l.next + 1) % len(l.window)
+	if l.next == 0 {
+		l.full = true
+	}
+	if !l.full {
+		return false
+	}
+
+	if l.current() > l.threshold {
+		l.breaches++
+	} else {
+		l.breaches = 0
+	}
+	return l.breaches >= l.sustain
+}
+
+// tooSlow reports whether a single call, such as a half-open probe, was
+// slower than the threshold.
+func (l *latencyTrip) tooSlow(d time.Duration) bool {
+	return d > l.threshold
+}
+
+// current returns the configured percentile of the window using the
+// nearest-rank method.
+func (l *latencyTrip) current() time.Duration {
+	samples := l.window
+	if !l.full {
+		samples = l.window[:l.next]
+	}
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(l.percentile*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// reset empties the window.
+func (l *latencyTrip) reset() {
+	l.next = 0
+	l.full = false
+	l.breaches = 0
+}
+
+// DefaultLatencyBuckets are the bucket bounds of the latency histograms
+// when Config.LatencyBuckets is not set.
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+	5 * time.Second, 10 * time.Second,
+}
+
+// LatencyHistogram is the distribution of the latencies of every call
+// with one outcome since the breaker was created.
+type LatencyHistogram struct {
+	// Bounds are the upper bounds of the buckets, in increasing order.
+	Bounds []time.Duration
+	// Counts[i] is the number of calls that took longer than Bounds[i-1]
+	// and at most Bounds[i]; the extra last entry counts the calls slower
+	// than every bound.
+	Counts []uint64
+	// Count and Sum are the number of calls and their total latency.
+	Count uint64
+	Sum   time.Duration
+	// Min and Max are the latencies of the fastest and slowest calls, and
+	// zero while there are none.
+	Min, Max time.Duration
+}
+
+func newLatencyHistogram(bounds []time.Duration) LatencyHistogram {
+	return LatencyHistogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+// observe adds a call latency.
+func (h *LatencyHistogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(h.Bounds, d)
+	h.Counts[i]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	h.Max = max(h.Max, d)
+	h.Count++
+	h.Sum += d
+}
+
+// clone returns a copy that does not share the counts.
+func (h LatencyHistogram) clone() LatencyHistogram {
+	h.Counts = slices.Clone(h.Counts)
+	return h
+}
+
+// Quantile estimates the q quantile, between 0 and 1, by interpolating
+// within the bucket it falls in. Calls slower than every bound are taken
+// to have the last bound's latency. An empty histogram returns zero.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := q * float64(h.Count)
+	var seen float64
+	for i, n := range h.Counts {
+		if n == 0 || seen+float64(n) < rank {
+			seen += float64(n)
+			continue
+		}
+		if i == len(h.Bounds) {
+			break
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		}
+		frac := (rank - seen) / float64(n)
+		return lower + time.Duration(frac*float64(h.Bounds[i]-lower))
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// LatencyStats summarizes a LatencyHistogram. The quantiles are the
+// histogram's estimates, kept between Min and Max, so they are only as
+// fine as the bucket bounds.
+type LatencyStats struct {
+	Count uint64        `json:"count"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+}
+
+// Stats returns the summary of h. An empty histogram returns zero stats.
+func (h LatencyHistogram) Stats() LatencyStats {
+	if h.Count == 0 {
+		return LatencyStats{}
+	}
+	quantile := func(q float64) time.Duration {
+		return min(max(h.Quantile(q), h.Min), h.Max)
+	}
+	return LatencyStats{
+		Count: h.Count,
+		Min:   h.Min,
+		Max:   h.Max,
+		Mean:  h.Sum / time.Duration(h.Count),
+		P50:   quantile(0.5),
+		P95:   quantile(0.95),
+		P99:   quantile(0.99),
+	}
+}
+
+// latencies splits the latencies of completed calls by outcome.
+type latencies struct {
+	success, failure LatencyHistogram
+}
+
+// Latencies returns the latency distributions of the calls that succeeded
+// and of those that failed, so that fast failures and slow successes (or
+// the reverse) stand out. Rejected calls are in neither. A nil breaker
+// returns empty histograms.
+func (cb *CircuitBreaker) Latencies() (success, failure LatencyHistogram) {
+	if cb == nil {
+		return LatencyHistogram{}, LatencyHistogram{}
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.latencies.success.clone(), cb.latencies.failure.clone()
+}
+
+// LatencyStats returns the Stats of the Latencies histograms: minimum,
+// maximum, mean and the 50th, 95th and 99th percentiles of the calls that
+// succeeded and of those that failed. Latency is measured from admission,
+// so time spent waiting to be admitted is left out, to completion. Memory
+// stays the same however many calls are made. A nil breaker returns zero
+// stats.
+func (cb *CircuitBreaker) LatencyStats() (success, failure LatencyStats) {
+	if cb == nil {
+		return LatencyStats{}, LatencyStats{}
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.latencies.success.Stats(), cb.latencies.failure.Stats()
+}
@@ -0,0 +1,294 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// slowCall returns a request that succeeds after d has passed on clock.
+func slowCall(clock *cbt.FakeClock, d time.Duration) func() (any, error) {
+	return func() (any, error) {
+		clock.Advance(d)
+		return "ok", nil
+	}
+}
+
+func newLatencyBreaker(clock *cbt.FakeClock, events *[]circuitbreaker.Event) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:              "latency",
+		FailureThreshold:  3,
+		SuccessThreshold:  2,
+		Timeout:           time.Second,
+		LatencyThreshold:  100 * time.Millisecond,
+		LatencyPercentile: 0.9,
+		LatencySustain:    3,
+		LatencyWindowSize: 10,
+		Clock:             clock,
+		Strict:            true,
+		OnEvent:           func(ev circuitbreaker.Event) { *events = append(*events, ev) },
+	})
+}
+
+func TestLatency_TripsWhenPercentileStaysHigh(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newLatencyBreaker(clock, &events)
+
+	// Fill the window with fast calls.
+	for i := 0; i < 10; i++ {
+		cb.Execute(slowCall(clock, 10*time.Millisecond))
+	}
+
+	// Ramp up: the p90 of a 10-call window only exceeds the threshold once
+	// two calls are slow, and then it must stay there for three evaluations.
+	ramp := []time.Duration{50, 90, 150, 200, 250, 300}
+	for i, ms := range ramp {
+		if cb.State() != circuitbreaker.Closed {
+			t.Fatalf("tripped early before ramp step %d", i)
+		}
+		cb.Execute(slowCall(clock, ms*time.Millisecond))
+	}
+	// p90 exceeded on the 200ms, 250ms and 300ms calls: three evaluations.
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected Open after sustained slow calls, got %v", cb.State())
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %+v", events)
+	}
+	ev := events[0]
+	if ev.Type != circuitbreaker.EventStateChange || ev.To != circuitbreaker.Open || ev.Reason != circuitbreaker.ReasonLatency {
+		t.Errorf("expected latency-based open event, got %+v", ev)
+	}
+	if ev.Name != "latency" || !ev.Time.Equal(clock.Now()) {
+		t.Errorf("expected event stamped with name and clock time, got %+v", ev)
+	}
+}
+
+func TestLatency_BriefSpikeDoesNotTrip(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newLatencyBreaker(clock, &events)
+
+	for i := 0; i < 10; i++ {
+		cb.Execute(slowCall(clock, 10*time.Millisecond))
+	}
+	// A single outlier per window never lifts the p90 of ten calls.
+	for i := 0; i < 3; i++ {
+		cb.Execute(slowCall(clock, 500*time.Millisecond))
+		for j := 0; j < 9; j++ {
+			cb.Execute(slowCall(clock, 10*time.Millisecond))
+		}
+	}
+	for i := 0; i < 10; i++ {
+		cb.Execute(slowCall(clock, 10*time.Millisecond))
+	}
+
+	if cb.State() != circuitbreaker.Closed || len(events) != 0 {
+		t.Errorf("expected to stay Closed, got %v with events %+v", cb.State(), events)
+	}
+}
+
+// slowFailure returns a request that fails after d has passed on clock.
+func slowFailure(clock *cbt.FakeClock, d time.Duration) func() (any, error) {
+	return func() (any, error) {
+		clock.Advance(d)
+		return nil, errSimulated
+	}
+}
+
+func TestLatency_SplitByOutcome(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1000, Clock: clock, Strict: true})
+
+	// successes are slow, around 400ms; failures are fast connection
+	// refusals, around 2ms.
+	for i := 0; i < 100; i++ {
+		cb.Execute(slowCall(clock, time.Duration(300+2*i)*time.Millisecond))
+		cb.Execute(slowFailure(clock, time.Duration(1+i%3)*time.Millisecond))
+	}
+	cb.Execute(func() (any, error) { return nil, circuitbreaker.ErrSkipRecording })
+
+	success, failure := cb.Latencies()
+	if success.Count != 100 || failure.Count != 100 {
+		t.Fatalf("expected 100 calls of each outcome, got %d and %d", success.Count, failure.Count)
+	}
+	if p50 := success.Quantile(0.5); p50 < 250*time.Millisecond || p50 > 500*time.Millisecond {
+		t.Errorf("success p50 = %v, want within the 250ms-500ms bucket", p50)
+	}
+	if p99 := failure.Quantile(0.99); p99 > 5*time.Millisecond {
+		t.Errorf("failure p99 = %v, want within the first 5ms bucket", p99)
+	}
+	if want := 100 * (300 + 99) * time.Millisecond; success.Sum != want {
+		t.Errorf("success sum = %v, want %v", success.Sum, want)
+	}
+
+	st := cb.Status()
+	if st.SuccessLatency.Count != 100 || st.FailureLatency.Count != 100 {
+		t.Errorf("expected Status to carry both distributions, got %+v / %+v", st.SuccessLatency, st.FailureLatency)
+	}
+
+	open := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Clock: clock, Strict: true})
+	open.Execute(failFn)
+	if _, err := open.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatal(err)
+	}
+	if success, failure := open.Latencies(); success.Count != 0 || failure.Count != 1 {
+		t.Errorf("expected rejected calls to be left out, got %d and %d", success.Count, failure.Count)
+	}
+}
+
+func TestLatency_OnlySuccessesTrip(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newLatencyBreaker(clock, &events)
+
+	// slow failures, interleaved with fast successes, do not trip on
+	// latency.
+	for i := 0; i < 30; i++ {
+		cb.Execute(slowCall(clock, 10*time.Millisecond))
+		cb.Execute(slowFailure(clock, time.Second))
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected slow failures not to trip on latency, got %v", cb.State())
+	}
+
+	// fast failures do not hide successes getting slow.
+	for i := 0; i < 10; i++ {
+		cb.Execute(slowCall(clock, 300*time.Millisecond))
+		cb.Execute(slowFailure(clock, time.Millisecond))
+		cb.Execute(slowCall(clock, 300*time.Millisecond))
+	}
+	if cb.State() != circuitbreaker.Open || events[len(events)-1].Reason != circuitbreaker.ReasonLatency {
+		t.Errorf("expected slow successes to trip on latency, got %v", cb.State())
+	}
+}
+
+func TestLatencyHistogram_Quantile(t *testing.T) {
+	h := circuitbreaker.LatencyHistogram{
+		Bounds: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		Counts: []uint64{0, 4, 0},
+		Count:  4,
+	}
+	if got := h.Quantile(0.5); got != 150*time.Millisecond {
+		t.Errorf("p50 = %v, want 150ms", got)
+	}
+	if got := (circuitbreaker.LatencyHistogram{}).Quantile(0.5); got != 0 {
+		t.Errorf("empty p50 = %v, want 0", got)
+	}
+}
+
+func TestLatency_SlowProbeReopens(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newLatencyBreaker(clock, &events)
+	cbt.AdvanceToHalfOpen(cb)
+	events = nil
+
+	cb.Execute(slowCall(clock, 150*time.Millisecond))
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected a slow probe to reopen the circuit, got %v", cb.State())
+	}
+	if len(events) != 1 || events[0].Reason != circuitbreaker.ReasonLatency {
+		t.Errorf("expected latency reason, got %+v", events)
+	}
+
+	// Fast probes recover as usual.
+	clock.Advance(time.Second)
+	cb.Execute(slowCall(clock, 10*time.Millisecond))
+	cb.Execute(slowCall(clock, 10*time.Millisecond))
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected fast probes to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestEvents_ReasonsForOrganicCycle(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Second,
+		Clock:            clock,
+		OnEvent:          func(ev circuitbreaker.Event) { events = append(events, ev) },
+	})
+
+	cb.Execute(failFn)
+	clock.Advance(time.Second)
+	cb.Execute(failFn)
+	clock.Advance(time.Second)
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	cb.Reset()
+
+	want := []string{
+		circuitbreaker.ReasonFailures,
+		circuitbreaker.ReasonTimeout,
+		circuitbreaker.ReasonProbeFailed,
+		circuitbreaker.ReasonTimeout,
+		circuitbreaker.ReasonRecovered,
+		circuitbreaker.ReasonFailures,
+		circuitbreaker.ReasonReset,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, ev := range events {
+		if ev.Reason != want[i] {
+			t.Errorf("event %d: expected reason %q, got %q (%v->%v)", i, want[i], ev.Reason, ev.From, ev.To)
+		}
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var buckets []time.Duration
+	for d := 10 * time.Millisecond; d <= time.Second; d += 10 * time.Millisecond {
+		buckets = append(buckets, d)
+	}
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1000, LatencyBuckets: buckets, Clock: clock, Strict: true})
+
+	// successes take 1ms to 1s, evenly spread; failures all take 5ms.
+	for i := 1; i <= 1000; i++ {
+		cb.Execute(slowCall(clock, time.Duration(i)*time.Millisecond))
+	}
+	for range 10 {
+		cb.Execute(slowFailure(clock, 5*time.Millisecond))
+	}
+
+	success, failure := cb.LatencyStats()
+	if success.Count != 1000 || success.Min != time.Millisecond || success.Max != time.Second {
+		t.Fatalf("expected 1000 calls from 1ms to 1s, got %+v", success)
+	}
+	if want := 500500 * time.Microsecond; success.Mean != want {
+		t.Errorf("mean = %v, want %v", success.Mean, want)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"p50", success.P50, 500 * time.Millisecond},
+		{"p95", success.P95, 950 * time.Millisecond},
+		{"p99", success.P99, 990 * time.Millisecond},
+	} {
+		if diff := c.got - c.want; diff < -10*time.Millisecond || diff > 10*time.Millisecond {
+			t.Errorf("%s = %v, want %v within a 10ms bucket", c.name, c.got, c.want)
+		}
+	}
+	// one bucket holds every failure; the estimates stay within its calls.
+	if failure.Count != 10 || failure.Min != 5*time.Millisecond || failure.P99 != 5*time.Millisecond {
+		t.Errorf("expected every failure at 5ms, got %+v", failure)
+	}
+
+	if snap := cb.Snapshot(); snap.SuccessLatency != success || snap.FailureLatency != failure {
+		t.Errorf("expected Snapshot to carry the stats, got %+v / %+v", snap.SuccessLatency, snap.FailureLatency)
+	}
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if s, f := nilBreaker.LatencyStats(); s != (circuitbreaker.LatencyStats{}) || f != (circuitbreaker.LatencyStats{}) {
+		t.Errorf("expected zero stats from a nil breaker, got %+v / %+v", s, f)
+	}
+}
@@ -0,0 +1,55 @@
+package circuitbreaker
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logEvent queues ev for Config.Logger once cb.mu is released. Must be
+// called with cb.mu held and a Logger set.
+func (cb *CircuitBreaker) logEvent(ev Event) {
+	logger := cb.config.Logger
+	level := slog.LevelInfo
+	attrs := []slog.Attr{slog.String("name", ev.Name)}
+	msg := "circuit breaker " + ev.Type.String()
+	switch ev.Type {
+	case EventStateChange:
+		msg = "circuit breaker state change"
+		if ev.To == Open {
+			level = slog.LevelWarn
+		}
+		attrs = append(attrs, slog.String("from", ev.From.String()), slog.String("to", ev.To.String()), slog.String("reason", ev.Reason))
+	case EventStuckOpen, EventRetryBudgetExhausted, EventPressureStart, EventEjected, EventClockJump, EventKeyOverflow:
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("state", ev.To.String()))
+	default:
+		attrs = append(attrs, slog.String("state", ev.To.String()))
+	}
+	if ev.Source != "" {
+		attrs = append(attrs, slog.String("source", ev.Source))
+	}
+	if ev.Key != "" {
+		attrs = append(attrs, slog.String("key", ev.Key))
+	}
+	if ev.FailureRate != 0 || ev.BaselineRate != 0 {
+		attrs = append(attrs, slog.Float64("failure_rate", ev.FailureRate), slog.Float64("baseline_rate", ev.BaselineRate))
+	}
+	if ev.Jump != 0 {
+		attrs = append(attrs, slog.Duration("jump", ev.Jump))
+	}
+	cb.queueHook("Logger", func() { logger.LogAttrs(context.Background(), level, msg, attrs...) })
+}
+
+// logRejection queues a debug record of a request turned away with err.
+// Rejections can come thick and fast, so nothing is built unless the
+// logger wants debug records. Must be called with cb.mu held.
+func (cb *CircuitBreaker) logRejection(err error) {
+	logger := cb.config.Logger
+	if logger == nil || !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("name", cb.config.Name), slog.String("state", cb.state.String()), slog.String("error", err.Error())}
+	cb.queueHook("Logger", func() {
+		logger.LogAttrs(context.Background(), slog.LevelDebug, "circuit breaker rejected request", attrs...)
+	})
+}
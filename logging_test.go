@@ -0,0 +1,110 @@
+package circuitbreaker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// logRecords decodes the JSON records written to buf.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestLogger_TripAndRejection(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "inventory", FailureThreshold: 2, Logger: logger, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	if buf.Len() != 0 {
+		t.Fatalf("expected ordinary calls not to be logged, got %s", buf.String())
+	}
+	cb.Execute(failFn)
+	cb.Execute(successFn)
+
+	records := logRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("expected a trip and a rejection, got %v", records)
+	}
+	trip := records[0]
+	want := map[string]any{
+		"level":  "WARN",
+		"msg":    "circuit breaker state change",
+		"name":   "inventory",
+		"from":   "Closed",
+		"to":     "Open",
+		"reason": circuitbreaker.ReasonFailures,
+	}
+	for k, v := range want {
+		if trip[k] != v {
+			t.Errorf("trip record: expected %s=%v, got %v", k, v, trip[k])
+		}
+	}
+	rejected := records[1]
+	if rejected["level"] != "DEBUG" || rejected["name"] != "inventory" || rejected["state"] != "Open" || rejected["error"] != `circuit breaker "inventory" is open, retry after 10s` {
+		t.Errorf("unexpected rejection record %v", rejected)
+	}
+}
+
+func TestLogger_RecoveryIsInfo(t *testing.T) {
+	var buf bytes.Buffer
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, SuccessThreshold: 1, Logger: slog.New(slog.NewJSONHandler(&buf, nil)), Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	cb.Execute(failFn)
+	cbt.AdvanceToHalfOpen(cb)
+	cb.Execute(successFn)
+
+	var levels []any
+	for _, r := range logRecords(t, &buf) {
+		levels = append(levels, r["level"].(string)+" "+r["to"].(string))
+	}
+	want := []any{"WARN Open", "INFO HalfOpen", "INFO Closed"}
+	if len(levels) != len(want) {
+		t.Fatalf("expected %v, got %v", want, levels)
+	}
+	for i := range want {
+		if levels[i] != want[i] {
+			t.Errorf("record %d: expected %v, got %v", i, want[i], levels[i])
+		}
+	}
+}
+
+func TestLogger_RejectionsCostNothingUnlessLogged(t *testing.T) {
+	rejectionAllocs := func(logger *slog.Logger) float64 {
+		cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Logger: logger, Clock: cbt.NewFakeClock(cbt.Epoch)})
+		cb.Execute(failFn)
+		return testing.AllocsPerRun(100, func() {
+			cb.Execute(successFn)
+		})
+	}
+	var buf bytes.Buffer
+	without := rejectionAllocs(nil)
+	quiet := rejectionAllocs(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	if quiet != without {
+		t.Errorf("expected a logger above Debug to add no allocations to rejections, got %v against %v", quiet, without)
+	}
+	if strings.Contains(buf.String(), "rejected") {
+		t.Errorf("expected no rejection records above Debug, got %s", buf.String())
+	}
+	if debug := rejectionAllocs(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))); debug <= without {
+		t.Errorf("expected logged rejections to cost something, got %v against %v", debug, without)
+	}
+}
@@ -0,0 +1,152 @@
+package circuitbreaker
+
+import "time"
+
+// Recurrence says how often a maintenance Window repeats.
+type Recurrence int
+
+const (
+	// Once: the window occurs a single time.
+	Once Recurrence = iota
+	// Daily: the window repeats every day at the same local time.
+	Daily
+	// Weekly: the window repeats every week on the same weekday and local time.
+	Weekly
+)
+
+// Window is a period of planned downtime during which the breaker is held
+// open. Recurring windows repeat at the same wall-clock time in Start's
+// location, so a window set in a zone with daylight saving time keeps its
+// local time across the change.
+type Window struct {
+	// Start is the beginning of the first occurrence. Its Location is the
+	// time zone recurrences are computed in.
+	Start time.Time
+	// Duration is how long each occurrence lasts.
+	Duration time.Duration
+	// Recurrence says how often the window repeats.
+	Recurrence Recurrence
+}
+
+// occurrence returns the start of the k-th occurrence.
+func (w Window) occurrence(k int) time.Time {
+	switch w.Recurrence {
+	case Daily:
+		return w.Start.AddDate(0, 0, k)
+	case Weekly:
+		return w.Start.AddDate(0, 0, 7*k)
+	default:
+		return w.Start
+	}
+}
+
+// latest returns the index of the last occurrence starting at or before t,
+// or -1 if the window has not started yet.
+func (w Window) latest(t time.Time) int {
+	if t.Before(w.Start) {
+		return -1
+	}
+	var period time.Duration
+	switch w.Recurrence {
+	case Daily:
+		period = 24 * time.Hour
+	case Weekly:
+		period = 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+	// Estimate from the nominal period, then correct for days that are
+	// longer or shorter than 24 hours.
+	k := int(t.Sub(w.Start) / period)
+	for w.occurrence(k+1).Compare(t) <= 0 {
+		k++
+	}
+	for k > 0 && w.occurrence(k).After(t) {
+		k--
+	}
+	return k
+}
+
+// contains reports whether t falls inside an occurrence of the window.
+func (w Window) contains(t time.Time) bool {
+	k := w.latest(t)
+	return k >= 0 && t.Before(w.occurrence(k).Add(w.Duration))
+}
+
+// nextBoundary returns the first start or end of an occurrence after t.
+func (w Window) nextBoundary(t time.Time) (time.Time, bool) {
+	k := w.latest(t)
+	if k >= 0 {
+		if end := w.occurrence(k).Add(w.Duration); end.After(t) {
+			return end, true
+		}
+	}
+	if w.Recurrence == Once {
+		if k < 0 {
+			return w.Start, true
+		}
+		return time.Time{}, false
+	}
+	return w.occurrence(k + 1), true
+}
+
+// startMaintenance evaluates the maintenance windows for the first time.
+// Called once from lazyInit.
+func (cb *CircuitBreaker) startMaintenance() {
+	if len(cb.config.MaintenanceWindows) == 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.evaluateMaintenance()
+}
+
+// evaluateMaintenance enters or leaves maintenance according to the
+// configured windows and arms a timer for the next window boundary. While
+// in maintenance the breaker is held Open and will not move to HalfOpen;
+// leaving maintenance closes the circuit, since failures recorded before
+// planned downtime say nothing about the service afterwards. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) evaluateMaintenance() {
+	now := cb.clock.Now()
+	active := false
+	var next time.Time
+	for _, w := range cb.config.MaintenanceWindows {
+		if w.contains(now) {
+			active = true
+		}
+		if b, ok := w.nextBoundary(now); ok && (next.IsZero() || b.Before(next)) {
+			next = b
+		}
+	}
+
+	switch {
+	case active && !cb.maintenance:
+		cb.maintenance = true
+		cb.emit(Event{Type: EventMaintenanceStart, Time: now, From: cb.state, To: Open, Reason: ReasonMaintenance})
+		cb.setState(Open, ReasonMaintenance)
+	case !active && cb.maintenance:
+		cb.maintenance = false
+		to := Closed
+		if cb.ejected {
+			// an outlier ejection outlasting the window keeps it open.
+			to = Open
+		}
+		cb.emit(Event{Type: EventMaintenanceEnd, Time: now, From: cb.state, To: to, Reason: ReasonMaintenance})
+		cb.setState(to, ReasonMaintenance)
+	}
+
+	if !next.IsZero() {
+		cb.maintenanceTimer = cb.clock.AfterFunc(next.Sub(now), cb.onMaintenanceTimer)
+	}
+}
+
+// onMaintenanceTimer runs when a window boundary is reached.
+func (cb *CircuitBreaker) onMaintenanceTimer() {
+	cb.mu.Lock()
+	defer cb.unlock()
+	if cb.closed {
+		return
+	}
+	cb.evaluateMaintenance()
+}
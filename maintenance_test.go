@@ -0,0 +1,189 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newMaintenanceBreaker(clock *cbt.FakeClock, events *[]circuitbreaker.Event, windows ...circuitbreaker.Window) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:               "billing",
+		Timeout:            time.Minute,
+		Clock:              clock,
+		Strict:             true,
+		MaintenanceWindows: windows,
+		OnEvent:            func(ev circuitbreaker.Event) { *events = append(*events, ev) },
+	})
+}
+
+func eventTypes(events []circuitbreaker.Event) []string {
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type.String()+"/"+ev.To.String())
+	}
+	return types
+}
+
+func TestMaintenance_CrossingIntoAndOutOfWindow(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newMaintenanceBreaker(clock, &events, circuitbreaker.Window{
+		Start:    cbt.Epoch.Add(time.Hour),
+		Duration: 30 * time.Minute,
+	})
+
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Fatalf("expected traffic before the window, got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if cb.State() != circuitbreaker.Open || !cb.Status().InMaintenance {
+		t.Fatalf("expected Open in maintenance, got %+v", cb.Status())
+	}
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected rejection during maintenance, got %v", err)
+	}
+
+	// The open timeout does not lead to HalfOpen during maintenance.
+	clock.Advance(29 * time.Minute)
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected rejection after the open timeout, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if cb.State() != circuitbreaker.Closed || cb.Status().InMaintenance {
+		t.Fatalf("expected Closed after the window, got %+v", cb.Status())
+	}
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Errorf("expected traffic after the window, got %v", err)
+	}
+
+	want := []string{"MaintenanceStart/Open", "StateChange/Open", "MaintenanceEnd/Closed", "StateChange/Closed"}
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], got[i])
+		}
+		if events[i].Reason != circuitbreaker.ReasonMaintenance {
+			t.Errorf("event %d: expected reason maintenance, got %q", i, events[i].Reason)
+		}
+	}
+	if !events[0].Time.Equal(cbt.Epoch.Add(time.Hour)) || !events[2].Time.Equal(cbt.Epoch.Add(90*time.Minute)) {
+		t.Errorf("expected events at the window boundaries, got %v and %v", events[0].Time, events[2].Time)
+	}
+
+	// A one-off window leaves nothing scheduled once it is over.
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected no pending timers, got %d", n)
+	}
+}
+
+func TestMaintenance_OverlappingWindows(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newMaintenanceBreaker(clock, &events,
+		circuitbreaker.Window{Start: cbt.Epoch, Duration: time.Hour},
+		circuitbreaker.Window{Start: cbt.Epoch.Add(30 * time.Minute), Duration: 90 * time.Minute},
+	)
+
+	if !cb.Status().InMaintenance {
+		t.Fatal("expected to start inside the first window")
+	}
+	clock.Advance(time.Hour + time.Minute)
+	if !cb.Status().InMaintenance {
+		t.Fatal("expected the second window to keep maintenance going")
+	}
+	clock.Advance(time.Hour)
+	if cb.Status().InMaintenance || cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected maintenance over, got %+v", cb.Status())
+	}
+
+	starts, ends := 0, 0
+	for _, ev := range events {
+		switch ev.Type {
+		case circuitbreaker.EventMaintenanceStart:
+			starts++
+		case circuitbreaker.EventMaintenanceEnd:
+			ends++
+			if !ev.Time.Equal(cbt.Epoch.Add(2 * time.Hour)) {
+				t.Errorf("expected maintenance to end at the end of the later window, got %v", ev.Time)
+			}
+		}
+	}
+	if starts != 1 || ends != 1 {
+		t.Errorf("expected one start and one end event, got %d and %d", starts, ends)
+	}
+}
+
+func TestMaintenance_WeeklyInTimeZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sundays 04:00-04:30 New York time. US daylight saving time starts on
+	// 10 March 2024, so the second occurrence is an hour earlier in UTC.
+	first := time.Date(2024, time.March, 3, 4, 0, 0, 0, ny)
+	clock := cbt.NewFakeClock(first.Add(-time.Hour))
+	var events []circuitbreaker.Event
+	cb := newMaintenanceBreaker(clock, &events, circuitbreaker.Window{
+		Start:      first,
+		Duration:   30 * time.Minute,
+		Recurrence: circuitbreaker.Weekly,
+	})
+
+	clock.Set(first.Add(10 * time.Minute))
+	if !cb.Status().InMaintenance {
+		t.Fatal("expected maintenance during the first occurrence")
+	}
+	clock.Set(first.Add(time.Hour))
+	if cb.Status().InMaintenance {
+		t.Fatal("expected maintenance over after the first occurrence")
+	}
+
+	second := time.Date(2024, time.March, 10, 4, 0, 0, 0, ny)
+	if second.Sub(first) != 7*24*time.Hour-time.Hour {
+		t.Fatalf("test assumption broken: %v between occurrences", second.Sub(first))
+	}
+	clock.Set(second.Add(-time.Minute))
+	if cb.Status().InMaintenance {
+		t.Fatal("expected no maintenance just before the second occurrence")
+	}
+	clock.Set(second.Add(time.Minute))
+	if !cb.Status().InMaintenance {
+		t.Fatal("expected maintenance at 04:00 local time after the DST change")
+	}
+	clock.Set(second.Add(31 * time.Minute))
+	if cb.Status().InMaintenance {
+		t.Fatal("expected maintenance over after the second occurrence")
+	}
+}
+
+func TestMaintenance_CloseStopsTimer(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newMaintenanceBreaker(clock, &events, circuitbreaker.Window{
+		Start:      cbt.Epoch.Add(time.Hour),
+		Duration:   time.Minute,
+		Recurrence: circuitbreaker.Daily,
+	})
+	if clock.PendingTimers() != 1 {
+		t.Fatalf("expected one timer for the next boundary, got %d", clock.PendingTimers())
+	}
+
+	cb.Close()
+	if clock.PendingTimers() != 0 {
+		t.Errorf("expected Close to stop the timer, got %d pending", clock.PendingTimers())
+	}
+	clock.Advance(2 * time.Hour)
+	if len(events) != 0 {
+		t.Errorf("expected no events after Close, got %v", eventTypes(events))
+	}
+}
@@ -0,0 +1,112 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestMaxOpenDuration_ProbesAtTheCeilingCadence(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var probes []time.Time
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Hour,
+		MaxOpenDuration:  5 * time.Minute,
+		Clock:            clock,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.Type == circuitbreaker.EventStateChange && ev.To == circuitbreaker.HalfOpen {
+				if ev.Reason != circuitbreaker.ReasonMaxOpenDuration {
+					t.Errorf("expected the ceiling as the reason, got %q", ev.Reason)
+				}
+				probes = append(probes, ev.Time)
+			}
+		},
+	})
+
+	cb.Execute(failFn)
+	// the dependency stays down; a caller knocks every second for 30 minutes.
+	for i := 0; i < 30*60; i++ {
+		clock.Advance(time.Second)
+		cb.Execute(failFn)
+	}
+	if len(probes) != 6 {
+		t.Fatalf("expected a probe every 5 minutes, got %d: %v", len(probes), probes)
+	}
+	for i, p := range probes {
+		if want := cbt.Epoch.Add(time.Duration(i+1) * 5 * time.Minute); !p.Equal(want) {
+			t.Errorf("probe %d at %v, want %v", i, p.Sub(cbt.Epoch), want.Sub(cbt.Epoch))
+		}
+	}
+}
+
+func TestMaxOpenDuration_AboveTimeoutHasNoEffect(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var reasons []string
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Minute,
+		MaxOpenDuration:  time.Hour,
+		Clock:            clock,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.Type == circuitbreaker.EventStateChange && ev.To == circuitbreaker.HalfOpen {
+				reasons = append(reasons, ev.Reason)
+			}
+		},
+	})
+
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+	cb.Execute(successFn)
+	if len(reasons) != 1 || reasons[0] != circuitbreaker.ReasonTimeout {
+		t.Errorf("expected the usual timeout, got %v", reasons)
+	}
+}
+
+func TestValidate_RejectsNegativeDurations(t *testing.T) {
+	for _, cfg := range []circuitbreaker.Config{
+		{Timeout: -time.Second},
+		{MaxOpenDuration: -time.Second},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+	if err := (circuitbreaker.Config{Timeout: time.Minute, MaxOpenDuration: time.Second}).Validate(); err != nil {
+		t.Errorf("a ceiling below Timeout is allowed, got %v", err)
+	}
+}
+
+func TestValidate_RejectsMaxOpenDurationAtOrAboveTimeout(t *testing.T) {
+	for _, d := range []time.Duration{time.Minute, time.Hour} {
+		cfg := circuitbreaker.Config{Timeout: time.Minute, MaxOpenDuration: d}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected a MaxOpenDuration of %v to be rejected with a one-minute Timeout", d)
+		}
+	}
+	// jitter can stretch the timeout past Timeout, where the ceiling applies.
+	cfg := circuitbreaker.Config{Timeout: time.Minute, TimeoutJitter: 0.5, MaxOpenDuration: 80 * time.Second}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("a ceiling below the longest jittered timeout is allowed, got %v", err)
+	}
+}
+
+func TestValidate_RejectsMaxOpenDurationAtOrAboveBackoffMaxTimeout(t *testing.T) {
+	backoff := circuitbreaker.OpenBackoff{Initial: time.Second, Multiplier: 2, MaxTimeout: 10 * time.Minute}
+	for _, d := range []time.Duration{10 * time.Minute, time.Hour} {
+		cfg := circuitbreaker.Config{Timeout: time.Second, OpenTimeoutBackoff: backoff, MaxOpenDuration: d}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected a MaxOpenDuration of %v to be rejected with a ten-minute MaxTimeout", d)
+		}
+	}
+	// with backoff on, the ceiling may sit above Timeout.
+	cfg := circuitbreaker.Config{Timeout: time.Second, OpenTimeoutBackoff: backoff, MaxOpenDuration: 5 * time.Minute}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("a ceiling below MaxTimeout is allowed, got %v", err)
+	}
+}
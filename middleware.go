@@ -0,0 +1,231 @@
+package circuitbreaker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MiddlewareOption configures optional Middleware behaviour.
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	stateHeader bool
+}
+
+// WithStateHeader makes Middleware set an X-Circuit-State header on every
+// response, holding the state of the circuit when the request was admitted
+// or rejected, for debugging.
+func WithStateHeader() MiddlewareOption {
+	return func(m *middleware) {
+		m.stateHeader = true
+	}
+}
+
+// Middleware returns HTTP server middleware that guards a handler with cb.
+// A request the breaker rejects is answered with 503 Service Unavailable
+// and a Retry-After header, rounded up to whole seconds, of the
+// OpenError's RetryAfter, without calling the handler.
+// Otherwise the handler runs, and responses with a 5xx status count as
+// failures. A handler that never calls WriteHeader answers 200 as usual
+// and counts as a success, as does one that hijacks the connection, since
+// its outcome is no longer visible; a panicking handler counts as a
+// failure and the panic goes on to net/http. The handler gets the call's
+// context, so with Config.CallTimeout its request's context is cancelled
+// once the timeout passes and the call counts as a failure. Its response
+// stands if it had started one by then; otherwise the request is answered
+// with 503 Service Unavailable. From then on, the handler, which may still
+// be running, can no longer touch the response: its writes fail with
+// http.ErrHandlerTimeout.
+func Middleware(cb Guard, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	var m middleware
+	for _, opt := range opts {
+		opt(&m)
+	}
+	if cb == nil {
+		// lets every request through, as a nil *CircuitBreaker does.
+		cb = (*CircuitBreaker)(nil)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseRecorder(w)
+			_, err := cb.ExecuteContext(r.Context(), func(ctx context.Context) (any, error) {
+				rw.ctx = ctx
+				m.setStateHeader(rw, cb)
+				next.ServeHTTP(rw, r.WithContext(ctx))
+				if status, hijacked := rw.finish(); !hijacked && status >= http.StatusInternalServerError {
+					return nil, errServerStatus
+				}
+				return nil, nil
+			})
+			if pe, ok := err.(*PanicError); ok {
+				// net/http has its own way with panics.
+				panic(pe.Value)
+			}
+			// rejected, or timed out before the handler answered.
+			if err != nil && rw.timeout() {
+				m.setStateHeader(w, cb)
+				w.Header().Set("Retry-After", retryAfterFor(err))
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// setStateHeader sets X-Circuit-State if WithStateHeader asked for it.
+func (m *middleware) setStateHeader(w http.ResponseWriter, cb Guard) {
+	if m.stateHeader {
+		w.Header().Set("X-Circuit-State", cb.State().String())
+	}
+}
+
+// retryAfterFor is the Retry-After value for a request rejected with err:
+// the RetryAfter of an *OpenError, and otherwise one second.
+func retryAfterFor(err error) string {
+	var d time.Duration
+	var oe *OpenError
+	if errors.As(err, &oe) {
+		d = oe.RetryAfter
+	}
+	return retryAfter(d)
+}
+
+// retryAfter formats d as a Retry-After value of at least one second.
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(d.Seconds()))))
+}
+
+// responseRecorder notes the status a handler responds with. With
+// Config.CallTimeout the handler may still be running after Middleware
+// has returned, so the handler writes headers to a map of its own, copied
+// to the real one when the response starts, and nothing it does reaches
+// the underlying writer once timeout has been called.
+type responseRecorder struct {
+	http.ResponseWriter
+	header http.Header
+	// the call's context, whose cause tells that the call timed out.
+	ctx context.Context
+
+	mu       sync.Mutex
+	status   int
+	hijacked bool
+	timedOut bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, header: w.Header().Clone()}
+}
+
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+// closed reports whether the handler may no longer touch the response,
+// with w.mu held.
+func (w *responseRecorder) closed() bool {
+	return w.timedOut || w.ctx != nil && errors.Is(context.Cause(w.ctx), ErrCallTimeout)
+}
+
+// copyHeader hands the handler's headers to the underlying writer, with
+// w.mu held.
+func (w *responseRecorder) copyHeader() {
+	h := w.ResponseWriter.Header()
+	clear(h)
+	for k, v := range w.header {
+		h[k] = v
+	}
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed() || w.hijacked {
+		return
+	}
+	// informational responses may come before the final one.
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.copyHeader()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 && !w.hijacked {
+		w.status = http.StatusOK
+		w.copyHeader()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher when the underlying writer does.
+func (w *responseRecorder) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed() || w.hijacked {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.copyHeader()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the underlying writer does.
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish is called once the handler has returned. It hands the headers on
+// for net/http to send, as trailers or with the implicit 200, and returns
+// the status and whether the connection was hijacked.
+func (w *responseRecorder) finish() (status int, hijacked bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed() && !w.hijacked {
+		w.copyHeader()
+	}
+	return w.status, w.hijacked
+}
+
+// timeout stops the handler from touching the response from now on. It
+// reports whether the response is still to be written, as it is when the
+// handler never ran or had not answered yet.
+func (w *responseRecorder) timeout() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	return w.status == 0 && !w.hijacked
+}
@@ -0,0 +1,234 @@
+package circuitbreaker_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func serve(h http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestMiddleware_TripsAndRejectsWithRetryAfter(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, SuccessThreshold: 1, Timeout: 30 * time.Second, Clock: clock, Strict: true})
+	status := http.StatusInternalServerError
+	calls := 0
+	h := circuitbreaker.Middleware(cb, circuitbreaker.WithStateHeader())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if w := serve(h); w.Code != http.StatusInternalServerError || w.Header().Get("X-Circuit-State") != "Closed" {
+			t.Fatalf("expected the handler's 500 while closed, got %d %v", w.Code, w.Header())
+		}
+	}
+	clock.Advance(10500 * time.Millisecond)
+	w := serve(h)
+	if w.Code != http.StatusServiceUnavailable || calls != 2 {
+		t.Fatalf("expected a 503 without calling the handler, got %d after %d calls", w.Code, calls)
+	}
+	if got := w.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("expected Retry-After of the 19.5s left rounded up, got %q", got)
+	}
+	if got := w.Header().Get("X-Circuit-State"); got != "Open" {
+		t.Errorf("expected X-Circuit-State Open, got %q", got)
+	}
+
+	status = http.StatusOK
+	clock.Advance(20 * time.Second)
+	if w := serve(h); w.Code != http.StatusOK || w.Header().Get("X-Circuit-State") != "HalfOpen" {
+		t.Errorf("expected the probe to reach the handler, got %d %v", w.Code, w.Header())
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the successful probe to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestMiddleware_ClientErrorsAndImplicitStatusSucceed(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	handlers := []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {},
+		func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) },
+		func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) },
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}
+	for i, h := range handlers {
+		serve(circuitbreaker.Middleware(cb)(h))
+		if cb.State() != circuitbreaker.Closed {
+			t.Fatalf("handler %d tripped the circuit", i)
+		}
+	}
+	if tot := cb.Totals(); tot.Successes != 4 {
+		t.Errorf("expected 4 successes, got %+v", tot)
+	}
+}
+
+func TestMiddleware_PanicCountsAsFailure(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	h := circuitbreaker.Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("expected the panic to reach the server, got %v", r)
+			}
+		}()
+		serve(h)
+	}()
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the panic to trip the circuit, got %v", cb.State())
+	}
+}
+
+func TestMiddleware_Hijack(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Strict: true})
+	srv := httptest.NewServer(circuitbreaker.Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("expected the connection to be hijackable, got %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 500 Internal Server Error\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		buf.Flush()
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the hijacked connection's own response, got %d", resp.StatusCode)
+	}
+	// the client can read the response before the handler returns, so wait
+	// for the middleware to record the request.
+	deadline := time.Now().Add(5 * time.Second)
+	for cb.Status().InFlight > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the hijacked request was never recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// the status went over the raw connection, so it is not seen.
+	if cb.State() != circuitbreaker.Closed || cb.Totals().Successes != 1 {
+		t.Errorf("expected a hijacked request to count as a success, got %v %+v", cb.State(), cb.Totals())
+	}
+}
+
+// hijackOnly is a ResponseWriter that can only be hijacked through a type
+// assertion, as websocket libraries do.
+type hijackOnly struct {
+	http.ResponseWriter
+	hijacked bool
+}
+
+func (w *hijackOnly) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestMiddleware_HijackTypeAssertion(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	w := &hijackOnly{ResponseWriter: httptest.NewRecorder()}
+	circuitbreaker.Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected the wrapped writer to be an http.Hijacker")
+		}
+		h.Hijack()
+	})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !w.hijacked {
+		t.Error("expected Hijack to reach the underlying writer")
+	}
+}
+
+func TestMiddleware_AcceptsGuard(t *testing.T) {
+	b := cbt.NewScriptedBreaker(cbt.Reject, cbt.Admit)
+	b.SetState(circuitbreaker.Open)
+	calls := 0
+	h := circuitbreaker.Middleware(b, circuitbreaker.WithStateHeader())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	if w := serve(h); w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Circuit-State") != "Open" || calls != 0 {
+		t.Fatalf("expected a scripted rejection to answer 503, got %d %v after %d calls", w.Code, w.Header(), calls)
+	}
+	if w := serve(h); w.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("expected the admitted request served, got %d after %d calls", w.Code, calls)
+	}
+}
+
+func TestMiddleware_CallTimeout(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, CallTimeout: time.Second, Clock: clock, Strict: true})
+	var cause error
+	late := make(chan error, 1)
+	h := circuitbreaker.Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(time.Second)
+		<-r.Context().Done()
+		cause = context.Cause(r.Context())
+		w.Header().Set("X-Late", "1")
+		_, err := w.Write([]byte("late"))
+		late <- err
+	}))
+
+	w := serve(h)
+	var err error
+	select {
+	case err = <-late:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the abandoned handler never finished")
+	}
+	if !errors.Is(cause, circuitbreaker.ErrCallTimeout) {
+		t.Fatalf("expected the handler's context cancelled by the timeout, got %v", cause)
+	}
+	if !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("expected the late write to fail with ErrHandlerTimeout, got %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Late") != "" {
+		t.Errorf("expected a 503 without the handler's headers, got %d %v", w.Code, w.Header())
+	}
+	if tot := cb.Totals(); tot.Failures != 1 {
+		t.Errorf("expected the overrun to count as a failure, got %+v", tot)
+	}
+}
+
+func TestMiddleware_CallTimeoutKeepsStartedResponse(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Second, Clock: clock, Strict: true})
+	late := make(chan error, 1)
+	h := circuitbreaker.Middleware(cb)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("early"))
+		clock.Advance(time.Second)
+		<-r.Context().Done()
+		_, err := w.Write([]byte("late"))
+		late <- err
+	}))
+
+	w := serve(h)
+	if err := <-late; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("expected the late write to fail with ErrHandlerTimeout, got %v", err)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "early" {
+		t.Errorf("expected the response the handler had started, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,33 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSkipRecording, returned by a request on its own or wrapped in its
+// error, makes the breaker leave the call out of every counter and window
+// and out of Config.OnCall, as though it had not run. The error is still returned to the caller
+// unchanged. Use it for calls that never reached the dependency, such as
+// cache hits:
+//
+//	return nil, fmt.Errorf("served from cache: %w", circuitbreaker.ErrSkipRecording)
+//
+// A half-open probe that skips recording gives its probe slot back.
+var ErrSkipRecording = errors.New("circuit breaker: skip recording")
+
+// callerGaveUp reports whether err is the request's reaction to ctx, the
+// caller's context, having ended.
+func callerGaveUp(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// MarkNeutral, called from a request run by ExecuteContext with the
+// context it was given, has the breaker record nothing for the call, like
+// ErrSkipRecording, while the request returns its real result and error.
+// With any other context it does nothing.
+func MarkNeutral(ctx context.Context) {
+	if cc := callContextFrom(ctx); cc != nil {
+		cc.neutral.Store(true)
+	}
+}
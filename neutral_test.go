@@ -0,0 +1,142 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+var errCacheHit = fmt.Errorf("served from cache: %w", circuitbreaker.ErrSkipRecording)
+
+func TestSkipRecording_Closed(t *testing.T) {
+	calls := 0
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+		OnCall:           func(circuitbreaker.CallRecord) { calls++ },
+	})
+	cb.Execute(failFn)
+
+	for i := 0; i < 5; i++ {
+		if _, err := cb.Execute(func() (any, error) { return nil, errCacheHit }); err != errCacheHit {
+			t.Fatalf("expected the request's own error back, got %v", err)
+		}
+		result, err := cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+			circuitbreaker.MarkNeutral(ctx)
+			return "local", errSimulated
+		})
+		if result != "local" || err != errSimulated {
+			t.Fatalf("expected the real result and error back, got %v, %v", result, err)
+		}
+	}
+	if cb.State() != circuitbreaker.Closed || cb.Counts().ConsecutiveFailures != 1 {
+		t.Errorf("expected only the first failure counted, got %v %+v", cb.State(), cb.Counts())
+	}
+	if calls != 1 {
+		t.Errorf("expected skipped calls to be left out of OnCall, got %d records", calls)
+	}
+}
+
+func TestSkipRecording_HalfOpenReleasesProbeSlot(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	// a neutral success does not close the circuit, a neutral failure does
+	// not reopen it, and neither keeps its probe slot.
+	cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+		circuitbreaker.MarkNeutral(ctx)
+		return successFn()
+	})
+	if _, err := cb.Execute(func() (any, error) { return nil, errors.Join(errSimulated, circuitbreaker.ErrSkipRecording) }); !errors.Is(err, errSimulated) {
+		t.Fatalf("expected the probe to run and return its error, got %v", err)
+	}
+	if cb.State() != circuitbreaker.HalfOpen || counters(cb.Counts()) != (circuitbreaker.Counts{}) {
+		t.Fatalf("expected an untouched half-open breaker, got %v %+v", cb.State(), cb.Counts())
+	}
+
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Fatalf("expected the single probe slot to be free, got %v", err)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected a counted probe to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestMarkNeutral_OutsideExecuteContext(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	ctx := context.Background()
+	cb.Execute(func() (any, error) {
+		circuitbreaker.MarkNeutral(ctx)
+		return failFn()
+	})
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected MarkNeutral on an unrelated context to have no effect, got %v", cb.State())
+	}
+}
+
+func TestExecuteContext_CallerCancellationIsNotCounted(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Strict: true})
+
+	// a context that is done before the call is admitted.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	if _, err := cb.ExecuteContext(cancelled, func(context.Context) (any, error) { ran = true; return nil, nil }); err != context.Canceled || ran {
+		t.Errorf("expected context.Canceled without running the request, got %v (ran: %v)", err, ran)
+	}
+
+	// callers giving up while the request runs.
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := cb.ExecuteContext(ctx, func(ctx context.Context) (any, error) {
+		cancel()
+		return nil, fmt.Errorf("get: %w", ctx.Err())
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the request's error back, got %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	cb.ExecuteContext(ctx, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if cb.State() != circuitbreaker.Closed || counters(cb.Counts()) != (circuitbreaker.Counts{}) {
+		t.Fatalf("expected cancellations to leave the breaker alone, got %v %+v", cb.State(), cb.Counts())
+	}
+
+	// a deadline of the request's own still counts, as does any other error.
+	cb.ExecuteContext(context.Background(), func(ctx context.Context) (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the request's own timeout to trip the breaker, got %v", cb.State())
+	}
+	cb.Reset()
+	cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { return nil, errSimulated })
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected a genuine error to trip the breaker, got %v", cb.State())
+	}
+}
+
+func TestExecuteContext_CountCallerCancellations(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, CountCallerCancellations: true, Strict: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	cb.ExecuteContext(ctx, func(ctx context.Context) (any, error) {
+		cancel()
+		return nil, ctx.Err()
+	})
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the cancellation to count, got %v", cb.State())
+	}
+}
@@ -0,0 +1,69 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestNilBreaker_PassesThrough(t *testing.T) {
+	var cb *circuitbreaker.CircuitBreaker
+
+	result, err := cb.Execute(successFn)
+	if err != nil || result != "ok" {
+		t.Errorf("expected pass-through success, got %v, %v", result, err)
+	}
+
+	// Failures pass through too and never trip anything.
+	for i := 0; i < 10; i++ {
+		if _, err := cb.Execute(failFn); !errors.Is(err, errSimulated) {
+			t.Fatalf("expected the request's own error, got %v", err)
+		}
+	}
+
+	if n, err := circuitbreaker.Do(cb, func() (int, error) { return 42, nil }); n != 42 || err != nil {
+		t.Errorf("expected Do to pass through, got %v, %v", n, err)
+	}
+
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected Closed, got %v", cb.State())
+	}
+	if cb.Counts() != (circuitbreaker.Counts{}) {
+		t.Errorf("expected zero counts, got %+v", cb.Counts())
+	}
+	cb.Reset()
+	if err := cb.Close(); err != nil {
+		t.Errorf("expected Close to return nil, got %v", err)
+	}
+	if cb.Clone() != nil || cb.CloneWithState() != nil {
+		t.Error("expected clones of a nil breaker to be nil")
+	}
+
+	cbt.SetState(cb, circuitbreaker.Open)
+	cbt.SetCounts(cb, circuitbreaker.Counts{ConsecutiveFailures: 1})
+	cbt.AdvanceToHalfOpen(cb)
+}
+
+func TestExecute_NilFunction(t *testing.T) {
+	breakers := map[string]*circuitbreaker.CircuitBreaker{
+		"nil breaker":  nil,
+		"real breaker": circuitbreaker.New(circuitbreaker.DefaultConfig()),
+	}
+
+	for name, cb := range breakers {
+		t.Run(name, func(t *testing.T) {
+			result, err := cb.Execute(nil)
+			if !errors.Is(err, circuitbreaker.ErrNilFunction) {
+				t.Errorf("expected ErrNilFunction, got %v", err)
+			}
+			if result != nil {
+				t.Errorf("expected nil result, got %v", result)
+			}
+			if cb.Counts() != (circuitbreaker.Counts{}) {
+				t.Errorf("a nil function must not be counted, got %+v", cb.Counts())
+			}
+		})
+	}
+}
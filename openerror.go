@@ -0,0 +1,92 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"time"
+)
+
+// OpenError describes a request an open circuit rejected. The error
+// returned matches ErrCircuitOpen with errors.Is, and errors.As gets an
+// *OpenError from it, telling callers using several breakers which one
+// rejected them and when to come back. The *OpenError is only made when
+// asked for, so that rejecting a request allocates nothing.
+type OpenError struct {
+	// Name is the name of the breaker that rejected the request.
+	Name string
+	// OpenedAt is when the circuit opened.
+	OpenedAt time.Time
+	// RetryAfter is how long until the circuit lets a probe through, as
+	// of the errors.As call; see Status.OpenRemaining. It is zero while
+	// the circuit is held open by ForceOpen, a maintenance window or an
+	// ejection, which have no timeout.
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	if e.RetryAfter <= 0 {
+		return fmt.Sprintf("circuit breaker %q is open", e.Name)
+	}
+	return fmt.Sprintf("circuit breaker %q is open, retry after %v", e.Name, e.RetryAfter)
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) match.
+func (e *OpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// openRejection is the error an open circuit rejects requests with. The
+// breaker keeps it for as long as what it says holds, normally a whole
+// open period, so that a rejection allocates nothing; errors.As makes the
+// *OpenError from it.
+type openRejection struct {
+	name     string
+	openedAt time.Time
+	// retryAt is when the circuit lets a probe through; zero while it is
+	// held open or already due.
+	retryAt time.Time
+	clock   Clock
+}
+
+// details is the *OpenError for e, as of now.
+func (e *openRejection) details() *OpenError {
+	var retryAfter time.Duration
+	if !e.retryAt.IsZero() {
+		retryAfter = max(0, e.retryAt.Sub(e.clock.Now()))
+	}
+	return &OpenError{Name: e.name, OpenedAt: e.openedAt, RetryAfter: retryAfter}
+}
+
+func (e *openRejection) Error() string {
+	return e.details().Error()
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) match.
+func (e *openRejection) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// As lets errors.As get the *OpenError.
+func (e *openRejection) As(target any) bool {
+	oe, ok := target.(**OpenError)
+	if ok {
+		*oe = e.details()
+	}
+	return ok
+}
+
+// openError returns the error for a request the open circuit rejects,
+// making a new one only when the last one no longer holds. Must be called
+// with cb.mu held.
+func (cb *CircuitBreaker) openError() error {
+	now := cb.clock.Now()
+	var retryAt time.Time
+	if remaining := cb.openRemaining(now); remaining > 0 {
+		retryAt = now.Add(remaining)
+	}
+	e := cb.openErr
+	if e == nil || !e.openedAt.Equal(cb.lastStateChange) || !e.retryAt.Equal(retryAt) || e.name != cb.config.Name {
+		e = &openRejection{name: cb.config.Name, openedAt: cb.lastStateChange, retryAt: retryAt, clock: cb.clock}
+		cb.openErr = e
+	}
+	return e
+}
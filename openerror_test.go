@@ -0,0 +1,95 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestOpenError_NameAndRetryAfter(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "search", FailureThreshold: 1, Timeout: 30 * time.Second, Clock: clock, Strict: true})
+	cb.Execute(failFn)
+
+	var last time.Duration = 31 * time.Second
+	for _, wait := range []time.Duration{0, 10 * time.Second, 15 * time.Second} {
+		clock.Advance(wait)
+		_, err := cb.Execute(successFn)
+		if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+			t.Fatalf("expected errors.Is to match ErrCircuitOpen, got %v", err)
+		}
+		var oe *circuitbreaker.OpenError
+		if !errors.As(err, &oe) {
+			t.Fatalf("expected an *OpenError, got %T", err)
+		}
+		if oe.Name != "search" || !oe.OpenedAt.Equal(cbt.Epoch) {
+			t.Errorf("expected search opened at the epoch, got %q at %v", oe.Name, oe.OpenedAt)
+		}
+		if oe.RetryAfter >= last || oe.RetryAfter != cb.Status().OpenRemaining {
+			t.Errorf("expected RetryAfter to count down to the open remaining time, got %v after %v", oe.RetryAfter, last)
+		}
+		last = oe.RetryAfter
+	}
+	if last != 5*time.Second {
+		t.Errorf("expected 5s left after 25s of 30s, got %v", last)
+	}
+}
+
+func TestOpenError_ForcedOpenHasNoRetryAfter(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "search", Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	cb.ForceOpen()
+	_, err := cb.Execute(successFn)
+	var oe *circuitbreaker.OpenError
+	if !errors.As(err, &oe) || oe.RetryAfter != 0 {
+		t.Fatalf("expected an *OpenError without RetryAfter, got %v", err)
+	}
+	if got := err.Error(); got != `circuit breaker "search" is open` {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestTimeUntilRetry(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:   1,
+		Timeout:            time.Minute,
+		OpenTimeoutBackoff: circuitbreaker.OpenBackoff{MaxTimeout: time.Hour},
+		Clock:              clock,
+		Strict:             true,
+	})
+	if d, ok := cb.TimeUntilRetry(); ok || d != 0 {
+		t.Errorf("expected no retry time while closed, got %v, %v", d, ok)
+	}
+
+	cb.Execute(failFn)
+	clock.Advance(20 * time.Second)
+	if d, ok := cb.TimeUntilRetry(); !ok || d != 40*time.Second {
+		t.Errorf("expected 40s left, got %v, %v", d, ok)
+	}
+	// a failed probe reopens with the backed-off timeout.
+	clock.Advance(40 * time.Second)
+	if d, ok := cb.TimeUntilRetry(); !ok || d != 0 {
+		t.Errorf("expected the next call to probe, got %v, %v", d, ok)
+	}
+	cb.Execute(failFn)
+	if d, ok := cb.TimeUntilRetry(); !ok || d != 2*time.Minute {
+		t.Errorf("expected the doubled timeout, got %v, %v", d, ok)
+	}
+	// a clock that steps backwards never yields more than the timeout.
+	clock.Set(cbt.Epoch)
+	if d, ok := cb.TimeUntilRetry(); !ok || d != 2*time.Minute {
+		t.Errorf("expected at most the timeout, got %v, %v", d, ok)
+	}
+
+	cb.ForceOpen()
+	if d, ok := cb.TimeUntilRetry(); ok || d != 0 {
+		t.Errorf("expected no retry time while forced open, got %v, %v", d, ok)
+	}
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if _, ok := nilBreaker.TimeUntilRetry(); ok {
+		t.Error("expected a nil breaker never to be open")
+	}
+}
@@ -0,0 +1,65 @@
+package circuitbreaker
+
+// CallOption adjusts how a single call is admitted and accounted for.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	cost    int
+	retry   bool
+	noProbe bool
+	// Logical call the attempt belongs to; see AsAttempt.
+	attempt string
+	// Tenant the call is made for; see ForTenant.
+	tenant string
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	if len(opts) == 0 {
+		// kept apart so that o, whose address the options take, does not
+		// cost a call without options an allocation.
+		return callOptions{cost: 1}
+	}
+	o := callOptions{cost: 1}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// WithCost declares how much of the bulkhead a call occupies while it
+// runs, relative to an ordinary call of cost 1. Give long-running calls
+// that hold a downstream connection for longer a proportionally higher
+// cost. Costs below 1 are treated as 1.
+func WithCost(cost int) CallOption {
+	return func(o *callOptions) {
+		if cost < 1 {
+			cost = 1
+		}
+		o.cost = cost
+	}
+}
+
+// AsRetry marks a call as a retry of an earlier failed attempt. When
+// Config.RetryBudgetRatio is set, retries are only admitted while the retry
+// budget has tokens and are rejected with ErrRetryBudgetExhausted
+// otherwise. Use it when driving the breaker from your own retry loop;
+// ExecuteWithRetry sets it for you.
+func AsRetry() CallOption {
+	return func(o *callOptions) {
+		o.retry = true
+	}
+}
+
+// NoProbe marks a call as unfit to be a half-open probe, such as a write
+// that must not be half-applied against a backend that may still be
+// failing. While the circuit is half-open the call is rejected with
+// ErrTooManyRequests before it takes a probe slot, so the slots go to calls
+// that are safe to repeat. Such calls never wait in ExecuteContext's
+// waiting room.
+func NoProbe() CallOption {
+	return func(o *callOptions) {
+		o.noProbe = true
+	}
+}
@@ -0,0 +1,197 @@
+package circuitbreaker
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// OutlierDetection ejects keys of a Group whose failure rate is far above
+// that of their siblings, even when it is below what would trip the
+// breaker on its own. See WithOutlierDetection.
+type OutlierDetection struct {
+	// Interval is how often keys are compared, and the window their
+	// failure rates are measured over. Defaults to 10s.
+	Interval time.Duration
+	// Ratio is how many times the median failure rate of the group a key's
+	// rate must reach to be ejected. Defaults to 10.
+	Ratio float64
+	// MinFailureRate is the failure rate a key must reach to be ejected
+	// however low the median is. Defaults to 0.05.
+	MinFailureRate float64
+	// MinRequests is the number of calls a key needs in the window to be
+	// compared. Defaults to 20.
+	MinRequests int
+	// BaseEjectionTime is how long a key stays ejected the first time;
+	// each later ejection of the same key lasts one BaseEjectionTime
+	// longer. Defaults to 30s.
+	BaseEjectionTime time.Duration
+	// MaxEjectionPercent caps the fraction of keys, between 0 and 1, that
+	// may be ejected at once. At least one key can always be ejected.
+	// Defaults to 0.1.
+	MaxEjectionPercent float64
+}
+
+func (o OutlierDetection) withDefaults() OutlierDetection {
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Second
+	}
+	if o.Ratio <= 0 {
+		o.Ratio = 10
+	}
+	if o.MinFailureRate <= 0 {
+		o.MinFailureRate = 0.05
+	}
+	if o.MinRequests <= 0 {
+		o.MinRequests = 20
+	}
+	if o.BaseEjectionTime <= 0 {
+		o.BaseEjectionTime = 30 * time.Second
+	}
+	if o.MaxEjectionPercent <= 0 {
+		o.MaxEjectionPercent = 0.1
+	}
+	return o
+}
+
+// WithOutlierDetection enables outlier detection for a Group. Every
+// Interval, each key's failure rate is compared with the median of the
+// keys, and outliers are ejected: their circuit is held open for the
+// ejection time and then closed again.
+func WithOutlierDetection(o OutlierDetection) GroupOption {
+	return func(g *Group) {
+		o = o.withDefaults()
+		g.outlier = &o
+	}
+}
+
+// ejection is a decision taken by the detector, applied once the group's
+// lock is released.
+type ejection struct {
+	m      *member
+	eject  bool
+	rate   float64
+	median float64
+}
+
+func (g *Group) onOutlierTimer() {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return
+	}
+	decisions := g.detectOutliers(g.clock.Now())
+	g.timer = g.clock.AfterFunc(g.outlier.Interval, g.onOutlierTimer)
+	g.mu.Unlock()
+
+	for _, d := range decisions {
+		if d.eject {
+			d.m.breaker.eject(d.rate, d.median)
+		} else {
+			d.m.breaker.reinstate()
+		}
+	}
+}
+
+// detectOutliers decides which keys to reinstate and which to eject. Must
+// be called with g.mu held.
+func (g *Group) detectOutliers(now time.Time) []ejection {
+	o := g.outlier
+	var decisions []ejection
+	type candidate struct {
+		m    *member
+		rate float64
+	}
+	var rates []float64
+	var candidates []candidate
+	ejected := 0
+	for key, m := range g.members {
+		if key == OverflowKey {
+			// shared by unrelated keys, so it is no one's sibling.
+			continue
+		}
+		if m.ejected {
+			if now.Before(m.ejectedUntil) {
+				ejected++
+				continue
+			}
+			m.ejected = false
+			decisions = append(decisions, ejection{m: m})
+		}
+		total, failures := m.window.counts(now)
+		if total < o.MinRequests {
+			continue
+		}
+		rate := float64(failures) / float64(total)
+		rates = append(rates, rate)
+		candidates = append(candidates, candidate{m: m, rate: rate})
+	}
+	if len(rates) < 2 {
+		return decisions
+	}
+	median := medianOf(rates)
+
+	// worst first, so the cap keeps the most broken keys out.
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].rate > candidates[j].rate })
+	limit := int(math.Floor(o.MaxEjectionPercent * float64(len(g.members))))
+	if limit < 1 {
+		limit = 1
+	}
+	for _, c := range candidates {
+		if ejected >= limit {
+			break
+		}
+		if c.rate < o.MinFailureRate || c.rate < o.Ratio*median {
+			break
+		}
+		c.m.ejected = true
+		c.m.ejections++
+		c.m.ejectedUntil = now.Add(time.Duration(c.m.ejections) * o.BaseEjectionTime)
+		c.m.window.reset()
+		ejected++
+		decisions = append(decisions, ejection{m: c.m, eject: true, rate: c.rate, median: median})
+	}
+	return decisions
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// eject holds the circuit open as an outlier until reinstate is called.
+func (cb *CircuitBreaker) eject(rate, median float64) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.closed {
+		return
+	}
+	cb.ejected = true
+	cb.emit(Event{Type: EventEjected, Time: cb.clock.Now(), From: cb.state, To: Open,
+		Reason: ReasonOutlier, FailureRate: rate, BaselineRate: median})
+	cb.setState(Open, ReasonOutlier)
+}
+
+// reinstate ends an ejection and closes the circuit, unless a maintenance
+// window is holding it open.
+func (cb *CircuitBreaker) reinstate() {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if !cb.ejected {
+		return
+	}
+	cb.ejected = false
+	to := Open
+	if !cb.maintenance {
+		to = Closed
+	}
+	cb.emit(Event{Type: EventReinstated, Time: cb.clock.Now(), From: cb.state, To: to, Reason: ReasonOutlier})
+	cb.setState(to, ReasonOutlier)
+}
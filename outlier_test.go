@@ -0,0 +1,159 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+type outlierGroup struct {
+	group  *circuitbreaker.Group
+	clock  *cbt.FakeClock
+	events []circuitbreaker.Event
+}
+
+func newOutlierGroup(maxEjection float64) *outlierGroup {
+	og := &outlierGroup{clock: cbt.NewFakeClock(cbt.Epoch)}
+	og.group = circuitbreaker.NewGroup(circuitbreaker.Config{
+		Name:             "hosts",
+		FailureThreshold: 1000,
+		Clock:            og.clock,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			og.events = append(og.events, ev)
+		},
+	}, circuitbreaker.WithOutlierDetection(circuitbreaker.OutlierDetection{
+		Interval:           10 * time.Second,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionPercent: maxEjection,
+	}))
+	return og
+}
+
+// interval sends 100 calls to each key with the given number of failures
+// and lets the detector run once.
+func (og *outlierGroup) interval(failures map[string]int) {
+	og.clock.Advance(time.Second)
+	for key, n := range failures {
+		for i := 0; i < 100; i++ {
+			fn := successFn
+			if i < n {
+				fn = failFn
+			}
+			og.group.Execute(key, fn)
+		}
+	}
+	og.clock.Advance(9 * time.Second)
+}
+
+func hostRates(bad map[string]int) map[string]int {
+	rates := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		rates[fmt.Sprintf("host-%d", i)] = 1
+	}
+	for k, v := range bad {
+		rates[k] = v
+	}
+	return rates
+}
+
+func TestOutlier_EjectsAndReinstates(t *testing.T) {
+	og := newOutlierGroup(0.1)
+	defer og.group.Close()
+
+	og.interval(hostRates(map[string]int{"host-3": 20}))
+	bad := og.group.Breaker("host-3")
+	if s := bad.Status(); !s.Ejected || s.State != circuitbreaker.Open {
+		t.Fatalf("expected host-3 to be ejected, got %+v", s)
+	}
+	if _, err := og.group.Execute("host-3", successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected an ejected key to reject calls, got %v", err)
+	}
+	var ejected *circuitbreaker.Event
+	for i := range og.events {
+		if og.events[i].Type == circuitbreaker.EventEjected {
+			ejected = &og.events[i]
+		}
+	}
+	if ejected == nil || ejected.Name != "hosts/host-3" || ejected.FailureRate != 0.2 || ejected.BaselineRate != 0.01 {
+		t.Fatalf("expected an ejection event with the compared rates, got %+v", ejected)
+	}
+	for _, key := range og.group.Keys() {
+		if key != "host-3" && og.group.Breaker(key).Status().Ejected {
+			t.Errorf("healthy key %s was ejected", key)
+		}
+	}
+
+	// an absolute rate of 20% is below what trips the breaker itself, so
+	// only the ejection keeps the circuit open; it ends after 30s.
+	for i := 0; i < 2; i++ {
+		og.interval(hostRates(nil))
+		if !bad.Status().Ejected {
+			t.Fatalf("reinstated after %d intervals, before the ejection time", i+1)
+		}
+	}
+	og.interval(hostRates(nil))
+	if s := bad.Status(); s.Ejected || s.State != circuitbreaker.Closed {
+		t.Fatalf("expected host-3 to be reinstated, got %+v", s)
+	}
+	if last := og.events[len(og.events)-1]; last.To != circuitbreaker.Closed || last.Reason != circuitbreaker.ReasonOutlier {
+		t.Errorf("expected a reinstatement state change, got %+v", last)
+	}
+
+	// a second ejection lasts twice as long.
+	og.interval(hostRates(map[string]int{"host-3": 20}))
+	for i := 0; i < 5; i++ {
+		og.interval(hostRates(nil))
+		if !bad.Status().Ejected {
+			t.Fatalf("second ejection ended after %d intervals", i+1)
+		}
+	}
+	og.interval(hostRates(nil))
+	if bad.Status().Ejected {
+		t.Error("expected the second ejection to end after 60s")
+	}
+}
+
+func TestOutlier_RespectsEjectionCap(t *testing.T) {
+	og := newOutlierGroup(0.2)
+	defer og.group.Close()
+
+	og.interval(hostRates(map[string]int{"host-1": 30, "host-2": 40, "host-5": 25, "host-7": 50}))
+	var ejected []string
+	for _, key := range og.group.Keys() {
+		if og.group.Breaker(key).Status().Ejected {
+			ejected = append(ejected, key)
+		}
+	}
+	if len(ejected) != 2 || ejected[0] != "host-2" || ejected[1] != "host-7" {
+		t.Errorf("expected only the two worst keys to be ejected, got %v", ejected)
+	}
+}
+
+func TestOutlier_SimilarKeysAreLeftAlone(t *testing.T) {
+	og := newOutlierGroup(0.5)
+	defer og.group.Close()
+
+	og.interval(hostRates(map[string]int{"host-0": 4, "host-4": 6}))
+	for _, key := range og.group.Keys() {
+		if og.group.Breaker(key).Status().Ejected {
+			t.Errorf("%s was ejected without being an outlier", key)
+		}
+	}
+}
+
+func TestGroup_ClosesBreakers(t *testing.T) {
+	og := newOutlierGroup(0.1)
+	og.group.Execute("a", successFn)
+	og.group.Close()
+	if _, err := og.group.Execute("a", successFn); !errors.Is(err, circuitbreaker.ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+	if n := og.clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop the detector, %d timers pending", n)
+	}
+}
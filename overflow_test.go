@@ -0,0 +1,114 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestGroup_MaxKeysCollapsesIntoOverflow(t *testing.T) {
+	var overflows []circuitbreaker.Event
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{
+		Name:             "api",
+		FailureThreshold: 3,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.Type == circuitbreaker.EventKeyOverflow {
+				overflows = append(overflows, ev)
+			}
+		},
+	}, circuitbreaker.WithMaxKeys(100))
+	defer g.Close()
+
+	// a key function gone wrong: a new key per request.
+	for i := 0; i < 10000; i++ {
+		g.Execute(fmt.Sprintf("request-%d", i), successFn)
+	}
+	stats := g.Stats()
+	if stats.Keys != 101 || len(g.Keys()) != 101 {
+		t.Fatalf("expected 100 keys plus the overflow breaker, got %d", stats.Keys)
+	}
+	if stats.Overflowed != 9900 {
+		t.Errorf("expected 9900 overflowed lookups, got %d", stats.Overflowed)
+	}
+	if len(overflows) != 1 || overflows[0].Key != "request-100" || overflows[0].Name != "api/"+circuitbreaker.OverflowKey {
+		t.Errorf("expected one overflow event for the first extra key, got %+v", overflows)
+	}
+
+	// overflow traffic shares one breaker; existing keys keep their own.
+	for i := 0; i < 3; i++ {
+		g.Execute(fmt.Sprintf("new-%d", i), failFn)
+	}
+	if _, err := g.Execute("new-3", successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected overflow keys to share the tripped overflow breaker, got %v", err)
+	}
+	if _, err := g.Execute("request-0", successFn); err != nil {
+		t.Errorf("expected an existing key to be unaffected, got %v", err)
+	}
+	if g.Breaker("request-99") == g.Breaker(circuitbreaker.OverflowKey) {
+		t.Error("a key within the limit was routed to the overflow breaker")
+	}
+}
+
+func TestGroup_KeyConfigOverrides(t *testing.T) {
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{Name: "grpc", FailureThreshold: 5, Strict: true},
+		circuitbreaker.WithKeyConfig("/export.Service/ExportAllData", circuitbreaker.Config{FailureThreshold: 1, Strict: true}))
+	defer g.Close()
+
+	g.Execute("/export.Service/ExportAllData", failFn)
+	g.Execute("/profile.Service/GetProfile", failFn)
+
+	export := g.Breaker("/export.Service/ExportAllData")
+	if export.State() != circuitbreaker.Open {
+		t.Errorf("expected the overridden key to trip after one failure, got %v", export.State())
+	}
+	if name := export.Status().Name; name != "grpc//export.Service/ExportAllData" {
+		t.Errorf("expected the group-derived name, got %q", name)
+	}
+	if s := g.Breaker("/profile.Service/GetProfile").State(); s != circuitbreaker.Closed {
+		t.Errorf("expected the other key to use the group config and stay Closed, got %v", s)
+	}
+}
+
+func TestGroup_IdleTTLEvictsRestingKeys(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	g := circuitbreaker.NewGroup(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Hour,
+		Clock:            clock,
+		Strict:           true,
+	}, circuitbreaker.WithIdleTTL(10*time.Minute))
+	defer g.Close()
+
+	for i := 0; i < 100; i++ {
+		g.Execute(fmt.Sprintf("client-%d", i), successFn)
+	}
+	g.Execute("broken", failFn)
+	forgotten := g.Breaker("client-0")
+	clock.Advance(5 * time.Minute)
+	g.Execute("busy", successFn)
+	clock.Advance(5 * time.Minute)
+
+	// the lookup sweeps: the idle closed breakers go, while the open one
+	// and the recently used one stay.
+	g.Execute("busy", successFn)
+	if got := g.Keys(); len(got) != 2 || got[0] != "broken" || got[1] != "busy" {
+		t.Fatalf("expected only broken and busy left, got %v", got)
+	}
+	if got := g.Stats().Evicted; got != 100 {
+		t.Errorf("expected 100 evictions, got %d", got)
+	}
+	if _, err := forgotten.Execute(successFn); !errors.Is(err, circuitbreaker.ErrClosed) {
+		t.Errorf("expected an evicted breaker to be closed, got %v", err)
+	}
+	if _, err := g.Execute("broken", successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the open breaker to be kept, got %v", err)
+	}
+	if g.Breaker("client-0") == forgotten {
+		t.Error("expected an evicted key to get a new breaker")
+	}
+}
@@ -0,0 +1,90 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Panic describes a panic recovered from a user-supplied function, such as
+// a hook or a PressureSource. It is passed to Config.OnPanic.
+type Panic struct {
+	// Name is the breaker's Config.Name.
+	Name string
+	// Component names what panicked: a Config field such as
+	// "OnStateChange" or "QuorumStore", or "Transport.ProbeSafe".
+	Component string
+	// Value is what was passed to panic.
+	Value any
+	// Stack is the goroutine's stack where the panic was recovered.
+	Stack []byte
+}
+
+func (p Panic) String() string {
+	return fmt.Sprintf("circuit breaker %q: %s panicked: %v", p.Name, p.Component, p.Value)
+}
+
+// PanicError is returned in place of a request's panic when
+// Config.RecoverPanics is set.
+type PanicError struct {
+	// Value is what was passed to panic.
+	Value any
+	// Stack is the goroutine's stack where the panic was recovered.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("circuit breaker: request panicked: %v", e.Value)
+}
+
+// Unwrap returns Value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// hookCall is a hook call queued to run once cb.mu is released.
+type hookCall struct {
+	component string
+	fn        func()
+}
+
+// queueHook queues fn, a call to the hook named by component, to run once
+// cb.mu is released. Must be called with cb.mu held.
+func (cb *CircuitBreaker) queueHook(component string, fn func()) {
+	cb.pending = append(cb.pending, hookCall{component: component, fn: fn})
+}
+
+// protect runs fn, which calls the user-supplied function named by
+// component, and recovers a panic in it, reporting it to Config.OnPanic.
+// It reports whether fn returned normally, so the caller can fall back to
+// a safe default. It must not be called with cb.mu held, and is safe on a
+// nil breaker.
+func (cb *CircuitBreaker) protect(component string, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			cb.reportPanic(component, r)
+		}
+	}()
+	fn()
+	return true
+}
+
+// protectFor is protect for a callback of a wrapper guarded by g. Only a
+// *CircuitBreaker has an OnPanic to report to; with another Guard the
+// panic is just recovered.
+func protectFor(g Guard, component string, fn func()) (ok bool) {
+	cb, _ := g.(*CircuitBreaker)
+	return cb.protect(component, fn)
+}
+
+// reportPanic passes a recovered panic to Config.OnPanic. A panic in
+// OnPanic itself is dropped.
+func (cb *CircuitBreaker) reportPanic(component string, value any) {
+	if cb == nil || cb.config.OnPanic == nil {
+		return
+	}
+	p := Panic{Name: cb.config.Name, Component: component, Value: value, Stack: debug.Stack()}
+	defer func() { recover() }()
+	cb.config.OnPanic(p)
+}
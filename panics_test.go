@@ -0,0 +1,212 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// panicRecorder collects the components reported to OnPanic.
+type panicRecorder []string
+
+func (r *panicRecorder) record(p circuitbreaker.Panic) {
+	*r = append(*r, p.Component)
+}
+
+func TestPanics_HooksAreDropped(t *testing.T) {
+	var panics panicRecorder
+	var events []circuitbreaker.EventType
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 2,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+		OnStateChange:    func(string, circuitbreaker.State, circuitbreaker.State) { panic("state change") },
+		OnCall:           func(circuitbreaker.CallRecord) { panic("call") },
+		OnEvent:          func(ev circuitbreaker.Event) { events = append(events, ev.Type) },
+		OnPanic:          panics.record,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cb.Execute(failFn); !errors.Is(err, errSimulated) {
+			t.Fatalf("call %d: expected the request's own error, got %v", i, err)
+		}
+	}
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the circuit open, got %v", err)
+	}
+	if st := cb.Status(); st.State != circuitbreaker.Open || counters(st.Counts) != (circuitbreaker.Counts{}) {
+		t.Errorf("expected a consistent open breaker, got %v %+v", st.State, st.Counts)
+	}
+	// the event queued after the panicking OnStateChange still arrives.
+	if len(events) != 1 || events[0] != circuitbreaker.EventStateChange {
+		t.Errorf("expected the state change event, got %v", events)
+	}
+	if got := strings.Join(panics, ","); got != "OnCall,OnCall,OnStateChange,OnCall" {
+		t.Errorf("unexpected panics reported: %s", got)
+	}
+}
+
+func TestPanics_PanickingOnPanicIsDropped(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		OnCall:  func(circuitbreaker.CallRecord) { panic("call") },
+		OnPanic: func(circuitbreaker.Panic) { panic("again") },
+	})
+	if result, err := cb.Execute(successFn); result != "ok" || err != nil {
+		t.Errorf("expected the call's result, got %v, %v", result, err)
+	}
+}
+
+// panickingPressure panics on every sample.
+type panickingPressure struct{}
+
+func (panickingPressure) Sample() circuitbreaker.PressureReading { panic("sample") }
+
+// panickingStore panics on every call.
+type panickingStore struct{}
+
+func (panickingStore) ReportBreach(context.Context, string, string, time.Duration) error {
+	panic("report")
+}
+
+func (panickingStore) Breaches(context.Context, string) (int, error) { panic("breaches") }
+
+func TestPanics_StrategiesFallBack(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var panics panicRecorder
+	var storeErrs []error
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Pressure:         panickingPressure{},
+		PressureInterval: time.Second,
+		MaxGoroutines:    1,
+		QuorumStore:      panickingStore{},
+		Quorum:           1,
+		QuorumInterval:   time.Second,
+		OnQuorumError:    func(err error) { storeErrs = append(storeErrs, err) },
+		OnPanic:          panics.record,
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(successFn)
+
+	clock.Advance(time.Second)
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Errorf("expected calls through without a reading, got %v", err)
+	}
+	if got := strings.Join(panics, ","); got != "Pressure,QuorumStore" {
+		t.Errorf("unexpected panics reported: %s", got)
+	}
+	if len(storeErrs) != 1 || cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the store treated as unreachable, got %v, %v", storeErrs, cb.State())
+	}
+
+	// both keep being retried on schedule.
+	clock.Advance(time.Second)
+	if len(panics) != 4 {
+		t.Errorf("expected the next samples to run, got %v", panics)
+	}
+}
+
+func TestPanics_ProbeSafeCountsAsFalse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	var panics panicRecorder
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		SuccessThreshold: 1,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+		OnPanic:          panics.record,
+	})
+	cbt.AdvanceToHalfOpen(cb)
+	client := &http.Client{Transport: &circuitbreaker.Transport{
+		Breaker:   cb,
+		ProbeSafe: func(*http.Request) bool { panic("probe safe") },
+	}}
+
+	if _, err := client.Get(srv.URL); !errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+		t.Errorf("expected the request kept from probing, got %v", err)
+	}
+	if len(panics) != 1 || panics[0] != "Transport.ProbeSafe" || cb.State() != circuitbreaker.HalfOpen {
+		t.Errorf("expected one reported panic and the circuit still half-open, got %v, %v", panics, cb.State())
+	}
+}
+
+func TestPanics_FallbackReturnsCallError(t *testing.T) {
+	exec := circuitbreaker.Pipeline(circuitbreaker.Fallback(func(context.Context, error) (any, error) {
+		panic("fallback")
+	}))
+	var runs int
+	_, err := exec.Execute(context.Background(), failOp(&runs))
+	if !errors.Is(err, errSimulated) || !strings.Contains(err.Error(), "fallback panicked") {
+		t.Errorf("expected the call's error with the panic noted, got %v", err)
+	}
+}
+
+func TestPanics_RequestPanicsTripAndPropagate(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, SuccessThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+	boom := func() (any, error) { panic("boom") }
+
+	for i := 0; i < 3; i++ {
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Fatalf("expected the original panic, got %v", r)
+				}
+			}()
+			cb.Execute(boom)
+		}()
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected three panics to trip the circuit, got %v", cb.State())
+	}
+	if _, err := cb.Execute(boom); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// the breaker is left in working order.
+	clock.Advance(time.Minute)
+	if result, err := cb.Execute(successFn); result != "ok" || err != nil {
+		t.Fatalf("expected the probe to run, got %v, %v", result, err)
+	}
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.InFlight != 0 {
+		t.Errorf("expected a closed breaker with nothing in flight, got %v with %d in flight", s.State, s.InFlight)
+	}
+}
+
+func TestPanics_RecoverPanics(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+		RecoverPanics:    true,
+		// panics count however errors are classified.
+		IsFailure: func(error) bool { return false },
+	})
+
+	_, err := cb.Execute(func() (any, error) { panic(errSimulated) })
+	var pe *circuitbreaker.PanicError
+	if !errors.As(err, &pe) || pe.Value != errSimulated || !strings.Contains(string(pe.Stack), "TestPanics_RecoverPanics") {
+		t.Fatalf("expected a *PanicError with the value and stack, got %#v", err)
+	}
+	if !errors.Is(err, errSimulated) {
+		t.Error("expected a panicking error to be unwrapped")
+	}
+	_, err = cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { panic("boom") })
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("expected a *PanicError from ExecuteContext, got %v", err)
+	}
+	_, pending, err := cb.ExecuteDeferred(func() (any, error) { panic("boom") })
+	if !errors.As(err, &pe) || pending != nil {
+		t.Fatalf("expected a *PanicError from ExecuteDeferred, got %v, %v", pending, err)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected three panics to trip the circuit, got %v", cb.State())
+	}
+}
@@ -0,0 +1,97 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrPendingTimeout is the outcome recorded for a PendingOutcome that was
+// not resolved within Config.PendingTimeout, unless
+// Config.PendingTimeoutSucceeds is set.
+var ErrPendingTimeout = errors.New("circuit breaker: pending outcome timed out")
+
+// PendingOutcome is a call whose outcome is only known after
+// ExecuteDeferred returns, such as a job whose ack arrives on a callback.
+// Until it is resolved the call stays in flight: it holds its bulkhead
+// cost and, when admitted as a half-open probe, its probe slot.
+type PendingOutcome struct {
+	cb       *CircuitBreaker
+	c        call
+	resolved atomic.Bool
+	timer    Timer
+}
+
+// ExecuteDeferred runs fn through the breaker like Execute, but leaves
+// the call's outcome open when fn succeeds: it is recorded when the
+// returned PendingOutcome is resolved, or when Config.PendingTimeout
+// expires first. A call whose fn fails or panics is recorded at once, and
+// a rejected one never runs; in both cases the PendingOutcome is nil.
+// Resolving a nil PendingOutcome does nothing, so callers need not check.
+func (cb *CircuitBreaker) ExecuteDeferred(fn func() (any, error), opts ...CallOption) (any, *PendingOutcome, error) {
+	if fn == nil {
+		return nil, nil, ErrNilFunction
+	}
+	if cb == nil {
+		result, err := fn()
+		return result, nil, err
+	}
+	cb.lazyInit()
+	c, err := cb.admit(newCallOptions(opts))
+	if err != nil {
+		if isRejection(err) {
+			cb.reportRejection(err)
+		}
+		return nil, nil, err
+	}
+
+	// a successful call stays in flight until it is resolved.
+	result, err := cb.run(func(err error) {
+		if err != nil {
+			cb.complete(c, err)
+		}
+	}, fn)
+	if err != nil {
+		return result, nil, err
+	}
+
+	p := &PendingOutcome{cb: cb, c: c}
+	cb.mu.Lock()
+	defer cb.unlock()
+	p.timer = cb.clock.AfterFunc(cb.config.PendingTimeout, p.expire)
+	if cb.deferred == nil {
+		cb.deferred = map[*PendingOutcome]struct{}{}
+	}
+	cb.deferred[p] = struct{}{}
+	return result, p, nil
+}
+
+// Resolve records err, or success when err is nil, as the call's outcome
+// and releases what the call held. Only the first resolution counts,
+// including one made by the timeout; Resolve reports whether this one did.
+func (p *PendingOutcome) Resolve(err error) bool {
+	if p == nil || !p.resolved.CompareAndSwap(false, true) {
+		return false
+	}
+	p.timer.Stop()
+	p.finish(err)
+	return true
+}
+
+// expire resolves p when Config.PendingTimeout runs out.
+func (p *PendingOutcome) expire() {
+	if !p.resolved.CompareAndSwap(false, true) {
+		return
+	}
+	var err error
+	if !p.cb.config.PendingTimeoutSucceeds {
+		err = ErrPendingTimeout
+	}
+	p.finish(err)
+}
+
+func (p *PendingOutcome) finish(err error) {
+	p.cb.mu.Lock()
+	delete(p.cb.deferred, p)
+	p.cb.mu.Unlock()
+	p.cb.complete(p.c, err)
+}
@@ -0,0 +1,126 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestExecuteDeferred_LateOutcomes(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, MaxConcurrent: 2, Clock: clock, Strict: true})
+
+	result, success, err := cb.ExecuteDeferred(func() (any, error) { return "job-1", nil })
+	if result != "job-1" || err != nil || success == nil {
+		t.Fatalf("expected an accepted job and a pending outcome, got %v, %v, %v", result, success, err)
+	}
+	_, failure, _ := cb.ExecuteDeferred(successFn)
+	if st := cb.Status(); st.InFlightCost != 2 {
+		t.Fatalf("expected both pending calls in flight, got %d", st.InFlightCost)
+	}
+	if _, _, err := cb.ExecuteDeferred(successFn); !errors.Is(err, circuitbreaker.ErrBulkheadFull) {
+		t.Errorf("expected pending calls to hold the bulkhead, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if !success.Resolve(nil) {
+		t.Error("expected the first resolution to count")
+	}
+	if success.Resolve(errSimulated) {
+		t.Error("expected a second resolution to be ignored")
+	}
+	failure.Resolve(errSimulated)
+	failure.Resolve(errSimulated)
+	if c := cb.Counts(); c.ConsecutiveFailures != 1 || c.Successes != 1 {
+		t.Errorf("expected one late success and one late failure, got %+v", c)
+	}
+	if st := cb.Status(); st.InFlightCost != 0 {
+		t.Errorf("expected resolved calls to be released, got %d in flight", st.InFlightCost)
+	}
+}
+
+func TestExecuteDeferred_ImmediateFailureIsRecordedAtOnce(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	_, p, err := cb.ExecuteDeferred(failFn)
+	if p != nil || !errors.Is(err, errSimulated) {
+		t.Fatalf("expected no pending outcome for a failed call, got %v, %v", p, err)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the failure counted straight away, got %v", cb.State())
+	}
+	if _, p, err = cb.ExecuteDeferred(successFn); p != nil || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected a rejection, got %v, %v", p, err)
+	}
+	if p.Resolve(nil) {
+		t.Error("expected resolving a nil outcome to do nothing")
+	}
+}
+
+func TestExecuteDeferred_Expiry(t *testing.T) {
+	for _, succeeds := range []bool{false, true} {
+		clock := cbt.NewFakeClock(cbt.Epoch)
+		var recorded error
+		cb := circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold:       1,
+			PendingTimeout:         30 * time.Second,
+			PendingTimeoutSucceeds: succeeds,
+			Clock:                  clock,
+			Strict:                 true,
+			OnCall:                 func(r circuitbreaker.CallRecord) { recorded = r.Err },
+		})
+		_, p, _ := cb.ExecuteDeferred(successFn)
+		clock.Advance(29 * time.Second)
+		if cb.State() != circuitbreaker.Closed || recorded != nil {
+			t.Fatalf("succeeds=%v: resolved before the timeout", succeeds)
+		}
+		clock.Advance(time.Second)
+		if p.Resolve(nil) {
+			t.Errorf("succeeds=%v: expected the timeout to have resolved the call", succeeds)
+		}
+		if succeeds {
+			if cb.State() != circuitbreaker.Closed || cb.Counts().Successes != 1 {
+				t.Errorf("expected the expired call counted as a success, got %v %+v", cb.State(), cb.Counts())
+			}
+		} else if cb.State() != circuitbreaker.Open || !errors.Is(recorded, circuitbreaker.ErrPendingTimeout) {
+			t.Errorf("expected the expired call counted as a failure, got %v (%v)", cb.State(), recorded)
+		}
+	}
+}
+
+func TestExecuteDeferred_HoldsHalfOpenProbeSlot(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	_, probe, err := cb.ExecuteDeferred(successFn)
+	if err != nil {
+		t.Fatalf("expected the probe admitted, got %v", err)
+	}
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+		t.Errorf("expected the pending probe to hold the only slot, got %v", err)
+	}
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected to stay half-open until the probe resolves, got %v", cb.State())
+	}
+	probe.Resolve(nil)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the late success to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestExecuteDeferred_CloseStopsExpiry(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: clock, Strict: true})
+	_, p, _ := cb.ExecuteDeferred(successFn)
+	cb.Close()
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop the expiry timer, got %d pending timers", n)
+	}
+	if !p.Resolve(nil) {
+		t.Error("expected the outcome still resolvable after Close")
+	}
+}
@@ -0,0 +1,200 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Operation is a call run through a Pipeline.
+type Operation func(ctx context.Context) (any, error)
+
+// Policy is one resilience concern wrapped around an Operation: it
+// returns an Operation that runs next in its own way, such as under a
+// deadline or through a breaker.
+type Policy func(next Operation) Operation
+
+// Executor runs calls through a fixed set of policies. Build one with
+// Pipeline or DefaultPipeline; it is safe for concurrent use.
+type Executor struct {
+	policies []Policy
+}
+
+// Pipeline composes policies into an Executor. Each policy wraps the ones
+// before it, so the list reads from the call outwards:
+//
+//	Pipeline(Timeout(time.Second), Breaker(cb), Retry(policy))
+//
+// gives every attempt its own one-second deadline, shows each attempt to
+// the breaker, and retries around both. Swapping Timeout and Retry would
+// instead make one deadline cover all attempts and the waits between
+// them. Nil policies are skipped.
+func Pipeline(policies ...Policy) *Executor {
+	e := &Executor{}
+	for _, p := range policies {
+		if p != nil {
+			e.policies = append(e.policies, p)
+		}
+	}
+	return e
+}
+
+// Execute runs fn through the executor's policies. A nil Executor runs fn
+// directly.
+func (e *Executor) Execute(ctx context.Context, fn Operation) (any, error) {
+	if fn == nil {
+		return nil, ErrNilFunction
+	}
+	if e == nil {
+		return fn(ctx)
+	}
+	for _, p := range e.policies {
+		fn = p(fn)
+	}
+	return fn(ctx)
+}
+
+// PipelineConfig holds the settings of DefaultPipeline. Each zero field
+// leaves its policy out.
+type PipelineConfig struct {
+	// Timeout bounds each attempt; it does not cover retries or the waits
+	// between them.
+	Timeout time.Duration
+	// MaxConcurrent bounds the attempts in flight through the pipeline.
+	MaxConcurrent int
+	// Breaker sees every attempt, so a failing call can trip it before
+	// its retries run out.
+	Breaker *CircuitBreaker
+	// Retry retries failed attempts; retrying stops when Breaker or the
+	// bulkhead rejects one.
+	Retry RetryPolicy
+	// Fallback handles the final error, after any retries.
+	Fallback func(ctx context.Context, err error) (any, error)
+}
+
+// DefaultPipeline returns the recommended ordering of the policies in cfg:
+//
+//	Pipeline(Timeout, Bulkhead, Breaker, Retry, Fallback)
+//
+// Each attempt runs under its own timeout inside the bulkhead and through
+// the breaker, failed attempts are retried, and the fallback sees only
+// what is left once retrying is over.
+func DefaultPipeline(cfg PipelineConfig) *Executor {
+	var policies []Policy
+	if cfg.Timeout > 0 {
+		policies = append(policies, Timeout(cfg.Timeout))
+	}
+	if cfg.MaxConcurrent > 0 {
+		policies = append(policies, Bulkhead(cfg.MaxConcurrent))
+	}
+	if cfg.Breaker != nil {
+		policies = append(policies, Breaker(cfg.Breaker))
+	}
+	if cfg.Retry.MaxAttempts > 1 {
+		policies = append(policies, Retry(cfg.Retry))
+	}
+	if cfg.Fallback != nil {
+		policies = append(policies, Fallback(cfg.Fallback))
+	}
+	return Pipeline(policies...)
+}
+
+// Timeout runs each call with a context that expires after d. The call
+// must watch its context; Timeout does not abandon it. A d of zero or
+// less leaves the context alone.
+func Timeout(d time.Duration) Policy {
+	return func(next Operation) Operation {
+		return func(ctx context.Context) (any, error) {
+			if d <= 0 {
+				return next(ctx)
+			}
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx)
+		}
+	}
+}
+
+// Bulkhead allows at most n calls in flight and rejects the rest with a
+// *BulkheadFullError, without waiting. It is independent of any breaker's
+// Config.MaxConcurrent. An n of zero or less means no limit.
+func Bulkhead(n int) Policy {
+	if n <= 0 {
+		return func(next Operation) Operation { return next }
+	}
+	slots := make(chan struct{}, n)
+	return func(next Operation) Operation {
+		return func(ctx context.Context) (any, error) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				return nil, &BulkheadFullError{Cost: 1, Headroom: 0, Limit: n}
+			}
+			defer func() { <-slots }()
+			return next(ctx)
+		}
+	}
+}
+
+// Breaker runs each call through cb with opts. Calls made by an enclosing
+// Retry after its first attempt are also marked AsRetry.
+func Breaker(cb Guard, opts ...CallOption) Policy {
+	if cb == nil {
+		// lets every call through, as a nil *CircuitBreaker does.
+		cb = (*CircuitBreaker)(nil)
+	}
+	return func(next Operation) Operation {
+		return func(ctx context.Context) (any, error) {
+			callOpts := opts
+			if n, _ := ctx.Value(retryAttemptKey{}).(int); n > 1 {
+				callOpts = append(append([]CallOption(nil), opts...), AsRetry())
+			}
+			return cb.ExecuteContext(ctx, next, callOpts...)
+		}
+	}
+}
+
+// Retry retries failed calls as described by policy, with the same rules
+// as ExecuteWithRetry: retrying stops when an attempt is rejected by a
+// breaker or bulkhead, and backoff waits end early when ctx is done.
+// Backoff is timed on the system clock and jittered from a securely
+// seeded source.
+func Retry(policy RetryPolicy) Policy {
+	return func(next Operation) Operation {
+		return func(ctx context.Context) (any, error) {
+			return retry(ctx, systemClock{}, globalRand{}, policy, func(n int) (any, error) {
+				return next(context.WithValue(ctx, retryAttemptKey{}, n))
+			})
+		}
+	}
+}
+
+// retryAttemptKey carries the attempt number Retry is on to the policies
+// it wraps.
+type retryAttemptKey struct{}
+
+// Fallback replaces the error of a failed call with what fn returns for
+// it. fn can tell rejections apart with errors.Is, for example against
+// ErrCircuitOpen. If fn panics, the call's own error is returned, with the
+// panic noted in its message.
+func Fallback(fn func(ctx context.Context, err error) (any, error)) Policy {
+	return func(next Operation) Operation {
+		return func(ctx context.Context) (any, error) {
+			result, err := next(ctx)
+			if err == nil || fn == nil {
+				return result, err
+			}
+			return fallback(ctx, fn, err)
+		}
+	}
+}
+
+// fallback calls fn for err, recovering a panic in it.
+func fallback(ctx context.Context, fn func(ctx context.Context, err error) (any, error), err error) (result any, fallbackErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, fallbackErr = nil, fmt.Errorf("%w (fallback panicked: %v)", err, r)
+		}
+	}()
+	return fn(ctx, err)
+}
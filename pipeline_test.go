@@ -0,0 +1,184 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// blockUntilDone is an operation that counts its runs and only returns
+// once its context is done.
+func blockUntilDone(runs *int) circuitbreaker.Operation {
+	return func(ctx context.Context) (any, error) {
+		*runs++
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+}
+
+func failOp(runs *int) circuitbreaker.Operation {
+	return func(context.Context) (any, error) {
+		*runs++
+		return nil, errSimulated
+	}
+}
+
+func TestPipeline_TimeoutInsideOrOutsideRetry(t *testing.T) {
+	retry := circuitbreaker.Retry(circuitbreaker.RetryPolicy{MaxAttempts: 3})
+	timeout := circuitbreaker.Timeout(10 * time.Millisecond)
+
+	var perAttempt int
+	_, err := circuitbreaker.Pipeline(timeout, retry).Execute(context.Background(), blockUntilDone(&perAttempt))
+	if perAttempt != 3 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout inside retry: expected 3 attempts each timing out, got %d (%v)", perAttempt, err)
+	}
+
+	var overall int
+	_, err = circuitbreaker.Pipeline(retry, timeout).Execute(context.Background(), blockUntilDone(&overall))
+	if overall != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("timeout outside retry: expected one deadline to end all attempts, got %d (%v)", overall, err)
+	}
+}
+
+func TestPipeline_BreakerInsideOrOutsideRetry(t *testing.T) {
+	newBreaker := func() *circuitbreaker.CircuitBreaker {
+		return circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	}
+	retry := circuitbreaker.Retry(circuitbreaker.RetryPolicy{MaxAttempts: 3})
+
+	perAttempt := newBreaker()
+	var runs int
+	circuitbreaker.Pipeline(circuitbreaker.Breaker(perAttempt), retry).Execute(context.Background(), failOp(&runs))
+	if runs != 3 || perAttempt.State() != circuitbreaker.Open {
+		t.Errorf("breaker inside retry: expected each attempt to count and trip it, got %d runs, %v", runs, perAttempt.State())
+	}
+
+	perCall := newBreaker()
+	runs = 0
+	circuitbreaker.Pipeline(retry, circuitbreaker.Breaker(perCall)).Execute(context.Background(), failOp(&runs))
+	if runs != 3 || perCall.State() != circuitbreaker.Closed || perCall.Counts().ConsecutiveFailures != 1 {
+		t.Errorf("breaker outside retry: expected one failure for the whole call, got %d runs, %v, %+v", runs, perCall.State(), perCall.Counts())
+	}
+}
+
+func TestPipeline_FallbackInsideOrOutsideBreaker(t *testing.T) {
+	fallback := circuitbreaker.Fallback(func(_ context.Context, err error) (any, error) {
+		return "cached", nil
+	})
+	newBreaker := func() *circuitbreaker.CircuitBreaker {
+		return circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	}
+	var runs int
+
+	// inside, the fallback hides failures from the breaker.
+	hidden := newBreaker()
+	for i := 0; i < 3; i++ {
+		circuitbreaker.Pipeline(fallback, circuitbreaker.Breaker(hidden)).Execute(context.Background(), failOp(&runs))
+	}
+	if hidden.State() != circuitbreaker.Closed {
+		t.Errorf("fallback inside the breaker: expected it never to trip, got %v", hidden.State())
+	}
+
+	// outside, the breaker trips and the fallback answers for it.
+	seen := newBreaker()
+	runs = 0
+	for i := 0; i < 3; i++ {
+		result, err := circuitbreaker.Pipeline(circuitbreaker.Breaker(seen), fallback).Execute(context.Background(), failOp(&runs))
+		if result != "cached" || err != nil {
+			t.Errorf("call %d: expected the fallback, got %v, %v", i, result, err)
+		}
+	}
+	if seen.State() != circuitbreaker.Open || runs != 1 {
+		t.Errorf("fallback outside the breaker: expected it to trip after one run, got %v after %d runs", seen.State(), runs)
+	}
+}
+
+func TestDefaultPipeline_MatchesDocumentedOrdering(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	var fallbackErr error
+	exec := circuitbreaker.DefaultPipeline(circuitbreaker.PipelineConfig{
+		Timeout: 10 * time.Millisecond,
+		Breaker: cb,
+		Retry:   circuitbreaker.RetryPolicy{MaxAttempts: 5},
+		Fallback: func(_ context.Context, err error) (any, error) {
+			fallbackErr = err
+			return "fallback", nil
+		},
+	})
+
+	var runs int
+	result, err := exec.Execute(context.Background(), blockUntilDone(&runs))
+	// each attempt times out on its own and counts with the breaker, which
+	// trips on the second and turns the third away; the fallback then gets
+	// the last attempt's error.
+	if runs != 2 {
+		t.Errorf("expected the breaker to stop retries after 2 attempts, got %d", runs)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected the breaker to see every attempt, got %v", cb.State())
+	}
+	if result != "fallback" || err != nil || !errors.Is(fallbackErr, context.DeadlineExceeded) {
+		t.Errorf("expected the fallback to handle the final timeout, got %v, %v (fallback saw %v)", result, err, fallbackErr)
+	}
+}
+
+func TestPipeline_RetriesThroughBreakerDrawOnBudget(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:     100,
+		RetryBudgetRatio:     0.1,
+		RetryBudgetMinTokens: 1,
+		RetryBudgetMaxTokens: 1,
+		Clock:                cbt.NewFakeClock(cbt.Epoch),
+		Strict:               true,
+	})
+	var runs int
+	_, err := circuitbreaker.Pipeline(circuitbreaker.Breaker(cb), circuitbreaker.Retry(circuitbreaker.RetryPolicy{MaxAttempts: 5})).
+		Execute(context.Background(), failOp(&runs))
+	if runs != 2 || !errors.Is(err, errSimulated) {
+		t.Errorf("expected one budgeted retry, got %d runs (%v)", runs, err)
+	}
+}
+
+func TestBulkheadPolicy_RejectsWhenFull(t *testing.T) {
+	exec := circuitbreaker.Pipeline(circuitbreaker.Bulkhead(1))
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		exec.Execute(context.Background(), func(context.Context) (any, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+	}()
+	<-started
+
+	_, err := exec.Execute(context.Background(), func(context.Context) (any, error) { return nil, nil })
+	if !errors.Is(err, circuitbreaker.ErrBulkheadFull) {
+		t.Errorf("expected ErrBulkheadFull, got %v", err)
+	}
+	close(release)
+	wg.Wait()
+	if _, err := exec.Execute(context.Background(), func(context.Context) (any, error) { return nil, nil }); err != nil {
+		t.Errorf("expected the slot back after the call finished, got %v", err)
+	}
+}
+
+func TestPipeline_NilHandling(t *testing.T) {
+	if _, err := circuitbreaker.Pipeline().Execute(context.Background(), nil); !errors.Is(err, circuitbreaker.ErrNilFunction) {
+		t.Errorf("expected ErrNilFunction, got %v", err)
+	}
+	var exec *circuitbreaker.Executor
+	if result, err := exec.Execute(context.Background(), func(context.Context) (any, error) { return 1, nil }); result != 1 || err != nil {
+		t.Errorf("expected a nil executor to run the call, got %v, %v", result, err)
+	}
+	if result, err := circuitbreaker.Pipeline(nil, circuitbreaker.Breaker(nil)).Execute(context.Background(), func(context.Context) (any, error) { return 2, nil }); result != 2 || err != nil {
+		t.Errorf("expected nil policies and breakers to pass the call through, got %v, %v", result, err)
+	}
+}
@@ -0,0 +1,171 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// ErrUnderPressure is returned instead of running a call while the process
+// itself is under pressure (see Config.Pressure) and
+// Config.PressureMaxConcurrent is not set.
+var ErrUnderPressure = errors.New("circuit breaker: shedding load under local pressure")
+
+// PressureReading is a sample of how hard the local process is struggling.
+type PressureReading struct {
+	// GCPause is the longest garbage collection pause since the previous
+	// reading.
+	GCPause time.Duration
+	// HeapFraction is the memory in use as a fraction of the memory limit,
+	// or zero when there is no limit.
+	HeapFraction float64
+	// Goroutines is the number of live goroutines.
+	Goroutines int
+}
+
+// PressureSource supplies readings for Config.Pressure. Sample is called
+// from a timer, never with the breaker locked.
+type PressureSource interface {
+	Sample() PressureReading
+}
+
+// RuntimePressure is a PressureSource that reads the Go runtime's own
+// metrics. Its zero value is not usable; create one with
+// NewRuntimePressure. One RuntimePressure can serve many breakers, though
+// each Sample then only sees the GC pauses since the previous one from any
+// breaker.
+type RuntimePressure struct {
+	mu      sync.Mutex
+	samples []metrics.Sample
+	// pauses are the GC pause histogram counts of the previous reading.
+	pauses []uint64
+}
+
+// Names of the runtime metrics RuntimePressure reads, in samples order.
+const (
+	metricGCPauses   = "/sched/pauses/total/gc:seconds"
+	metricGoroutines = "/sched/goroutines:goroutines"
+	metricMemTotal   = "/memory/classes/total:bytes"
+	metricMemFreed   = "/memory/classes/heap/released:bytes"
+	metricMemLimit   = "/gc/gomemlimit:bytes"
+)
+
+// NewRuntimePressure returns a PressureSource backed by runtime/metrics.
+// HeapFraction is measured against the limit set with GOMEMLIMIT or
+// debug.SetMemoryLimit.
+func NewRuntimePressure() *RuntimePressure {
+	p := &RuntimePressure{}
+	for _, name := range []string{metricGCPauses, metricGoroutines, metricMemTotal, metricMemFreed, metricMemLimit} {
+		p.samples = append(p.samples, metrics.Sample{Name: name})
+	}
+	// start the pause history from now.
+	p.Sample()
+	return p
+}
+
+// Sample reads the runtime metrics.
+func (p *RuntimePressure) Sample() PressureReading {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics.Read(p.samples)
+	var r PressureReading
+	if v := p.samples[0].Value; v.Kind() == metrics.KindFloat64Histogram {
+		h := v.Float64Histogram()
+		for i := len(h.Counts) - 1; i >= 0; i-- {
+			if i < len(p.pauses) && h.Counts[i] > p.pauses[i] {
+				// the bucket's upper bound, unless that is infinite.
+				bound := h.Buckets[i+1]
+				if math.IsInf(bound, 1) {
+					bound = h.Buckets[i]
+				}
+				r.GCPause = time.Duration(bound * float64(time.Second))
+				break
+			}
+		}
+		p.pauses = append(p.pauses[:0], h.Counts...)
+	}
+	if v := p.samples[1].Value; v.Kind() == metrics.KindUint64 {
+		r.Goroutines = int(v.Uint64())
+	}
+	total, freed, limit := p.samples[2].Value, p.samples[3].Value, p.samples[4].Value
+	if limit.Kind() == metrics.KindUint64 && limit.Uint64() < math.MaxInt64 && limit.Uint64() > 0 &&
+		total.Kind() == metrics.KindUint64 && freed.Kind() == metrics.KindUint64 {
+		r.HeapFraction = float64(total.Uint64()-freed.Uint64()) / float64(limit.Uint64())
+	}
+	return r
+}
+
+// pressure is the breaker's view of its PressureSource.
+type pressure struct {
+	reading PressureReading
+	// active is set while the latest reading is over a threshold.
+	active bool
+	timer  Timer
+}
+
+// startPressure arms the first pressure sample. Called once from lazyInit.
+func (cb *CircuitBreaker) startPressure() {
+	if cb.config.Pressure == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.pressure.timer = cb.clock.AfterFunc(cb.config.PressureInterval, cb.onPressureTimer)
+}
+
+// onPressureTimer takes a sample, applies it and arms the next one. A
+// sample that panics is skipped.
+func (cb *CircuitBreaker) onPressureTimer() {
+	var reading PressureReading
+	sampled := cb.protect("Pressure", func() { reading = cb.config.Pressure.Sample() })
+	cb.mu.Lock()
+	defer cb.unlock()
+	if cb.closed {
+		return
+	}
+	if sampled {
+		cb.applyPressure(reading)
+	}
+	cb.pressure.timer = cb.clock.AfterFunc(cb.config.PressureInterval, cb.onPressureTimer)
+}
+
+// applyPressure records reading and starts or stops shedding. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) applyPressure(reading PressureReading) {
+	c := &cb.config
+	active := (c.MaxGCPause > 0 && reading.GCPause > c.MaxGCPause) ||
+		(c.MaxHeapFraction > 0 && reading.HeapFraction > c.MaxHeapFraction) ||
+		(c.MaxGoroutines > 0 && reading.Goroutines > c.MaxGoroutines)
+	cb.pressure.reading = reading
+	if active == cb.pressure.active {
+		return
+	}
+	cb.pressure.active = active
+	ev := Event{Type: EventPressureStart, Time: cb.clock.Now(), From: cb.state, To: cb.state}
+	if !active {
+		ev.Type = EventPressureEnd
+		// calls turned away under pressure may now fit.
+		cb.signalFreed()
+	}
+	cb.emit(ev)
+}
+
+// shedding reports whether calls are turned away outright under pressure.
+// Must be called with cb.mu held.
+func (cb *CircuitBreaker) shedding() bool {
+	return cb.pressure.active && cb.config.PressureMaxConcurrent <= 0
+}
+
+// bulkheadLimit is the bulkhead limit in effect, lowered to
+// Config.PressureMaxConcurrent under pressure. Zero means unlimited. Must
+// be called with cb.mu held.
+func (cb *CircuitBreaker) bulkheadLimit() int {
+	limit := cb.config.MaxConcurrent
+	if p := cb.config.PressureMaxConcurrent; cb.pressure.active && p > 0 && (limit <= 0 || p < limit) {
+		limit = p
+	}
+	return limit
+}
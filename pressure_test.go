@@ -0,0 +1,114 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// fakePressure returns whatever reading it was last given.
+type fakePressure struct {
+	mu      sync.Mutex
+	reading circuitbreaker.PressureReading
+}
+
+func (p *fakePressure) set(r circuitbreaker.PressureReading) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reading = r
+}
+
+func (p *fakePressure) Sample() circuitbreaker.PressureReading {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reading
+}
+
+func TestPressure_ShedsWhileOverThreshold(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	source := &fakePressure{}
+	var events []circuitbreaker.EventType
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Pressure:         source,
+		PressureInterval: time.Second,
+		MaxGCPause:       200 * time.Millisecond,
+		MaxGoroutines:    10000,
+		Clock:            clock,
+		Strict:           true,
+		OnEvent:          func(ev circuitbreaker.Event) { events = append(events, ev.Type) },
+	})
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Fatalf("expected calls through before any pressure, got %v", err)
+	}
+
+	source.set(circuitbreaker.PressureReading{GCPause: 300 * time.Millisecond, Goroutines: 50})
+	clock.Advance(time.Second)
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrUnderPressure) {
+		t.Fatalf("expected the call shed, got %v", err)
+	}
+	st := cb.Status()
+	if !st.UnderPressure || st.Pressure.GCPause != 300*time.Millisecond {
+		t.Errorf("expected the pressure in Status, got %v %+v", st.UnderPressure, st.Pressure)
+	}
+	if st.State != circuitbreaker.Closed || counters(st.Counts) != (circuitbreaker.Counts{ConsecutiveSuccesses: 1, Successes: 1}) {
+		t.Errorf("expected shedding to leave the circuit alone, got %v %+v", st.State, st.Counts)
+	}
+
+	source.set(circuitbreaker.PressureReading{GCPause: time.Millisecond, Goroutines: 50})
+	clock.Advance(time.Second)
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Errorf("expected calls through once the pressure eased, got %v", err)
+	}
+	if len(events) != 2 || events[0] != circuitbreaker.EventPressureStart || events[1] != circuitbreaker.EventPressureEnd {
+		t.Errorf("expected start and end events, got %v", events)
+	}
+
+	cb.Close()
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop sampling, got %d pending timers", n)
+	}
+}
+
+func TestPressure_TightensBulkhead(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	source := &fakePressure{}
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Pressure:              source,
+		MaxHeapFraction:       0.9,
+		MaxConcurrent:         4,
+		PressureMaxConcurrent: 1,
+		Clock:                 clock,
+		Strict:                true,
+	})
+	source.set(circuitbreaker.PressureReading{HeapFraction: 0.95})
+	clock.Advance(time.Second)
+
+	_, err := cb.Execute(func() (any, error) {
+		_, err := cb.Execute(successFn)
+		return nil, err
+	})
+	var full *circuitbreaker.BulkheadFullError
+	if !errors.As(err, &full) || full.Limit != 1 {
+		t.Fatalf("expected the bulkhead lowered to 1, got %v", err)
+	}
+	if st := cb.Status(); st.MaxConcurrent != 1 {
+		t.Errorf("expected Status to report the lowered limit, got %d", st.MaxConcurrent)
+	}
+
+	source.set(circuitbreaker.PressureReading{HeapFraction: 0.5})
+	clock.Advance(time.Second)
+	if st := cb.Status(); st.MaxConcurrent != 4 || st.UnderPressure {
+		t.Errorf("expected the configured limit back, got %d", st.MaxConcurrent)
+	}
+}
+
+func TestRuntimePressure_Reads(t *testing.T) {
+	r := circuitbreaker.NewRuntimePressure().Sample()
+	if r.Goroutines < 1 {
+		t.Errorf("expected at least one goroutine, got %+v", r)
+	}
+}
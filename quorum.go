@@ -0,0 +1,169 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// QuorumStore is where the instances of a breaker in different processes
+// share their trips; see Config.QuorumStore. Breakers are identified by
+// Config.Name and instances by Config.InstanceID. Implementations must be
+// safe for concurrent use. MemoryQuorumStore keeps them in process, and
+// the cbredis package in Redis.
+type QuorumStore interface {
+	// ReportBreach records that instance's breaker tripped, for ttl.
+	ReportBreach(ctx context.Context, breaker, instance string, ttl time.Duration) error
+	// Breaches returns the number of distinct instances with an
+	// unexpired breach of breaker.
+	Breaches(ctx context.Context, breaker string) (int, error)
+}
+
+// MemoryQuorumStore is a QuorumStore for breakers in the same process,
+// mostly useful in tests.
+type MemoryQuorumStore struct {
+	clock Clock
+	mu    sync.Mutex
+	// expiry of each instance's breach, by breaker.
+	breaches map[string]map[string]time.Time
+}
+
+// NewMemoryQuorumStore returns an empty store that expires breaches by
+// clock, or by the system clock when clock is nil.
+func NewMemoryQuorumStore(clock Clock) *MemoryQuorumStore {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &MemoryQuorumStore{clock: clock, breaches: map[string]map[string]time.Time{}}
+}
+
+// ReportBreach implements QuorumStore.
+func (s *MemoryQuorumStore) ReportBreach(_ context.Context, breaker, instance string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.breaches[breaker] == nil {
+		s.breaches[breaker] = map[string]time.Time{}
+	}
+	if until := s.clock.Now().Add(ttl); until.After(s.breaches[breaker][instance]) {
+		s.breaches[breaker][instance] = until
+	}
+	return nil
+}
+
+// Breaches implements QuorumStore.
+func (s *MemoryQuorumStore) Breaches(_ context.Context, breaker string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	n := 0
+	for instance, until := range s.breaches[breaker] {
+		if now.Before(until) {
+			n++
+		} else {
+			delete(s.breaches[breaker], instance)
+		}
+	}
+	return n, nil
+}
+
+// errQuorumStorePanicked is passed to Config.OnQuorumError when the store
+// panics.
+var errQuorumStorePanicked = errors.New("circuit breaker: quorum store panicked")
+
+// quorum is the breaker's side of Config.QuorumStore.
+type quorum struct {
+	// breach is when the circuit last tripped on its own calls, and
+	// reported the breach last passed to the store.
+	breach   time.Time
+	reported time.Time
+	// breaches is the latest count read from the store.
+	breaches int
+	timer    Timer
+}
+
+// localTrip reports whether a transition to Open for reason came from the
+// breaker's own calls, and so is worth reporting to the quorum.
+func localTrip(reason string) bool {
+	switch reason {
+	case ReasonFailures, ReasonLatency, ReasonFailureRate, ReasonSessionChurn, ReasonSpike, ReasonProbeFailed:
+		return true
+	}
+	return false
+}
+
+// defaultInstanceID identifies this process when Config.InstanceID is not
+// set.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// startQuorum arms the first exchange with the quorum store. Called once
+// from lazyInit.
+func (cb *CircuitBreaker) startQuorum() {
+	if cb.config.QuorumStore == nil || cb.config.Quorum <= 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.quorum.timer = cb.clock.AfterFunc(cb.config.QuorumInterval, cb.onQuorumTimer)
+}
+
+// onQuorumTimer reports a new breach, reads the fleet's count, opens the
+// circuit if it has reached Config.Quorum, and arms the next exchange. The
+// store is called without holding cb.mu, and a store that panics is
+// treated like one that cannot be reached.
+func (cb *CircuitBreaker) onQuorumTimer() {
+	cb.mu.Lock()
+	if cb.closed {
+		cb.unlock()
+		return
+	}
+	now := cb.clock.Now()
+	breach := cb.quorum.breach
+	report := breach.After(cb.quorum.reported)
+	cb.unlock()
+
+	c := &cb.config
+	ctx, cancel := context.WithTimeout(context.Background(), c.QuorumInterval)
+	defer cancel()
+	var err error
+	var breaches int
+	ok := cb.protect("QuorumStore", func() {
+		if ttl := c.QuorumWindow - elapsed(breach, now); report && ttl > 0 {
+			err = c.QuorumStore.ReportBreach(ctx, c.Name, c.InstanceID, ttl)
+		}
+		if err == nil {
+			breaches, err = c.QuorumStore.Breaches(ctx, c.Name)
+		}
+	})
+	if !ok {
+		err = errQuorumStorePanicked
+	}
+
+	cb.mu.Lock()
+	defer cb.unlock()
+	if cb.closed {
+		return
+	}
+	cb.quorum.timer = cb.clock.AfterFunc(c.QuorumInterval, cb.onQuorumTimer)
+	if err != nil {
+		if hook := c.OnQuorumError; hook != nil {
+			cb.queueHook("OnQuorumError", func() { hook(err) })
+		}
+		return
+	}
+	if report {
+		cb.quorum.reported = breach
+	}
+	cb.quorum.breaches = breaches
+	if breaches >= c.Quorum && cb.state == Closed && !cb.maintenance && !cb.ejected {
+		cb.setState(Open, ReasonQuorum)
+	}
+}
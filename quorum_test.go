@@ -0,0 +1,119 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newQuorumBreaker(clock *cbt.FakeClock, store circuitbreaker.QuorumStore, instance string, reasons *[]string) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:             "inventory",
+		FailureThreshold: 1,
+		Timeout:          10 * time.Second,
+		QuorumStore:      store,
+		Quorum:           2,
+		QuorumWindow:     time.Minute,
+		QuorumInterval:   5 * time.Second,
+		InstanceID:       instance,
+		Clock:            clock,
+		Strict:           true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if reasons != nil && ev.Type == circuitbreaker.EventStateChange && ev.To == circuitbreaker.Open {
+				*reasons = append(*reasons, ev.Reason)
+			}
+		},
+	})
+}
+
+func TestQuorum_OpensFleetOnlyAtQuorum(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	store := circuitbreaker.NewMemoryQuorumStore(clock)
+	var reasons []string
+	a := newQuorumBreaker(clock, store, "a", nil)
+	b := newQuorumBreaker(clock, store, "b", nil)
+	c := newQuorumBreaker(clock, store, "c", &reasons)
+
+	// one bad pod trips on its own but the others stay closed.
+	a.Execute(failFn)
+	clock.Advance(5 * time.Second)
+	if a.State() != circuitbreaker.Open || c.State() != circuitbreaker.Closed {
+		t.Fatalf("expected only a open, got a=%v c=%v", a.State(), c.State())
+	}
+	if got := c.Status().QuorumBreaches; got != 1 {
+		t.Errorf("expected one breach in the store, got %d", got)
+	}
+
+	// a second instance makes the quorum and the rest follow.
+	b.Execute(failFn)
+	clock.Advance(5 * time.Second)
+	if c.State() != circuitbreaker.Open || len(reasons) != 1 || reasons[0] != circuitbreaker.ReasonQuorum {
+		t.Errorf("expected c opened by the quorum, got %v %v", c.State(), reasons)
+	}
+}
+
+func TestQuorum_BreachesExpire(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	store := circuitbreaker.NewMemoryQuorumStore(clock)
+	a := newQuorumBreaker(clock, store, "a", nil)
+	c := newQuorumBreaker(clock, store, "c", nil)
+
+	a.Execute(failFn)
+	clock.Advance(5 * time.Second)
+	// a goes away; its breach still counts for the rest of the window.
+	a.Close()
+	if n, _ := store.Breaches(context.Background(), "inventory"); n != 1 {
+		t.Fatalf("expected a's breach recorded, got %d", n)
+	}
+
+	clock.Advance(time.Minute)
+	if n, _ := store.Breaches(context.Background(), "inventory"); n != 0 {
+		t.Errorf("expected the breach to expire, got %d", n)
+	}
+	b := newQuorumBreaker(clock, store, "b", nil)
+	b.Execute(failFn)
+	clock.Advance(5 * time.Second)
+	if c.State() != circuitbreaker.Closed {
+		t.Errorf("expected an expired breach not to count towards the quorum, got %v", c.State())
+	}
+}
+
+// failingStore is a QuorumStore that is down.
+type failingStore struct{}
+
+func (failingStore) ReportBreach(context.Context, string, string, time.Duration) error {
+	return errSimulated
+}
+
+func (failingStore) Breaches(context.Context, string) (int, error) { return 0, errSimulated }
+
+func TestQuorum_StoreErrorsLeaveCircuitAlone(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var errs []error
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		QuorumStore:   failingStore{},
+		Quorum:        1,
+		InstanceID:    "a",
+		OnQuorumError: func(err error) { errs = append(errs, err) },
+		Clock:         clock,
+		Strict:        true,
+	})
+	clock.Advance(10 * time.Second)
+	if cb.State() != circuitbreaker.Closed || len(errs) != 2 || !errors.Is(errs[0], errSimulated) {
+		t.Errorf("expected two reported errors and a closed circuit, got %v %v", cb.State(), errs)
+	}
+	cb.Close()
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop the exchanges, got %d pending timers", n)
+	}
+}
+
+func TestMemoryQuorumStore_Conformance(t *testing.T) {
+	cbt.RunQuorumStoreConformance(t, func(clock circuitbreaker.Clock) circuitbreaker.QuorumStore {
+		return circuitbreaker.NewMemoryQuorumStore(clock)
+	})
+}
@@ -0,0 +1,82 @@
+package circuitbreaker
+
+import "time"
+
+// RampStep is one step of a gradual recovery; see Config.RampUp.
+type RampStep struct {
+	// Percent is the share of calls admitted during the step, in (0, 100].
+	Percent float64
+	// Duration is how long the step lasts.
+	Duration time.Duration
+}
+
+// rampState counts the outcomes of the calls admitted during a ramp.
+type rampState struct {
+	calls    int
+	failures int
+}
+
+// ramping reports whether the half-open circuit is ramping up rather than
+// admitting probes. Must be called with cb.mu held.
+func (cb *CircuitBreaker) ramping() bool {
+	return cb.state == HalfOpen && len(cb.config.RampUp) > 0
+}
+
+// rampPercent returns the share of calls the ramp admits at now, and
+// whether the ramp is over. Steps are measured from when the circuit went
+// half-open. Must be called with cb.mu held.
+func (cb *CircuitBreaker) rampPercent(now time.Time) (percent float64, over bool) {
+	at := cb.sinceStateChange(now)
+	for _, step := range cb.config.RampUp {
+		if at < step.Duration {
+			return step.Percent, false
+		}
+		at -= step.Duration
+	}
+	return 100, true
+}
+
+// rampAdmit decides whether a call is let through by the ramp, closing the
+// circuit once the last step is over. Must be called with cb.mu held.
+func (cb *CircuitBreaker) rampAdmit(o callOptions) error {
+	percent, over := cb.rampPercent(cb.clock.Now())
+	if over {
+		cb.setState(Closed, ReasonRecovered)
+		return nil
+	}
+	if o.noProbe || cb.rand.Float64()*100 >= percent {
+		return cb.openError()
+	}
+	return nil
+}
+
+// rampRecord counts the outcome of a call admitted by the ramp, reopening
+// the circuit when the failure rate reaches Config.RampFailureRate and
+// closing it once the ramp is over. Must be called with cb.mu held.
+func (cb *CircuitBreaker) rampRecord(err error) {
+	r := &cb.ramp
+	r.calls++
+	if err != nil {
+		r.failures++
+	}
+	if r.calls >= cb.config.RampMinRequests &&
+		float64(r.failures)/float64(r.calls) >= cb.config.RampFailureRate {
+		cb.setState(Open, ReasonFailureRate)
+		return
+	}
+	if _, over := cb.rampPercent(cb.clock.Now()); over {
+		cb.setState(Closed, ReasonRecovered)
+		return
+	}
+	cb.checkInvariants(cb.state)
+}
+
+// currentRampPercent is the share of calls admitted right now by a ramp in
+// progress, and zero when none is. Must be called with cb.mu held.
+func (cb *CircuitBreaker) currentRampPercent(now time.Time) float64 {
+	if !cb.ramping() || cb.mode != Automatic || cb.maintenance || cb.ejected {
+		return 0
+	}
+	percent, _ := cb.rampPercent(now)
+	return percent
+}
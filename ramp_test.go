@@ -0,0 +1,95 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestRampUp_AdmitsGrowingShareThenCloses(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Minute,
+		RampUp: []circuitbreaker.RampStep{
+			{Percent: 10, Duration: time.Minute},
+			{Percent: 50, Duration: time.Minute},
+		},
+		Rand:   cbt.NewScriptedRand(0.05, 0.2, 0.3, 0.6),
+		Clock:  clock,
+		Strict: true,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	steps := []struct {
+		percent float64
+		admit   []bool
+	}{
+		{10, []bool{true, false}},
+		{50, []bool{true, false}},
+	}
+	for _, step := range steps {
+		for i, admit := range step.admit {
+			_, err := cb.Execute(successFn)
+			if admit && err != nil || !admit && !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+				t.Fatalf("at %v%%, call %d: expected admitted %v, got %v", step.percent, i, admit, err)
+			}
+		}
+		if s := cb.Status(); s.State != circuitbreaker.HalfOpen || s.RampPercent != step.percent {
+			t.Fatalf("expected a ramp at %v%%, got %v at %v%%", step.percent, s.State, s.RampPercent)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	if _, err := cb.Execute(successFn); err != nil {
+		t.Fatalf("expected the end of the ramp to admit the call, got %v", err)
+	}
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.RampPercent != 0 {
+		t.Fatalf("expected the circuit closed after the ramp, got %v at %v%%", s.State, s.RampPercent)
+	}
+}
+
+func TestRampUp_ReopensOnFailureRate(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Minute,
+		RampUp:           []circuitbreaker.RampStep{{Percent: 50, Duration: time.Hour}},
+		RampMinRequests:  4,
+		Rand:             cbt.NewScriptedRand(0),
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	cb.Execute(successFn)
+	if s := cb.Snapshot(); s.State != circuitbreaker.HalfOpen || s.RampPercent != 50 {
+		t.Fatalf("expected a single failure not to end the ramp, got %v at %v%%", s.State, s.RampPercent)
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected a 50%% failure rate to reopen the circuit, got %v", cb.State())
+	}
+}
+
+func TestRampUp_Validate(t *testing.T) {
+	for _, steps := range [][]circuitbreaker.RampStep{
+		{{Percent: 0, Duration: time.Minute}},
+		{{Percent: 101, Duration: time.Minute}},
+		{{Percent: 50}},
+	} {
+		if err := (circuitbreaker.Config{RampUp: steps}).Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", steps)
+		}
+	}
+	if err := (circuitbreaker.Config{RampFailureRate: 1.5}).Validate(); err == nil {
+		t.Error("expected a RampFailureRate above 1 to be rejected")
+	}
+}
@@ -0,0 +1,54 @@
+package circuitbreaker
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Rand is the source of randomness behind the breaker's probabilistic
+// decisions, such as retry jitter; see Config.Rand. It must be safe for
+// concurrent use, since calls run in parallel: a *rand.Rand from
+// math/rand/v2 has the right method but must be wrapped, for instance by
+// NewRand.
+type Rand interface {
+	// Float64 returns a number in [0.0, 1.0).
+	Float64() float64
+}
+
+// NewRand returns a Rand seeded with seed that is safe for concurrent
+// use, for reproducible runs. Breakers without a Config.Rand draw from a
+// securely seeded source instead.
+func NewRand(seed uint64) Rand {
+	return &lockedRand{r: rand.New(rand.NewPCG(seed, seed))}
+}
+
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+// globalRand draws from math/rand/v2's top-level source, which is safe for
+// concurrent use and seeded securely by the runtime.
+type globalRand struct{}
+
+func (globalRand) Float64() float64 { return rand.Float64() }
+
+// jitter spreads d uniformly over [d*(1-fraction), d] using r, so that
+// callers waiting the same d do not all wake together. fraction is
+// clamped to [0, 1].
+func jitter(d time.Duration, fraction float64, r Rand) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return d - time.Duration(float64(d)*fraction*r.Float64())
+}
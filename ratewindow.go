@@ -0,0 +1,86 @@
+package circuitbreaker
+
+import "time"
+
+// RateWindow is a sliding time window whose failure rate can vote to open
+// the circuit.
+type RateWindow struct {
+	// Duration is how far back the window looks.
+	Duration time.Duration
+	// FailureRateThreshold is the failure rate, between 0 and 1, at or
+	// above which the window votes to trip.
+	FailureRateThreshold float64
+	// MinRequests is the number of calls the window must hold before it
+	// can vote to trip.
+	MinRequests int
+}
+
+// WindowAgreement says how the votes of several RateWindows combine.
+type WindowAgreement int
+
+const (
+	// AllWindows trips only when every window is at or above its
+	// threshold. Pair a fast window for responsiveness with a slow one
+	// that filters out blips.
+	AllWindows WindowAgreement = iota
+	// AnyWindow trips as soon as one window is at or above its threshold.
+	AnyWindow
+)
+
+// rateTrip evaluates the configured FailureRateWindows after every call.
+type rateTrip struct {
+	windows   []RateWindow
+	buckets   []*bucketWindow
+	agreement WindowAgreement
+}
+
+// newRateTrip returns nil when no rate windows are configured.
+func newRateTrip(cfg Config) *rateTrip {
+	if len(cfg.FailureRateWindows) == 0 {
+		return nil
+	}
+	r := &rateTrip{
+		windows:   append([]RateWindow(nil), cfg.FailureRateWindows...),
+		agreement: cfg.WindowAgreement,
+	}
+	for _, w := range r.windows {
+		r.buckets = append(r.buckets, newBucketWindow(w.Duration, windowBuckets))
+	}
+	return r
+}
+
+// record adds an outcome to every window and reports whether they agree
+// the circuit should trip.
+func (r *rateTrip) record(now time.Time, failed bool) bool {
+	votes := 0
+	for i, w := range r.windows {
+		b := r.buckets[i]
+		b.add(now, failed)
+		total, failures := b.counts(now)
+		if total >= w.MinRequests && float64(failures)/float64(total) >= w.FailureRateThreshold {
+			votes++
+		}
+	}
+	if r.agreement == AnyWindow {
+		return votes > 0
+	}
+	return votes == len(r.windows)
+}
+
+// currentRates returns each window's failure rate as of now.
+func (r *rateTrip) currentRates(now time.Time) []float64 {
+	rates := make([]float64, len(r.buckets))
+	for i, b := range r.buckets {
+		if total, failures := b.counts(now); total > 0 {
+			rates[i] = float64(failures) / float64(total)
+		}
+	}
+	return rates
+}
+
+// reset empties every window.
+func (r *rateTrip) reset() {
+	for _, b := range r.buckets {
+		b.reset()
+	}
+}
@@ -0,0 +1,171 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newWindowTrace(agreement circuitbreaker.WindowAgreement) *trace {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	return &trace{
+		clock: clock,
+		cb: circuitbreaker.New(circuitbreaker.Config{
+			// high enough that only the windows trip.
+			FailureThreshold: 1000,
+			SuccessThreshold: 1,
+			Timeout:          time.Second,
+			FailureRateWindows: []circuitbreaker.RateWindow{
+				{Duration: 10 * time.Second, FailureRateThreshold: 0.5, MinRequests: 20},
+				{Duration: 2 * time.Minute, FailureRateThreshold: 0.5, MinRequests: 100},
+			},
+			WindowAgreement: agreement,
+			Clock:           clock,
+			Strict:          true,
+		}),
+	}
+}
+
+func TestRateWindows_BlipDoesNotTrip(t *testing.T) {
+	tr := newWindowTrace(circuitbreaker.AllWindows)
+	if _, tripped := tr.run(2*time.Minute, constant(0)); tripped {
+		t.Fatal("tripped on healthy traffic")
+	}
+	if _, tripped := tr.run(10*time.Second, constant(1)); tripped {
+		t.Fatalf("a 10s blip should not trip both windows, rates %v", tr.cb.Status().WindowFailureRates)
+	}
+	rates := tr.cb.Status().WindowFailureRates
+	if len(rates) != 2 || rates[0] < 0.5 || rates[1] >= 0.5 {
+		t.Errorf("expected only the fast window over threshold, got %v", rates)
+	}
+}
+
+func TestRateWindows_AnyWindowTripsOnBlip(t *testing.T) {
+	tr := newWindowTrace(circuitbreaker.AnyWindow)
+	tr.run(2*time.Minute, constant(0))
+	after, tripped := tr.run(10*time.Second, constant(1))
+	if !tripped {
+		t.Fatal("expected the fast window alone to trip")
+	}
+	if after > 6*time.Second {
+		t.Errorf("expected to trip once the fast window reached 50%%, took %s", after)
+	}
+}
+
+func TestRateWindows_SustainedFailuresTrip(t *testing.T) {
+	tr := newWindowTrace(circuitbreaker.AllWindows)
+	tr.run(2*time.Minute, constant(0))
+
+	after, tripped := tr.run(3*time.Minute, constant(0.6))
+	if !tripped {
+		t.Fatalf("expected sustained failures to trip, rates %v", tr.cb.Status().WindowFailureRates)
+	}
+	if after < 30*time.Second || after > 2*time.Minute {
+		t.Errorf("expected the slow window to gate the trip, took %s", after)
+	}
+}
+
+func TestRateWindows_ConsecutiveFailuresStillTrip(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		FailureRateWindows: []circuitbreaker.RateWindow{
+			{Duration: 10 * time.Second, FailureRateThreshold: 0.5, MinRequests: 20},
+		},
+		Clock:  cbt.NewFakeClock(cbt.Epoch),
+		Strict: true,
+	})
+	for range 3 {
+		cb.Execute(failFn)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected 3 failures in a row to trip before the window has 20 calls, got %v", cb.State())
+	}
+}
+
+func TestRateWindows_RecoveryClearsBothWindows(t *testing.T) {
+	var events []circuitbreaker.Event
+	tr := &trace{clock: cbt.NewFakeClock(cbt.Epoch)}
+	tr.cb = circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1000,
+		SuccessThreshold: 1,
+		Timeout:          time.Second,
+		FailureRateWindows: []circuitbreaker.RateWindow{
+			{Duration: 10 * time.Second, FailureRateThreshold: 0.5, MinRequests: 20},
+			{Duration: 2 * time.Minute, FailureRateThreshold: 0.5, MinRequests: 100},
+		},
+		Clock:   tr.clock,
+		Strict:  true,
+		OnEvent: func(ev circuitbreaker.Event) { events = append(events, ev) },
+	})
+	if _, tripped := tr.run(time.Minute, constant(1)); !tripped {
+		t.Fatal("expected constant failures to trip")
+	}
+	if last := events[len(events)-1]; last.Reason != circuitbreaker.ReasonFailureRate {
+		t.Errorf("expected a failure-rate open event, got %+v", last)
+	}
+
+	tr.clock.Advance(time.Second)
+	if _, err := tr.cb.Execute(successFn); err != nil {
+		t.Fatalf("expected the probe to be admitted, got %v", err)
+	}
+	if s := tr.cb.State(); s != circuitbreaker.Closed {
+		t.Fatalf("expected closed after the probe, got %s", s)
+	}
+	for i, rate := range tr.cb.Status().WindowFailureRates {
+		if rate != 0 {
+			t.Errorf("window %d kept failures across recovery: %f", i, rate)
+		}
+	}
+
+	// A fresh failure right after recovery must not see the old history.
+	tr.cb.Execute(failFn)
+	if s := tr.cb.State(); s != circuitbreaker.Closed {
+		t.Errorf("expected to stay closed on a single failure, got %s", s)
+	}
+}
+
+func TestRateWindows_OutcomesAgeOutWithTheClock(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1000,
+		FailureRateWindows: []circuitbreaker.RateWindow{
+			{Duration: 30 * time.Second, FailureRateThreshold: 0.5, MinRequests: 4},
+		},
+		Clock:  clock,
+		Strict: true,
+	})
+	rate := func() float64 { return cb.Status().WindowFailureRates[0] }
+
+	cb.Execute(failFn)
+	clock.Advance(15 * time.Second)
+	for range 3 {
+		cb.Execute(successFn)
+	}
+	if r := rate(); r != 0.25 {
+		t.Fatalf("expected 1 failure in 4 calls, got rate %v", r)
+	}
+	// the first bucket ages out without any call touching the window.
+	clock.Advance(15 * time.Second)
+	if r := rate(); r != 0 {
+		t.Fatalf("expected the failure to have aged out after 30s, got rate %v", r)
+	}
+
+	clock.Advance(10 * time.Second)
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if r := rate(); r != 0.4 || cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected 2 failures in 5 calls to stay closed, got rate %v and %v", r, cb.State())
+	}
+	// the successes age out; the failures alone reach the threshold.
+	clock.Advance(6 * time.Second)
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected 3 calls to be under MinRequests, got %v", cb.State())
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected 4 failures in the last 30s to trip, got %v with rate %v", cb.State(), rate())
+	}
+}
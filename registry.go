@@ -0,0 +1,100 @@
+package circuitbreaker
+
+import "sync"
+
+// Registry holds named breakers, such as one per downstream service, so a
+// program can look them up by name instead of keeping its own map. Unlike
+// a Group, each breaker has a Config of its own. The zero value is an empty
+// registry ready to use, and a Registry is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry is the Registry used by the package-level GetOrCreate,
+// Get, Remove and All, for programs too small to pass one around.
+var DefaultRegistry = NewRegistry()
+
+// GetOrCreate returns the breaker registered under name, creating it from
+// cfg if there is none. Concurrent calls for the same name get the same
+// breaker, and cfg is ignored once it exists. An empty cfg.Name is set to
+// name.
+func (r *Registry) GetOrCreate(name string, cfg Config) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cb, ok := r.breakers[name]; ok {
+		return cb
+	}
+	if cfg.Name == "" {
+		cfg.Name = name
+	}
+	cb := New(cfg)
+	if r.breakers == nil {
+		r.breakers = make(map[string]*CircuitBreaker)
+	}
+	r.breakers[name] = cb
+	return cb
+}
+
+// Get returns the breaker registered under name, if any.
+func (r *Registry) Get(name string) (*CircuitBreaker, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cb, ok := r.breakers[name]
+	return cb, ok
+}
+
+// Remove unregisters the breaker under name and closes it, stopping its
+// background work; callers still holding it get ErrClosed. A later
+// GetOrCreate for name creates a new breaker. Removing a name that is not
+// registered does nothing.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	cb, ok := r.breakers[name]
+	delete(r.breakers, name)
+	r.mu.Unlock()
+
+	if ok {
+		cb.Close()
+	}
+}
+
+// All returns a copy of the registered breakers by name. Changes to the
+// registry after it returns are not reflected in the map.
+func (r *Registry) All() map[string]*CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	all := make(map[string]*CircuitBreaker, len(r.breakers))
+	for name, cb := range r.breakers {
+		all[name] = cb
+	}
+	return all
+}
+
+// GetOrCreate calls DefaultRegistry.GetOrCreate.
+func GetOrCreate(name string, cfg Config) *CircuitBreaker {
+	return DefaultRegistry.GetOrCreate(name, cfg)
+}
+
+// Get calls DefaultRegistry.Get.
+func Get(name string) (*CircuitBreaker, bool) {
+	return DefaultRegistry.Get(name)
+}
+
+// Remove calls DefaultRegistry.Remove.
+func Remove(name string) {
+	DefaultRegistry.Remove(name)
+}
+
+// All calls DefaultRegistry.All.
+func All() map[string]*CircuitBreaker {
+	return DefaultRegistry.All()
+}
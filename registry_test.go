@@ -0,0 +1,89 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestRegistry_GetOrCreateIsRaceFree(t *testing.T) {
+	var r circuitbreaker.Registry
+	created := make([]*circuitbreaker.CircuitBreaker, 100)
+
+	var wg sync.WaitGroup
+	for i := range created {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created[i] = r.GetOrCreate("payments", circuitbreaker.Config{Strict: true})
+		}()
+	}
+	wg.Wait()
+	for _, cb := range created {
+		if cb != created[0] {
+			t.Fatal("expected every caller to get the same breaker")
+		}
+	}
+	if name := created[0].Status().Name; name != "payments" {
+		t.Errorf("expected the breaker named after its key, got %q", name)
+	}
+	if cb, ok := r.Get("payments"); !ok || cb != created[0] {
+		t.Errorf("expected Get to find the breaker, got %v, %v", cb, ok)
+	}
+	if _, ok := r.Get("search"); ok {
+		t.Error("expected no breaker for an unknown name")
+	}
+}
+
+func TestRegistry_ConfigOnlyUsedOnCreate(t *testing.T) {
+	r := circuitbreaker.NewRegistry()
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := r.GetOrCreate("search", circuitbreaker.Config{Name: "search-v2", FailureThreshold: 1, Clock: clock, Strict: true})
+	if again := r.GetOrCreate("search", circuitbreaker.Config{FailureThreshold: 5}); again != cb {
+		t.Fatal("expected the existing breaker back")
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open || cb.Status().Name != "search-v2" {
+		t.Errorf("expected the first config to stick, got %v %q", cb.State(), cb.Status().Name)
+	}
+}
+
+func TestRegistry_RemoveClosesBreaker(t *testing.T) {
+	r := circuitbreaker.NewRegistry()
+	cb := r.GetOrCreate("payments", circuitbreaker.Config{Strict: true})
+	r.GetOrCreate("search", circuitbreaker.Config{Strict: true})
+
+	all := r.All()
+	r.Remove("payments")
+	r.Remove("payments")
+	if len(all) != 2 || all["payments"] != cb {
+		t.Errorf("expected the snapshot taken before Remove to be unchanged, got %v", all)
+	}
+	if all := r.All(); len(all) != 1 || all["search"] == nil {
+		t.Errorf("expected only search left, got %v", all)
+	}
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrClosed) {
+		t.Errorf("expected the removed breaker to be closed, got %v", err)
+	}
+	if again := r.GetOrCreate("payments", circuitbreaker.Config{Strict: true}); again == cb {
+		t.Error("expected a new breaker after Remove")
+	}
+}
+
+func TestRegistry_Default(t *testing.T) {
+	cb := circuitbreaker.GetOrCreate("registry-test", circuitbreaker.Config{Strict: true})
+	defer circuitbreaker.Remove("registry-test")
+
+	if got, ok := circuitbreaker.Get("registry-test"); !ok || got != cb {
+		t.Errorf("expected the default registry to hold the breaker, got %v, %v", got, ok)
+	}
+	if circuitbreaker.All()["registry-test"] != cb {
+		t.Error("expected All to include the breaker")
+	}
+	if circuitbreaker.DefaultRegistry.All()["registry-test"] != cb {
+		t.Error("expected the package functions to use DefaultRegistry")
+	}
+}
@@ -0,0 +1,129 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Report is a summary of a breaker for debugging endpoints, such as the
+// one StatusHandler serves. It marshals to a JSON object with the state by
+// name, RFC 3339 timestamps and the remaining open time in seconds,
+// leaving out timestamps and durations that are zero, and the mode when
+// it is Automatic.
+type Report struct {
+	Name  string
+	State State
+	Mode  Mode
+	// ConsecutiveFailures and ConsecutiveSuccesses are the current runs
+	// of failures and successes; see Counts.
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	// Successes is the number of successes since the last state change.
+	Successes int
+	Totals    Totals
+	// LastFailure and LastStateChange are zero if there was none.
+	LastFailure     time.Time
+	LastStateChange time.Time
+	// OpenRemaining is how long until the open circuit lets a probe
+	// through; see Status.OpenRemaining.
+	OpenRemaining time.Duration
+}
+
+// Report returns a summary of the breaker. A nil breaker reports Closed.
+func (cb *CircuitBreaker) Report() Report {
+	s := cb.Status()
+	return Report{
+		Name:                 s.Name,
+		State:                s.State,
+		Mode:                 s.Mode,
+		ConsecutiveFailures:  s.Counts.ConsecutiveFailures,
+		ConsecutiveSuccesses: s.Counts.ConsecutiveSuccesses,
+		Successes:            s.Counts.Successes,
+		Totals:               s.Totals,
+		LastFailure:          s.LastFailure,
+		LastStateChange:      s.LastStateChange,
+		OpenRemaining:        s.OpenRemaining,
+	}
+}
+
+type jsonTotals struct {
+	Requests         uint64  `json:"requests"`
+	Successes        uint64  `json:"successes"`
+	Failures         uint64  `json:"failures"`
+	Rejected         uint64  `json:"rejected"`
+	RejectedOpen     uint64  `json:"rejected_open"`
+	RejectedHalfOpen uint64  `json:"rejected_half_open"`
+	SecondsClosed    float64 `json:"seconds_closed"`
+	SecondsOpen      float64 `json:"seconds_open"`
+	SecondsHalfOpen  float64 `json:"seconds_half_open"`
+}
+
+type jsonReport struct {
+	Name                 string     `json:"name"`
+	State                string     `json:"state"`
+	Mode                 string     `json:"mode,omitempty"`
+	ConsecutiveFailures  int        `json:"consecutive_failures"`
+	ConsecutiveSuccesses int        `json:"consecutive_successes"`
+	Successes            int        `json:"successes"`
+	Totals               jsonTotals `json:"totals"`
+	LastFailure          string     `json:"last_failure,omitempty"`
+	LastStateChange      string     `json:"last_state_change,omitempty"`
+	OpenRemainingSeconds float64    `json:"open_remaining_seconds,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonReport{
+		Name:                 r.Name,
+		State:                r.State.String(),
+		Mode:                 mode(r.Mode),
+		ConsecutiveFailures:  r.ConsecutiveFailures,
+		ConsecutiveSuccesses: r.ConsecutiveSuccesses,
+		Successes:            r.Successes,
+		Totals: jsonTotals{
+			Requests:         r.Totals.Requests,
+			Successes:        r.Totals.Successes,
+			Failures:         r.Totals.Failures,
+			Rejected:         r.Totals.Rejected,
+			RejectedOpen:     r.Totals.RejectedOpen,
+			RejectedHalfOpen: r.Totals.RejectedHalfOpen,
+			SecondsClosed:    r.Totals.TimeClosed.Seconds(),
+			SecondsOpen:      r.Totals.TimeOpen.Seconds(),
+			SecondsHalfOpen:  r.Totals.TimeHalfOpen.Seconds(),
+		},
+		LastFailure:          rfc3339(r.LastFailure),
+		LastStateChange:      rfc3339(r.LastStateChange),
+		OpenRemainingSeconds: r.OpenRemaining.Seconds(),
+	})
+}
+
+// mode names m, or returns "" for Automatic.
+func mode(m Mode) string {
+	if m == Automatic {
+		return ""
+	}
+	return m.String()
+}
+
+// rfc3339 formats t, or returns "" for the zero time.
+func rfc3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// StatusHandler returns an http.Handler that responds with a JSON array of
+// the Report of each of cbs, in order, for mounting at a path such as
+// /debug/circuitbreakers.
+func StatusHandler(cbs ...*CircuitBreaker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reports := make([]Report, len(cbs))
+		for i, cb := range cbs {
+			reports[i] = cb.Report()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reports)
+	})
+}
@@ -0,0 +1,108 @@
+package circuitbreaker_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestReport_JSON(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "payments", FailureThreshold: 2, Timeout: time.Minute, Clock: clock, Strict: true})
+	cb.Execute(successFn)
+	clock.Advance(time.Second)
+	cb.Execute(failFn)
+	clock.Advance(time.Second)
+	cb.Execute(failFn)
+	clock.Advance(15 * time.Second)
+	cb.Execute(successFn)
+
+	body, err := json.Marshal(cb.Report())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	json.Unmarshal(body, &got)
+	want := map[string]any{
+		"name":                   "payments",
+		"state":                  "Open",
+		"consecutive_failures":   0.0,
+		"consecutive_successes":  0.0,
+		"successes":              0.0,
+		"totals":                 map[string]any{"requests": 4.0, "successes": 1.0, "failures": 2.0, "rejected": 1.0, "rejected_open": 1.0, "rejected_half_open": 0.0, "seconds_closed": 2.0, "seconds_open": 15.0, "seconds_half_open": 0.0},
+		"last_failure":           cbt.Epoch.Add(2 * time.Second).Format(time.RFC3339),
+		"last_state_change":      cbt.Epoch.Add(2 * time.Second).Format(time.RFC3339),
+		"open_remaining_seconds": 45.0,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d fields, got %s", len(want), body)
+	}
+	for k, v := range want {
+		if gotJSON, wantJSON := mustJSON(got[k]), mustJSON(v); gotJSON != wantJSON {
+			t.Errorf("%s: expected %s, got %s", k, wantJSON, gotJSON)
+		}
+	}
+}
+
+func TestReport_ConsecutiveRuns(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 5, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	cb.Execute(failFn)
+	cb.Execute(successFn)
+	cb.Execute(successFn)
+
+	r := cb.Report()
+	if r.ConsecutiveFailures != 0 || r.ConsecutiveSuccesses != 2 {
+		t.Errorf("expected a run of 2 successes, got %+v", r)
+	}
+	body, _ := json.Marshal(r)
+	var got map[string]any
+	json.Unmarshal(body, &got)
+	if got["consecutive_successes"] != 2.0 || got["consecutive_failures"] != 0.0 {
+		t.Errorf("expected the runs in the JSON, got %s", body)
+	}
+}
+
+func TestReport_OmitsZeroTimes(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	body, _ := json.Marshal(cb.Report())
+	var got map[string]any
+	json.Unmarshal(body, &got)
+	for _, k := range []string{"last_failure", "last_state_change", "open_remaining_seconds"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("expected %s left out, got %s", k, body)
+		}
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	payments := circuitbreaker.New(circuitbreaker.Config{Name: "payments", FailureThreshold: 1, Clock: clock, Strict: true})
+	search := circuitbreaker.New(circuitbreaker.Config{Name: "search", Clock: clock, Strict: true})
+	payments.Execute(failFn)
+
+	w := httptest.NewRecorder()
+	circuitbreaker.StatusHandler(payments, search).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/circuitbreakers", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON, got %q", ct)
+	}
+	var got []struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("expected a JSON array, got %s: %v", w.Body, err)
+	}
+	if len(got) != 2 || got[0].Name != "payments" || got[0].State != "Open" || got[1].Name != "search" || got[1].State != "Closed" {
+		t.Errorf("unexpected reports %+v", got)
+	}
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
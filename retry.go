@@ -0,0 +1,186 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is returned for a retry (see AsRetry) when the
+// breaker's retry budget has no tokens left.
+var ErrRetryBudgetExhausted = errors.New("circuit breaker: retry budget exhausted")
+
+// RetryPolicy controls ExecuteWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 1 mean a single attempt.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+	// Multiplier grows the wait after each retry. Zero means 2.
+	Multiplier float64
+	// MaxBackoff caps the wait between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// Jitter shortens each wait by a random fraction of up to Jitter, so
+	// callers that failed together do not retry together. 0.5 spreads a
+	// 1s wait over [500ms, 1s]. It draws from the breaker's Config.Rand;
+	// values are clamped to [0, 1].
+	Jitter float64
+	// PerCall makes the call as a whole, rather than each attempt, feed
+	// the breaker's counters: its attempts are made with AsAttempt, so
+	// however many of them fail they count as a single failure. An
+	// attempt that succeeds still counts as usual.
+	PerCall bool
+}
+
+// retryCalls numbers the logical calls of ExecuteWithRetry with
+// RetryPolicy.PerCall set, giving each its own AsAttempt ID.
+var retryCalls atomic.Uint64
+
+// ExecuteWithRetry runs fn through the breaker, retrying failures with
+// exponential backoff as described by policy. Attempts after the first are
+// made with AsRetry, so they draw on the retry budget when one is
+// configured. Retrying stops as soon as the breaker rejects an attempt
+// (open circuit, full bulkhead, exhausted budget or local pressure); if an earlier attempt
+// ran, its error is returned rather than the rejection. Backoff waits end
+// early with ctx's error if ctx is done. Each attempt feeds the breaker's
+// counters unless policy.PerCall is set.
+func (cb *CircuitBreaker) ExecuteWithRetry(ctx context.Context, fn func() (any, error), policy RetryPolicy) (any, error) {
+	if fn == nil {
+		return nil, ErrNilFunction
+	}
+	var clock Clock = systemClock{}
+	var random Rand = globalRand{}
+	if cb != nil {
+		cb.lazyInit()
+		clock, random = cb.clock, cb.rand
+	}
+	var id string
+	if policy.PerCall {
+		// the NUL keeps it apart from the caller's own AsAttempt IDs,
+		// which share the namespace.
+		id = "\x00retry-" + strconv.FormatUint(retryCalls.Add(1), 10)
+	}
+	return retry(ctx, clock, random, policy, func(attempt int) (any, error) {
+		var opts []CallOption
+		if attempt > 1 {
+			opts = append(opts, AsRetry())
+		}
+		if id != "" {
+			opts = append(opts, AsAttempt(id))
+		}
+		return cb.Execute(fn, opts...)
+	})
+}
+
+// retry runs attempt, numbered from 1, until it succeeds, is rejected or
+// runs out of attempts, waiting on clock between attempts as described by
+// policy and jittered with random. This is the loop behind both
+// ExecuteWithRetry and Retry.
+func retry(ctx context.Context, clock Clock, random Rand, policy RetryPolicy, attempt func(n int) (any, error)) (any, error) {
+	multiplier := policy.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	backoff := policy.InitialBackoff
+	var lastErr error
+	for n := 1; ; n++ {
+		result, err := attempt(n)
+		if err == nil {
+			return result, nil
+		}
+		if isRejection(err) {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			// a stale result served in place of the call; see
+			// Config.StaleCacheTTL.
+			return result, err
+		}
+		lastErr = err
+		if n >= policy.MaxAttempts {
+			return result, err
+		}
+		if err := sleep(ctx, clock, jitter(backoff, policy.Jitter, random)); err != nil {
+			return nil, err
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// isRejection reports whether err came from the breaker turning a call
+// away rather than from the call itself.
+func isRejection(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrTooManyRequests) || errors.Is(err, ErrBulkheadFull) ||
+		errors.Is(err, ErrRetryBudgetExhausted) || errors.Is(err, ErrClosed) ||
+		errors.Is(err, ErrUnderPressure) || errors.Is(err, ErrFailedChecks)
+}
+
+// sleep waits for d on clock, or until ctx is done.
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	done := make(chan struct{})
+	t := clock.AfterFunc(d, func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
+
+// retryBudget is a token bucket that successful first attempts fill and
+// retries drain, so retries stay a bounded fraction of real traffic.
+type retryBudget struct {
+	ratio   float64
+	max     float64
+	tokens  float64
+	skipped uint64
+}
+
+// newRetryBudget returns nil when Config.RetryBudgetRatio is not set.
+func newRetryBudget(cfg Config) *retryBudget {
+	if cfg.RetryBudgetRatio <= 0 {
+		return nil
+	}
+	return &retryBudget{
+		ratio:  cfg.RetryBudgetRatio,
+		max:    cfg.RetryBudgetMaxTokens,
+		tokens: cfg.RetryBudgetMinTokens,
+	}
+}
+
+// deposit credits a successful first attempt.
+func (b *retryBudget) deposit() {
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+// withdraw spends a token for a retry, reporting whether there was one.
+func (b *retryBudget) withdraw() bool {
+	if b.tokens < 1 {
+		b.skipped++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund returns a token for a retry that was rejected for another reason.
+func (b *retryBudget) refund() {
+	b.tokens++
+}
@@ -0,0 +1,237 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestRetryBudget_CapsRetriesUnderHighFailure(t *testing.T) {
+	var exhausted int
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:     1000,
+		RetryBudgetRatio:     0.1,
+		RetryBudgetMinTokens: 5,
+		Strict:               true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.Type == circuitbreaker.EventRetryBudgetExhausted {
+				exhausted++
+			}
+		},
+	})
+
+	// three in four logical calls fail on every attempt.
+	var firstAttempts, retries, firstSuccesses int
+	for i := 0; i < 400; i++ {
+		attempt := 0
+		cb.ExecuteWithRetry(context.Background(), func() (any, error) {
+			attempt++
+			if attempt == 1 {
+				firstAttempts++
+			} else {
+				retries++
+			}
+			if i%4 != 0 {
+				return failFn()
+			}
+			if attempt == 1 {
+				firstSuccesses++
+			}
+			return successFn()
+		}, circuitbreaker.RetryPolicy{MaxAttempts: 3})
+	}
+
+	if firstAttempts != 400 {
+		t.Errorf("first attempts must never be limited, got %d", firstAttempts)
+	}
+	if limit := 5 + int(0.1*float64(firstSuccesses)); retries > limit {
+		t.Errorf("expected at most %d retries, got %d", limit, retries)
+	}
+	s := cb.Status()
+	if s.RetriesSkipped == 0 || exhausted != int(s.RetriesSkipped) {
+		t.Errorf("expected skipped retries to be counted and reported, got %d skipped and %d events",
+			s.RetriesSkipped, exhausted)
+	}
+	if s.RetryBudget >= 1 {
+		t.Errorf("expected the budget to be drained, %f left", s.RetryBudget)
+	}
+}
+
+func TestRetryBudget_ExhaustedRetryReturnsAttemptError(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold:     1000,
+		RetryBudgetRatio:     0.1,
+		RetryBudgetMinTokens: 0.5,
+		Strict:               true,
+	})
+	calls := 0
+	_, err := cb.ExecuteWithRetry(context.Background(), func() (any, error) {
+		calls++
+		return failFn()
+	}, circuitbreaker.RetryPolicy{MaxAttempts: 3})
+	if !errors.Is(err, errSimulated) || calls != 1 {
+		t.Errorf("expected only the first attempt and its error, got %d calls and %v", calls, err)
+	}
+	if _, err := cb.Execute(failFn, circuitbreaker.AsRetry()); !errors.Is(err, circuitbreaker.ErrRetryBudgetExhausted) {
+		t.Errorf("expected a direct retry to be rejected, got %v", err)
+	}
+}
+
+func TestExecuteWithRetry_BacksOffOnTheClock(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1000, Clock: clock, Strict: true})
+
+	var at []time.Duration
+	done := make(chan error, 1)
+	go func() {
+		_, err := cb.ExecuteWithRetry(context.Background(), func() (any, error) {
+			at = append(at, clock.Now().Sub(cbt.Epoch))
+			return failFn()
+		}, circuitbreaker.RetryPolicy{
+			MaxAttempts:    4,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     300 * time.Millisecond,
+		})
+		done <- err
+	}()
+	for {
+		select {
+		case err := <-done:
+			if !errors.Is(err, errSimulated) {
+				t.Fatalf("expected the last attempt's error, got %v", err)
+			}
+			want := []time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond, 600 * time.Millisecond}
+			if len(at) != len(want) {
+				t.Fatalf("expected attempts at %v, got %v", want, at)
+			}
+			for i := range want {
+				if at[i] != want[i] {
+					t.Errorf("attempt %d at %s, want %s", i+1, at[i], want[i])
+				}
+			}
+			return
+		default:
+			if clock.PendingTimers() > 0 {
+				clock.Advance(50 * time.Millisecond)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestExecuteWithRetry_StopsOnOpenCircuit(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	calls := 0
+	_, err := cb.ExecuteWithRetry(context.Background(), func() (any, error) {
+		calls++
+		return failFn()
+	}, circuitbreaker.RetryPolicy{MaxAttempts: 5})
+	if calls != 2 || !errors.Is(err, errSimulated) {
+		t.Errorf("expected retries to stop once the circuit opened, got %d calls and %v", calls, err)
+	}
+}
+
+func TestExecuteWithRetry_PerCallCountsOneFailure(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	calls := 0
+	flaky := func() (any, error) {
+		calls++
+		return failFn()
+	}
+	policy := circuitbreaker.RetryPolicy{MaxAttempts: 3, PerCall: true}
+
+	if _, err := cb.ExecuteWithRetry(context.Background(), flaky, policy); !errors.Is(err, errSimulated) {
+		t.Fatalf("expected the last attempt's error, got %v", err)
+	}
+	if calls != 3 || cb.State() != circuitbreaker.Closed || cb.Counts().ConsecutiveFailures != 1 {
+		t.Fatalf("expected 3 attempts counted as one failure, got %d calls, %v and %+v", calls, cb.State(), cb.Counts())
+	}
+	// a second logical call is a failure of its own.
+	cb.ExecuteWithRetry(context.Background(), flaky, policy)
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected two failed calls to open the circuit, got %v", cb.State())
+	}
+	if got := cb.Totals().Failures; got != 2 {
+		t.Errorf("expected 2 failures in the totals, got %d", got)
+	}
+}
+
+func TestExecuteWithRetry_ContextCancelsBackoff(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: clock, Strict: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, err := cb.ExecuteWithRetry(ctx, func() (any, error) {
+		calls++
+		cancel()
+		return failFn()
+	}, circuitbreaker.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("expected cancellation during backoff, got %d calls and %v", calls, err)
+	}
+}
+
+// retrySchedule runs a failing call through ExecuteWithRetry and returns
+// when each attempt was made, stepping clock 5ms at a time.
+func retrySchedule(t *testing.T, cb *circuitbreaker.CircuitBreaker, clock *cbt.FakeClock, policy circuitbreaker.RetryPolicy) []time.Duration {
+	t.Helper()
+	var at []time.Duration
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb.ExecuteWithRetry(context.Background(), func() (any, error) {
+			at = append(at, clock.Now().Sub(cbt.Epoch))
+			return failFn()
+		}, policy)
+	}()
+	for {
+		select {
+		case <-done:
+			return at
+		default:
+			if clock.PendingTimers() > 0 {
+				clock.Advance(5 * time.Millisecond)
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}
+}
+
+func TestExecuteWithRetry_JitterDrawsFromConfigRand(t *testing.T) {
+	policy := circuitbreaker.RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Jitter:         0.5,
+	}
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	random := cbt.NewScriptedRand(0.5, 0.2, 1)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1000, Clock: clock, Rand: random, Strict: true})
+
+	// waits of 100ms, 200ms and 300ms cut by 25%, 10% and 50%.
+	at := retrySchedule(t, cb, clock, policy)
+	want := []time.Duration{0, 75 * time.Millisecond, 255 * time.Millisecond, 405 * time.Millisecond}
+	if len(at) != len(want) || random.Draws() != 3 {
+		t.Fatalf("expected attempts at %v from 3 draws, got %v from %d", want, at, random.Draws())
+	}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Errorf("attempt %d at %s, want %s", i+1, at[i], want[i])
+		}
+	}
+
+	// the same seed gives the same schedule on every run.
+	seeded := func(seed uint64) []time.Duration {
+		clock := cbt.NewFakeClock(cbt.Epoch)
+		cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1000, Clock: clock, Rand: circuitbreaker.NewRand(seed), Strict: true})
+		return retrySchedule(t, cb, clock, policy)
+	}
+	if a, b := seeded(42), seeded(42); !slices.Equal(a, b) {
+		t.Errorf("expected seed 42 to repeat its schedule, got %v and %v", a, b)
+	}
+}
@@ -0,0 +1,94 @@
+package circuitbreaker_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// Transition tests written as deterministic scenarios on a fake clock.
+
+func newScenario() *cbt.Scenario {
+	return cbt.NewScenario(circuitbreaker.Config{
+		Name:             "test",
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		Timeout:          100 * time.Millisecond,
+		Strict:           true,
+	})
+}
+
+func TestStateTransition_OpenToHalfOpen(t *testing.T) {
+	newScenario().Run(t,
+		cbt.Fail(), cbt.Fail(), cbt.Fail(),
+		cbt.ExpectState(circuitbreaker.Open),
+		cbt.Advance(99*time.Millisecond),
+		cbt.Succeed(),
+		cbt.ExpectRejected(),
+		cbt.Advance(time.Millisecond),
+		cbt.Succeed(),
+		cbt.ExpectError(nil),
+		cbt.ExpectState(circuitbreaker.HalfOpen),
+	)
+}
+
+func TestStateTransition_HalfOpenToClosed(t *testing.T) {
+	newScenario().Run(t,
+		cbt.Fail(), cbt.Fail(), cbt.Fail(),
+		cbt.Advance(150*time.Millisecond),
+		cbt.Succeed(),
+		cbt.ExpectState(circuitbreaker.HalfOpen),
+		cbt.Succeed(),
+		cbt.ExpectState(circuitbreaker.Closed),
+	)
+}
+
+func TestStateTransition_HalfOpenToOpen(t *testing.T) {
+	newScenario().Run(t,
+		cbt.Fail(), cbt.Fail(), cbt.Fail(),
+		cbt.Advance(150*time.Millisecond),
+		cbt.Succeed(),
+		cbt.ExpectState(circuitbreaker.HalfOpen),
+		cbt.Fail(),
+		cbt.ExpectState(circuitbreaker.Open),
+		cbt.Succeed(),
+		cbt.ExpectRejected(),
+	)
+}
+
+// A long run of successes while closed must not carry over into the
+// half-open count: closing still takes SuccessThreshold probes, cycle
+// after cycle, and every transition is stamped.
+func TestStateTransition_SuccessesDoNotLeakIntoHalfOpen(t *testing.T) {
+	s := newScenario()
+	var steps []cbt.Step
+	for cycle := 0; cycle < 3; cycle++ {
+		for i := 0; i < 50; i++ {
+			steps = append(steps, cbt.Succeed())
+		}
+		steps = append(steps,
+			cbt.ExpectCounts(circuitbreaker.Counts{ConsecutiveSuccesses: 50, Successes: 50}),
+			cbt.Fail(), cbt.Fail(), cbt.Fail(),
+			cbt.ExpectState(circuitbreaker.Open),
+			cbt.ExpectCounts(circuitbreaker.Counts{}),
+			cbt.Advance(100*time.Millisecond),
+			cbt.Succeed(),
+			cbt.ExpectState(circuitbreaker.HalfOpen),
+			cbt.ExpectCounts(circuitbreaker.Counts{ConsecutiveSuccesses: 1, Successes: 1}),
+			cbt.Advance(time.Millisecond),
+			cbt.Succeed(),
+			cbt.ExpectState(circuitbreaker.Closed),
+			cbt.ExpectCounts(circuitbreaker.Counts{}),
+			cbt.StepFunc("closing is stamped", func(s *cbt.Scenario) error {
+				if got := s.Breaker.Status().LastStateChange; !got.Equal(s.Clock.Now()) {
+					return fmt.Errorf("expected the close stamped at %v, got %v", s.Clock.Now(), got)
+				}
+				return nil
+			}),
+		)
+	}
+	s.Run(t, steps...)
+}
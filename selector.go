@@ -0,0 +1,118 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrAllEndpointsOpen is returned by Selector.Execute when every endpoint's
+// breaker rejected the call. The error also wraps each breaker's
+// rejection, so errors.Is(err, ErrCircuitOpen) matches too.
+var ErrAllEndpointsOpen = errors.New("circuit breaker: all endpoints are unavailable")
+
+// SelectStrategy decides which endpoint a Selector tries first.
+type SelectStrategy int
+
+const (
+	// RoundRobin rotates through the endpoints in the order they were added.
+	RoundRobin SelectStrategy = iota
+	// LeastFailures prefers closed breakers over half-open ones and, among
+	// those, the endpoint with the fewest consecutive failures. Ties are
+	// broken round-robin.
+	LeastFailures
+)
+
+// Selector spreads calls over interchangeable endpoints, each protected by
+// its own breaker, and routes around endpoints whose circuit is open.
+// It is safe for concurrent use.
+type Selector[E any] struct {
+	strategy SelectStrategy
+
+	mu        sync.Mutex
+	endpoints []selectorEndpoint[E]
+	next      int
+}
+
+type selectorEndpoint[E any] struct {
+	endpoint E
+	breaker  *CircuitBreaker
+}
+
+// NewSelector returns an empty Selector that picks endpoints using
+// strategy.
+func NewSelector[E any](strategy SelectStrategy) *Selector[E] {
+	return &Selector[E]{strategy: strategy}
+}
+
+// Add registers an endpoint and the breaker that protects it. A nil
+// breaker leaves the endpoint unprotected, so it is always tried.
+func (s *Selector[E]) Add(endpoint E, cb *CircuitBreaker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.endpoints = append(s.endpoints, selectorEndpoint[E]{endpoint: endpoint, breaker: cb})
+}
+
+// Execute calls fn with a healthy endpoint, through that endpoint's
+// breaker, and returns its result. Endpoints whose breaker rejects the
+// call are skipped; when every breaker rejects it, Execute returns an error
+// matching ErrAllEndpointsOpen. An error returned by fn itself is returned
+// as is, without trying another endpoint.
+func (s *Selector[E]) Execute(fn func(endpoint E) (any, error)) (any, error) {
+	if fn == nil {
+		return nil, ErrNilFunction
+	}
+	var rejections []error
+	for _, e := range s.candidates() {
+		result, err := e.breaker.Execute(func() (any, error) { return fn(e.endpoint) })
+		if err != nil && isRejection(err) {
+			rejections = append(rejections, err)
+			continue
+		}
+		return result, err
+	}
+	return nil, errors.Join(append([]error{ErrAllEndpointsOpen}, rejections...)...)
+}
+
+// candidates returns the endpoints in the order they should be tried.
+func (s *Selector[E]) candidates() []selectorEndpoint[E] {
+	s.mu.Lock()
+	n := len(s.endpoints)
+	order := make([]selectorEndpoint[E], 0, n)
+	for i := 0; i < n; i++ {
+		order = append(order, s.endpoints[(s.next+i)%n])
+	}
+	if n > 0 {
+		s.next = (s.next + 1) % n
+	}
+	s.mu.Unlock()
+
+	if s.strategy == LeastFailures {
+		status := make(map[*CircuitBreaker]Status, n)
+		for _, e := range order {
+			status[e.breaker] = e.breaker.Status()
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := status[order[i].breaker], status[order[j].breaker]
+			if ra, rb := preference(a.State), preference(b.State); ra != rb {
+				return ra < rb
+			}
+			return a.Counts.ConsecutiveFailures < b.Counts.ConsecutiveFailures
+		})
+	}
+	return order
+}
+
+// preference ranks states for LeastFailures: closed, then half-open, then
+// open, which will most likely reject the call.
+func preference(s State) int {
+	switch s {
+	case Closed:
+		return 0
+	case HalfOpen:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,133 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+type replica struct {
+	name string
+	down bool
+	hits int
+}
+
+func newReplicas(t *testing.T, strategy circuitbreaker.SelectStrategy) (*circuitbreaker.Selector[*replica], []*replica, *cbt.FakeClock) {
+	t.Helper()
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	sel := circuitbreaker.NewSelector[*replica](strategy)
+	var replicas []*replica
+	for _, name := range []string{"a", "b", "c"} {
+		r := &replica{name: name}
+		replicas = append(replicas, r)
+		sel.Add(r, circuitbreaker.New(circuitbreaker.Config{
+			Name:             name,
+			FailureThreshold: 2,
+			SuccessThreshold: 1,
+			Timeout:          time.Minute,
+			Clock:            clock,
+			Strict:           true,
+		}))
+	}
+	return sel, replicas, clock
+}
+
+func callReplica(r *replica) (any, error) {
+	r.hits++
+	if r.down {
+		return nil, errSimulated
+	}
+	return r.name, nil
+}
+
+func resetHits(replicas []*replica) {
+	for _, r := range replicas {
+		r.hits = 0
+	}
+}
+
+func TestSelector_RoutesAroundOpenEndpoints(t *testing.T) {
+	sel, replicas, _ := newReplicas(t, circuitbreaker.RoundRobin)
+	for i := 0; i < 6; i++ {
+		if _, err := sel.Execute(callReplica); err != nil {
+			t.Fatalf("healthy call %d failed: %v", i, err)
+		}
+	}
+	for _, r := range replicas {
+		if r.hits != 2 {
+			t.Errorf("expected round-robin to spread calls evenly, %s got %d", r.name, r.hits)
+		}
+	}
+
+	// a goes down; its failures surface until its breaker opens.
+	replicas[0].down = true
+	failures := 0
+	for i := 0; i < 30; i++ {
+		if _, err := sel.Execute(callReplica); err != nil {
+			failures++
+		}
+	}
+	if failures != 2 {
+		t.Errorf("expected only the failures that tripped a's breaker, got %d", failures)
+	}
+
+	resetHits(replicas)
+	replicas[1].down = true
+	for i := 0; i < 30; i++ {
+		sel.Execute(callReplica)
+	}
+	resetHits(replicas)
+	for i := 0; i < 10; i++ {
+		if v, err := sel.Execute(callReplica); err != nil || v != "c" {
+			t.Fatalf("expected every call to go to c, got %v, %v", v, err)
+		}
+	}
+	if replicas[0].hits != 0 || replicas[1].hits != 0 {
+		t.Errorf("open endpoints were called: a=%d b=%d", replicas[0].hits, replicas[1].hits)
+	}
+}
+
+func TestSelector_AllOpen(t *testing.T) {
+	sel, replicas, clock := newReplicas(t, circuitbreaker.LeastFailures)
+	for _, r := range replicas {
+		r.down = true
+	}
+	for i := 0; i < 6; i++ {
+		sel.Execute(callReplica)
+	}
+	_, err := sel.Execute(callReplica)
+	if !errors.Is(err, circuitbreaker.ErrAllEndpointsOpen) || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected a combined all-open error, got %v", err)
+	}
+
+	// once the timeout passes, a recovered endpoint takes traffic again.
+	replicas[2].down = false
+	clock.Advance(time.Minute)
+	var served int
+	for i := 0; i < 3; i++ {
+		if v, err := sel.Execute(callReplica); err == nil && v == "c" {
+			served++
+		}
+	}
+	if served == 0 {
+		t.Error("expected the recovered endpoint to serve calls")
+	}
+}
+
+func TestSelector_LeastFailuresPrefersHealthy(t *testing.T) {
+	sel, replicas, _ := newReplicas(t, circuitbreaker.LeastFailures)
+	replicas[0].down = true
+	sel.Execute(callReplica) // a fails once, staying closed
+	replicas[0].down = false
+
+	resetHits(replicas)
+	for i := 0; i < 4; i++ {
+		sel.Execute(callReplica)
+	}
+	if replicas[0].hits != 0 {
+		t.Errorf("expected the endpoint with a failure to be avoided, got %d hits", replicas[0].hits)
+	}
+}
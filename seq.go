@@ -0,0 +1,211 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// OpenPolicy says what ExecuteSeq does with an item the breaker rejects.
+type OpenPolicy int
+
+const (
+	// StopOnOpen stops the loop at the first rejected item and returns the
+	// rejection. It is the default.
+	StopOnOpen OpenPolicy = iota
+	// SkipOnOpen counts rejected items as skipped and carries on with the
+	// next one.
+	SkipOnOpen
+	// WaitOnOpen holds a rejected item until the breaker may admit it
+	// again, such as when an open circuit's timeout expires, and then
+	// retries it.
+	WaitOnOpen
+)
+
+// SeqOption configures ExecuteSeq.
+type SeqOption func(*seqOptions)
+
+type seqOptions struct {
+	parallelism int
+	onOpen      OpenPolicy
+	callOpts    []CallOption
+}
+
+// WithParallelism processes up to n items at once. The default is 1, one
+// item after another in sequence order.
+func WithParallelism(n int) SeqOption {
+	return func(o *seqOptions) {
+		o.parallelism = max(n, 1)
+	}
+}
+
+// WithOpenPolicy sets what happens to items the breaker rejects.
+func WithOpenPolicy(p OpenPolicy) SeqOption {
+	return func(o *seqOptions) {
+		o.onOpen = p
+	}
+}
+
+// WithSeqCallOptions applies opts to the call made for every item.
+func WithSeqCallOptions(opts ...CallOption) SeqOption {
+	return func(o *seqOptions) {
+		o.callOpts = opts
+	}
+}
+
+// Summary counts what ExecuteSeq did with the items it took from the
+// sequence.
+type Summary struct {
+	// Processed is the number of items fn ran for, and Failed how many of
+	// those returned an error.
+	Processed int
+	Failed    int
+	// Skipped is the number of items taken from the sequence that fn did
+	// not run for, because the breaker rejected them or the loop stopped.
+	Skipped int
+}
+
+// ExecuteSeq runs fn through cb for every item of seq and summarises the
+// outcome. Errors from fn are counted in the Summary rather than
+// returned. Items the breaker rejects are handled according to the
+// OpenPolicy, so a batch job does not grind through thousands of items
+// after the circuit opens: by default the loop stops at the first
+// rejection and returns it, without taking further items from seq. Items
+// turned away by a full bulkhead wait for room whatever the policy, and a
+// closed breaker always stops the loop with ErrClosed.
+//
+// ExecuteSeq also stops, returning ctx's error, when ctx is done. Items
+// that are running when the loop stops are allowed to finish. With a nil
+// cb, fn runs for every item.
+func ExecuteSeq[T any](ctx context.Context, cb *CircuitBreaker, seq iter.Seq[T], fn func(T) error, opts ...SeqOption) (Summary, error) {
+	if fn == nil {
+		return Summary{}, ErrNilFunction
+	}
+	o := seqOptions{parallelism: 1}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return Summary{}, err
+	}
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+
+	var (
+		mu  sync.Mutex
+		sum Summary
+	)
+	count := func(processed, failed, skipped int) {
+		mu.Lock()
+		sum.Processed += processed
+		sum.Failed += failed
+		sum.Skipped += skipped
+		mu.Unlock()
+	}
+	process := func(item T) {
+		for {
+			ran := false
+			_, err := cb.ExecuteContext(ctx, func(context.Context) (any, error) {
+				ran = true
+				return nil, fn(item)
+			}, o.callOpts...)
+			switch {
+			case ran && err != nil:
+				count(1, 1, 0)
+			case ran:
+				count(1, 0, 0)
+			case !isRejection(err):
+				// ctx is done.
+				count(0, 0, 1)
+			case errors.Is(err, ErrBulkheadFull) || (o.onOpen == WaitOnOpen && !errors.Is(err, ErrClosed)):
+				if cb.waitAdmittable(ctx) == nil {
+					continue
+				}
+				count(0, 0, 1)
+			case o.onOpen == SkipOnOpen && !errors.Is(err, ErrClosed):
+				count(0, 0, 1)
+			default:
+				count(0, 0, 1)
+				stop(err)
+			}
+			return
+		}
+	}
+
+	if o.parallelism == 1 {
+		for item := range seq {
+			process(item)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	} else {
+		items := make(chan T)
+		var wg sync.WaitGroup
+		for range o.parallelism {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for item := range items {
+					process(item)
+				}
+			}()
+		}
+	feed:
+		for item := range seq {
+			select {
+			case items <- item:
+			case <-ctx.Done():
+				count(0, 0, 1)
+				break feed
+			}
+		}
+		close(items)
+		wg.Wait()
+	}
+
+	if ctx.Err() != nil {
+		return sum, context.Cause(ctx)
+	}
+	return sum, nil
+}
+
+// waitAdmittable blocks until a rejected request might be admitted: the
+// state changes, a running call finishes or an open circuit's timeout
+// expires. It returns ctx's error if ctx is done first.
+func (cb *CircuitBreaker) waitAdmittable(ctx context.Context) error {
+	cb.mu.Lock()
+	if cb.freed == nil {
+		cb.freed = make(chan struct{})
+	}
+	freed := cb.freed
+	var expired chan struct{}
+	// no timer for a circuit held open or already due, whose callers
+	// would only be rejected again at once.
+	if d, ok := cb.untilRetry(); ok && d > 0 {
+		expired = make(chan struct{})
+		t := cb.clock.AfterFunc(d, func() { close(expired) })
+		defer t.Stop()
+	}
+	cb.unlock()
+
+	select {
+	case <-freed:
+	case <-expired:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// signalFreed wakes the callers in waitAdmittable. Must be called with
+// cb.mu held.
+func (cb *CircuitBreaker) signalFreed() {
+	if cb.freed != nil {
+		close(cb.freed)
+		cb.freed = nil
+	}
+}
@@ -0,0 +1,247 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// items yields 0..n-1 and counts how many were taken.
+func items(n int, taken *atomic.Int32) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range n {
+			taken.Add(1)
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func failing(int) error { return errSimulated }
+
+func TestExecuteSeq_StopsWhenTheCircuitOpens(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Strict: true})
+	var taken atomic.Int32
+
+	sum, err := circuitbreaker.ExecuteSeq(context.Background(), cb, items(1000, &taken), failing)
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	want := circuitbreaker.Summary{Processed: 3, Failed: 3, Skipped: 1}
+	if sum != want {
+		t.Errorf("summary = %+v, want %+v", sum, want)
+	}
+	if n := taken.Load(); n != 4 {
+		t.Errorf("took %d items from the sequence, want 4", n)
+	}
+}
+
+func TestExecuteSeq_SkipsWhileOpen(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Strict: true})
+	var taken atomic.Int32
+
+	sum, err := circuitbreaker.ExecuteSeq(context.Background(), cb, items(10, &taken), failing,
+		circuitbreaker.WithOpenPolicy(circuitbreaker.SkipOnOpen))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := circuitbreaker.Summary{Processed: 3, Failed: 3, Skipped: 7}
+	if sum != want {
+		t.Errorf("summary = %+v, want %+v", sum, want)
+	}
+}
+
+func TestExecuteSeq_WaitsForTheCircuitToClose(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		SuccessThreshold: 1,
+		Timeout:          10 * time.Second,
+		Clock:            clock,
+		Strict:           true,
+	})
+	var calls atomic.Int32
+	// the downstream fails its first three calls, then recovers.
+	fn := func(int) error {
+		if calls.Add(1) <= 3 {
+			return errSimulated
+		}
+		return nil
+	}
+
+	type outcome struct {
+		sum circuitbreaker.Summary
+		err error
+	}
+	done := make(chan outcome, 1)
+	var taken atomic.Int32
+	go func() {
+		sum, err := circuitbreaker.ExecuteSeq(context.Background(), cb, items(10, &taken), fn,
+			circuitbreaker.WithOpenPolicy(circuitbreaker.WaitOnOpen))
+		done <- outcome{sum, err}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.PendingTimers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("ExecuteSeq never started waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected Open while waiting, got %v", cb.State())
+	}
+	clock.Advance(10 * time.Second)
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			t.Fatalf("expected no error, got %v", o.err)
+		}
+		want := circuitbreaker.Summary{Processed: 10, Failed: 3}
+		if o.sum != want {
+			t.Errorf("summary = %+v, want %+v", o.sum, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExecuteSeq did not finish after the circuit recovered")
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected Closed, got %v", cb.State())
+	}
+}
+
+func TestExecuteSeq_WaitsWithoutRetryingWhileHeldOpen(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Timeout: 10 * time.Second, Clock: clock, Strict: true})
+	cb.ForceOpen()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	var taken atomic.Int32
+	go func() {
+		_, err := circuitbreaker.ExecuteSeq(ctx, cb, items(1, &taken), func(int) error { return nil },
+			circuitbreaker.WithOpenPolicy(circuitbreaker.WaitOnOpen))
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for cb.Totals().Rejected == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("ExecuteSeq never tried the held-open circuit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// a forced circuit is not due to half-open when its timeout passes.
+	clock.Advance(time.Minute)
+	// give a caller woken by a retry timer the time to be rejected again.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExecuteSeq did not stop when cancelled")
+	}
+	if tot := cb.Totals(); tot.Rejected != 1 {
+		t.Errorf("expected one rejection and no retries, got %+v", tot)
+	}
+}
+
+func TestExecuteSeq_BoundsParallelism(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	var (
+		mu            sync.Mutex
+		running, peak int
+		taken         atomic.Int32
+		allStarted    = make(chan struct{})
+		once          sync.Once
+	)
+	fn := func(int) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		if running == 4 {
+			once.Do(func() { close(allStarted) })
+		}
+		mu.Unlock()
+		// hold the first items until every worker is busy.
+		select {
+		case <-allStarted:
+		case <-time.After(5 * time.Second):
+		}
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	sum, err := circuitbreaker.ExecuteSeq(context.Background(), cb, items(40, &taken), fn,
+		circuitbreaker.WithParallelism(4))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sum != (circuitbreaker.Summary{Processed: 40}) {
+		t.Errorf("summary = %+v, want 40 processed", sum)
+	}
+	if peak != 4 {
+		t.Errorf("peak concurrency = %d, want 4", peak)
+	}
+}
+
+func TestExecuteSeq_ParallelStopsFeeding(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 3, Strict: true})
+	var taken atomic.Int32
+
+	sum, err := circuitbreaker.ExecuteSeq(context.Background(), cb, items(1000, &taken), failing,
+		circuitbreaker.WithParallelism(4))
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if sum.Failed < 3 || sum.Processed != sum.Failed || sum.Skipped == 0 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	if n := int(taken.Load()); n > sum.Processed+sum.Skipped+1 {
+		t.Errorf("took %d items but only accounted for %d", n, sum.Processed+sum.Skipped)
+	}
+}
+
+func TestExecuteSeq_ContextCancelled(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	var taken atomic.Int32
+	fn := func(i int) error {
+		if i == 4 {
+			cancel()
+		}
+		return nil
+	}
+
+	sum, err := circuitbreaker.ExecuteSeq(ctx, cb, items(10, &taken), fn)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if sum != (circuitbreaker.Summary{Processed: 5}) {
+		t.Errorf("summary = %+v, want 5 processed", sum)
+	}
+}
+
+func TestExecuteSeq_NilFunctionAndBreaker(t *testing.T) {
+	var taken atomic.Int32
+	if _, err := circuitbreaker.ExecuteSeq[int](context.Background(), nil, items(3, &taken), nil); !errors.Is(err, circuitbreaker.ErrNilFunction) {
+		t.Errorf("expected ErrNilFunction, got %v", err)
+	}
+
+	sum, err := circuitbreaker.ExecuteSeq(context.Background(), nil, items(5, &taken), failing)
+	if err != nil || sum != (circuitbreaker.Summary{Processed: 5, Failed: 5}) {
+		t.Errorf("nil breaker: got %+v, %v", sum, err)
+	}
+}
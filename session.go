@@ -0,0 +1,74 @@
+package circuitbreaker
+
+import "time"
+
+// ObserveSession reports the end of a long-lived session, such as a
+// websocket, a gRPC stream or a replication link, that started at start
+// and ended with err (nil for a clean close). For connections like these
+// the unit of failure is a reset rather than a request: five resets in
+// ten minutes mean the path is unhealthy even though every session lasted
+// minutes.
+//
+// It does nothing unless Config.SessionFailureThreshold is set. While
+// Closed, each session adds its weight (see SessionFailureThreshold) to a
+// SessionWindow-long window, and the circuit opens with reason
+// "session churn" once the window reaches the threshold. While HalfOpen,
+// only sessions started since the circuit went half-open count: a healthy
+// one counts toward SuccessThreshold like a successful probe and a failed
+// one reopens the circuit. Observations while Open, or on a nil or closed
+// breaker, during a maintenance window or while ejected, are ignored.
+func (cb *CircuitBreaker) ObserveSession(start time.Time, err error) {
+	if cb == nil {
+		return
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.sessions == nil || cb.closed || cb.maintenance || cb.ejected {
+		return
+	}
+	now := cb.clock.Now()
+	weight := cb.sessionWeight(elapsed(start, now), err)
+
+	switch cb.state {
+	case Closed:
+		if weight == 0 {
+			return
+		}
+		cb.sessions.addWeight(now, weight)
+		if cb.sessions.weight(now) >= cb.config.SessionFailureThreshold {
+			cb.setState(Open, ReasonSessionChurn)
+		}
+	case HalfOpen:
+		if start.Before(cb.lastStateChange) {
+			return
+		}
+		if weight > 0 {
+			cb.setState(Open, ReasonSessionChurn)
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.config.SuccessThreshold {
+			cb.setState(Closed, ReasonRecovered)
+			return
+		}
+		cb.checkInvariants(cb.state)
+	}
+}
+
+// sessionWeight is how much a session that lived for lived and ended with
+// err counts as a failure, between 0 and 1.
+func (cb *CircuitBreaker) sessionWeight(lived time.Duration, err error) float64 {
+	if err == nil {
+		return 0
+	}
+	healthy := cb.config.HealthyAfter
+	if healthy <= 0 {
+		return 1
+	}
+	if lived >= healthy {
+		return 0
+	}
+	return 1 - float64(max(lived, 0))/float64(healthy)
+}
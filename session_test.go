@@ -0,0 +1,138 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newSessionBreaker(clock *cbt.FakeClock, events *[]circuitbreaker.Event) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:                    "replication",
+		SuccessThreshold:        1,
+		SessionFailureThreshold: 3,
+		SessionWindow:           10 * time.Minute,
+		HealthyAfter:            5 * time.Minute,
+		Clock:                   clock,
+		Strict:                  true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if events != nil {
+				*events = append(*events, ev)
+			}
+		},
+	})
+}
+
+// session runs one session on clock for lived and reports how it ended.
+func session(cb *circuitbreaker.CircuitBreaker, clock *cbt.FakeClock, lived time.Duration, err error) {
+	start := clock.Now()
+	clock.Advance(lived)
+	cb.ObserveSession(start, err)
+}
+
+func TestObserveSession_TripsOnChurn(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var events []circuitbreaker.Event
+	cb := newSessionBreaker(clock, &events)
+
+	// sessions dying after 30s weigh 0.9 each.
+	for i := 0; i < 3; i++ {
+		session(cb, clock, 30*time.Second, errSimulated)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected Closed at 2.7 weighted failures, got %v", cb.State())
+	}
+	if got := cb.Status().SessionFailures; got < 2.69 || got > 2.71 {
+		t.Errorf("expected 2.7 weighted failures, got %v", got)
+	}
+	session(cb, clock, 30*time.Second, errSimulated)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected the fourth reset in two minutes to trip, got %v", cb.State())
+	}
+	last := events[len(events)-1]
+	if last.Type != circuitbreaker.EventStateChange || last.Reason != circuitbreaker.ReasonSessionChurn {
+		t.Errorf("expected a session churn trip, got %+v", last)
+	}
+}
+
+func TestObserveSession_LongLivedDropsDoNotTrip(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newSessionBreaker(clock, nil)
+
+	// for two hours, sessions live four minutes (weight 0.2) or past
+	// HealthyAfter before dropping with an error.
+	for i := 0; i < 40; i++ {
+		lived := 4 * time.Minute
+		if i%2 == 1 {
+			lived = 6 * time.Minute
+		}
+		session(cb, clock, lived, errSimulated)
+		if cb.State() != circuitbreaker.Closed {
+			t.Fatalf("tripped on session %d with %v weighted failures", i, cb.Status().SessionFailures)
+		}
+	}
+	// clean closes never count, however short.
+	for i := 0; i < 10; i++ {
+		session(cb, clock, time.Second, nil)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("clean closes tripped the circuit")
+	}
+}
+
+func TestObserveSession_WindowIsTimeBased(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newSessionBreaker(clock, nil)
+
+	session(cb, clock, 0, errSimulated)
+	session(cb, clock, 0, errSimulated)
+	clock.Advance(11 * time.Minute)
+	if got := cb.Status().SessionFailures; got != 0 {
+		t.Errorf("expected old resets to age out, got %v", got)
+	}
+	session(cb, clock, 0, errSimulated)
+	session(cb, clock, 0, errSimulated)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("resets more than SessionWindow apart should not add up, got %v", cb.State())
+	}
+}
+
+func TestObserveSession_HalfOpen(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newSessionBreaker(clock, nil)
+	staleStart := clock.Now()
+	clock.Advance(time.Second)
+	cbt.AdvanceToHalfOpen(cb)
+
+	// a session from before the trip says nothing about recovery.
+	cb.ObserveSession(staleStart, nil)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected a stale session to be ignored, got %v", cb.State())
+	}
+	session(cb, clock, time.Second, errSimulated)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected a failed session to reopen, got %v", cb.State())
+	}
+
+	cbt.AdvanceToHalfOpen(cb)
+	session(cb, clock, 6*time.Minute, errSimulated)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected a session past HealthyAfter to close, got %v", cb.State())
+	}
+}
+
+func TestObserveSession_DisabledByDefault(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: clock, Strict: true})
+	for i := 0; i < 100; i++ {
+		session(cb, clock, 0, errSimulated)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected ObserveSession to do nothing without SessionFailureThreshold, got %v", cb.State())
+	}
+
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	nilBreaker.ObserveSession(clock.Now(), errSimulated)
+}
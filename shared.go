@@ -0,0 +1,84 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is one shared execution and the callers waiting for it.
+type flight struct {
+	done   chan struct{}
+	result any
+	err    error
+	// Set if the shared call panicked; re-raised in every waiter.
+	panicked  bool
+	panicking any
+}
+
+// flights tracks the shared executions in progress, by key.
+type flights struct {
+	mu sync.Mutex
+	m  map[string]*flight
+	// Callers currently waiting on any flight.
+	waiting int
+}
+
+// ExecuteShared is like Execute, but concurrent calls with the same key
+// share a single execution of fn: the first caller starts it through the
+// breaker, later callers wait for it, and all of them get its result and
+// error. The breaker records one outcome per actual execution. Each caller
+// stops waiting and returns ctx's error if its own ctx is done first; the
+// shared execution carries on for the others. Use it for read paths where
+// identical concurrent requests, such as for the same cache key, can be
+// collapsed.
+func (cb *CircuitBreaker) ExecuteShared(ctx context.Context, key string, fn func() (any, error), opts ...CallOption) (any, error) {
+	if fn == nil {
+		return nil, ErrNilFunction
+	}
+	if cb == nil {
+		return fn()
+	}
+	cb.lazyInit()
+
+	cb.flights.mu.Lock()
+	if cb.flights.m == nil {
+		cb.flights.m = make(map[string]*flight)
+	}
+	f, ok := cb.flights.m[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		cb.flights.m[key] = f
+		go cb.fly(key, f, fn, opts)
+	}
+	cb.flights.waiting++
+	cb.flights.mu.Unlock()
+	defer func() {
+		cb.flights.mu.Lock()
+		cb.flights.waiting--
+		cb.flights.mu.Unlock()
+	}()
+
+	select {
+	case <-f.done:
+		if f.panicked {
+			panic(f.panicking)
+		}
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fly runs a shared execution and hands its outcome to the waiters.
+func (cb *CircuitBreaker) fly(key string, f *flight, fn func() (any, error), opts []CallOption) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.panicked, f.panicking = true, r
+		}
+		cb.flights.mu.Lock()
+		delete(cb.flights.m, key)
+		cb.flights.mu.Unlock()
+		close(f.done)
+	}()
+	f.result, f.err = cb.Execute(fn, opts...)
+}
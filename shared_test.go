@@ -0,0 +1,125 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+)
+
+// shareCalls starts n ExecuteShared callers on the same key and waits
+// until all of them are waiting on the shared execution.
+func shareCalls(t *testing.T, cb *circuitbreaker.CircuitBreaker, ctxs []context.Context, fn func() (any, error)) []chan sharedResult {
+	t.Helper()
+	results := make([]chan sharedResult, len(ctxs))
+	for i, ctx := range ctxs {
+		results[i] = make(chan sharedResult, 1)
+		go func(ctx context.Context, out chan<- sharedResult) {
+			v, err := cb.ExecuteShared(ctx, "user:42", fn)
+			out <- sharedResult{v, err}
+		}(ctx, results[i])
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for cb.Status().SharedWaiters < len(ctxs) {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d callers joined", cb.Status().SharedWaiters, len(ctxs))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return results
+}
+
+type sharedResult struct {
+	v   any
+	err error
+}
+
+func background(n int) []context.Context {
+	ctxs := make([]context.Context, n)
+	for i := range ctxs {
+		ctxs[i] = context.Background()
+	}
+	return ctxs
+}
+
+func TestExecuteShared_OneInvocationForConcurrentCallers(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	var invocations atomic.Int32
+	release := make(chan struct{})
+	results := shareCalls(t, cb, background(50), func() (any, error) {
+		invocations.Add(1)
+		<-release
+		return "profile", nil
+	})
+	close(release)
+
+	for i, r := range results {
+		if got := <-r; got.err != nil || got.v != "profile" {
+			t.Errorf("caller %d got %v, %v", i, got.v, got.err)
+		}
+	}
+	if n := invocations.Load(); n != 1 {
+		t.Errorf("expected exactly one downstream invocation, got %d", n)
+	}
+	if c := cb.Counts(); c.Successes != 1 {
+		t.Errorf("expected one recorded outcome, got %+v", c)
+	}
+}
+
+func TestExecuteShared_FansOutErrors(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	release := make(chan struct{})
+	results := shareCalls(t, cb, background(10), func() (any, error) {
+		<-release
+		return failFn()
+	})
+	close(release)
+
+	for i, r := range results {
+		if got := <-r; !errors.Is(got.err, errSimulated) {
+			t.Errorf("caller %d: expected the shared error, got %v", i, got.err)
+		}
+	}
+	if c := cb.Counts(); c.ConsecutiveFailures != 1 {
+		t.Errorf("expected one recorded failure, got %+v", c)
+	}
+}
+
+func TestExecuteShared_CancelledWaiterLeavesCallRunning(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	ctxs := append([]context.Context{ctx}, background(3)...)
+	release := make(chan struct{})
+	results := shareCalls(t, cb, ctxs, func() (any, error) {
+		<-release
+		return "ok", nil
+	})
+
+	cancel()
+	if got := <-results[0]; !errors.Is(got.err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to give up, got %v", got.err)
+	}
+	close(release)
+	for i, r := range results[1:] {
+		if got := <-r; got.err != nil || got.v != "ok" {
+			t.Errorf("caller %d: expected the shared result, got %v, %v", i+1, got.v, got.err)
+		}
+	}
+}
+
+func TestExecuteShared_SequentialCallsRunAgain(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Strict: true})
+	calls := 0
+	for i := 0; i < 3; i++ {
+		cb.ExecuteShared(context.Background(), "k", func() (any, error) {
+			calls++
+			return nil, nil
+		})
+	}
+	if calls != 3 {
+		t.Errorf("expected each non-overlapping call to execute, got %d", calls)
+	}
+}
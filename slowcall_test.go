@@ -0,0 +1,92 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// slowFn returns a request that succeeds after d on clock.
+func slowFn(clock *cbt.FakeClock, d time.Duration) func() (any, error) {
+	return func() (any, error) {
+		clock.Advance(d)
+		return "ok", nil
+	}
+}
+
+func TestSlowCalls_TripOnSlowRate(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var reason string
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		SlowCallDuration:      2 * time.Second,
+		SlowCallRateThreshold: 0.6,
+		SlowCallWindowSize:    5,
+		Clock:                 clock,
+		Strict:                true,
+		OnEvent: func(ev circuitbreaker.Event) {
+			if ev.To == circuitbreaker.Open {
+				reason = ev.Reason
+			}
+		},
+	})
+	slow, fast := slowFn(clock, 2*time.Second), slowFn(clock, time.Second)
+	for i, fn := range []func() (any, error){slow, fast, slow, fast} {
+		if v, err := cb.Execute(fn); v != "ok" || err != nil {
+			t.Fatalf("call %d: expected slow calls to return their result, got %v %v", i, v, err)
+		}
+	}
+	if s := cb.Status(); s.State != circuitbreaker.Closed || s.SlowCallRate != 0.5 {
+		t.Fatalf("expected a half-slow window to stay closed, got %v %v", s.State, s.SlowCallRate)
+	}
+	cb.Execute(slow)
+	if cb.State() != circuitbreaker.Open || reason != circuitbreaker.ReasonSlowCalls {
+		t.Fatalf("expected 3 slow calls in 5 to trip, got %v %q", cb.State(), reason)
+	}
+	if tot := cb.Totals(); tot.Successes != 5 || tot.Failures != 0 {
+		t.Errorf("expected slow calls to count as successes, got %+v", tot)
+	}
+	if rate := cb.Status().SlowCallRate; rate != 0 {
+		t.Errorf("expected the window to start empty after tripping, got %v", rate)
+	}
+}
+
+func TestSlowCalls_SlowSuccessKeepsFailures(t *testing.T) {
+	for _, tc := range []struct {
+		latency time.Duration
+		want    circuitbreaker.State
+	}{
+		{time.Second, circuitbreaker.Closed},
+		{3 * time.Second, circuitbreaker.Open},
+	} {
+		clock := cbt.NewFakeClock(cbt.Epoch)
+		cb := circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold:      3,
+			SlowCallDuration:      2 * time.Second,
+			SlowCallRateThreshold: 1,
+			Clock:                 clock,
+			Strict:                true,
+		})
+		cb.Execute(failFn)
+		cb.Execute(failFn)
+		cb.Execute(slowFn(clock, tc.latency))
+		cb.Execute(failFn)
+		if got := cb.State(); got != tc.want {
+			t.Errorf("success after %v: expected %v, got %v", tc.latency, tc.want, got)
+		}
+	}
+}
+
+func TestSlowCalls_Validate(t *testing.T) {
+	for _, cfg := range []circuitbreaker.Config{
+		{SlowCallDuration: -time.Second},
+		{SlowCallDuration: time.Second},
+		{SlowCallDuration: time.Second, SlowCallRateThreshold: 1.5},
+		{SlowCallDuration: time.Second, SlowCallRateThreshold: 0.5, SlowCallWindowSize: -1},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
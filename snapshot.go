@@ -0,0 +1,293 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is the version of the Snapshot format written by
+// Snapshot. Version 1 snapshots carry only the state and counters;
+// version 2 adds the contents of the time windows and version 3 those of
+// the count-based windows, the run of reopens and ConsecutiveSuccesses.
+const SnapshotVersion = 3
+
+// ErrSnapshotVersion is returned by Restore for a snapshot of a version
+// it does not know.
+var ErrSnapshotVersion = errors.New("circuit breaker: unsupported snapshot version")
+
+// Snapshot is the part of a breaker's state worth carrying across a
+// restart, so that a deploy does not give a failing dependency a fresh
+// grace period. It marshals to JSON.
+type Snapshot struct {
+	Version int `json:"version"`
+	// Taken is when the snapshot was taken, on the breaker's clock.
+	Taken               time.Time `json:"taken"`
+	State               State     `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	// FailureWeight is the accumulated weight of the consecutive
+	// failures, kept only when Config.FailureWeight is set; otherwise it
+	// is their number.
+	FailureWeight        float64   `json:"failure_weight,omitzero"`
+	Successes            int       `json:"successes"`
+	ConsecutiveSuccesses int       `json:"consecutive_successes,omitzero"`
+	LastFailure          time.Time `json:"last_failure,omitzero"`
+	LastStateChange      time.Time `json:"last_state_change,omitzero"`
+	// Reopens is how many failed probes in a row have reopened the
+	// circuit, on which Config.OpenTimeoutBackoff bases the open timeout.
+	Reopens int `json:"reopens,omitzero"`
+	// RampPercent is Status.RampPercent when the snapshot was taken. It is
+	// for display only: a restored ramp carries on from LastStateChange.
+	RampPercent float64 `json:"ramp_percent,omitzero"`
+	// SuccessLatency and FailureLatency are the LatencyStats when the
+	// snapshot was taken, for display only; Restore ignores them.
+	SuccessLatency LatencyStats `json:"success_latency,omitzero"`
+	FailureLatency LatencyStats `json:"failure_latency,omitzero"`
+	// Windows holds the breaker's time windows, from version 2 on.
+	Windows []WindowSnapshot `json:"windows,omitempty"`
+	// CallWindows holds the breaker's count-based windows, from version 3
+	// on.
+	CallWindows []CallWindowSnapshot `json:"call_windows,omitempty"`
+}
+
+// CallWindowSnapshot is the contents of one count-based window.
+type CallWindowSnapshot struct {
+	// Setting names the configuration field the window comes from,
+	// "WindowSize" or "SlowCallWindowSize".
+	Setting string `json:"setting"`
+	// Outcomes are the calls the window holds, oldest first: true for a
+	// failure, or for a slow call in the SlowCallWindowSize window.
+	Outcomes []bool `json:"outcomes"`
+}
+
+// WindowSnapshot is the contents of one time window.
+type WindowSnapshot struct {
+	// Setting names the configuration field the window comes from, such
+	// as "FailureRateWindows[1]" or "SpikeShortWindow".
+	Setting string `json:"setting"`
+	// Buckets are the window's non-empty buckets, oldest first.
+	Buckets []BucketSnapshot `json:"buckets"`
+}
+
+// BucketSnapshot is one bucket of a time window.
+type BucketSnapshot struct {
+	// Start is the beginning of the bucket's interval.
+	Start    time.Time `json:"start"`
+	Total    int       `json:"total"`
+	Failures int       `json:"failures"`
+	Weight   float64   `json:"weight,omitempty"`
+}
+
+// Snapshot returns the breaker's state, counters and window contents. A
+// nil breaker returns a Closed snapshot.
+func (cb *CircuitBreaker) Snapshot() Snapshot {
+	if cb == nil {
+		return Snapshot{Version: SnapshotVersion, State: Closed}
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.snapshot(cb.clock.Now())
+}
+
+// snapshot is Snapshot with cb.mu held.
+func (cb *CircuitBreaker) snapshot(now time.Time) Snapshot {
+	s := Snapshot{
+		Version:              SnapshotVersion,
+		Taken:                now,
+		State:                cb.state,
+		ConsecutiveFailures:  cb.failures,
+		ConsecutiveSuccesses: cb.consecutiveSuccesses,
+		Successes:            cb.successes,
+		LastFailure:          cb.lastFailureTime,
+		LastStateChange:      cb.lastStateChange,
+		Reopens:              cb.reopens,
+		RampPercent:          cb.currentRampPercent(now),
+		SuccessLatency:       cb.latencies.success.Stats(),
+		FailureLatency:       cb.latencies.failure.Stats(),
+	}
+	if cb.config.FailureWeight != nil {
+		s.FailureWeight = cb.failureWeight
+	}
+	windows := cb.windows()
+	for _, setting := range slices.Sorted(maps.Keys(windows)) {
+		s.Windows = append(s.Windows, WindowSnapshot{Setting: setting, Buckets: windows[setting].snapshot(now)})
+	}
+	callWindows := cb.callWindows()
+	for _, setting := range slices.Sorted(maps.Keys(callWindows)) {
+		s.CallWindows = append(s.CallWindows, CallWindowSnapshot{Setting: setting, Outcomes: callWindows[setting].snapshot()})
+	}
+	return s
+}
+
+// Restore makes the breaker carry on from s, typically taken by a
+// previous process. Window buckets are placed by their timestamps on the
+// breaker's clock, so those that have aged out since s was taken are
+// dropped and the rest expire on time; a count-based window keeps as many
+// of the latest outcomes as it now holds; windows whose setting is no
+// longer configured are ignored. Restoring is not a transition, so OnStateChange
+// is not called, and calls in flight are not counted against the restored
+// state. While a maintenance window or an ejection holds the circuit open
+// the state is left alone. A version 1 snapshot restores only the state
+// and counters, and a version 2 one no count-based windows. Restoring a nil breaker does nothing.
+func (cb *CircuitBreaker) Restore(s Snapshot) error {
+	if cb == nil {
+		return nil
+	}
+	cb.lazyInit()
+	return cb.restore(s)
+}
+
+// restore is Restore once the breaker is initialised.
+func (cb *CircuitBreaker) restore(s Snapshot) error {
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, s.Version)
+	}
+	if s.State < Closed || s.State > HalfOpen {
+		return fmt.Errorf("circuit breaker: snapshot has unknown state %d", int(s.State))
+	}
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	now := cb.clock.Now()
+	from := cb.state
+	if !cb.maintenance && !cb.ejected {
+		cb.accountStateTime(now)
+		cb.state = s.State
+		cb.published.Store(int32(s.State))
+		cb.generation++
+		cb.ramp = rampState{}
+		cb.probeFailures = 0
+		cb.reopens = s.Reopens
+		cb.lastStateChange = s.LastStateChange
+		if cb.lastStateChange.IsZero() {
+			cb.lastStateChange = now
+		}
+		cb.trackEpisode(from, cb.state)
+		cb.trackHealthCheck(from, cb.state)
+	}
+	cb.failures = s.ConsecutiveFailures
+	cb.failureWeight = float64(s.ConsecutiveFailures)
+	if s.FailureWeight > 0 {
+		cb.failureWeight = s.FailureWeight
+	}
+	cb.successes = s.Successes
+	if cb.state == HalfOpen {
+		cb.successes = min(cb.successes, cb.config.SuccessThreshold)
+	}
+	cb.consecutiveSuccesses = min(s.ConsecutiveSuccesses, cb.successes)
+	cb.lastFailureTime = s.LastFailure
+
+	windows := cb.windows()
+	for _, ws := range s.Windows {
+		if w, ok := windows[ws.Setting]; ok {
+			w.restore(now, ws.Buckets)
+		}
+	}
+	callWindows := cb.callWindows()
+	for _, ws := range s.CallWindows {
+		if w, ok := callWindows[ws.Setting]; ok {
+			w.restore(ws.Outcomes)
+		}
+	}
+	cb.signalFreed()
+	cb.updateDegraded()
+	// not a transition, so the restored state need not follow from the
+	// old one.
+	cb.checkInvariants(cb.state)
+	return nil
+}
+
+// windows returns the breaker's time windows by setting. Must be called
+// with cb.mu held.
+func (cb *CircuitBreaker) windows() map[string]*bucketWindow {
+	windows := map[string]*bucketWindow{}
+	if cb.rate != nil {
+		for i, b := range cb.rate.buckets {
+			windows[fmt.Sprintf("FailureRateWindows[%d]", i)] = b
+		}
+	}
+	if cb.spike != nil {
+		windows["SpikeShortWindow"] = cb.spike.short
+		windows["SpikeLongWindow"] = cb.spike.long
+	}
+	if cb.sessions != nil {
+		windows["SessionWindow"] = cb.sessions
+	}
+	return windows
+}
+
+// callWindows returns the breaker's count-based windows by setting. Must
+// be called with cb.mu held.
+func (cb *CircuitBreaker) callWindows() map[string]*callWindow {
+	windows := map[string]*callWindow{}
+	if cb.calls != nil {
+		windows["WindowSize"] = cb.calls
+	}
+	if cb.slowCalls != nil {
+		windows["SlowCallWindowSize"] = cb.slowCalls
+	}
+	return windows
+}
+
+// snapshot returns the window's live, non-empty buckets as of now, oldest
+// first.
+func (w *bucketWindow) snapshot(now time.Time) []BucketSnapshot {
+	var out []BucketSnapshot
+	n := int64(len(w.buckets))
+	for idx := w.head - n + 1; idx <= w.head; idx++ {
+		i := w.pos(idx)
+		b := w.buckets[i]
+		if b == (windowBucket{}) || !w.live(i, now) {
+			continue
+		}
+		out = append(out, BucketSnapshot{
+			Start:    time.Unix(0, idx*int64(w.width)),
+			Total:    b.total,
+			Failures: b.failures,
+			Weight:   b.weight,
+		})
+	}
+	return out
+}
+
+// restore replaces the window's contents with buckets, realigned to now:
+// each lands in the bucket covering its start, those that have aged out
+// are dropped, and those from the future count as now.
+func (w *bucketWindow) restore(now time.Time, buckets []BucketSnapshot) {
+	w.reset()
+	w.head = 0
+	w.rotate(now)
+	n := int64(len(w.buckets))
+	for _, b := range buckets {
+		idx := min(b.Start.UnixNano()/int64(w.width), w.head)
+		if idx <= w.head-n {
+			continue
+		}
+		slot := &w.buckets[w.pos(idx)]
+		slot.total += b.Total
+		slot.failures += b.Failures
+		slot.weight += b.Weight
+	}
+}
+
+// snapshot returns the outcomes the window holds, oldest first.
+func (w *callWindow) snapshot() []bool {
+	out := make([]bool, 0, w.size)
+	for i := range w.size {
+		out = append(out, w.outcomes[(w.next-w.size+i+len(w.outcomes))%len(w.outcomes)])
+	}
+	return out
+}
+
+// restore replaces the window's contents with outcomes, keeping the
+// latest when there are more than it holds.
+func (w *callWindow) restore(outcomes []bool) {
+	w.reset()
+	for _, failed := range outcomes {
+		w.record(failed)
+	}
+}
@@ -0,0 +1,184 @@
+package circuitbreaker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newWindowedBreaker(clock *cbt.FakeClock) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:             "orders",
+		FailureThreshold: 1000,
+		FailureRateWindows: []circuitbreaker.RateWindow{
+			{Duration: time.Minute, FailureRateThreshold: 0.5, MinRequests: 20},
+		},
+		Clock:  clock,
+		Strict: true,
+	})
+}
+
+func TestSnapshot_RestoresWindowRealignedToClock(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newWindowedBreaker(clock)
+
+	// 10 calls in the first 30s, 8 of them failures; 10 successes in the
+	// next 20s.
+	for i := 0; i < 10; i++ {
+		if i < 8 {
+			cb.Execute(failFn)
+		} else {
+			cb.Execute(successFn)
+		}
+		clock.Advance(3 * time.Second)
+	}
+	for i := 0; i < 10; i++ {
+		cb.Execute(successFn)
+		clock.Advance(2 * time.Second)
+	}
+	if got := cb.Status().WindowFailureRates[0]; math.Abs(got-0.4) > 1e-9 {
+		t.Fatalf("window rate before snapshot = %v, want 0.4", got)
+	}
+
+	data, err := json.Marshal(cb.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore := func() *circuitbreaker.CircuitBreaker {
+		var snap circuitbreaker.Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			t.Fatal(err)
+		}
+		restored := newWindowedBreaker(clock)
+		if err := restored.Restore(snap); err != nil {
+			t.Fatal(err)
+		}
+		return restored
+	}
+
+	// a 20s deploy: the calls of the first 12s have aged out, leaving 4 of
+	// the failures among 16 calls, just as in the breaker that kept running.
+	clock.Advance(20 * time.Second)
+	got, want := restore().Status().WindowFailureRates[0], cb.Status().WindowFailureRates[0]
+	if math.Abs(got-0.25) > 1e-9 || got != want {
+		t.Errorf("restored window rate = %v, want 0.25 like the original's %v", got, want)
+	}
+
+	// after another 35s only the later successes are left.
+	clock.Advance(35 * time.Second)
+	if got := restore().Status().WindowFailureRates[0]; got != 0 {
+		t.Errorf("expected the failures to have aged out, got rate %v", got)
+	}
+}
+
+func TestSnapshot_CarriesStateAcrossRestart(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Timeout: time.Minute, Clock: clock, Strict: true})
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	snap := cb.Snapshot()
+	if snap.Version != circuitbreaker.SnapshotVersion || snap.State != circuitbreaker.Open {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+
+	clock.Advance(30 * time.Second)
+	restored := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Timeout: time.Minute, Clock: clock, Strict: true})
+	if err := restored.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the restored circuit open, got %v", err)
+	}
+	// the open period counts from the original trip, not the restart.
+	clock.Advance(30 * time.Second)
+	if _, err := restored.Execute(successFn); err != nil {
+		t.Errorf("expected a probe a minute after the original trip, got %v", err)
+	}
+}
+
+func TestSnapshot_RestoresHalfOpenOntoClosedBreaker(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, SuccessThreshold: 2, Clock: clock, Strict: true})
+	cbt.AdvanceToHalfOpen(cb)
+	cb.Execute(successFn)
+
+	restored := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, SuccessThreshold: 2, Clock: clock, Strict: true})
+	if err := restored.Restore(cb.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	if restored.State() != circuitbreaker.HalfOpen || restored.Counts().Successes != 1 {
+		t.Errorf("expected the half-open state restored, got %v %+v", restored.State(), restored.Counts())
+	}
+}
+
+func TestSnapshot_VersionCompatibility(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newWindowedBreaker(clock)
+
+	// a version 1 snapshot has no windows.
+	v1 := `{"version":1,"state":0,"consecutive_failures":4,"successes":0}`
+	var snap circuitbreaker.Snapshot
+	if err := json.Unmarshal([]byte(v1), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Restore(snap); err != nil {
+		t.Fatalf("expected a version 1 snapshot to restore, got %v", err)
+	}
+	if c := cb.Counts(); c.ConsecutiveFailures != 4 {
+		t.Errorf("expected the counters restored, got %+v", c)
+	}
+
+	for _, v := range []int{0, circuitbreaker.SnapshotVersion + 1} {
+		if err := cb.Restore(circuitbreaker.Snapshot{Version: v}); !errors.Is(err, circuitbreaker.ErrSnapshotVersion) {
+			t.Errorf("version %d: expected ErrSnapshotVersion, got %v", v, err)
+		}
+	}
+	var nilBreaker *circuitbreaker.CircuitBreaker
+	if err := nilBreaker.Restore(nilBreaker.Snapshot()); err != nil {
+		t.Errorf("expected a nil breaker to ignore Restore, got %v", err)
+	}
+}
+
+func TestSnapshot_CarriesCallWindowsAndBackoff(t *testing.T) {
+	t.Run("call window", func(t *testing.T) {
+		cb := newCallWindowBreaker(5)
+		const f, s = true, false
+		// 2 failures in 10; one more would make 30%.
+		play(cb, s, s, s, s, s, s, s, s, f, f)
+		data, err := json.Marshal(cb.Snapshot())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var snap circuitbreaker.Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			t.Fatal(err)
+		}
+		restored := newCallWindowBreaker(5)
+		if err := restored.Restore(snap); err != nil {
+			t.Fatal(err)
+		}
+		if i := play(restored, f); i != 0 {
+			t.Errorf("expected the restored window to trip on the next failure, state %v", restored.State())
+		}
+	})
+	t.Run("backoff", func(t *testing.T) {
+		clock := cbt.NewFakeClock(cbt.Epoch)
+		cb := backoffBreaker(clock)
+		cb.Execute(failFn)
+		waitOutOpen(t, cb, clock, 5*time.Second, failFn)
+		waitOutOpen(t, cb, clock, 10*time.Second, failFn)
+
+		restored := backoffBreaker(clock)
+		if err := restored.Restore(cb.Snapshot()); err != nil {
+			t.Fatal(err)
+		}
+		if got := restored.Status().OpenRemaining; got != 20*time.Second {
+			t.Errorf("expected the restored backoff to carry on at 20s, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package circuitbreaker
+
+import "time"
+
+// spikeDetector opens the circuit when the failure rate over a short window
+// jumps well above the baseline rate of the longer window before it. The
+// baseline excludes the short window so a spike does not inflate its own
+// yardstick.
+type spikeDetector struct {
+	short, long *bucketWindow
+	multiplier  float64
+	minRate     float64
+	minRequests int
+	// Most recent short-to-baseline ratio, reported in Status.
+	ratio float64
+}
+
+// newSpikeDetector returns nil when spike detection is not configured.
+func newSpikeDetector(cfg Config) *spikeDetector {
+	if cfg.SpikeMultiplier <= 0 {
+		return nil
+	}
+	return &spikeDetector{
+		short:       newBucketWindow(cfg.SpikeShortWindow, windowBuckets),
+		long:        newBucketWindow(cfg.SpikeLongWindow, windowBuckets),
+		multiplier:  cfg.SpikeMultiplier,
+		minRate:     cfg.SpikeMinRate,
+		minRequests: cfg.SpikeMinRequests,
+	}
+}
+
+// record adds an outcome and reports whether the failure rate has spiked.
+func (s *spikeDetector) record(now time.Time, failed bool) bool {
+	s.short.add(now, failed)
+	s.long.add(now, failed)
+
+	shortTotal, shortFailures := s.short.counts(now)
+	longTotal, longFailures := s.long.counts(now)
+	baseTotal := longTotal - shortTotal
+	baseFailures := max(0, longFailures-shortFailures)
+	if shortTotal == 0 || baseTotal <= 0 {
+		s.ratio = 0
+		return false
+	}
+
+	shortRate := float64(shortFailures) / float64(shortTotal)
+	// Less than one failure in the baseline is treated as one, which keeps
+	// the ratio finite for a perfectly healthy history.
+	baseRate := float64(max(baseFailures, 1)) / float64(baseTotal)
+	s.ratio = shortRate / baseRate
+
+	return shortTotal >= s.minRequests && shortRate >= s.minRate && s.ratio >= s.multiplier
+}
+
+// reset forgets all recorded outcomes.
+func (s *spikeDetector) reset() {
+	s.short.reset()
+	s.long.reset()
+	s.ratio = 0
+}
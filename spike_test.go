@@ -0,0 +1,108 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// trace replays synthetic traffic at 20 calls per second. rate gives the
+// failure rate at each point in time; failures are spread out evenly
+// (error diffusion) so runs are deterministic and never consecutive.
+type trace struct {
+	clock *cbt.FakeClock
+	cb    *circuitbreaker.CircuitBreaker
+	debt  float64
+}
+
+func (tr *trace) run(d time.Duration, rate func(elapsed time.Duration) float64) (trippedAfter time.Duration, tripped bool) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += 50 * time.Millisecond {
+		tr.debt += rate(elapsed)
+		fn := successFn
+		if tr.debt >= 1 {
+			tr.debt--
+			fn = failFn
+		}
+		tr.cb.Execute(fn)
+		if tr.cb.State() == circuitbreaker.Open {
+			return elapsed, true
+		}
+		tr.clock.Advance(50 * time.Millisecond)
+	}
+	return 0, false
+}
+
+func newSpikeTrace(events *[]circuitbreaker.Event) *trace {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	return &trace{
+		clock: clock,
+		cb: circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold: 1000,
+			SpikeMultiplier:  10,
+			SpikeShortWindow: 10 * time.Second,
+			SpikeLongWindow:  5 * time.Minute,
+			SpikeMinRate:     0.02,
+			SpikeMinRequests: 50,
+			Clock:            clock,
+			Strict:           true,
+			OnEvent:          func(ev circuitbreaker.Event) { *events = append(*events, ev) },
+		}),
+	}
+}
+
+func constant(rate float64) func(time.Duration) float64 {
+	return func(time.Duration) float64 { return rate }
+}
+
+func TestSpike_SuddenJumpTrips(t *testing.T) {
+	var events []circuitbreaker.Event
+	tr := newSpikeTrace(&events)
+
+	if _, tripped := tr.run(5*time.Minute, constant(0.001)); tripped {
+		t.Fatal("tripped on the 0.1% baseline")
+	}
+	if ratio := tr.cb.Status().SpikeRatio; ratio == 0 || ratio >= 10 {
+		t.Errorf("expected a non-zero ratio below the multiplier at the baseline, got %f", ratio)
+	}
+
+	after, tripped := tr.run(time.Minute, constant(0.05))
+	if !tripped {
+		t.Fatalf("expected a jump to 5%% to trip, ratio %f", tr.cb.Status().SpikeRatio)
+	}
+	if after > 10*time.Second {
+		t.Errorf("expected to trip within the short window, took %s", after)
+	}
+	last := events[len(events)-1]
+	if last.To != circuitbreaker.Open || last.Reason != circuitbreaker.ReasonSpike {
+		t.Errorf("expected spike-based open event, got %+v", last)
+	}
+}
+
+func TestSpike_GradualDegradationDoesNotTrip(t *testing.T) {
+	var events []circuitbreaker.Event
+	tr := newSpikeTrace(&events)
+
+	tr.run(5*time.Minute, constant(0.001))
+	// Climb from 0.1% to 5% over 20 minutes: bad, but never a spike.
+	degrade := func(elapsed time.Duration) float64 {
+		return 0.001 + 0.049*float64(elapsed)/float64(20*time.Minute)
+	}
+	if after, tripped := tr.run(20*time.Minute, degrade); tripped {
+		t.Fatalf("gradual degradation tripped after %s with ratio %f", after, tr.cb.Status().SpikeRatio)
+	}
+}
+
+func TestSpike_FloorIgnoresTinyVolumes(t *testing.T) {
+	var events []circuitbreaker.Event
+	tr := newSpikeTrace(&events)
+
+	tr.run(5*time.Minute, constant(0))
+	// One failure among a handful of calls is a huge ratio but below the
+	// minimum request count and rate floor.
+	tr.cb.Execute(failFn)
+	if tr.cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected a single failure not to count as a spike, got %v", tr.cb.State())
+	}
+}
@@ -0,0 +1,57 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrServedStale is returned, wrapping the rejection, along with the last
+// successful result when the circuit rejects a call and
+// Config.StaleCacheTTL is set. errors.Is(err, ErrCircuitOpen) or
+// ErrTooManyRequests still holds for it, so callers that only check for a
+// rejection see one.
+var ErrServedStale = errors.New("circuit breaker: served stale result")
+
+// staleResult is the last successful result, kept for Config.StaleCacheTTL.
+type staleResult struct {
+	value any
+	// when the result was stored; zero when there is none.
+	at time.Time
+}
+
+// remember keeps value as the last successful result.
+func (cb *CircuitBreaker) remember(value any) {
+	if cb.config.StaleCacheTTL <= 0 {
+		return
+	}
+	now := cb.clock.Now()
+	cb.mu.Lock()
+	defer cb.unlock()
+	cb.stale = staleResult{value: value, at: now}
+}
+
+// serveStale turns a rejection by the circuit into the last successful
+// result and an ErrServedStale, if one younger than Config.StaleCacheTTL
+// is kept. Any other error, and a rejection with nothing fresh to serve,
+// is returned unchanged. Serving stale counts nothing beyond the
+// rejection itself.
+func (cb *CircuitBreaker) serveStale(err error) (any, error) {
+	ttl := cb.config.StaleCacheTTL
+	if ttl <= 0 || !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrTooManyRequests) {
+		return nil, err
+	}
+	now := cb.clock.Now()
+	cb.mu.RLock()
+	s := cb.stale
+	cb.mu.RUnlock()
+	if s.at.IsZero() || elapsed(s.at, now) >= ttl {
+		return nil, err
+	}
+	return s.value, fmt.Errorf("%w: %w", ErrServedStale, err)
+}
+
+// staleCaching reports whether results are kept for serving stale.
+func (cb *CircuitBreaker) staleCaching() bool {
+	return cb != nil && cb.config.StaleCacheTTL > 0
+}
@@ -0,0 +1,74 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newStaleBreaker(clock *cbt.FakeClock) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Hour,
+		StaleCacheTTL:    time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+}
+
+func TestStaleCache_NothingCachedYet(t *testing.T) {
+	cb := newStaleBreaker(cbt.NewFakeClock(cbt.Epoch))
+	cb.Execute(failFn)
+
+	value, err := cb.Execute(successFn)
+	if value != nil || !errors.Is(err, circuitbreaker.ErrCircuitOpen) || errors.Is(err, circuitbreaker.ErrServedStale) {
+		t.Fatalf("expected a plain rejection with nothing cached, got %v, %v", value, err)
+	}
+}
+
+func TestStaleCache_ServesLastSuccessUntilExpiry(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := newStaleBreaker(clock)
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected open, got %v", cb.State())
+	}
+
+	counts, totals := cb.Counts(), cb.Totals()
+	clock.Advance(59 * time.Second)
+	value, err := cb.Execute(successFn)
+	if value != "ok" || !errors.Is(err, circuitbreaker.ErrServedStale) || !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the stale result with ErrServedStale, got %v, %v", value, err)
+	}
+	if got := cb.Counts(); counters(got) != counters(counts) {
+		t.Errorf("expected serving stale to leave counts at %+v, got %+v", counts, got)
+	}
+	got := cb.Totals()
+	if got.Successes != totals.Successes || got.Failures != totals.Failures || got.Rejected != totals.Rejected+1 {
+		t.Errorf("expected only the rejection counted, totals went from %+v to %+v", totals, got)
+	}
+
+	if s, err := circuitbreaker.Do(cb, func() (string, error) { return "fresh", nil }); s != "ok" || !errors.Is(err, circuitbreaker.ErrServedStale) {
+		t.Errorf("expected Do to serve the stale result, got %q, %v", s, err)
+	}
+
+	clock.Advance(time.Second)
+	if value, err := cb.Execute(successFn); value != nil || errors.Is(err, circuitbreaker.ErrServedStale) {
+		t.Fatalf("expected an expired result not to be served, got %v, %v", value, err)
+	}
+}
+
+func TestStaleCache_ClearedByReset(t *testing.T) {
+	cb := newStaleBreaker(cbt.NewFakeClock(cbt.Epoch))
+	cb.Execute(successFn)
+	cb.Reset()
+	cb.Execute(failFn)
+
+	if value, err := cb.Execute(successFn); value != nil || errors.Is(err, circuitbreaker.ErrServedStale) {
+		t.Fatalf("expected Reset to empty the cache, got %v, %v", value, err)
+	}
+}
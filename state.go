@@ -12,6 +12,20 @@ const (
 	HalfOpen
 )
 
+// HalfOpenPolicy says how half-open probe successes count towards
+// Config.SuccessThreshold.
+type HalfOpenPolicy int
+
+const (
+	// CumulativeSuccesses closes the circuit once SuccessThreshold probes
+	// have succeeded since it went half-open, whatever failed in between.
+	CumulativeSuccesses HalfOpenPolicy = iota
+	// ConsecutiveSuccesses closes the circuit once SuccessThreshold probes
+	// in a row have succeeded; a failed probe that does not reopen the
+	// circuit starts the count over.
+	ConsecutiveSuccesses
+)
+
 // String returns the string representation of the state.
 func (s State) String() string {
 	switch s {
@@ -0,0 +1,199 @@
+package circuitbreaker
+
+import "time"
+
+// Status is a point-in-time view of a circuit breaker for dashboards,
+// health endpoints and metrics.
+type Status struct {
+	// Name is the breaker's configured name.
+	Name string
+	// State is the current state.
+	State State
+	// Mode says whether the state was forced by hand.
+	Mode Mode
+	// Counts holds the current request counters.
+	Counts Counts
+	// FailureWeight is the accumulated weight of the consecutive failures,
+	// which trips the circuit at FailureThreshold; see
+	// Config.FailureWeight. Without a FailureWeight it is their number.
+	FailureWeight float64
+	// Totals holds the running totals of calls.
+	Totals Totals
+	// LastStateChange is when the breaker last changed state, or the zero
+	// time if it never has.
+	LastStateChange time.Time
+	// LastFailure is when the breaker last recorded a failure, or the zero
+	// time if it never has.
+	LastFailure time.Time
+	// OpenTimeout is how long the circuit stays open when it next opens,
+	// or stays open now if it is open, before a probe is let through. It
+	// grows with OpenTimeoutBackoff and is capped by MaxOpenDuration.
+	OpenTimeout time.Duration
+	// OpenRemaining is how long the open circuit has left before it lets a
+	// probe through. It is zero unless the circuit is open, and while it
+	// is forced open or a maintenance window or ejection holds it open.
+	OpenRemaining time.Duration
+	// RampPercent is the share of calls, out of 100, that a ramp in
+	// progress lets through, and zero when none is; see Config.RampUp.
+	RampPercent float64
+	// FailureRate is an exponentially weighted moving average of the
+	// failure rate between 0 and 1. The weight of past calls halves every
+	// Config.FailureRateHalfLife, which makes it a smoother signal to alert
+	// on than the raw counters.
+	FailureRate float64
+	// WindowFailureRates holds the current failure rate of each of the
+	// FailureRateWindows, in configuration order.
+	WindowFailureRates []float64
+	// CallWindowFailureRate is the failure rate over the last
+	// Config.WindowSize calls, and zero when WindowSize is not set.
+	CallWindowFailureRate float64
+	// SlowCallRate is the share of slow calls over the last
+	// Config.SlowCallWindowSize calls, and zero when SlowCallDuration is
+	// not set.
+	SlowCallRate float64
+	// SpikeRatio is the latest ratio of the short-window failure rate to
+	// the baseline rate when spike detection is on, useful for tuning
+	// SpikeMultiplier. Zero when there is not enough history.
+	SpikeRatio float64
+	// SessionFailures is the weighted failure count of the sessions that
+	// ended within the last SessionWindow, compared against
+	// SessionFailureThreshold.
+	SessionFailures float64
+	// Latency is the LatencyPercentile of the recent successful call
+	// latencies when
+	// Config.LatencyThreshold is set, and zero otherwise.
+	Latency time.Duration
+	// SuccessLatency and FailureLatency are the latency distributions of
+	// successful and failed calls; see Latencies.
+	SuccessLatency LatencyHistogram
+	FailureLatency LatencyHistogram
+	// InFlightCost is the total cost of the calls currently running, and
+	// MaxConcurrent the bulkhead limit it is checked against, lowered
+	// while under pressure (0 when
+	// unlimited).
+	InFlightCost  int
+	MaxConcurrent int
+	// InFlight is the number of calls currently running and
+	// OldestInFlight how long the longest-running of them has been.
+	InFlight       int
+	OldestInFlight time.Duration
+	// QueueDepth is the number of callers waiting for admission. Of the
+	// callers that have waited so far, QueueAdmitted were let in after
+	// waiting QueueWait in total and QueueRejected timed out or found the
+	// queue full.
+	QueueDepth    int
+	QueueAdmitted uint64
+	QueueRejected uint64
+	QueueWait     time.Duration
+	// RetryBudget is the number of retry tokens available and
+	// RetriesSkipped the number of retries turned away for lack of one.
+	// Both are zero unless Config.RetryBudgetRatio is set.
+	RetryBudget    float64
+	RetriesSkipped uint64
+	// SharedWaiters is the number of ExecuteShared callers waiting for a
+	// shared execution to finish.
+	SharedWaiters int
+	// Degraded is the value of Degraded.
+	Degraded bool
+	// Ejected reports whether a Group's outlier detection is holding the
+	// circuit open.
+	Ejected bool
+	// InMaintenance reports whether a maintenance window is holding the
+	// circuit open.
+	InMaintenance bool
+	// QuorumBreaches is the number of instances that reported tripping
+	// within Config.QuorumWindow, as last read from Config.QuorumStore.
+	QuorumBreaches int
+	// UnderPressure reports whether the latest reading of Config.Pressure
+	// was over a threshold, and Pressure holds that reading.
+	UnderPressure bool
+	Pressure      PressureReading
+	// Findings are the results of the latest Diagnose, whether run on
+	// demand or every Config.DiagnoseInterval.
+	Findings []Finding
+}
+
+// Status returns a consistent snapshot of the breaker's state and counters.
+// A nil breaker reports a Closed status with zero counters.
+func (cb *CircuitBreaker) Status() Status {
+	if cb == nil {
+		return Status{State: Closed}
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	var spikeRatio float64
+	if cb.spike != nil {
+		spikeRatio = cb.spike.ratio
+	}
+	var budget float64
+	var skipped uint64
+	if cb.retryBudget != nil {
+		budget, skipped = cb.retryBudget.tokens, cb.retryBudget.skipped
+	}
+	cb.flights.mu.Lock()
+	sharedWaiters := cb.flights.waiting
+	cb.flights.mu.Unlock()
+	var sessionFailures float64
+	if cb.sessions != nil {
+		sessionFailures = cb.sessions.weight(cb.clock.Now())
+	}
+	var latency time.Duration
+	if cb.latency != nil {
+		latency = cb.latency.current()
+	}
+	var callWindowRate float64
+	if cb.calls != nil {
+		callWindowRate = cb.calls.rate()
+	}
+	var slowCallRate float64
+	if cb.slowCalls != nil {
+		slowCallRate = cb.slowCalls.rate()
+	}
+	openTimeout, _ := cb.openTimeout()
+	var windowRates []float64
+	if cb.rate != nil {
+		windowRates = cb.rate.currentRates(cb.clock.Now())
+	}
+	return Status{
+		Name:                  cb.config.Name,
+		State:                 cb.state,
+		Mode:                  cb.mode,
+		Counts:                cb.counts(),
+		FailureWeight:         cb.failureWeight,
+		Totals:                cb.totals(),
+		LastStateChange:       cb.lastStateChange,
+		LastFailure:           cb.lastFailureTime,
+		OpenTimeout:           openTimeout,
+		OpenRemaining:         cb.openRemaining(cb.clock.Now()),
+		RampPercent:           cb.currentRampPercent(cb.clock.Now()),
+		FailureRate:           cb.failureRate.rate(),
+		WindowFailureRates:    windowRates,
+		CallWindowFailureRate: callWindowRate,
+		SlowCallRate:          slowCallRate,
+		SpikeRatio:            spikeRatio,
+		SessionFailures:       sessionFailures,
+		Latency:               latency,
+		SuccessLatency:        cb.latencies.success.clone(),
+		FailureLatency:        cb.latencies.failure.clone(),
+		InFlightCost:          cb.inFlightCost,
+		MaxConcurrent:         cb.bulkheadLimit(),
+		InFlight:              len(cb.running.calls),
+		OldestInFlight:        cb.running.oldest(cb.clock.Now()),
+		QueueDepth:            len(cb.queue),
+		QueueAdmitted:         cb.queueStats.admitted,
+		QueueRejected:         cb.queueStats.rejected,
+		QueueWait:             cb.queueStats.wait,
+		RetryBudget:           budget,
+		RetriesSkipped:        skipped,
+		SharedWaiters:         sharedWaiters,
+		Degraded:              cb.degraded,
+		Ejected:               cb.ejected,
+		InMaintenance:         cb.maintenance,
+		QuorumBreaches:        cb.quorum.breaches,
+		UnderPressure:         cb.pressure.active,
+		Pressure:              cb.pressure.reading,
+		Findings:              append([]Finding(nil), cb.diag.findings...),
+	}
+}
@@ -0,0 +1,184 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// PersistedState is what a Store keeps for a breaker: its Snapshot.
+type PersistedState = Snapshot
+
+// Store persists breakers' state across restarts; see Config.Store.
+// Breakers are identified by Config.Name. Implementations must be safe for
+// concurrent use. MemoryStore keeps states in process and FileStore in a
+// directory; one backed by a shared cache needs only these two methods.
+type Store interface {
+	// Save replaces the state kept for the named breaker.
+	Save(name string, s PersistedState) error
+	// Load returns the state kept for the named breaker, and false if
+	// there is none.
+	Load(name string) (s PersistedState, ok bool, err error)
+}
+
+// errStorePanicked is passed to Config.OnStoreError when the store panics.
+var errStorePanicked = errors.New("circuit breaker: store panicked")
+
+// MemoryStore is a Store that keeps states in memory, mostly useful in
+// tests and for breakers recreated within one process. The zero value is
+// ready to use.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]PersistedState
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(name string, st PersistedState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = map[string]PersistedState{}
+	}
+	s.states[name] = st
+	return nil
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(name string) (PersistedState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[name]
+	return st, ok, nil
+}
+
+// FileStore is a Store that keeps each breaker's state as a JSON file in
+// a directory, named after the breaker. Files are replaced atomically, so
+// a crash mid-save leaves the previous state behind.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that keeps its files in dir, which
+// must exist.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// Save implements Store.
+func (s *FileStore) Save(name string, st PersistedState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, ".circuitbreaker-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path(name))
+}
+
+// Load implements Store.
+func (s *FileStore) Load(name string) (PersistedState, bool, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return PersistedState{}, false, nil
+	}
+	if err != nil {
+		return PersistedState{}, false, err
+	}
+	var st PersistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return PersistedState{}, false, err
+	}
+	return st, true, nil
+}
+
+// path is the file the named breaker's state is kept in.
+func (s *FileStore) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".json")
+}
+
+// persistence orders the saves of a breaker's state, which run after its
+// lock is released and so may race.
+type persistence struct {
+	mu sync.Mutex
+	// seq numbers the states queued for saving, under cb.mu; saved is the
+	// latest one saved, under mu.
+	seq   uint64
+	saved uint64
+}
+
+// loadState restores the state kept in Config.Store, if any. Called once
+// from lazyInit.
+func (cb *CircuitBreaker) loadState() {
+	store := cb.config.Store
+	if store == nil {
+		return
+	}
+	var s PersistedState
+	var found bool
+	var err error
+	if !cb.protect("Store", func() { s, found, err = store.Load(cb.config.Name) }) {
+		err = errStorePanicked
+	}
+	if err == nil && found {
+		err = cb.restore(s)
+	}
+	if err != nil {
+		cb.reportStoreError(err)
+	}
+}
+
+// queueSave queues the current state for saving to Config.Store once
+// cb.mu is released. Must be called with cb.mu held.
+func (cb *CircuitBreaker) queueSave() {
+	store := cb.config.Store
+	if store == nil {
+		return
+	}
+	s := cb.snapshot(cb.clock.Now())
+	cb.persist.seq++
+	seq := cb.persist.seq
+	name := cb.config.Name
+	cb.queueHook("Store", func() {
+		p := &cb.persist
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if seq <= p.saved {
+			// a later state has been saved already.
+			return
+		}
+		p.saved = seq
+		var err error
+		if !cb.protect("Store", func() { err = store.Save(name, s) }) {
+			err = errStorePanicked
+		}
+		if err != nil {
+			cb.reportStoreError(err)
+		}
+	})
+}
+
+// reportStoreError passes err to Config.OnStoreError. It must not be
+// called with cb.mu held.
+func (cb *CircuitBreaker) reportStoreError(err error) {
+	if hook := cb.config.OnStoreError; hook != nil {
+		cb.protect("OnStoreError", func() { hook(err) })
+	}
+}
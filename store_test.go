@@ -0,0 +1,139 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func storedBreaker(store circuitbreaker.Store, clock *cbt.FakeClock, onErr func(error)) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:             "payments",
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          30 * time.Second,
+		Store:            store,
+		OnStoreError:     onErr,
+		Clock:            clock,
+		Strict:           true,
+	})
+}
+
+func TestStore_RestartKeepsOpenTimeout(t *testing.T) {
+	for name, store := range map[string]circuitbreaker.Store{
+		"memory": circuitbreaker.NewMemoryStore(),
+		"file":   circuitbreaker.NewFileStore(t.TempDir()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := cbt.NewFakeClock(cbt.Epoch)
+			cb := storedBreaker(store, clock, func(err error) { t.Errorf("unexpected store error: %v", err) })
+			cb.Execute(failFn)
+			cb.Execute(failFn)
+			clock.Advance(20 * time.Second)
+
+			// the process restarts 20s into the 30s timeout.
+			restarted := storedBreaker(store, clock, func(err error) { t.Errorf("unexpected store error: %v", err) })
+			s := restarted.Status()
+			if s.State != circuitbreaker.Open || s.OpenRemaining != 10*time.Second {
+				t.Fatalf("expected the circuit still open for 10s, got %v for %v", s.State, s.OpenRemaining)
+			}
+			if !s.LastStateChange.Equal(cbt.Epoch) {
+				t.Errorf("expected the persisted trip time, got %v", s.LastStateChange)
+			}
+			clock.Advance(10 * time.Second)
+			restarted.Execute(successFn)
+			if restarted.State() != circuitbreaker.Closed {
+				t.Fatalf("expected a probe after the rest of the timeout to close, got %v", restarted.State())
+			}
+
+			// the close was saved too.
+			if again := storedBreaker(store, clock, nil); again.State() != circuitbreaker.Closed {
+				t.Errorf("expected the latest state restored, got %v", again.State())
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	cbt.RunStoreConformance(t, func() (circuitbreaker.Store, func() circuitbreaker.Store) {
+		store := circuitbreaker.NewMemoryStore()
+		return store, func() circuitbreaker.Store { return store }
+	})
+}
+
+func TestFileStore_Conformance(t *testing.T) {
+	cbt.RunStoreConformance(t, func() (circuitbreaker.Store, func() circuitbreaker.Store) {
+		dir := t.TempDir()
+		return circuitbreaker.NewFileStore(dir), func() circuitbreaker.Store { return circuitbreaker.NewFileStore(dir) }
+	})
+}
+
+func TestStore_NothingSavedStartsClosed(t *testing.T) {
+	cb := storedBreaker(circuitbreaker.NewFileStore(t.TempDir()), cbt.NewFakeClock(cbt.Epoch), func(err error) {
+		t.Errorf("unexpected store error: %v", err)
+	})
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected a breaker with no saved state to start closed, got %v", cb.State())
+	}
+}
+
+// brokenStore fails every Save and Load.
+type brokenStore struct{}
+
+var errStore = errors.New("store unavailable")
+
+func (brokenStore) Save(string, circuitbreaker.PersistedState) error { return errStore }
+func (brokenStore) Load(string) (circuitbreaker.PersistedState, bool, error) {
+	return circuitbreaker.PersistedState{}, false, errStore
+}
+
+func TestStore_ErrorsAreReported(t *testing.T) {
+	var errs []error
+	cb := storedBreaker(brokenStore{}, cbt.NewFakeClock(cbt.Epoch), func(err error) { errs = append(errs, err) })
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	if len(errs) != 2 || errs[0] != errStore || errs[1] != errStore {
+		t.Fatalf("expected the failed load and save reported, got %v", errs)
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Errorf("expected store errors to leave the breaker working, got %v", cb.State())
+	}
+}
+
+func TestFileStore_CorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "payments.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var got error
+	cb := storedBreaker(circuitbreaker.NewFileStore(dir), cbt.NewFakeClock(cbt.Epoch), func(err error) { got = err })
+	if got == nil || cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the bad file reported and the breaker closed, got %v %v", got, cb.State())
+	}
+}
+
+func TestStore_CloneStartsFromItsOwnState(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	store := circuitbreaker.NewMemoryStore()
+	cb := storedBreaker(store, clock, nil)
+	cb.Execute(failFn)
+	cb.Execute(failFn)
+	clone := cb.Clone()
+	if clone.State() != circuitbreaker.Closed {
+		t.Errorf("expected a pristine clone, got %v", clone.State())
+	}
+
+	// the clone saves under the original's Name, over its state.
+	clock.Advance(10 * time.Second)
+	clone.Execute(failFn)
+	clone.Execute(failFn)
+	st, ok, _ := store.Load("payments")
+	if !ok || !st.LastStateChange.Equal(clock.Now()) {
+		t.Errorf("expected the clone's trip saved under the shared Name, got %+v", st)
+	}
+}
@@ -0,0 +1,93 @@
+package circuitbreaker
+
+import "time"
+
+// StuckOpen describes an open episode that has lasted longer than
+// Config.OpenAlertAfter. It is passed to Config.OnStuckOpen.
+type StuckOpen struct {
+	// Name is the breaker's configured name.
+	Name string
+	// Since is when the circuit left Closed, and OpenFor how long ago that
+	// was.
+	Since   time.Time
+	OpenFor time.Duration
+	// Rejections is the number of calls turned away because the circuit
+	// was open, and ProbeFailures the number of times it went back from
+	// HalfOpen to Open, during the episode.
+	Rejections    int
+	ProbeFailures int
+}
+
+// openEpisode tracks the time from a trip until the circuit closes again.
+// Half-open periods that end by reopening the circuit belong to the same
+// episode.
+type openEpisode struct {
+	// since is when the episode began; zero while the circuit is closed.
+	since         time.Time
+	rejections    int
+	probeFailures int
+	// timer fires OnStuckOpen; nil unless Config.OpenAlertAfter is set.
+	timer Timer
+	// seq identifies the episode, so a timer that could not be stopped in
+	// time does not report a later one.
+	seq uint64
+}
+
+// trackEpisode starts, extends or ends the open episode for a transition.
+// Must be called with cb.mu held.
+func (cb *CircuitBreaker) trackEpisode(from, to State) {
+	ep := &cb.episode
+	switch {
+	case to == Closed:
+		if ep.timer != nil {
+			ep.timer.Stop()
+		}
+		*ep = openEpisode{seq: ep.seq}
+	case from == Closed:
+		ep.seq++
+		ep.since = cb.lastStateChange
+		ep.rejections = 0
+		ep.probeFailures = 0
+		if d := cb.config.OpenAlertAfter; d > 0 {
+			seq := ep.seq
+			ep.timer = cb.clock.AfterFunc(d, func() { cb.onStuckOpen(seq) })
+		}
+	case from == HalfOpen && to == Open:
+		ep.probeFailures++
+	}
+}
+
+// rejectedOpen counts a call turned away by the open circuit towards the
+// episode. Like Totals.Rejected, it counts callers that got a rejection
+// error, not admission attempts such as a queued caller's retries. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) rejectedOpen() {
+	if !cb.episode.since.IsZero() {
+		cb.episode.rejections++
+	}
+}
+
+// onStuckOpen runs when an episode reaches OpenAlertAfter. Time spent held
+// open by a maintenance window is planned and not reported.
+func (cb *CircuitBreaker) onStuckOpen(seq uint64) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	ep := &cb.episode
+	if cb.closed || ep.seq != seq || ep.since.IsZero() || cb.maintenance {
+		return
+	}
+	ep.timer = nil
+	now := cb.clock.Now()
+	info := StuckOpen{
+		Name:          cb.config.Name,
+		Since:         ep.since,
+		OpenFor:       elapsed(ep.since, now),
+		Rejections:    ep.rejections,
+		ProbeFailures: ep.probeFailures,
+	}
+	if hook := cb.config.OnStuckOpen; hook != nil {
+		cb.queueHook("OnStuckOpen", func() { hook(info) })
+	}
+	cb.emit(Event{Type: EventStuckOpen, Time: now, From: cb.state, To: cb.state, StuckOpen: &info})
+}
@@ -0,0 +1,194 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newStuckBreaker(clock *cbt.FakeClock, alerts *[]circuitbreaker.StuckOpen, events *[]circuitbreaker.Event) *circuitbreaker.CircuitBreaker {
+	return circuitbreaker.New(circuitbreaker.Config{
+		Name:             "ledger",
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		OpenAlertAfter:   45 * time.Minute,
+		Clock:            clock,
+		Strict:           true,
+		OnStuckOpen:      func(s circuitbreaker.StuckOpen) { *alerts = append(*alerts, s) },
+		OnEvent: func(ev circuitbreaker.Event) {
+			if events != nil && ev.Type == circuitbreaker.EventStuckOpen {
+				*events = append(*events, ev)
+			}
+		},
+	})
+}
+
+func TestStuckOpen_FiresOncePerEpisode(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var alerts []circuitbreaker.StuckOpen
+	var events []circuitbreaker.Event
+	cb := newStuckBreaker(clock, &alerts, &events)
+
+	cb.Execute(failFn)
+	tripped := clock.Now()
+	for i := 0; i < 4; i++ {
+		cb.Execute(successFn) // rejected
+	}
+	// every minute a probe fails and reopens the circuit.
+	for i := 0; i < 10; i++ {
+		clock.Advance(time.Minute)
+		cb.Execute(failFn)
+	}
+	clock.Set(tripped.Add(45*time.Minute - time.Nanosecond))
+	if len(alerts) != 0 {
+		t.Fatalf("alerted before OpenAlertAfter: %+v", alerts)
+	}
+	clock.Advance(time.Nanosecond)
+	if len(alerts) != 1 {
+		t.Fatalf("expected one alert at OpenAlertAfter, got %d", len(alerts))
+	}
+	got := alerts[0]
+	if got.Name != "ledger" || !got.Since.Equal(tripped) || got.OpenFor != 45*time.Minute {
+		t.Errorf("unexpected alert %+v", got)
+	}
+	if got.Rejections != 4 || got.ProbeFailures != 10 {
+		t.Errorf("expected 4 rejections and 10 probe failures, got %+v", got)
+	}
+	if len(events) != 1 || events[0].StuckOpen == nil || *events[0].StuckOpen != got {
+		t.Errorf("expected a matching EventStuckOpen, got %+v", events)
+	}
+
+	for i := 0; i < 60; i++ {
+		clock.Advance(time.Minute)
+		cb.Execute(failFn)
+	}
+	if len(alerts) != 1 {
+		t.Errorf("expected no further alerts in the same episode, got %d", len(alerts))
+	}
+}
+
+func TestStuckOpen_RearmsAfterClosing(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var alerts []circuitbreaker.StuckOpen
+	cb := newStuckBreaker(clock, &alerts, nil)
+
+	cb.Execute(failFn)
+	clock.Advance(time.Hour)
+	clock.Advance(time.Minute)
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.Closed || len(alerts) != 1 {
+		t.Fatalf("expected one alert and a recovery, got %v and %d alerts", cb.State(), len(alerts))
+	}
+
+	cb.Execute(failFn)
+	second := clock.Now()
+	clock.Advance(45 * time.Minute)
+	if len(alerts) != 2 {
+		t.Fatalf("expected the next episode to alert again, got %d alerts", len(alerts))
+	}
+	if got := alerts[1]; !got.Since.Equal(second) || got.Rejections != 0 || got.ProbeFailures != 0 {
+		t.Errorf("expected fresh counts for the new episode, got %+v", got)
+	}
+}
+
+func TestStuckOpen_QuietWhenRecoveredInTime(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var alerts []circuitbreaker.StuckOpen
+	cb := newStuckBreaker(clock, &alerts, nil)
+
+	cb.Execute(failFn)
+	clock.Advance(10 * time.Minute)
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected the probe to close the circuit, got %v", cb.State())
+	}
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected closing to stop the alert timer, got %d pending timers", n)
+	}
+	clock.Advance(2 * time.Hour)
+	if len(alerts) != 0 {
+		t.Errorf("expected no alert for an episode that ended in time, got %+v", alerts)
+	}
+}
+
+func TestStuckOpen_StoppedByClose(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var alerts []circuitbreaker.StuckOpen
+	cb := newStuckBreaker(clock, &alerts, nil)
+
+	cb.Execute(failFn)
+	cb.Close()
+	if n := clock.PendingTimers(); n != 0 {
+		t.Errorf("expected Close to stop the alert timer, got %d pending timers", n)
+	}
+	clock.Advance(time.Hour)
+	if len(alerts) != 0 {
+		t.Errorf("expected a closed breaker not to alert, got %+v", alerts)
+	}
+}
+
+func TestStuckOpen_RecoveryRampBelongsToTheEpisode(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var alerts []circuitbreaker.StuckOpen
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          time.Minute,
+		RampUp:           []circuitbreaker.RampStep{{Percent: 50, Duration: time.Hour}},
+		OpenAlertAfter:   45 * time.Minute,
+		Rand:             cbt.NewScriptedRand(0),
+		Clock:            clock,
+		Strict:           true,
+		OnStuckOpen:      func(s circuitbreaker.StuckOpen) { alerts = append(alerts, s) },
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected the ramp to have started, got %v", cb.State())
+	}
+
+	// the circuit has not closed until the ramp is over.
+	clock.Advance(44 * time.Minute)
+	if len(alerts) != 1 {
+		t.Fatalf("expected a ramp that outlasts OpenAlertAfter to alert once, got %d", len(alerts))
+	}
+}
+
+func TestStuckOpen_CountsRejectedCallersNotAdmissionAttempts(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	var alerts []circuitbreaker.StuckOpen
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Minute,
+		OpenAlertAfter:   45 * time.Minute,
+		MaxQueueWait:     100 * time.Millisecond,
+		MaxQueueDepth:    1,
+		Clock:            clock,
+		Strict:           true,
+		OnStuckOpen:      func(s circuitbreaker.StuckOpen) { alerts = append(alerts, s) },
+	})
+	cb.Execute(failFn)
+	clock.Advance(time.Minute)
+	release := hold(t, cb, 1)
+	defer release()
+
+	// every rejection below also tries, and fails, to admit the waiter.
+	done := enqueue(t, context.Background(), cb)
+	for i := 0; i < 3; i++ {
+		cb.Execute(successFn)
+	}
+	clock.Advance(100 * time.Millisecond)
+	result(t, done)
+	clock.Advance(45 * time.Minute)
+	if len(alerts) != 1 {
+		t.Fatalf("expected one alert, got %d", len(alerts))
+	}
+	if got, want := alerts[0].Rejections, int(cb.Totals().Rejected); got != 4 || got != want {
+		t.Errorf("expected the 4 rejected callers, as in Totals, got %d and %d", got, want)
+	}
+}
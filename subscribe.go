@@ -0,0 +1,68 @@
+package circuitbreaker
+
+// subscriberBuffer is how many undelivered events each subscription holds
+// before newer ones are dropped.
+const subscriberBuffer = 64
+
+// Subscribe returns a channel that receives a copy of every event the
+// breaker emits, the ones delivered to Config.OnEvent as well as
+// EventRejected, EventCallSucceeded and EventCallFailed, and a function
+// that ends the subscription and closes the channel. Each subscriber has
+// its own channel.
+//
+// Delivery never blocks the breaker: each channel buffers 64 events, and
+// events that arrive while the buffer is full are dropped, so a consumer
+// that falls behind misses the newest events rather than stalling
+// Execute. Close closes every subscription channel. Subscribing to a nil
+// or closed breaker returns a closed channel.
+func (cb *CircuitBreaker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	if cb == nil {
+		close(ch)
+		return ch, func() {}
+	}
+	cb.lazyInit()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	cb.nextSubscriber++
+	id := cb.nextSubscriber
+	if cb.subscribers == nil {
+		cb.subscribers = map[uint64]chan Event{}
+	}
+	cb.subscribers[id] = ch
+	return ch, func() {
+		cb.mu.Lock()
+		defer cb.unlock()
+		if ch, ok := cb.subscribers[id]; ok {
+			delete(cb.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// publish hands ev to every subscriber that has room for it. Must be
+// called with cb.mu held, which also keeps channels from being closed
+// under it.
+func (cb *CircuitBreaker) publish(ev Event) {
+	for _, ch := range cb.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// the subscriber is behind; drop rather than block.
+		}
+	}
+}
+
+// closeSubscribers ends every subscription. Must be called with cb.mu
+// held.
+func (cb *CircuitBreaker) closeSubscribers() {
+	for _, ch := range cb.subscribers {
+		close(ch)
+	}
+	cb.subscribers = nil
+}
@@ -0,0 +1,106 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// drain returns the types of the events waiting on ch.
+func drain(ch <-chan circuitbreaker.Event) []circuitbreaker.EventType {
+	var types []circuitbreaker.EventType
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return types
+			}
+			types = append(types, ev.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestSubscribe_CallsRejectionsAndStateChanges(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Name: "users", FailureThreshold: 1, Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	first, unsubscribe := cb.Subscribe()
+	defer unsubscribe()
+	second, unsubscribe2 := cb.Subscribe()
+	defer unsubscribe2()
+
+	cb.Execute(successFn)
+	cb.Execute(failFn)
+	cb.Execute(successFn)
+
+	want := []circuitbreaker.EventType{circuitbreaker.EventCallSucceeded, circuitbreaker.EventCallFailed, circuitbreaker.EventStateChange, circuitbreaker.EventRejected}
+	for i, ch := range []<-chan circuitbreaker.Event{first, second} {
+		var got []circuitbreaker.Event
+		for range want {
+			got = append(got, <-ch)
+		}
+		for j, ev := range got {
+			if ev.Type != want[j] || ev.Name != "users" {
+				t.Errorf("subscriber %d, event %d: expected %v from users, got %v from %q", i, j, want[j], ev.Type, ev.Name)
+			}
+		}
+		if got[1].Err != errSimulated || !errors.Is(got[3].Err, circuitbreaker.ErrCircuitOpen) {
+			t.Errorf("subscriber %d: expected the failure and rejection errors, got %v and %v", i, got[1].Err, got[3].Err)
+		}
+		if got[2].From != circuitbreaker.Closed || got[2].To != circuitbreaker.Open {
+			t.Errorf("subscriber %d: expected Closed to Open, got %v to %v", i, got[2].From, got[2].To)
+		}
+	}
+}
+
+func TestSubscribe_SlowSubscriberDropsNewest(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	ch, unsubscribe := cb.Subscribe()
+	defer unsubscribe()
+
+	// nobody reads ch, yet Execute must not stall.
+	for i := 0; i < 100; i++ {
+		cb.Execute(successFn)
+	}
+	cb.Execute(failFn)
+	types := drain(ch)
+	if len(types) != 64 {
+		t.Fatalf("expected the 64 buffered events, got %d", len(types))
+	}
+	for _, typ := range types {
+		if typ != circuitbreaker.EventCallSucceeded {
+			t.Fatalf("expected the oldest events kept and the newest dropped, got %v", typ)
+		}
+	}
+	cb.Execute(failFn)
+	if types := drain(ch); len(types) != 1 || types[0] != circuitbreaker.EventCallFailed {
+		t.Errorf("expected delivery to resume once there is room, got %v", types)
+	}
+}
+
+func TestSubscribe_UnsubscribeAndClose(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{Clock: cbt.NewFakeClock(cbt.Epoch), Strict: true})
+	gone, unsubscribe := cb.Subscribe()
+	kept, _ := cb.Subscribe()
+	unsubscribe()
+	unsubscribe()
+	cb.Execute(successFn)
+	if _, ok := <-gone; ok {
+		t.Error("expected the unsubscribed channel to be closed without events")
+	}
+	if types := drain(kept); len(types) != 1 {
+		t.Errorf("expected the other subscriber to keep receiving, got %v", types)
+	}
+
+	cb.Close()
+	if _, ok := <-kept; ok {
+		t.Error("expected Close to close the channel")
+	}
+	late, _ := cb.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("expected subscribing to a closed breaker to return a closed channel")
+	}
+	cb.Execute(successFn)
+}
@@ -0,0 +1,31 @@
+package circuitbreaker
+
+import "github.com/teresamychu/circuitbreaker/internal/testhook"
+
+func init() {
+	testhook.SetState = func(b any, s any) {
+		cb := b.(*CircuitBreaker)
+		if cb == nil {
+			return
+		}
+		cb.lazyInit()
+		cb.mu.Lock()
+		defer cb.unlock()
+		cb.setState(s.(State), ReasonForced)
+	}
+	testhook.SetCounts = func(b any, c any) {
+		cb := b.(*CircuitBreaker)
+		if cb == nil {
+			return
+		}
+		counts := c.(Counts)
+		cb.lazyInit()
+		cb.mu.Lock()
+		defer cb.unlock()
+		cb.failures = counts.ConsecutiveFailures
+		cb.failureWeight = float64(counts.ConsecutiveFailures)
+		cb.successes = counts.Successes
+		cb.consecutiveSuccesses = counts.ConsecutiveSuccesses
+		cb.checkInvariants(cb.state)
+	}
+}
@@ -0,0 +1,71 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestTimeoutJitter_RerolledEachOpen(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	rnd := cbt.NewScriptedRand(0, 0.5, 0.75)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		Timeout:          10 * time.Second,
+		TimeoutJitter:    0.2,
+		Rand:             rnd,
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+
+	// draws of 0, 0.5 and 0.75 land at -20%, 0 and +10% of Timeout.
+	for _, want := range []time.Duration{8 * time.Second, 10 * time.Second, 11 * time.Second, 8 * time.Second} {
+		if got := cb.Status().OpenTimeout; got != want {
+			t.Fatalf("expected an open timeout of %v, got %v", want, got)
+		}
+		waitOutOpen(t, cb, clock, want, failFn)
+	}
+	if got := rnd.Draws(); got != 5 {
+		t.Errorf("expected one draw per open, got %d", got)
+	}
+}
+
+func TestTimeoutJitter_StaysWithinBounds(t *testing.T) {
+	for _, r := range []float64{0, 0.25, 0.5, 0.9999} {
+		clock := cbt.NewFakeClock(cbt.Epoch)
+		cb := circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold: 1,
+			Timeout:          10 * time.Second,
+			TimeoutJitter:    0.2,
+			Rand:             cbt.NewScriptedRand(r),
+			Clock:            clock,
+			Strict:           true,
+		})
+		cb.Execute(failFn)
+		if got := cb.Status().OpenTimeout; got < 8*time.Second || got > 12*time.Second {
+			t.Errorf("draw %v: expected an open timeout in [8s, 12s], got %v", r, got)
+		}
+	}
+}
+
+func TestTimeoutJitter_ZeroDoesNotDraw(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	rnd := cbt.NewScriptedRand(0)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: 10 * time.Second, Rand: rnd, Clock: clock, Strict: true})
+	cb.Execute(failFn)
+	waitOutOpen(t, cb, clock, 10*time.Second, failFn)
+	if got := rnd.Draws(); got != 0 {
+		t.Errorf("expected no draws without jitter, got %d", got)
+	}
+}
+
+func TestTimeoutJitter_Validate(t *testing.T) {
+	for _, j := range []float64{-0.1, 1, 1.5} {
+		if err := (circuitbreaker.Config{TimeoutJitter: j}).Validate(); err == nil {
+			t.Errorf("expected TimeoutJitter %v to be rejected", j)
+		}
+	}
+}
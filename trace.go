@@ -0,0 +1,300 @@
+package circuitbreaker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// traceEntry is one line of a recorded trace.
+type traceEntry struct {
+	// Kind is "call", "reject" or "state".
+	Kind string `json:"k"`
+	// Time is in Unix nanoseconds: when a call started or was rejected, or
+	// when the state changed.
+	Time int64 `json:"t"`
+	// Duration of a call in nanoseconds.
+	Duration int64 `json:"d,omitempty"`
+	// Err is the call's error or the rejection, empty on success.
+	Err    string `json:"e,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Reason string `json:"r,omitempty"`
+}
+
+// Recorder writes what a breaker sees, every call outcome, rejection and
+// state change, to an io.Writer as newline-delimited JSON that Replay can
+// read back. Feed it by setting Config.OnCall to Recorder.ObserveCall and
+// Config.OnEvent to Recorder.ObserveEvent.
+//
+// Recording never slows the calls down: entries are handed to a
+// background goroutine through a bounded buffer, and entries that find
+// the buffer full are dropped and counted. Close the Recorder to flush it.
+type Recorder struct {
+	entries chan traceEntry
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	// First write error, reported by Close.
+	err error
+}
+
+// NewRecorder starts a Recorder writing to w that buffers up to buffer
+// entries. A buffer below 1 holds 4096.
+func NewRecorder(w io.Writer, buffer int) *Recorder {
+	if buffer < 1 {
+		buffer = 4096
+	}
+	r := &Recorder{
+		entries: make(chan traceEntry, buffer),
+		done:    make(chan struct{}),
+	}
+	go r.write(w)
+	return r
+}
+
+// write encodes entries until the channel is closed, flushing whenever it
+// catches up.
+func (r *Recorder) write(w io.Writer) {
+	defer close(r.done)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for e := range r.entries {
+		err := enc.Encode(e)
+		if err == nil && len(r.entries) == 0 {
+			err = bw.Flush()
+		}
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	if err := bw.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// record queues e unless the buffer is full or the Recorder is closed.
+func (r *Recorder) record(e traceEntry) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	select {
+	case r.entries <- e:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// ObserveCall records a completed or rejected call.
+func (r *Recorder) ObserveCall(rec CallRecord) {
+	e := traceEntry{Kind: "call", Time: rec.Time.UnixNano(), Duration: int64(rec.Duration)}
+	if rec.Rejected {
+		e.Kind = "reject"
+	}
+	if rec.Err != nil {
+		e.Err = rec.Err.Error()
+	}
+	r.record(e)
+}
+
+// ObserveEvent records state changes; other events are ignored.
+func (r *Recorder) ObserveEvent(ev Event) {
+	if ev.Type != EventStateChange {
+		return
+	}
+	r.record(traceEntry{Kind: "state", Time: ev.Time.UnixNano(), From: ev.From.String(), To: ev.To.String(), Reason: ev.Reason})
+}
+
+// Dropped returns the number of entries lost to a full buffer or to
+// recording after Close.
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Close writes out the buffered entries and stops the Recorder. It
+// returns the first error writing failed with. Later calls return the
+// same error.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.entries)
+	}
+	r.mu.Unlock()
+	<-r.done
+	return r.err
+}
+
+// ReplayReport compares a replayed trace with the recording.
+type ReplayReport struct {
+	// Calls is the number of calls in the trace. Of these the replayed
+	// breaker Admitted some and Rejected the rest, and Failures of the
+	// admitted calls failed.
+	Calls    int
+	Admitted int
+	Rejected int
+	Failures int
+	// Assumed counts admitted calls that the recorded breaker had
+	// rejected, so their outcome is not known. Each is assumed to have
+	// ended like the latest recorded call that did run before it.
+	Assumed int
+	// Trips are the times the replayed circuit opened, and RecordedTrips
+	// the times the recorded one did.
+	Trips         []time.Time
+	RecordedTrips []time.Time
+}
+
+// Replay runs a trace written by a Recorder through a new breaker built
+// from cfg, on a virtual clock that follows the recorded timestamps, to
+// show how different settings would have handled the same traffic. Calls
+// are replayed one at a time in the order they started. cfg's Clock is
+// replaced; its hooks are called as usual.
+func Replay(r io.Reader, cfg Config) (ReplayReport, error) {
+	var calls []traceEntry
+	var report ReplayReport
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var e traceEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return ReplayReport{}, fmt.Errorf("circuitbreaker: trace entry %d: %w", n, err)
+		}
+		switch e.Kind {
+		case "call", "reject":
+			calls = append(calls, e)
+		case "state":
+			if e.To == Open.String() {
+				report.RecordedTrips = append(report.RecordedTrips, time.Unix(0, e.Time))
+			}
+		default:
+			return ReplayReport{}, fmt.Errorf("circuitbreaker: trace entry %d: unknown kind %q", n, e.Kind)
+		}
+	}
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].Time < calls[j].Time })
+
+	clock := &virtualClock{}
+	if len(calls) > 0 {
+		clock.now = time.Unix(0, calls[0].Time)
+	}
+	cfg.Clock = clock
+	onEvent := cfg.OnEvent
+	cfg.OnEvent = func(ev Event) {
+		if ev.Type == EventStateChange && ev.To == Open {
+			report.Trips = append(report.Trips, ev.Time)
+		}
+		if onEvent != nil {
+			onEvent(ev)
+		}
+	}
+	cb := New(cfg)
+	defer cb.Close()
+
+	lastErr := ""
+	for _, e := range calls {
+		start := time.Unix(0, e.Time)
+		clock.set(start)
+		outcome, assumed := e.Err, false
+		if e.Kind == "reject" {
+			outcome, assumed = lastErr, true
+		} else {
+			lastErr = e.Err
+		}
+		ran := false
+		_, err := cb.Execute(func() (any, error) {
+			ran = true
+			clock.set(start.Add(time.Duration(e.Duration)))
+			if outcome != "" {
+				return nil, replayedError(outcome)
+			}
+			return nil, nil
+		})
+		report.Calls++
+		switch {
+		case !ran:
+			report.Rejected++
+		default:
+			report.Admitted++
+			if assumed {
+				report.Assumed++
+			}
+			if err != nil {
+				report.Failures++
+			}
+		}
+	}
+	return report, nil
+}
+
+// replayedError stands in for a recorded error.
+type replayedError string
+
+func (e replayedError) Error() string { return string(e) }
+
+// virtualClock is the Clock Replay drives. It only moves forward, and
+// timers fire inside set once it reaches them. It is not safe for
+// concurrent use, which Replay does not need.
+type virtualClock struct {
+	now    time.Time
+	timers []*virtualTimer
+}
+
+type virtualTimer struct {
+	clock *virtualClock
+	when  time.Time
+	f     func()
+}
+
+func (c *virtualClock) Now() time.Time { return c.now }
+
+func (c *virtualClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &virtualTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// set moves the clock to t, or leaves it if t is in the past, running the
+// timers that fall due on the way in deadline order.
+func (c *virtualClock) set(t time.Time) {
+	for {
+		next := -1
+		for i, timer := range c.timers {
+			if !timer.when.After(t) && (next < 0 || timer.when.Before(c.timers[next].when)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		timer := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		if timer.when.After(c.now) {
+			c.now = timer.when
+		}
+		timer.f()
+	}
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+func (t *virtualTimer) Stop() bool {
+	c := t.clock
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package circuitbreaker_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// recordIncident records a minute of traffic with a three-failure blip
+// at 10s and a sustained outage from 30s to 40s, through a breaker with
+// FailureThreshold 5.
+func recordIncident(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	rec := circuitbreaker.NewRecorder(&buf, 0)
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 5,
+		SuccessThreshold: 1,
+		Timeout:          5 * time.Second,
+		Clock:            clock,
+		Strict:           true,
+		OnCall:           rec.ObserveCall,
+		OnEvent:          rec.ObserveEvent,
+	})
+
+	for i := 0; i < 600; i++ {
+		at := time.Duration(i) * 100 * time.Millisecond
+		clock.Set(cbt.Epoch.Add(at))
+		failing := (at >= 10*time.Second && at < 10300*time.Millisecond) ||
+			(at >= 30*time.Second && at < 40*time.Second)
+		cb.Execute(func() (any, error) {
+			clock.Advance(20 * time.Millisecond)
+			if failing {
+				return nil, errSimulated
+			}
+			return "ok", nil
+		})
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Dropped() != 0 {
+		t.Fatalf("dropped %d entries", rec.Dropped())
+	}
+	return buf.Bytes()
+}
+
+func TestReplay_SameConfigReproducesTheRecording(t *testing.T) {
+	trace := recordIncident(t)
+
+	report, err := circuitbreaker.Replay(bytes.NewReader(trace), circuitbreaker.Config{
+		FailureThreshold: 5,
+		SuccessThreshold: 1,
+		Timeout:          5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Calls != 600 || report.Assumed != 0 {
+		t.Errorf("expected 600 calls and none assumed, got %+v", report)
+	}
+	if len(report.RecordedTrips) == 0 {
+		t.Fatal("expected the recording to include trips")
+	}
+	if fmt.Sprint(report.Trips) != fmt.Sprint(report.RecordedTrips) {
+		t.Errorf("replayed trips %v differ from recorded %v", report.Trips, report.RecordedTrips)
+	}
+}
+
+func TestReplay_DifferentConfigsTripDifferently(t *testing.T) {
+	trace := recordIncident(t)
+	replay := func(threshold int) circuitbreaker.ReplayReport {
+		t.Helper()
+		report, err := circuitbreaker.Replay(bytes.NewReader(trace), circuitbreaker.Config{
+			FailureThreshold: threshold,
+			SuccessThreshold: 1,
+			Timeout:          5 * time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	strict, lenient := replay(2), replay(5)
+	blip := cbt.Epoch.Add(10 * time.Second)
+	outage := cbt.Epoch.Add(30 * time.Second)
+
+	if first := lenient.Trips[0]; first.Before(outage) {
+		t.Errorf("FailureThreshold 5 should ride out the blip, first trip at %v", first.Sub(cbt.Epoch))
+	}
+	if first := strict.Trips[0]; first.Before(blip) || !first.Before(outage) {
+		t.Errorf("FailureThreshold 2 should trip on the blip, first trip at %v", first.Sub(cbt.Epoch))
+	}
+	if strict.Rejected <= lenient.Rejected {
+		t.Errorf("expected the stricter config to reject more: %d vs %d", strict.Rejected, lenient.Rejected)
+	}
+}
+
+func TestReplay_AssumesOutcomeOfCallsItAdmitsAnew(t *testing.T) {
+	trace := recordIncident(t)
+
+	// a breaker that never trips runs every call the recording rejected.
+	report, err := circuitbreaker.Replay(bytes.NewReader(trace), circuitbreaker.Config{FailureThreshold: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rejected != 0 || report.Assumed == 0 || report.Admitted != report.Calls {
+		t.Errorf("expected every call admitted with some outcomes assumed, got %+v", report)
+	}
+}
+
+func TestReplay_RejectsMalformedTraces(t *testing.T) {
+	for _, trace := range []string{`{"k":"call","t":1}` + "\n" + `not json`, `{"k":"bogus","t":1}`} {
+		if _, err := circuitbreaker.Replay(strings.NewReader(trace), circuitbreaker.Config{}); err == nil {
+			t.Errorf("expected an error for %q", trace)
+		}
+	}
+}
+
+// blockingWriter blocks every write until released.
+type blockingWriter struct{ release chan struct{} }
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestRecorder_NeverBlocksTheCaller(t *testing.T) {
+	w := blockingWriter{release: make(chan struct{})}
+	rec := circuitbreaker.NewRecorder(w, 8)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10000; i++ {
+			rec.ObserveCall(circuitbreaker.CallRecord{Time: cbt.Epoch, Err: errSimulated})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording blocked on a stuck writer")
+	}
+	if rec.Dropped() == 0 {
+		t.Error("expected entries beyond the buffer to be dropped")
+	}
+	close(w.release)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rec.ObserveCall(circuitbreaker.CallRecord{})
+	if err := rec.Close(); err != nil {
+		t.Errorf("expected Close to be idempotent, got %v", err)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestRecorder_CloseReportsWriteErrors(t *testing.T) {
+	rec := circuitbreaker.NewRecorder(failingWriter{}, 0)
+	rec.ObserveCall(circuitbreaker.CallRecord{Time: cbt.Epoch})
+	if err := rec.Close(); err != io.ErrClosedPipe {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
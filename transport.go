@@ -0,0 +1,218 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Transport is an http.RoundTripper that sends requests through a
+// CircuitBreaker. Transport errors and, by default, responses with a 5xx
+// status count as failures; the response is still returned to the caller
+// untouched. A rejected request fails with the breaker's error without
+// being sent.
+type Transport struct {
+	// Breaker guards the requests. A nil Breaker lets every request
+	// through.
+	Breaker Guard
+
+	// Hosts, if set, keeps one breaker per destination host instead, so
+	// one bad host does not open the circuit for the others: each request
+	// goes through the group's breaker for its req.URL.Host, and Breaker
+	// is ignored.
+	Hosts *Group
+
+	// Base sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// ProbeSafe, if set, decides which requests may be used as half-open
+	// probes. While the circuit is half-open, requests it reports false for
+	// fail fast with ErrTooManyRequests, so a write is not half-applied
+	// against a backend that may still be failing, while the probe slots go
+	// to requests that pass. Rejected requests do not take a probe slot, so
+	// the half-open probe limit counts eligible requests only. Idempotent
+	// is a ready-made check. Nil lets any request probe; a ProbeSafe that
+	// panics counts as false.
+	ProbeSafe func(*http.Request) bool
+
+	// IsFailure, if set, decides which responses count as failures, such
+	// as 429s as well as 5xx, or only 503s. Nil counts every 5xx status; an
+	// IsFailure that panics counts the response as a failure.
+	IsFailure func(*http.Response) bool
+
+	// ServiceUnavailable, if set, answers a rejected request with a
+	// synthesized 503 Service Unavailable response instead of an error, for
+	// clients that already back off on 503s. Its Retry-After header holds
+	// the OpenError's RetryAfter, rounded up to whole seconds, or one
+	// second for other rejections.
+	ServiceUnavailable bool
+}
+
+// errServerStatus marks a response as a failure for the breaker.
+var errServerStatus = errors.New("circuit breaker: server error status")
+
+// failed reports whether resp counts as a failure.
+func (t *Transport) failed(cb Guard, resp *http.Response) bool {
+	if t.IsFailure == nil {
+		return resp.StatusCode >= http.StatusInternalServerError
+	}
+	failed := true
+	protectFor(cb, "Transport.IsFailure", func() { failed = t.IsFailure(resp) })
+	return failed
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cb := t.Breaker
+	if t.Hosts != nil {
+		cb = t.Hosts.Breaker(req.URL.Host)
+	} else if cb == nil {
+		// lets every request through, as a nil *CircuitBreaker does.
+		cb = (*CircuitBreaker)(nil)
+	}
+	var opts []CallOption
+	if t.ProbeSafe != nil {
+		safe := false
+		protectFor(cb, "Transport.ProbeSafe", func() { safe = t.ProbeSafe(req) })
+		if !safe {
+			opts = append(opts, NoProbe())
+		}
+	}
+	// the response goes through a handoff, as with Config.CallTimeout the
+	// call may be given up on while it is still being sent.
+	h := &handoff[*http.Response]{release: func(resp *http.Response) { resp.Body.Close() }}
+	var sent atomic.Bool
+	send := func(ctx context.Context) (any, error) {
+		sent.Store(true)
+		r, finish := within(ctx, req)
+		resp, err := base.RoundTrip(r)
+		finish(resp, err)
+		if err != nil {
+			return nil, err
+		}
+		failed := t.failed(cb, resp)
+		if !h.give(resp) {
+			// answered after the call timed out; nobody will read it.
+			return nil, context.Cause(ctx)
+		}
+		if failed {
+			return nil, errServerStatus
+		}
+		return nil, nil
+	}
+	var err error
+	if t.Hosts != nil {
+		// through the group, which tracks hosts for outlier detection.
+		_, err = t.Hosts.ExecuteContext(req.Context(), req.URL.Host, send, opts...)
+	} else {
+		_, err = cb.ExecuteContext(req.Context(), send, opts...)
+	}
+	resp := h.take()
+	if errors.Is(err, errServerStatus) {
+		return resp, nil
+	}
+	if err != nil {
+		if resp != nil {
+			// the call timed out after the response arrived.
+			resp.Body.Close()
+		}
+		if !sent.Load() {
+			closeBody(req)
+			if t.ServiceUnavailable && isRejection(err) {
+				return unavailable(req, err), nil
+			}
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// within returns req made under the call's ctx, which Config.CallTimeout
+// cancels, and the function to hand the outcome of sending it to. ctx ends
+// when the call returns, while the response body is read after that, so
+// the request gets a context of its own, which ctx cancels only while the
+// response is awaited and which ends when the body is closed.
+func within(ctx context.Context, req *http.Request) (*http.Request, func(*http.Response, error)) {
+	if ctx.Done() == req.Context().Done() {
+		// nothing but the caller's own context can cancel the call.
+		return req, func(*http.Response, error) {}
+	}
+	reqCtx, cancel := context.WithCancelCause(req.Context())
+	stop := context.AfterFunc(ctx, func() { cancel(context.Cause(ctx)) })
+	return req.WithContext(reqCtx), func(resp *http.Response, err error) {
+		stop()
+		if err != nil {
+			cancel(nil)
+			return
+		}
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	}
+}
+
+// cancelBody ends the context of the request it is the response to when
+// it is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
+
+// unavailable is the 503 response for a request rejected with err.
+func unavailable(req *http.Request, err error) *http.Response {
+	return &http.Response{
+		Status:     "503 " + http.StatusText(http.StatusServiceUnavailable),
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Retry-After": {retryAfterFor(err)}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// closeBody closes a request body that was never handed to the base
+// transport, as RoundTrip must.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+type idempotentKey struct{}
+
+// MarkIdempotent returns a context that marks requests made with it as
+// safe to repeat for Idempotent.
+func MarkIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// Idempotent reports whether req is safe to use as a probe: a GET, HEAD,
+// OPTIONS or TRACE request, or one marked idempotent with an
+// Idempotency-Key or X-Idempotency-Key header (as net/http does for its
+// own retries) or with MarkIdempotent.
+func Idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
@@ -0,0 +1,420 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// halfOpenClient returns a client whose transport uses a half-open
+// breaker that closes after one successful probe.
+func halfOpenClient(t *testing.T) (*http.Client, *circuitbreaker.CircuitBreaker) {
+	t.Helper()
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		SuccessThreshold: 1,
+		Clock:            cbt.NewFakeClock(cbt.Epoch),
+		Strict:           true,
+	})
+	cbt.AdvanceToHalfOpen(cb)
+	return &http.Client{Transport: &circuitbreaker.Transport{
+		Breaker:   cb,
+		ProbeSafe: circuitbreaker.Idempotent,
+	}}, cb
+}
+
+func TestTransport_PostRejectedWhileGetProbes(t *testing.T) {
+	var posts atomic.Int32
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts.Add(1)
+			return
+		}
+		close(probing)
+		<-release
+	}))
+	defer srv.Close()
+	client, cb := halfOpenClient(t)
+
+	got := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		got <- err
+	}()
+	select {
+	case <-probing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the GET probe never reached the server")
+	}
+
+	_, err := client.Post(srv.URL, "text/plain", strings.NewReader("write"))
+	if !errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+		t.Errorf("expected the POST to fail fast with ErrTooManyRequests, got %v", err)
+	}
+	if posts.Load() != 0 {
+		t.Error("the POST reached the server during recovery")
+	}
+
+	close(release)
+	if err := <-got; err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected the probe to close the circuit, got %v", cb.State())
+	}
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("write"))
+	if err != nil {
+		t.Fatalf("expected writes to flow once closed, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransport_MarkedWritesMayProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		prepare func(*http.Request) *http.Request
+		probe   bool
+	}{
+		{"plain POST", func(r *http.Request) *http.Request { return r }, false},
+		{"Idempotency-Key header", func(r *http.Request) *http.Request {
+			r.Header.Set("Idempotency-Key", "order-42")
+			return r
+		}, true},
+		{"marked context", func(r *http.Request) *http.Request {
+			return r.WithContext(circuitbreaker.MarkIdempotent(r.Context()))
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, cb := halfOpenClient(t)
+			req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("write"))
+			resp, err := client.Do(tt.prepare(req))
+			if !tt.probe {
+				if !errors.Is(err, circuitbreaker.ErrTooManyRequests) || cb.State() != circuitbreaker.HalfOpen {
+					t.Errorf("expected rejection in HalfOpen, got %v in %v", err, cb.State())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the request to probe, got %v", err)
+			}
+			resp.Body.Close()
+			if cb.State() != circuitbreaker.Closed {
+				t.Errorf("expected the probe to close the circuit, got %v", cb.State())
+			}
+		})
+	}
+}
+
+func TestTransport_ServerErrorsCountButPassThrough(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "overloaded")
+	}))
+	defer srv.Close()
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, Strict: true})
+	client := &http.Client{Transport: &circuitbreaker.Transport{Breaker: cb}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("expected the 503 response itself, got %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || string(body) != "overloaded" {
+			t.Errorf("response altered: %d %q", resp.StatusCode, body)
+		}
+	}
+	if _, err := client.Get(srv.URL); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen after two 503s, got %v", err)
+	}
+}
+
+func TestTransport_TripRejectAndRecover(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, SuccessThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+	client := &http.Client{Transport: &circuitbreaker.Transport{Breaker: cb}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("expected the 502 response itself, got %v", err)
+		}
+		resp.Body.Close()
+	}
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected two 502s to trip the circuit, got %v", cb.State())
+	}
+
+	// an open circuit fails fast without reaching the server.
+	if _, err := client.Get(srv.URL); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("expected the rejected request never sent, got %d hits", n)
+	}
+
+	healthy.Store(true)
+	clock.Advance(time.Minute)
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the probe to go through, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("response altered: %d %q", resp.StatusCode, body)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the successful probe to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestTransport_IsFailure(t *testing.T) {
+	var status atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+	var panics []circuitbreaker.Panic
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		Strict:           true,
+		OnPanic:          func(p circuitbreaker.Panic) { panics = append(panics, p) },
+	})
+	client := &http.Client{Transport: &circuitbreaker.Transport{
+		Breaker: cb,
+		IsFailure: func(resp *http.Response) bool {
+			if resp.StatusCode == http.StatusTeapot {
+				panic("unexpected status")
+			}
+			return resp.StatusCode == http.StatusTooManyRequests
+		},
+	}}
+	get := func(code int) {
+		t.Helper()
+		status.Store(int32(code))
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("expected the %d response itself, got %v", code, err)
+		}
+		resp.Body.Close()
+	}
+
+	get(http.StatusTooManyRequests)
+	get(http.StatusInternalServerError)
+	if c := cb.Counts(); c.ConsecutiveFailures != 0 {
+		t.Errorf("expected the 500 to count as a success, got %+v", c)
+	}
+	get(http.StatusTooManyRequests)
+	get(http.StatusTeapot)
+	if c := cb.Counts(); c.ConsecutiveFailures != 2 {
+		t.Errorf("expected the 429 and the panicking check to count as failures, got %+v", c)
+	}
+	if len(panics) != 1 || panics[0].Component != "Transport.IsFailure" {
+		t.Errorf("expected the panic reported, got %+v", panics)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	for method, want := range map[string]bool{
+		http.MethodGet: true, http.MethodHead: true, http.MethodOptions: true,
+		http.MethodPost: false, http.MethodPatch: false, http.MethodDelete: false,
+	} {
+		req := httptest.NewRequestWithContext(context.Background(), method, "/", nil)
+		if got := circuitbreaker.Idempotent(req); got != want {
+			t.Errorf("Idempotent(%s) = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestTransport_ServiceUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, Timeout: time.Minute, Clock: clock, Strict: true})
+	client := &http.Client{Transport: &circuitbreaker.Transport{Breaker: cb, ServiceUnavailable: true}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	clock.Advance(20500 * time.Millisecond)
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected a 503 response instead of an error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "40" {
+		t.Errorf("expected 503 with Retry-After 40, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestTransport_AcceptsGuard(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+	b := cbt.NewScriptedBreaker(cbt.Reject, cbt.Admit)
+	client := &http.Client{Transport: &circuitbreaker.Transport{
+		Breaker:            b,
+		ServiceUnavailable: true,
+		IsFailure:          func(*http.Response) bool { panic("boom") },
+	}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || hits.Load() != 0 {
+		t.Fatalf("expected a scripted rejection to answer 503 unsent, got %d after %d hits", resp.StatusCode, hits.Load())
+	}
+	// a panicking IsFailure counts the response as a failure but still
+	// returns it.
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits.Load() != 1 {
+		t.Fatalf("expected the admitted request sent, got %d after %d hits", resp.StatusCode, hits.Load())
+	}
+	if c := b.Counts(); c.ConsecutiveFailures != 1 {
+		t.Errorf("expected the response counted as a failure, got %+v", c)
+	}
+}
+
+func TestTransport_HostsKeysByHost(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer good.Close()
+	hosts := circuitbreaker.NewGroup(circuitbreaker.Config{Name: "hosts", FailureThreshold: 1, Strict: true})
+	defer hosts.Close()
+	client := &http.Client{Transport: &circuitbreaker.Transport{Hosts: hosts}}
+
+	resp, err := client.Get(bad.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := client.Get(bad.URL); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Errorf("expected the failing host's circuit to open, got %v", err)
+	}
+	resp, err = client.Get(good.URL)
+	if err != nil {
+		t.Fatalf("expected the healthy host to be unaffected, got %v", err)
+	}
+	resp.Body.Close()
+	badHost, goodHost := strings.TrimPrefix(bad.URL, "http://"), strings.TrimPrefix(good.URL, "http://")
+	if hosts.Breaker(badHost).State() != circuitbreaker.Open || hosts.Breaker(goodHost).State() != circuitbreaker.Closed {
+		t.Errorf("expected one breaker per host, got keys %v", hosts.Keys())
+	}
+}
+
+// roundTripFunc is an http.RoundTripper made of a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// trackedBody closes its closed channel when it is closed.
+type trackedBody struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (b *trackedBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestTransport_CallTimeout(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 2, CallTimeout: time.Second, Clock: clock, Strict: true})
+	late := &trackedBody{Reader: strings.NewReader("late"), closed: make(chan struct{})}
+	var cause error
+	client := &http.Client{Transport: &circuitbreaker.Transport{Breaker: cb, Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		clock.Advance(time.Second)
+		<-req.Context().Done()
+		cause = context.Cause(req.Context())
+		// the response arrives anyway, after the caller has been told.
+		return &http.Response{StatusCode: http.StatusOK, Body: late, Request: req}, nil
+	})}}
+
+	_, err := client.Get("http://backend/")
+	if !errors.Is(err, circuitbreaker.ErrCallTimeout) {
+		t.Fatalf("expected ErrCallTimeout, got %v", err)
+	}
+	select {
+	case <-late.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the late response's body to be closed")
+	}
+	if !errors.Is(cause, circuitbreaker.ErrCallTimeout) {
+		t.Errorf("expected the request cancelled by the timeout, got %v", cause)
+	}
+	if tot := cb.Totals(); tot.Failures != 1 {
+		t.Errorf("expected the timeout to count as a failure, got %+v", tot)
+	}
+}
+
+func TestTransport_CallTimeoutLeavesBodyReadable(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{CallTimeout: time.Second, Clock: clock, Strict: true})
+	var reqCtx context.Context
+	client := &http.Client{Transport: &circuitbreaker.Transport{Breaker: cb, Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		reqCtx = req.Context()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+	})}}
+
+	resp, err := client.Get("http://backend/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if reqCtx.Err() != nil {
+		t.Fatal("expected the request's context to outlive the call while the body is unread")
+	}
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != "ok" {
+		t.Errorf("expected the body, got %q, %v", body, err)
+	}
+	resp.Body.Close()
+	if reqCtx.Err() == nil {
+		t.Error("expected closing the body to end the request's context")
+	}
+}
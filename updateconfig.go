@@ -0,0 +1,69 @@
+package circuitbreaker
+
+import "slices"
+
+// UpdateConfig applies the thresholds and timeouts of cfg to the running
+// breaker without losing its state or counters: FailureThreshold,
+// SuccessThreshold, ExternalFailureThreshold, MaxHalfOpenRequests,
+// HalfOpenPolicy, HalfOpenMaxFailures, Timeout, OpenTimeoutBackoff,
+// TimeoutJitter and MaxOpenDuration. Every
+// other field is fixed when the breaker is created and keeps its value.
+// cfg is validated and has its defaults filled in first, as for New, and
+// an invalid cfg changes nothing.
+//
+// The new values take effect at the next decision they are part of. A
+// FailureThreshold lowered below the current failure count trips the
+// circuit on the next failure, and a Timeout shortened while the circuit
+// is open shortens the remaining wait, measured from when it opened. A
+// half-open circuit that already has the lowered SuccessThreshold of
+// successful probes closes at once.
+func (cb *CircuitBreaker) UpdateConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cb == nil {
+		return nil
+	}
+	cb.lazyInit()
+	cfg = cfg.withDefaults()
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	c := &cb.config
+	c.FailureThreshold = cfg.FailureThreshold
+	c.SuccessThreshold = cfg.SuccessThreshold
+	c.ExternalFailureThreshold = cfg.ExternalFailureThreshold
+	c.MaxHalfOpenRequests = cfg.MaxHalfOpenRequests
+	c.HalfOpenPolicy = cfg.HalfOpenPolicy
+	c.HalfOpenMaxFailures = cfg.HalfOpenMaxFailures
+	c.Timeout = cfg.Timeout
+	c.OpenTimeoutBackoff = cfg.OpenTimeoutBackoff
+	c.TimeoutJitter = cfg.TimeoutJitter
+	c.MaxOpenDuration = cfg.MaxOpenDuration
+	if cb.state == HalfOpen && cb.successes >= c.SuccessThreshold {
+		cb.setState(Closed, ReasonRecovered)
+	}
+	// callers waiting out an open circuit may now be admitted sooner.
+	cb.signalFreed()
+	return nil
+}
+
+// Config returns a copy of the configuration in use, with its defaults
+// filled in.
+func (cb *CircuitBreaker) Config() Config {
+	if cb == nil {
+		return Config{}
+	}
+	cb.lazyInit()
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	c := cb.config
+	c.IgnoredErrors = slices.Clone(c.IgnoredErrors)
+	c.RampUp = slices.Clone(c.RampUp)
+	c.FailureRateWindows = slices.Clone(c.FailureRateWindows)
+	c.MaintenanceWindows = slices.Clone(c.MaintenanceWindows)
+	c.LatencyBuckets = slices.Clone(c.LatencyBuckets)
+	c.PreRequestChecks = slices.Clone(c.PreRequestChecks)
+	return c
+}
@@ -0,0 +1,118 @@
+package circuitbreaker_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func TestUpdateConfig_KeepsStateAndCounts(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		Name:             "payments",
+		FailureThreshold: 5,
+		SuccessThreshold: 3,
+		Timeout:          time.Minute,
+		Clock:            clock,
+		Strict:           true,
+	})
+	for i := 0; i < 3; i++ {
+		cb.Execute(failFn)
+	}
+
+	// the count already passes the new threshold; the next failure trips.
+	if err := cb.UpdateConfig(circuitbreaker.Config{Name: "ignored", FailureThreshold: 2, SuccessThreshold: 3, Timeout: 10 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if cb.State() != circuitbreaker.Closed || cb.Counts().ConsecutiveFailures != 3 {
+		t.Fatalf("expected the state and counts kept, got %v and %+v", cb.State(), cb.Counts())
+	}
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected the next failure to trip, got %v", cb.State())
+	}
+	if got := cb.Config(); got.Name != "payments" || got.FailureThreshold != 2 || got.Timeout != 10*time.Minute {
+		t.Errorf("expected the new thresholds under the old name, got %+v", got)
+	}
+
+	// shortening the timeout while open shortens the wait since opening.
+	clock.Advance(2 * time.Minute)
+	cb.UpdateConfig(circuitbreaker.Config{FailureThreshold: 2, SuccessThreshold: 3, Timeout: time.Minute})
+	if _, err := cb.Execute(successFn); err != nil || cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected the shorter timeout to have expired, got %v in %v", err, cb.State())
+	}
+
+	// lowering SuccessThreshold to the probes already passed closes.
+	cb.UpdateConfig(circuitbreaker.Config{FailureThreshold: 2, SuccessThreshold: 1, Timeout: time.Minute})
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected enough successful probes to close the circuit, got %v", cb.State())
+	}
+}
+
+func TestUpdateConfig_RejectsInvalid(t *testing.T) {
+	cb := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 4, Strict: true})
+	if err := cb.UpdateConfig(circuitbreaker.Config{FailureThreshold: 1, Timeout: -time.Second}); err == nil {
+		t.Fatal("expected a negative Timeout to be rejected")
+	}
+	if got := cb.Config().FailureThreshold; got != 4 {
+		t.Errorf("expected an invalid config to change nothing, got FailureThreshold %d", got)
+	}
+}
+
+func TestUpdateConfig_ConcurrentWithExecute(t *testing.T) {
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		Timeout:          time.Second,
+		Clock:            clock,
+		Strict:           true,
+	})
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if (w+i)%3 == 0 {
+					cb.Execute(failFn)
+				} else {
+					cb.Execute(successFn)
+				}
+				cb.State()
+			}
+		}()
+	}
+	for i := 0; i < 500; i++ {
+		err := cb.UpdateConfig(circuitbreaker.Config{
+			FailureThreshold: 1 + i%5,
+			SuccessThreshold: 1 + i%3,
+			Timeout:          time.Duration(1+i%4) * time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb.Config()
+		clock.Advance(500 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	// whatever state the hammering left, the breaker still recovers.
+	clock.Advance(time.Minute)
+	for i := 0; i < 5 && cb.State() != circuitbreaker.Closed; i++ {
+		cb.Execute(successFn)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected the breaker to recover, got %v", cb.State())
+	}
+}
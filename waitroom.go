@@ -0,0 +1,172 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// waiter is a caller queued in ExecuteContext.
+type waiter struct {
+	opts     callOptions
+	enqueued time.Time
+	// Receives the admitted call, or the error to reject with, exactly once.
+	ready chan admission
+	// Rejects the waiter once it has waited Config.MaxQueueWait.
+	timer Timer
+}
+
+type admission struct {
+	c   call
+	err error
+}
+
+// queueStats are the cumulative waiting-room counters reported in Status.
+type queueStats struct {
+	admitted uint64
+	rejected uint64
+	wait     time.Duration
+}
+
+// admitWait admits a request like admit but, when the waiting room is
+// enabled and the circuit is about to admit again, queues the caller for up
+// to Config.MaxQueueWait instead of rejecting it.
+func (cb *CircuitBreaker) admitWait(ctx context.Context, o callOptions) (call, error) {
+	checkErr := cb.runChecks()
+	cb.mu.Lock()
+	cb.diag.requests++
+	if checkErr != nil {
+		cb.unlock()
+		return call{}, checkErr
+	}
+	// callers already waiting go first unless the circuit is closed.
+	if len(cb.queue) == 0 || cb.state == Closed {
+		c, err := cb.tryAdmit(o)
+		if (!errors.Is(err, ErrCircuitOpen) && err != ErrTooManyRequests) || o.noProbe || !cb.canWait() {
+			cb.unlock()
+			return c, err
+		}
+	}
+	if o.noProbe || !cb.canWait() {
+		err := cb.rejection()
+		cb.unlock()
+		return call{}, err
+	}
+	if len(cb.queue) >= cb.config.MaxQueueDepth {
+		cb.queueStats.rejected++
+		err := cb.rejection()
+		cb.unlock()
+		return call{}, err
+	}
+	w := &waiter{opts: o, enqueued: cb.clock.Now(), ready: make(chan admission, 1)}
+	w.timer = cb.clock.AfterFunc(cb.config.MaxQueueWait, func() { cb.expire(w) })
+	cb.queue = append(cb.queue, w)
+	cb.unlock()
+
+	select {
+	case a := <-w.ready:
+		return a.c, a.err
+	case <-ctx.Done():
+		cb.mu.Lock()
+		if cb.dequeue(w) {
+			w.timer.Stop()
+			cb.unlock()
+			return call{}, ctx.Err()
+		}
+		// admitted or rejected at the same moment; give back the slot.
+		if a := <-w.ready; a.err == nil {
+			cb.release(a.c)
+		}
+		cb.unlock()
+		return call{}, ctx.Err()
+	}
+}
+
+// canWait reports whether a rejected caller may join the waiting room: it
+// is enabled and the circuit is either half-open with every probe slot
+// taken, or open but due to half-open within MaxQueueWait. A circuit held
+// open, by ForceOpen, a maintenance window or ejection, is not due to
+// half-open at all. Must be called with cb.mu held.
+func (cb *CircuitBreaker) canWait() bool {
+	if cb.config.MaxQueueWait <= 0 || cb.config.MaxQueueDepth <= 0 || cb.closed {
+		return false
+	}
+	if cb.mode == ForcedOpen || cb.maintenance || cb.ejected {
+		return false
+	}
+	switch cb.state {
+	case HalfOpen:
+		return true
+	case Open:
+		d, ok := cb.untilRetry()
+		return ok && d <= cb.config.MaxQueueWait
+	}
+	return false
+}
+
+// dispatch admits queued callers in order for as long as the breaker lets
+// them in, and arms a timer to try again when an open circuit is due to
+// half-open. With Config.FairProbes, a half-open circuit may let a caller
+// in ahead of one whose tenant has had its share of probes. Must be
+// called with cb.mu held.
+func (cb *CircuitBreaker) dispatch() {
+	for i := 0; i < len(cb.queue); {
+		w := cb.queue[i]
+		c, err := cb.tryAdmit(w.opts)
+		if err != nil && err != ErrClosed {
+			if cb.fair == nil || cb.state != HalfOpen {
+				break
+			}
+			i++
+			continue
+		}
+		cb.queue = append(cb.queue[:i], cb.queue[i+1:]...)
+		w.timer.Stop()
+		if err == nil {
+			cb.queueStats.admitted++
+			cb.queueStats.wait += elapsed(w.enqueued, cb.clock.Now())
+		}
+		w.ready <- admission{c: c, err: err}
+	}
+	if cb.queueTimer != nil {
+		cb.queueTimer.Stop()
+		cb.queueTimer = nil
+	}
+	if len(cb.queue) == 0 || cb.closed {
+		return
+	}
+	// a circuit held open or already due gets no timer, which would fire
+	// at once into another rejection; the waiters are dispatched again
+	// when the state changes, or time out.
+	if d, ok := cb.untilRetry(); ok && d > 0 {
+		cb.queueTimer = cb.clock.AfterFunc(d, cb.onQueueTimer)
+	}
+}
+
+func (cb *CircuitBreaker) onQueueTimer() {
+	cb.mu.Lock()
+	// unlock dispatches.
+	cb.unlock()
+}
+
+// expire rejects a caller that has waited Config.MaxQueueWait.
+func (cb *CircuitBreaker) expire(w *waiter) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	if cb.dequeue(w) {
+		cb.queueStats.rejected++
+		w.ready <- admission{err: cb.rejection()}
+	}
+}
+
+// dequeue removes w from the queue, reporting whether it was still there.
+func (cb *CircuitBreaker) dequeue(w *waiter) bool {
+	for i, q := range cb.queue {
+		if q == w {
+			cb.queue = append(cb.queue[:i], cb.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+func newWaitingRoom(t *testing.T) (*circuitbreaker.CircuitBreaker, *cbt.FakeClock) {
+	t.Helper()
+	clock := cbt.NewFakeClock(cbt.Epoch)
+	cb := circuitbreaker.New(circuitbreaker.Config{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		Timeout:          time.Second,
+		MaxQueueWait:     100 * time.Millisecond,
+		MaxQueueDepth:    2,
+		Clock:            clock,
+		Strict:           true,
+	})
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatal("expected the breaker to trip")
+	}
+	return cb, clock
+}
+
+// enqueue starts an ExecuteContext call in the background and waits until
+// it is queued.
+func enqueue(t *testing.T, ctx context.Context, cb *circuitbreaker.CircuitBreaker) <-chan error {
+	t.Helper()
+	depth := cb.Status().QueueDepth
+	done := make(chan error, 1)
+	go func() {
+		_, err := cb.ExecuteContext(ctx, func(context.Context) (any, error) { return successFn() })
+		done <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for cb.Status().QueueDepth == depth {
+		select {
+		case err := <-done:
+			t.Fatalf("expected the call to wait, it returned %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("call was never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+func result(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued call never finished")
+		return nil
+	}
+}
+
+func TestWaitingRoom_AdmittedAtRecovery(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(950 * time.Millisecond)
+
+	done := enqueue(t, context.Background(), cb)
+	clock.Advance(50 * time.Millisecond)
+	if err := result(t, done); err != nil {
+		t.Fatalf("expected the queued caller to become the probe, got %v", err)
+	}
+	s := cb.Status()
+	if s.State != circuitbreaker.Closed || s.QueueDepth != 0 {
+		t.Errorf("expected closed with an empty queue, got %+v", s)
+	}
+	if s.QueueAdmitted != 1 || s.QueueWait != 50*time.Millisecond {
+		t.Errorf("expected one admission after 50ms, got %d after %s", s.QueueAdmitted, s.QueueWait)
+	}
+}
+
+func TestWaitingRoom_FarFromRecoveryRejectsImmediately(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(500 * time.Millisecond)
+
+	_, err := cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { return successFn() })
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected an immediate rejection, got %v", err)
+	}
+	if s := cb.Status(); s.QueueRejected != 0 {
+		t.Errorf("callers that never queued are not waiting-room rejections, got %d", s.QueueRejected)
+	}
+}
+
+func TestWaitingRoom_HeldOpenRejectsImmediately(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(950 * time.Millisecond)
+	// the circuit would be due to half-open, but is held open instead.
+	cb.ForceOpen()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { return successFn() })
+		done <- err
+	}()
+	if err := result(t, done); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected an immediate rejection, got %v", err)
+	}
+	if s := cb.Status(); s.QueueDepth != 0 || s.QueueRejected != 0 {
+		t.Errorf("expected nobody queued, got %+v", s)
+	}
+}
+
+func TestWaitingRoom_HeldOpenWhileQueuedArmsNoRetry(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(950 * time.Millisecond)
+	done := enqueue(t, context.Background(), cb)
+
+	pending := clock.PendingTimers()
+	cb.ForceOpen()
+	// only the waiter's MaxQueueWait timer is left; a retry timer would
+	// fire into one rejection after another.
+	if got := clock.PendingTimers(); got != pending-1 {
+		t.Fatalf("expected the retry timer stopped, got %d pending timers, had %d", got, pending)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := result(t, done); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected rejection at the wait deadline, got %v", err)
+	}
+	if tot := cb.Totals(); tot.Rejected != 1 {
+		t.Errorf("expected the waiter rejected once, got %+v", tot)
+	}
+}
+
+func TestWaitingRoom_RejectsBeyondCapacity(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(950 * time.Millisecond)
+
+	first := enqueue(t, context.Background(), cb)
+	second := enqueue(t, context.Background(), cb)
+	_, err := cb.ExecuteContext(context.Background(), func(context.Context) (any, error) { return successFn() })
+	if !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected the third caller to be turned away, got %v", err)
+	}
+
+	// only one probe is needed: the first caller gets in, the second waits
+	// for it and follows once the circuit closes.
+	clock.Advance(50 * time.Millisecond)
+	for i, done := range []<-chan error{first, second} {
+		if err := result(t, done); err != nil {
+			t.Errorf("queued caller %d: %v", i, err)
+		}
+	}
+	if s := cb.Status(); s.QueueAdmitted != 2 || s.QueueRejected != 1 {
+		t.Errorf("expected 2 admitted and 1 rejected, got %d and %d", s.QueueAdmitted, s.QueueRejected)
+	}
+}
+
+func TestWaitingRoom_RejectsAfterMaxQueueWait(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(time.Second)
+
+	// the probe takes longer than the waiter is willing to wait.
+	release := hold(t, cb, 1)
+	defer release()
+	done := enqueue(t, context.Background(), cb)
+	clock.Advance(100 * time.Millisecond)
+	// the circuit is half-open by then, so the rejection says so.
+	if err := result(t, done); !errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+		t.Fatalf("expected rejection at the wait deadline, got %v", err)
+	}
+	if s := cb.Status(); s.QueueRejected != 1 || s.QueueDepth != 0 {
+		t.Errorf("expected one rejection and an empty queue, got %+v", s)
+	}
+}
+
+func TestWaitingRoom_WaitsForSaturatedHalfOpen(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(time.Second)
+
+	release := hold(t, cb, 1)
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected the held call to be the half-open probe, got %s", cb.State())
+	}
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrTooManyRequests) {
+		t.Fatalf("expected Execute to reject while the probe runs, got %v", err)
+	}
+	done := enqueue(t, context.Background(), cb)
+	release()
+	if err := result(t, done); err != nil {
+		t.Fatalf("expected the waiter to be admitted once the probe closed the circuit, got %v", err)
+	}
+}
+
+func TestWaitingRoom_ContextCancelLeavesQueue(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(950 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := enqueue(t, ctx, cb)
+	cancel()
+	if err := result(t, done); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if d := cb.Status().QueueDepth; d != 0 {
+		t.Errorf("expected the cancelled caller to leave the queue, depth %d", d)
+	}
+}
+
+func TestWaitingRoom_CloseRejectsWaiters(t *testing.T) {
+	cb, clock := newWaitingRoom(t)
+	clock.Advance(950 * time.Millisecond)
+
+	done := enqueue(t, context.Background(), cb)
+	cb.Close()
+	if err := result(t, done); !errors.Is(err, circuitbreaker.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
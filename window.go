@@ -0,0 +1,112 @@
+package circuitbreaker
+
+import "time"
+
+// windowBuckets is the number of buckets a time window is split into.
+const windowBuckets = 10
+
+// bucketWindow counts call outcomes over a sliding time window made of
+// fixed-width buckets. Buckets that have aged out are cleared lazily
+// whenever the window is touched, so no background goroutine is needed.
+type bucketWindow struct {
+	width   time.Duration
+	buckets []windowBucket
+	// Absolute index (time / width) of the newest bucket.
+	head int64
+}
+
+// windowBucket holds the outcomes recorded during one bucket's interval.
+type windowBucket struct {
+	total    int
+	failures int
+	// Sum of the weights passed to addWeight.
+	weight float64
+}
+
+// newBucketWindow returns a window covering d split into n buckets.
+func newBucketWindow(d time.Duration, n int) *bucketWindow {
+	width := d / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return &bucketWindow{
+		width:   width,
+		buckets: make([]windowBucket, n),
+	}
+}
+
+// rotate clears buckets that have aged out by now and makes the bucket
+// containing now the head. A clock that steps backwards keeps recording
+// into the current head rather than into the past.
+func (w *bucketWindow) rotate(now time.Time) {
+	idx := now.UnixNano() / int64(w.width)
+	if idx <= w.head {
+		return
+	}
+	expired := idx - w.head
+	if expired > int64(len(w.buckets)) {
+		expired = int64(len(w.buckets))
+	}
+	for i := int64(1); i <= expired; i++ {
+		w.buckets[w.pos(w.head+i)] = windowBucket{}
+	}
+	w.head = idx
+}
+
+// pos maps an absolute bucket index to a slot.
+func (w *bucketWindow) pos(idx int64) int {
+	n := int64(len(w.buckets))
+	return int(((idx % n) + n) % n)
+}
+
+// add records one outcome at now.
+func (w *bucketWindow) add(now time.Time, failed bool) {
+	w.rotate(now)
+	b := &w.buckets[w.pos(w.head)]
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+// counts returns the totals over the window as of now. It does not
+// modify the window, so it is safe under a read lock.
+func (w *bucketWindow) counts(now time.Time) (total, failures int) {
+	for i, b := range w.buckets {
+		if w.live(i, now) {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+// live reports whether slot i still falls inside the window at now.
+func (w *bucketWindow) live(i int, now time.Time) bool {
+	n := int64(len(w.buckets))
+	idx := w.head - int64((w.pos(w.head)-i+len(w.buckets))%len(w.buckets))
+	return idx > max(now.UnixNano()/int64(w.width), w.head)-n
+}
+
+// addWeight adds a failure weight at now.
+func (w *bucketWindow) addWeight(now time.Time, weight float64) {
+	w.rotate(now)
+	w.buckets[w.pos(w.head)].weight += weight
+}
+
+// weight returns the total weight over the window as of now, without
+// modifying the window.
+func (w *bucketWindow) weight(now time.Time) float64 {
+	var sum float64
+	for i, b := range w.buckets {
+		if w.live(i, now) {
+			sum += b.weight
+		}
+	}
+	return sum
+}
+
+// reset empties the window.
+func (w *bucketWindow) reset() {
+	clear(w.buckets)
+}
@@ -0,0 +1,68 @@
+package circuitbreaker_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/teresamychu/circuitbreaker"
+	cbt "github.com/teresamychu/circuitbreaker/circuitbreakertest"
+)
+
+// client embeds a breaker by value, which only works if the zero value is usable.
+type client struct {
+	breaker circuitbreaker.CircuitBreaker
+}
+
+func TestZeroValue_FullCycle(t *testing.T) {
+	var c client
+	cb := &c.breaker
+	defaults := circuitbreaker.DefaultConfig()
+
+	// A zero threshold would trip on the first failure; the defaults apply instead.
+	for i := 0; i < defaults.FailureThreshold-1; i++ {
+		cb.Execute(failFn)
+	}
+	if cb.State() != circuitbreaker.Closed {
+		t.Fatalf("expected Closed below the default threshold, got %v", cb.State())
+	}
+
+	cb.Execute(failFn)
+	if cb.State() != circuitbreaker.Open {
+		t.Fatalf("expected Open at the default threshold, got %v", cb.State())
+	}
+	if _, err := cb.Execute(successFn); !errors.Is(err, circuitbreaker.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	cbt.AdvanceToHalfOpen(cb)
+	for i := 0; i < defaults.SuccessThreshold-1; i++ {
+		cb.Execute(successFn)
+	}
+	if cb.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("expected HalfOpen below the default success threshold, got %v", cb.State())
+	}
+	cb.Execute(successFn)
+	if cb.State() != circuitbreaker.Closed {
+		t.Errorf("expected Closed after recovery, got %v", cb.State())
+	}
+}
+
+func TestNew_GeneratesNameWhenEmpty(t *testing.T) {
+	var names []string
+	for i := 0; i < 2; i++ {
+		cb := circuitbreaker.New(circuitbreaker.Config{
+			OnStateChange: func(name string, _, _ circuitbreaker.State) { names = append(names, name) },
+		})
+		cbt.SetState(cb, circuitbreaker.Open)
+	}
+
+	if len(names) != 2 || names[0] == names[1] {
+		t.Fatalf("expected two distinct generated names, got %v", names)
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "breaker-") {
+			t.Errorf("expected generated name with prefix breaker-, got %q", name)
+		}
+	}
+}